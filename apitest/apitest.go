@@ -0,0 +1,75 @@
+// Package apitest 是路由测试共用的工具：以某个用户的身份发请求，检查状态码并解码响应
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Client 把请求直接交给 Handler（通常是注册好路由的 mux.Router），不经过网络
+type Client struct {
+	Handler http.Handler
+}
+
+func New(h http.Handler) *Client {
+	return &Client{Handler: h}
+}
+
+// Do 发送请求体为 body 的请求，body 不为空时 Content-Type 为 application/json；user 为 nil 时不带令牌
+func (c *Client) Do(user *types.User, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.Send(user, req)
+}
+
+// JSON 把 payload 编码成 JSON 作为请求体发送，payload 为 nil 时没有请求体
+func (c *Client) JSON(user *types.User, method, path string, payload any) *httptest.ResponseRecorder {
+	if payload == nil {
+		return c.Do(user, method, path, "")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	return c.Do(user, method, path, string(body))
+}
+
+// Raw 发送指定 Content-Type 的请求体，contentType 为空时不设置，用于测试 CSV、multipart 等非 JSON 请求
+func (c *Client) Raw(user *types.User, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.Send(user, req)
+}
+
+// Send 发送构造好的请求，user 不为空时加上该用户的 JWT
+func (c *Client) Send(user *types.User, req *http.Request) *httptest.ResponseRecorder {
+	if user != nil {
+		token, _ := auth.GenerateJWT([]byte(config.Envs.JWTSecret), user.ID)
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	c.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// Decode 检查状态码并把响应体解码到 v，任何一步失败都立即结束测试
+func Decode(t testing.TB, rr *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if rr.Code != status {
+		t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", status, rr.Code, rr.Body)
+	}
+	if err := json.NewDecoder(rr.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kind 错误的类别，决定了返回给客户端的 HTTP 状态码
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindPayloadTooLarge
+	KindUnsupportedMediaType
+	KindTooManyRequests
+	KindTimeout
+)
+
+// Error 应用层统一使用的错误类型
+// Code 是稳定的、机器可读的错误码（如 "user_not_found"），客户端应该依赖它而不是 Message
+// Err 是底层原因，只用于日志，永远不会返回给客户端
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError 单个字段的校验失败信息
+type FieldError struct {
+	Field   string `json:"field"`   // 字段的 json 路径，如 "items[0].quantity"
+	Rule    string `json:"rule"`    // 未通过的校验规则，如 "min"
+	Param   string `json:"param"`   // 规则参数，如 "6"
+	Message string `json:"message"` // 按 Accept-Language 本地化后的提示
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status 返回该错误对应的 HTTP 状态码
+func (e *Error) Status() int {
+	switch e.Kind {
+	case KindBadRequest, KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WithCause 附加底层错误，便于日志排查
+func (e *Error) WithCause(err error) *Error {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+// WithFields 附加字段级的校验错误
+func (e *Error) WithFields(fields []FieldError) *Error {
+	cp := *e
+	cp.Fields = fields
+	return &cp
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error {
+	return newError(KindBadRequest, code, message)
+}
+
+func Validation(code, message string) *Error {
+	return newError(KindValidation, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return newError(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return newError(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return newError(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return newError(KindConflict, code, message)
+}
+
+func PayloadTooLarge(code, message string) *Error {
+	return newError(KindPayloadTooLarge, code, message)
+}
+
+func UnsupportedMediaType(code, message string) *Error {
+	return newError(KindUnsupportedMediaType, code, message)
+}
+
+func TooManyRequests(code, message string) *Error {
+	return newError(KindTooManyRequests, code, message)
+}
+
+func Timeout(code, message string) *Error {
+	return newError(KindTimeout, code, message)
+}
+
+// Internal 包装一个内部错误，对客户端只显示通用的提示
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal", Message: "internal server error", Err: err}
+}
+
+// From 把任意错误转换为 *Error，未识别的错误一律视为内部错误
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
+
+// IsKind 判断错误链中是否有指定类别的 *Error
+func IsKind(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+// Problem RFC 7807 problem+json 响应体
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Problem 生成对应的 problem+json 结构，不包含底层错误
+func (e *Error) Problem(instance, requestID string) Problem {
+	status := e.Status()
+	return Problem{
+		Type:      "urn:ecom:problem:" + e.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  instance,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	}
+}
@@ -1,57 +1,193 @@
 package api
 
 import (
-	"database/sql"
+	"context"
 	"log"
 	"net/http"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/filestore"
+	"github.com/Albert-tru/ecom/notify"
 	"github.com/Albert-tru/ecom/service/cart"
+	"github.com/Albert-tru/ecom/service/catalog"
+	"github.com/Albert-tru/ecom/service/category"
+	"github.com/Albert-tru/ecom/service/image"
+	"github.com/Albert-tru/ecom/service/inventory"
 	"github.com/Albert-tru/ecom/service/order"
+	"github.com/Albert-tru/ecom/service/pricing"
 	"github.com/Albert-tru/ecom/service/product"
+	"github.com/Albert-tru/ecom/service/review"
+	"github.com/Albert-tru/ecom/service/search"
 	"github.com/Albert-tru/ecom/service/user"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/service/wishlist"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
+// 产品导出接口的路由模板
+const exportRoute = apiBasePath + "/admin/products/export"
+
 type APIServer struct {
-	addr string  //服务器监听地址，如":8080"
-	db   *sql.DB //数据库连接对象
+	addr     string          //服务器监听地址，如":8080"
+	stores   types.Stores    //各个 store 的实现（SQL 或内存）
+	notifier notify.Notifier //心愿单提醒、库存告警和到货通知的发送方式
 }
 
 // 创建服务器实例
-func NewAPIServer(addr string, db *sql.DB) *APIServer {
+func NewAPIServer(addr string, stores types.Stores) *APIServer {
 	return &APIServer{
-		addr: addr,
-		db:   db,
+		addr:     addr,
+		stores:   stores,
+		notifier: notify.FromConfig(config.Envs),
 	}
 }
 
 // 运行服务器
 func (s *APIServer) Run() error {
+	handler, err := s.Handler()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.Envs.ReadHeaderTimeout,
+		IdleTimeout:       config.Envs.IdleTimeout,
+	}
+
+	// 定期检查心愿单中的产品是否到货或降价
+	if interval := config.Envs.WishlistCheckInterval; interval > 0 {
+		go wishlist.Watch(context.Background(), s.stores, s.notifier, interval)
+	}
+	// 定期检查到货订阅（补货接口也会立即检查，这里兜底其它途径的补货，比如取消订单、导入和 ecomctl；默认每分钟一次）
+	if interval := config.Envs.StockNotifyInterval; interval > 0 {
+		go inventory.Watch(context.Background(), s.stores, s.notifier, interval)
+	}
+
+	//	启动服务器前，打印一条日志
+	log.Println("listening on", s.addr)
 
+	// 启动 HTTP 服务，开始监听端口
+	return server.ListenAndServe()
+}
+
+// Handler 创建路由并组装完整的中间件链
+func (s *APIServer) Handler() (http.Handler, error) {
+	router, err := s.routes()
+	if err != nil {
+		return nil, err
+	}
+
+	// 外层中间件链：请求ID -> panic 恢复 -> 安全响应头 -> 跨域
+	// 它们包在路由器外层，预检请求和 404/405 响应也会经过
+	return Chain(router,
+		utils.WithRequestID,
+		Recover,
+		SecurityHeaders(securityConfigFromEnv(config.Envs)),
+		CORS(corsConfigFromEnv(config.Envs)),
+	), nil
+}
+
+// 创建路由器并注册所有接口
+func (s *APIServer) routes() (*mux.Router, error) {
 	// 创建一个新的路由器 （路由器是用来管理“请求路径和处理函数的映射关系”的）
 	router := mux.NewRouter()
+	// 为每个请求开启 server span，并从 traceparent 头中继承上游的链路
+	router.Use(otelmux.Middleware(config.Envs.ServiceName))
+	// 按路由限流，超出时返回 429
+	limiter, err := newRateLimiter(config.Envs)
+	if err != nil {
+		return nil, err
+	}
+	router.Use(limiter.Middleware)
+	// 按路由设置处理超时，同时给请求 context 加上截止时间
+	timeouts, err := parseRouteTimeouts(config.Envs.HandlerTimeout, config.Envs.RouteTimeouts)
+	if err != nil {
+		return nil, err
+	}
+	// 导出边查询边写出，默认不限时也不缓冲，可以通过 ROUTE_TIMEOUTS 单独设置
+	if _, ok := timeouts.Routes[exportRoute]; !ok {
+		timeouts.Routes[exportRoute] = 0
+	}
+	router.Use(timeouts.Timeout)
+	// 未匹配的路由同样返回 problem+json
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, r, apperr.NotFound("route_not_found", "no route matches the request path"))
+	})
 	// 创建带前缀的子路由器
-	subrouter := router.PathPrefix("/api/v1").Subrouter() //只处理以 /api/v1 开头的请求【api版本化】
-
-	userStore := user.NewStore(s.db) //创建用户存储对象，传入数据库连接
+	subrouter := router.PathPrefix(apiBasePath).Subrouter() //只处理以 /api/v1 开头的请求【api版本化】
 
 	// 创建专门处理用户相关接口的 handler，并注册路由
-	userHandler := user.NewHandler(userStore)
+	userHandler := user.NewHandler(s.stores.Users)
 	userHandler.RegisterRoutes(subrouter) //把用户相关的路由注册到子路由器上
 
 	// 创建专门处理产品相关接口的 handler，并注册路由
-	productStore := product.NewStore(s.db)             //创建产品存储对象，传入数据库连接
-	productHandler := product.NewHandler(productStore) //假设你有一个产品服务
-	productHandler.RegisterRoutes(subrouter)           //把产品相关的路由注册到子路由器上
+	productHandler := product.NewHandler(s.stores.Products, s.stores.Categories)
+	productHandler.RegisterRoutes(subrouter) //把产品相关的路由注册到子路由器上
+
+	// 注册产品搜索路由
+	searchHandler := search.NewHandler(s.stores)
+	searchHandler.RegisterRoutes(subrouter)
+
+	// 注册产品图片路由（图片列表、上传、排序和删除）
+	files, err := filestore.Open(config.Envs)
+	if err != nil {
+		return nil, err
+	}
+	imageOpts, err := image.OptionsFromConfig(config.Envs)
+	if err != nil {
+		return nil, err
+	}
+	imageHandler := image.NewHandler(s.stores, files, imageOpts)
+	imageHandler.RegisterRoutes(subrouter)
+	// 本地存储的图片由本服务提供，地址不在 /api/v1 下
+	if local, ok := files.(*filestore.Local); ok {
+		router.PathPrefix(filestore.MediaPath + "/").Handler(http.StripPrefix(filestore.MediaPath, local.Handler()))
+	}
+
+	// 注册产品评价路由（评价列表、发表和修改评价、评价审核）
+	reviewHandler := review.NewHandler(s.stores)
+	reviewHandler.RegisterRoutes(subrouter)
+
+	// 注册产品批量导入导出路由（仅管理员）
+	catalogHandler := catalog.NewHandler(s.stores, catalog.OptionsFromConfig(config.Envs))
+	catalogHandler.RegisterRoutes(subrouter)
 
-	// 注册购物车路由
-	orderStore := order.NewStore(s.db)
-	cartHandler := cart.NewHandler(orderStore, productStore, userStore)
+	// 注册价格计划路由（仅管理员）
+	pricingHandler := pricing.NewHandler(s.stores)
+	pricingHandler.RegisterRoutes(subrouter)
+
+	// 注册产品变体路由（规格、变体和变体管理）
+	variantHandler := variant.NewHandler(s.stores)
+	variantHandler.RegisterRoutes(subrouter)
+
+	// 注册分类路由（分类树、分类下的产品和分类管理）
+	categoryHandler := category.NewHandler(s.stores)
+	categoryHandler.RegisterRoutes(subrouter)
+
+	// 注册购物车路由（结账后检查补货阈值）
+	cartHandler := cart.NewHandler(s.stores, s.notifier)
 	cartHandler.RegisterRoutes(subrouter)
 
-	//	启动服务器前，打印一条日志
-	log.Println("listening on", s.addr)
+	// 注册心愿单路由（心愿单管理、移到购物车和分享链接）
+	wishlistHandler := wishlist.NewHandler(s.stores)
+	wishlistHandler.RegisterRoutes(subrouter)
 
-	// 启动 HTTP 服务，开始监听端口
-	return http.ListenAndServe(s.addr, router)
+	// 注册库存路由（库存调整、流水和补货阈值仅管理员，到货通知需要登录）
+	inventoryHandler := inventory.NewHandler(s.stores, s.notifier)
+	inventoryHandler.RegisterRoutes(subrouter)
+
+	// 注册订单管理路由（仅管理员）
+	orderHandler := order.NewHandler(s.stores)
+	orderHandler.RegisterRoutes(subrouter)
+
+	// OpenAPI 文档和文档页面
+	registerDocsRoutes(subrouter)
+
+	return router, nil
 }
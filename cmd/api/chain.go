@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+// Middleware 标准的 net/http 中间件
+type Middleware func(http.Handler) http.Handler
+
+// Chain 按顺序组合中间件，第一个中间件在最外层
+// Chain(h, a, b, c) 等价于 a(b(c(h)))
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// 记录响应是否已经开始写出，panic 时据此决定还能不能返回错误响应
+type statusRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Recover 捕获处理函数中的 panic：记录带请求ID的堆栈，并返回 500 problem 响应
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// 客户端断开等情况由 net/http 自行处理
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			log.Printf("[%s] panic serving %s %s: %v\n%s",
+				utils.GetRequestID(r.Context()), r.Method, r.URL.Path, v, debug.Stack())
+
+			if !rec.wroteHeader {
+				utils.WriteError(rec, r, apperr.Internal(fmt.Errorf("panic: %v", v)))
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// RouteTimeouts 按 mux 路由模板设置处理超时
+type RouteTimeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+func parseRouteTimeouts(def time.Duration, spec string) (RouteTimeouts, error) {
+	t := RouteTimeouts{Default: def, Routes: map[string]time.Duration{}}
+	for _, entry := range splitList(spec) {
+		route, value, ok := strings.Cut(entry, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil {
+			return t, fmt.Errorf("invalid route timeout %q, expected ROUTE=DURATION", entry)
+		}
+		t.Routes[strings.TrimSpace(route)] = d
+	}
+	return t, nil
+}
+
+func (t RouteTimeouts) forRequest(r *http.Request) time.Duration {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if d, ok := t.Routes[tpl]; ok {
+				return d
+			}
+		}
+	}
+	return t.Default
+}
+
+// Timeout 作为 router.Use 的中间件，按路由给请求 context 设置截止时间
+// 行为与 http.TimeoutHandler 一致：超时后立即返回 503，处理函数之后的写入都会被丢弃；
+// 不同的是超时响应使用 problem+json 格式
+func (t RouteTimeouts) Timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := t.forRequest(r)
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{w: w, h: make(http.Header), code: http.StatusOK}
+		done := make(chan struct{})
+		panicChan := make(chan any, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					// 带上 goroutine 的堆栈，交给外层的 Recover 处理
+					panicChan <- fmt.Sprintf("%v\n%s", p, debug.Stack())
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, vv := range tw.h {
+				dst[k] = vv
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			if ctx.Err() == context.DeadlineExceeded {
+				log.Printf("[%s] %s %s timed out after %v", utils.GetRequestID(r.Context()), r.Method, r.URL.Path, d)
+				utils.WriteError(w, r, apperr.Timeout("request_timeout", "the server did not finish the request in time"))
+			}
+		}
+	})
+}
+
+// 把处理函数的响应先写入缓冲区，超时后的写入返回 http.ErrHandlerTimeout
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	h        http.Header
+	buf      bytes.Buffer
+	mu       sync.Mutex
+	code     int
+	wrote    bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wrote {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wrote {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wrote = true
+	tw.code = code
+}
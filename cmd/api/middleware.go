@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/config"
+)
+
+// CORSConfig 跨域策略
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+func corsConfigFromEnv(cfg config.Config) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   splitList(cfg.CORSAllowedOrigins),
+		AllowedMethods:   splitList(cfg.CORSAllowedMethods),
+		AllowedHeaders:   splitList(cfg.CORSAllowedHeaders),
+		ExposedHeaders:   splitList(cfg.CORSExposedHeaders),
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+}
+
+// 判断 origin 是否在白名单中，支持 "*" 以及 "https://*.example.com" 这种子域名通配
+func (c CORSConfig) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			// 通配符至少要匹配一个字符，且不能跨越 scheme 或端口
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				middle := origin[len(prefix) : len(origin)-len(suffix)]
+				if !strings.ContainsAny(middle, "/:") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// CORS 处理跨域请求和预检请求
+// 需要包在路由器外层：预检的 OPTIONS 请求不会匹配到只声明了 POST/GET 的路由
+func CORS(c CORSConfig) func(http.Handler) http.Handler {
+	allowMethods := strings.Join(c.AllowedMethods, ", ")
+	allowHeaders := strings.Join(c.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(c.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !c.originAllowed(origin) {
+				if preflight {
+					// 不允许的来源：不返回任何 CORS 头，由浏览器拦截
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 允许携带凭证时不能返回 "*"，必须回显具体的 origin
+			if len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*" && !c.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+				if c.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SecurityConfig 安全相关的响应头
+type SecurityConfig struct {
+	HSTSMaxAge            time.Duration
+	FrameOptions          string
+	ContentSecurityPolicy string
+}
+
+func securityConfigFromEnv(cfg config.Config) SecurityConfig {
+	return SecurityConfig{
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		FrameOptions:          cfg.FrameOptions,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+	}
+}
+
+// SecurityHeaders 为所有响应加上安全相关的响应头
+// 返回 HTML 的处理函数可以在写响应前自行覆盖 Content-Security-Policy
+func SecurityHeaders(c SecurityConfig) func(http.Handler) http.Handler {
+	hsts := "max-age=" + strconv.Itoa(int(c.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "no-referrer")
+			if c.FrameOptions != "" {
+				h.Set("X-Frame-Options", c.FrameOptions)
+			}
+			if c.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", c.ContentSecurityPolicy)
+			}
+			if c.HSTSMaxAge > 0 {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://shop.example.com", "https://*.preview.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	nextCalled := false
+	handler := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+	}))
+
+	do := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		nextCalled = false
+		req := httptest.NewRequest(method, "/api/v1/cart/checkout", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("允许的来源预检", func(t *testing.T) {
+		rr := do(http.MethodOptions, "https://shop.example.com", true)
+		if rr.Code != http.StatusNoContent || nextCalled {
+			t.Fatalf("预检请求应直接返回 204, 实际 %d (next 被调用: %v)", rr.Code, nextCalled)
+		}
+		h := rr.Header()
+		if h.Get("Access-Control-Allow-Origin") != "https://shop.example.com" {
+			t.Errorf("Allow-Origin 不正确: %q", h.Get("Access-Control-Allow-Origin"))
+		}
+		if h.Get("Access-Control-Allow-Credentials") != "true" {
+			t.Error("应该允许携带凭证")
+		}
+		if h.Get("Access-Control-Allow-Methods") != "GET, POST" || h.Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("预检响应头不正确: %v", h)
+		}
+	})
+
+	t.Run("通配子域名", func(t *testing.T) {
+		rr := do(http.MethodGet, "https://pr-42.preview.example.com", false)
+		if !nextCalled || rr.Header().Get("Access-Control-Allow-Origin") != "https://pr-42.preview.example.com" {
+			t.Errorf("子域名应该被允许: %v", rr.Header())
+		}
+		if rr.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+			t.Errorf("Expose-Headers 不正确: %q", rr.Header().Get("Access-Control-Expose-Headers"))
+		}
+	})
+
+	t.Run("通配符不能跨越路径或端口", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.com/x.preview.example.com", "https://.preview.example.com", "http://a.preview.example.com"} {
+			if cfg.originAllowed(origin) {
+				t.Errorf("%s 不应该被允许", origin)
+			}
+		}
+	})
+
+	t.Run("不允许的来源", func(t *testing.T) {
+		rr := do(http.MethodOptions, "https://evil.com", true)
+		if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("不允许的来源不应返回 CORS 头: %v", rr.Header())
+		}
+
+		rr = do(http.MethodGet, "https://evil.com", false)
+		if !nextCalled || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("普通请求应该继续处理但不带 CORS 头: %v", rr.Header())
+		}
+	})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders(SecurityConfig{
+		HSTSMaxAge:            time.Hour,
+		FrameOptions:          "DENY",
+		ContentSecurityPolicy: "default-src 'none'",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Content-Security-Policy":   "default-src 'none'",
+	}
+	for k, v := range want {
+		if got := rr.Header().Get(k); got != v {
+			t.Errorf("%s 期望 %q, 实际 %q", k, v, got)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("a"), mw("b"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if strings.Join(order, ",") != "a,b,handler" {
+		t.Errorf("中间件执行顺序不正确: %v", order)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"] = 1 // 向 nil map 写入会 panic
+	}), utils.WithRequestID, Recover)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("期望状态码 500, 实际 %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("期望 problem+json 响应, 实际 %q", ct)
+	}
+	if strings.Contains(rr.Body.String(), "nil map") {
+		t.Errorf("响应中不应包含 panic 细节: %s", rr.Body.String())
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	timeouts, err := parseRouteTimeouts(time.Second, "/slow=20ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(timeouts.Timeout)
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		// 模拟一个尊重 context 截止时间的慢查询
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte("too late"))
+	})
+	router.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("请求 context 应该带有截止时间")
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	h := Recover(router)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rr.Code != http.StatusServiceUnavailable || strings.Contains(rr.Body.String(), "too late") {
+		t.Errorf("超时请求应返回 503 且丢弃处理函数的输出, 实际 %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rr.Code != http.StatusCreated || rr.Body.String() != "ok" {
+		t.Errorf("未超时的请求应原样返回, 实际 %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("处理 goroutine 中的 panic 应由 Recover 转为 500, 实际 %d", rr.Code)
+	}
+}
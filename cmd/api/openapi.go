@@ -0,0 +1,611 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"net/http"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/openapi"
+	"github.com/Albert-tru/ecom/service/search"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+const apiBasePath = "/api/v1"
+
+// 所有在 /api/v1 下注册的接口，新增路由时必须同步添加，否则 TestOpenAPICoversRoutes 会失败
+var apiOperations = []openapi.Operation{
+	{
+		Method: "POST", Path: "/register", OperationID: "registerUser", Tags: []string{"users"},
+		Summary: "注册用户",
+		Request: types.RegisterUserPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "User created"},
+			{Status: http.StatusConflict, Description: "Email already registered", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/login", OperationID: "login", Tags: []string{"users"},
+		Summary: "用户登录，返回 JWT",
+		Request: types.LoginrUserPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Login successful", Body: types.LoginResponse{}},
+			{Status: http.StatusUnauthorized, Description: "Invalid credentials", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/products", OperationID: "listProducts", Tags: []string{"products"},
+		Summary: "获取产品列表",
+		Query: []openapi.Parameter{
+			{Name: "category", Description: "分类 slug，只返回该分类及其子分类下的产品", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "sort", Description: "rating 按评分从高到低排列", Schema: &openapi.Schema{Type: "string", Enum: []any{"rating"}}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Product list", Body: []types.Product{}},
+			{Status: http.StatusBadRequest, Description: "Unknown sort", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Category not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/products/search", OperationID: "searchProducts", Tags: []string{"products"},
+		Summary: "全文检索产品，按相关度排序，返回高亮片段和分面统计",
+		Query: []openapi.Parameter{
+			{Name: "q", Description: "查询词，不超过 3 个词时容忍拼写错误", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "category", Description: "分类 slug，包含子分类", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "price", Description: "价格区间", Schema: &openapi.Schema{Type: "string", Enum: priceBucketKeys()}},
+			{Name: "inStock", Description: "只返回有货（true）或缺货（false）的产品", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "limit", Description: "每页条数，默认 20，最大 100", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "offset", Description: "跳过的条数", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Search results", Body: types.SearchResult{}},
+			{Status: http.StatusBadRequest, Description: "Missing query or invalid filter", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Category not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/products/{id:[0-9]+}/reviews", OperationID: "listProductReviews", Tags: []string{"products"},
+		Summary: "获取产品已通过审核的评价，最新的在前",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Approved reviews", Body: []types.Review{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/products/{id:[0-9]+}/reviews", OperationID: "createProductReview", Tags: []string{"products"},
+		Summary: "评价产品，只有订单已完成、包含该产品的用户可以评价，每人一条，审核通过后公开",
+		Auth:    true,
+		Request: types.ReviewPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Review created and waiting for moderation", Body: types.Review{}},
+			{Status: http.StatusBadRequest, Description: "Invalid rating, title or body", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "No completed order containing the product", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Already reviewed", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/products/{id:[0-9]+}/reviews/{reviewId:[0-9]+}", OperationID: "updateProductReview", Tags: []string{"products"},
+		Summary: "修改自己的评价，修改后需要重新审核",
+		Auth:    true,
+		Request: types.ReviewPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Review updated and waiting for moderation", Body: types.Review{}},
+			{Status: http.StatusBadRequest, Description: "Invalid rating, title or body", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Review not found or written by another user", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/products/{id:[0-9]+}/variants", OperationID: "listProductVariants", Tags: []string{"products"},
+		Summary: "获取产品的规格类型和所有变体",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Options and variants", Body: types.ProductVariants{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/products/{id:[0-9]+}/images", OperationID: "listProductImages", Tags: []string{"products"},
+		Summary: "获取产品图片，按顺序排列，第一张是主图",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Images with thumbnails", Body: []types.ProductImage{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/products/{id:[0-9]+}/images", OperationID: "uploadProductImages", Tags: []string{"admin"},
+		Summary:     "上传产品图片并生成缩略图（仅管理员），file 部分可以重复，按上传顺序追加",
+		Auth:        true,
+		Request:     imageUploadSchema,
+		RequestType: "multipart/form-data",
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Images uploaded", Body: []types.ProductImage{}},
+			{Status: http.StatusBadRequest, Description: "No file, invalid image or too many pixels", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+			{Status: http.StatusRequestEntityTooLarge, Description: "File too large", Body: apperr.Problem{}},
+			{Status: http.StatusUnsupportedMediaType, Description: "Not multipart/form-data, or not a JPEG, PNG or GIF image", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/products/{id:[0-9]+}/images/order", OperationID: "reorderProductImages", Tags: []string{"admin"},
+		Summary: "调整产品图片的顺序（仅管理员）",
+		Auth:    true,
+		Request: types.ReorderImagesPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Images in the new order", Body: []types.ProductImage{}},
+			{Status: http.StatusBadRequest, Description: "imageIds do not list each image exactly once", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/products/{id:[0-9]+}/images/{imageId:[0-9]+}", OperationID: "deleteProductImage", Tags: []string{"admin"},
+		Summary: "删除产品图片及其缩略图（仅管理员）",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "Image deleted"},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Image not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/products/{id:[0-9]+}/options", OperationID: "setProductOptions", Tags: []string{"admin"},
+		Summary: "设置产品的规格类型（仅管理员）",
+		Auth:    true,
+		Request: types.ProductOptionsPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Options and variants", Body: types.ProductVariants{}},
+			{Status: http.StatusBadRequest, Description: "Duplicate option", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/admin/products/{id:[0-9]+}/variants", OperationID: "createVariant", Tags: []string{"admin"},
+		Summary: "创建产品变体（仅管理员）",
+		Auth:    true,
+		Request: types.CreateVariantPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Variant created", Body: types.ProductVariant{}},
+			{Status: http.StatusBadRequest, Description: "Options do not match the product", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "SKU or options already used", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/variants/{id:[0-9]+}", OperationID: "updateVariant", Tags: []string{"admin"},
+		Summary: "修改产品变体（仅管理员），库存不在这里修改",
+		Auth:    true,
+		Request: types.VariantPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Variant updated", Body: types.ProductVariant{}},
+			{Status: http.StatusBadRequest, Description: "Options do not match the product", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Variant not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "SKU or options already used", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/admin/products/{id:[0-9]+}/stock-adjustments", OperationID: "adjustStock", Tags: []string{"admin"},
+		Summary: "调整产品的库存并记入库存流水（仅管理员），产品只有一个变体时可以不指定变体",
+		Auth:    true,
+		Request: types.StockAdjustmentPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Stock movement recorded", Body: types.StockMovement{}},
+			{Status: http.StatusBadRequest, Description: "Invalid adjustment or variant required", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product or variant not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Insufficient stock", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/admin/products/{id:[0-9]+}/stock-history", OperationID: "getStockHistory", Tags: []string{"admin"},
+		Summary: "产品所有变体的库存流水（仅管理员），最新的在前",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Stock movements", Body: []types.StockMovement{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/admin/products/{id:[0-9]+}/prices", OperationID: "listProductPrices", Tags: []string{"admin"},
+		Summary: "产品的所有价格记录（仅管理员），包括已经结束的，按生效时间排序",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Price schedule", Body: []types.ProductPrice{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/admin/products/{id:[0-9]+}/prices", OperationID: "scheduleProductPrice", Tags: []string{"admin"},
+		Summary: "安排价格或促销（仅管理员）：不指定 effectiveFrom 时立即生效，有 effectiveTo 的价格在时间段内优先于长期价格",
+		Auth:    true,
+		Request: types.ProductPricePayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Price scheduled", Body: types.ProductPrice{}},
+			{Status: http.StatusBadRequest, Description: "Invalid price or time range", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/admin/products/{id:[0-9]+}/prices/{priceId:[0-9]+}", OperationID: "cancelProductPrice", Tags: []string{"admin"},
+		Summary: "取消价格（仅管理员）：未生效的删除，正在生效的立即结束并保留为历史",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "Price cancelled"},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product or price not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Price has already ended", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/products/{id:[0-9]+}/reorder-threshold", OperationID: "setReorderThreshold", Tags: []string{"admin"},
+		Summary: "设置产品的补货阈值（仅管理员），结账后库存降到阈值以下时发出告警；0 表示不告警",
+		Auth:    true,
+		Request: types.ReorderThresholdPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Stock level", Body: types.StockLevel{}},
+			{Status: http.StatusBadRequest, Description: "Invalid threshold", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/products/{id:[0-9]+}/notify-me", OperationID: "subscribeStockNotification", Tags: []string{"products"},
+		Summary: "缺货时订阅到货通知，补货后通知一次；请求体可以省略，表示产品的任意变体",
+		Auth:    true,
+		Request: types.StockSubscriptionPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Subscription created", Body: types.StockSubscription{}},
+			{Status: http.StatusNotFound, Description: "Product or variant not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "In stock or already subscribed", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/categories", OperationID: "getCategoryTree", Tags: []string{"categories"},
+		Summary: "获取分类树",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Category tree", Body: []types.Category{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/categories/{slug}/products", OperationID: "listCategoryProducts", Tags: []string{"categories"},
+		Summary: "获取分类及其子分类下的产品",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Product list", Body: []types.Product{}},
+			{Status: http.StatusNotFound, Description: "Category not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/admin/categories", OperationID: "createCategory", Tags: []string{"admin"},
+		Summary: "创建分类（仅管理员）",
+		Auth:    true,
+		Request: types.CategoryPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Category created", Body: types.Category{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Parent category not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Slug already exists", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/categories/{id:[0-9]+}", OperationID: "updateCategory", Tags: []string{"admin"},
+		Summary: "修改分类（仅管理员）",
+		Auth:    true,
+		Request: types.CategoryPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Category updated", Body: types.Category{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Category not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Slug already exists or the move would create a cycle", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/admin/categories/{id:[0-9]+}", OperationID: "deleteCategory", Tags: []string{"admin"},
+		Summary: "删除分类（仅管理员）",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "Category deleted"},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Category not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Category still has subcategories", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/products/{id:[0-9]+}/categories", OperationID: "setProductCategories", Tags: []string{"admin"},
+		Summary: "设置产品所属的分类（仅管理员）",
+		Auth:    true,
+		Request: types.ProductCategoriesPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Categories of the product", Body: types.ProductCategoriesPayload{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product or category not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/admin/reviews", OperationID: "listReviewsForModeration", Tags: []string{"admin"},
+		Summary: "按审核状态列出评价（仅管理员），最早的在前",
+		Auth:    true,
+		Query: []openapi.Parameter{
+			{Name: "status", Description: "默认 pending", Schema: &openapi.Schema{Type: "string", Enum: []any{"pending", "approved", "rejected"}}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Reviews", Body: []types.Review{}},
+			{Status: http.StatusBadRequest, Description: "Unknown status", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/reviews/{id:[0-9]+}/status", OperationID: "moderateReview", Tags: []string{"admin"},
+		Summary: "通过或拒绝评价（仅管理员），产品的评分随之更新",
+		Auth:    true,
+		Request: types.ReviewStatusPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Review moderated", Body: types.Review{}},
+			{Status: http.StatusBadRequest, Description: "Invalid status", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Review not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/admin/products/import", OperationID: "importProducts", Tags: []string{"admin"},
+		Summary: "按 SKU 批量导入产品（仅管理员），请求体是 CSV 或 JSON Lines 文件，SKU 不存在时创建产品，空单元格保持不变；" +
+			"行数较多或 async=true 时在后台执行并返回 202，通过 Location 查询进度",
+		Auth: true,
+		Query: []openapi.Parameter{
+			{Name: "format", Description: "文件格式，默认根据 Content-Type 判断", Schema: &openapi.Schema{Type: "string", Enum: []any{"csv", "jsonl"}}},
+			{Name: "dryRun", Description: "只校验并报告将要发生的修改，不写入", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "async", Description: "在后台执行", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+		Request:     &openapi.Schema{Type: "string", Description: "CSV 表头为 sku,name,description,price,quantity,imageUrl，只有 sku 列是必需的"},
+		RequestType: "text/csv",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Import result with per-row errors", Body: types.ImportResult{}},
+			{Status: http.StatusAccepted, Description: "Import job queued", Body: types.ImportJob{}},
+			{Status: http.StatusBadRequest, Description: "Invalid header or empty file", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusRequestEntityTooLarge, Description: "File too large", Body: apperr.Problem{}},
+			{Status: http.StatusUnsupportedMediaType, Description: "Unknown format", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/admin/products/import/{id:[0-9a-f]+}", OperationID: "getImportJob", Tags: []string{"admin"},
+		Summary: "查询后台导入任务的进度和结果（仅管理员），任务只保存在处理请求的实例的内存中",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Import job", Body: types.ImportJob{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Import job not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/admin/products/export", OperationID: "exportProducts", Tags: []string{"admin"},
+		Summary: "导出所有产品变体（仅管理员），每个变体一行，导出的文件可以直接导入",
+		Auth:    true,
+		Query: []openapi.Parameter{
+			{Name: "format", Description: "文件格式，默认 csv", Schema: &openapi.Schema{Type: "string", Enum: []any{"csv", "jsonl"}}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "CSV file, or JSON Lines with format=jsonl", Body: "", ContentType: "text/csv"},
+			{Status: http.StatusBadRequest, Description: "Unknown format", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Not an admin", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/cart/checkout", OperationID: "checkout", Tags: []string{"cart"},
+		Summary: "购物车结账，创建订单",
+		Auth:    true,
+		Request: types.CartCheckoutPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Order created", Body: types.CheckoutResponse{}},
+			{Status: http.StatusBadRequest, Description: "Product has several variants and no variant was given", Body: apperr.Problem{}},
+			{Status: http.StatusForbidden, Description: "Account disabled", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Product or variant not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Insufficient stock", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/me/wishlists", OperationID: "listWishlists", Tags: []string{"wishlists"},
+		Summary: "获取当前用户的所有心愿单及其中的产品",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Wishlists", Body: []types.Wishlist{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/me/wishlists", OperationID: "createWishlist", Tags: []string{"wishlists"},
+		Summary: "创建心愿单",
+		Auth:    true,
+		Request: types.WishlistPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Wishlist created", Body: types.Wishlist{}},
+			{Status: http.StatusBadRequest, Description: "Invalid name", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "A wishlist with this name already exists", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/me/wishlists/{id:[0-9]+}", OperationID: "getWishlist", Tags: []string{"wishlists"},
+		Summary: "获取自己的一个心愿单",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Wishlist", Body: types.Wishlist{}},
+			{Status: http.StatusNotFound, Description: "Wishlist not found or owned by another user", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/me/wishlists/{id:[0-9]+}", OperationID: "deleteWishlist", Tags: []string{"wishlists"},
+		Summary: "删除心愿单及其中的产品",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "Wishlist deleted"},
+			{Status: http.StatusNotFound, Description: "Wishlist not found or owned by another user", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/me/wishlists/{id:[0-9]+}/items", OperationID: "addWishlistItem", Tags: []string{"wishlists"},
+		Summary: "把产品或变体加入心愿单",
+		Auth:    true,
+		Request: types.WishlistItemPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusCreated, Description: "Item added", Body: types.WishlistItem{}},
+			{Status: http.StatusNotFound, Description: "Wishlist, product or variant not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Already in the wishlist", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/me/wishlists/{id:[0-9]+}/items/{itemId:[0-9]+}", OperationID: "removeWishlistItem", Tags: []string{"wishlists"},
+		Summary: "从心愿单中移除产品",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "Item removed"},
+			{Status: http.StatusNotFound, Description: "Wishlist or item not found", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/me/wishlists/{id:[0-9]+}/items/{itemId:[0-9]+}/move-to-cart", OperationID: "moveWishlistItemToCart", Tags: []string{"wishlists"},
+		Summary: "检查库存后把产品从心愿单中移除，返回可以放进结账请求的购物车项；请求体可以省略，默认 1 件",
+		Auth:    true,
+		Request: types.MoveToCartPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Cart item", Body: types.CartItem{}},
+			{Status: http.StatusBadRequest, Description: "Product has several variants and no variant was saved", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Wishlist or item not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Insufficient stock", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/me/wishlists/{id:[0-9]+}/share", OperationID: "shareWishlist", Tags: []string{"wishlists"},
+		Summary: "生成新的分享链接 token，之前的链接失效",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Wishlist with its share token", Body: types.Wishlist{}},
+			{Status: http.StatusNotFound, Description: "Wishlist not found or owned by another user", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "DELETE", Path: "/me/wishlists/{id:[0-9]+}/share", OperationID: "unshareWishlist", Tags: []string{"wishlists"},
+		Summary: "关闭分享链接",
+		Auth:    true,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Wishlist without a share token", Body: types.Wishlist{}},
+			{Status: http.StatusNotFound, Description: "Wishlist not found or owned by another user", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/wishlists/shared/{token:[A-Za-z0-9_-]+}", OperationID: "getSharedWishlist", Tags: []string{"wishlists"},
+		Summary: "通过分享链接查看心愿单，不需要登录",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Wishlist", Body: types.Wishlist{}},
+			{Status: http.StatusNotFound, Description: "Share link not found or revoked", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "PUT", Path: "/admin/orders/{id:[0-9]+}/status", OperationID: "updateOrderStatus", Tags: []string{"admin"},
+		Summary: "修改订单状态（仅管理员）",
+		Auth:    true,
+		Request: types.UpdateOrderStatusPayload{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "Order updated", Body: types.Order{}},
+			{Status: http.StatusForbidden, Description: "Not an admin or account disabled", Body: apperr.Problem{}},
+			{Status: http.StatusNotFound, Description: "Order not found", Body: apperr.Problem{}},
+			{Status: http.StatusConflict, Description: "Status transition not allowed", Body: apperr.Problem{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/openapi.json", OperationID: "getOpenAPISpec", Tags: []string{"docs"},
+		Summary: "OpenAPI 3.1 文档",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "OpenAPI document", Body: map[string]any{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/docs", OperationID: "getAPIDocs", Tags: []string{"docs"},
+		Summary: "API 文档页面",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "HTML documentation UI", Body: "", ContentType: "text/html"},
+		},
+	},
+	{
+		Method: "GET", Path: "/docs/{file}", OperationID: "getAPIDocsAsset", Tags: []string{"docs"},
+		Summary: "文档页面使用的 Swagger UI 脚本和样式",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "JavaScript or CSS file", Body: "", ContentType: "text/javascript"},
+			{Status: http.StatusNotFound, Description: "File not found", Body: apperr.Problem{}},
+		},
+	},
+}
+
+// 构建本服务的 OpenAPI 文档
+func apiSpec() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "Ecom API",
+		Version:     "1.0.0",
+		Description: "电商后端 API，所有错误均以 application/problem+json 返回",
+	}, apiBasePath, apperr.Problem{}, apiOperations)
+}
+
+//go:embed docs/index.html
+var docsHTML []byte
+
+// Swagger UI 随服务一起发布（见 docs/swagger-ui.LICENSE），文档页面不依赖外部资源
+//
+//go:embed docs/swagger-ui.css docs/swagger-ui-bundle.js docs/swagger-init.js
+var docsAssets embed.FS
+
+// 文档页面只加载本服务的脚本和样式，Swagger UI 的图标是 data: 图片
+const docsCSP = "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self' data:; " +
+	"connect-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// 注册文档相关的路由
+func registerDocsRoutes(router *mux.Router) {
+	spec := apiSpec()
+
+	router.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteJson(w, http.StatusOK, spec)
+	}).Methods("GET")
+
+	router.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsCSP)
+		w.WriteHeader(http.StatusOK)
+		w.Write(docsHTML)
+	}).Methods("GET")
+
+	started := time.Now()
+	router.HandleFunc("/docs/{file}", func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["file"]
+		data, err := docsAssets.ReadFile("docs/" + name)
+		if err != nil {
+			utils.WriteError(w, r, apperr.NotFound("file_not_found", "file not found"))
+			return
+		}
+		// 内容随版本发布变化，按启动时间做协商缓存
+		http.ServeContent(w, r, name, started, bytes.NewReader(data))
+	}).Methods("GET")
+}
+
+// 上传图片的 multipart 表单
+var imageUploadSchema = &openapi.Schema{
+	Type: "object",
+	Properties: map[string]*openapi.Schema{
+		"file": {Type: "array", Items: &openapi.Schema{Type: "string", Format: "binary"},
+			Description: "JPEG、PNG 或 GIF 图片"},
+	},
+	Required: []string{"file"},
+}
+
+// ?price= 允许的取值，与 search.PriceBuckets 保持一致
+func priceBucketKeys() []any {
+	keys := make([]any, len(search.PriceBuckets))
+	for i, b := range search.PriceBuckets {
+		keys[i] = b.Key
+	}
+	return keys
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Albert-tru/ecom/openapi"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+// 每个注册到路由器上的接口都必须出现在 OpenAPI 文档中，反之亦然
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router, err := NewAPIServer(":0", types.Stores{}).routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := apiSpec()
+
+	registered := map[string]bool{}
+	err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, apiBasePath+"/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("路由 %s 没有声明 HTTP 方法", tpl)
+			return nil
+		}
+
+		path := openapi.PathFromMux(strings.TrimPrefix(tpl, apiBasePath))
+		for _, m := range methods {
+			registered[m+" "+path] = true
+			if !spec.HasOperation(m, path) {
+				t.Errorf("路由 %s %s 没有出现在 OpenAPI 文档中", m, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				t.Errorf("OpenAPI 文档中的 %s %s 没有注册对应的路由", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemasFromValidateTags(t *testing.T) {
+	spec := apiSpec()
+
+	register := spec.Components.Schemas["RegisterUserPayload"]
+	if register == nil {
+		t.Fatal("缺少 RegisterUserPayload 的 schema")
+	}
+	if strings.Join(register.Required, ",") != "firstname,lastname,email,password" {
+		t.Errorf("required 字段不正确: %v", register.Required)
+	}
+	if register.Properties["email"].Format != "email" {
+		t.Error("email 字段应该有 format: email")
+	}
+	if min := register.Properties["password"].MinLength; min == nil || *min != 6 {
+		t.Error("password 字段应该有 minLength: 6")
+	}
+
+	item := spec.Components.Schemas["CartItem"]
+	if min := item.Properties["quantity"].Minimum; min == nil || *min != 1 {
+		t.Error("quantity 字段应该有 minimum: 1")
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	router, err := NewAPIServer(":0", types.Stores{}).routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200, 实际 %d", rr.Code)
+	}
+	var doc map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("期望 openapi 3.1.0, 实际 %v", doc["openapi"])
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("文档页面应返回 HTML, 实际 %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if csp := rr.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self';") || strings.Contains(csp, "https:") {
+		t.Errorf("文档页面的 CSP 只应放行本服务的资源: %s", csp)
+	}
+	if strings.Contains(rr.Body.String(), "https://") {
+		t.Error("文档页面不应该加载外部资源")
+	}
+
+	for path, ct := range map[string]string{
+		"/api/v1/docs/swagger-ui-bundle.js": "text/javascript",
+		"/api/v1/docs/swagger-init.js":      "text/javascript",
+		"/api/v1/docs/swagger-ui.css":       "text/css",
+	} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), ct) {
+			t.Errorf("%s: 期望 200 %s, 实际 %d %s", path, ct, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+	for _, path := range []string{"/api/v1/docs/index.html", "/api/v1/docs/missing.js"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: 期望状态码 404, 实际 %d", path, rr.Code)
+		}
+	}
+}
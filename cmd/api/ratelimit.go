@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/ratelimit"
+	"github.com/Albert-tru/ecom/service/auth"
+)
+
+// 根据配置创建限流器
+// 规则中可用的 key：ip（客户端IP）、user（登录用户ID，匿名时退回IP）、apikey（API key，缺失时退回IP）
+func newRateLimiter(cfg config.Config) (*ratelimit.Limiter, error) {
+	trusted, err := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	byIP := ratelimit.ByIP(trusted)
+	keys := map[string]ratelimit.KeyFunc{
+		"ip":     byIP,
+		"user":   ratelimit.FirstOf(ratelimit.ByUser(auth.UserIDFromRequest), byIP),
+		"apikey": ratelimit.FirstOf(ratelimit.ByAPIKey(cfg.APIKeyHeader), byIP),
+	}
+
+	rules, err := ratelimit.ParseRules(cfg.RateLimits, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultRule *ratelimit.Rule
+	if cfg.RateLimitDefault != "" {
+		rule, err := ratelimit.ParseRule(cfg.RateLimitDefault, keys)
+		if err != nil {
+			return nil, err
+		}
+		defaultRule = &rule
+	}
+
+	return ratelimit.New(ratelimit.NewMemoryStore(), rules, defaultRule), nil
+}
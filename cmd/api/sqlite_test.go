@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/storage"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 在内存 SQLite 上应用迁移，然后走完 注册 -> 登录 -> 商品列表 -> 下单 的完整流程
+func TestSQLiteEndToEnd(t *testing.T) {
+	conn, err := db.NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrations.New(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpAll(); err != nil {
+		t.Fatal(err)
+	}
+	stores := storage.SQL(conn, db.SQLite)
+	keyboard := &types.Product{Name: "keyboard", Description: "mechanical", Price: 49.90, Quantity: 5}
+	if err := stores.Products.CreateProduct(context.Background(), keyboard); err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := NewAPIServer(":0", stores).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, apiBasePath+path, &buf)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/register", "", types.RegisterUserPayload{
+		Firstname: "Ada", Lastname: "Lovelace", Email: "ada@example.com", Password: "secret123",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register: status %d, body %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPost, "/register", "", types.RegisterUserPayload{
+		Firstname: "Ada", Lastname: "Lovelace", Email: "ada@example.com", Password: "secret123",
+	})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate register: status %d, body %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPost, "/login", "", types.LoginrUserPayload{Email: "ada@example.com", Password: "secret123"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rr.Code, rr.Body)
+	}
+	var login types.LoginResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &login); err != nil || login.Token == "" {
+		t.Fatalf("login response: %s", rr.Body)
+	}
+
+	rr = do(http.MethodGet, "/products", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("products: status %d, body %s", rr.Code, rr.Body)
+	}
+	var products []types.Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Price != 49.90 || products[0].CreatedAt.IsZero() {
+		t.Fatalf("unexpected products: %+v", products)
+	}
+
+	rr = do(http.MethodGet, "/products/search?q=mechanicl", "", nil)
+	var found types.SearchResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &found); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("search: status %d, body %s", rr.Code, rr.Body)
+	}
+	if found.Total != 1 || found.Hits[0].Highlights.Description != "<mark>mechanical</mark>" {
+		t.Fatalf("unexpected search result: %+v", found)
+	}
+
+	rr = do(http.MethodGet, "/products/1/images", "", nil)
+	if rr.Code != http.StatusOK || string(bytes.TrimSpace(rr.Body.Bytes())) != "[]" {
+		t.Fatalf("images: status %d, body %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPost, "/cart/checkout", login.Token, types.CartCheckoutPayload{
+		Items: []types.CartItem{{ProductID: products[0].ID, Quantity: 2}},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("checkout: status %d, body %s", rr.Code, rr.Body)
+	}
+	var checkout types.CheckoutResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &checkout); err != nil || checkout.OrderID == 0 {
+		t.Fatalf("checkout response: %s", rr.Body)
+	}
+
+	var items int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM order_items WHERE order_id = ?", checkout.OrderID).Scan(&items); err != nil {
+		t.Fatal(err)
+	}
+	if items != 1 {
+		t.Fatalf("expected 1 order item, got %d", items)
+	}
+
+	// 库存不足时整个结账回滚，不会留下没有订单项的订单
+	rr = do(http.MethodPost, "/cart/checkout", login.Token, types.CartCheckoutPayload{
+		Items: []types.CartItem{{ProductID: products[0].ID, Quantity: 10}},
+	})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("checkout without stock: status %d, body %s", rr.Code, rr.Body)
+	}
+	var orders int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if orders != 1 {
+		t.Fatalf("expected 1 order after failed checkout, got %d", orders)
+	}
+
+	// 有多个变体后必须指定变体，价格使用变体的单独定价
+	price := 59.90
+	xl := &types.ProductVariant{ProductID: keyboard.ID, SKU: "KB-XL", Price: &price, Quantity: 3}
+	if err := stores.Variants.CreateVariant(context.Background(), xl); err != nil {
+		t.Fatal(err)
+	}
+	rr = do(http.MethodPost, "/cart/checkout", login.Token, types.CartCheckoutPayload{
+		Items: []types.CartItem{{ProductID: keyboard.ID, Quantity: 1}},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("checkout without variant: status %d, body %s", rr.Code, rr.Body)
+	}
+	rr = do(http.MethodPost, "/cart/checkout", login.Token, types.CartCheckoutPayload{
+		Items: []types.CartItem{{VariantID: xl.ID, Quantity: 2}},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("checkout with variant: status %d, body %s", rr.Code, rr.Body)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &checkout); err != nil || checkout.TotalPrice != 119.80 {
+		t.Fatalf("checkout response: %s", rr.Body)
+	}
+	var variantID, left int
+	err = conn.QueryRow("SELECT oi.variant_id, v.quantity FROM order_items oi JOIN product_variants v ON v.id = oi.variant_id WHERE oi.order_id = ?",
+		checkout.OrderID).Scan(&variantID, &left)
+	if err != nil || variantID != xl.ID || left != 1 {
+		t.Fatalf("expected variant %d with 1 left, got %d with %d left (%v)", xl.ID, variantID, left, err)
+	}
+
+	// 订单送达后可以评价，审核通过前不计入评分
+	if err := stores.Orders.UpdateOrderStatus(context.Background(), checkout.OrderID, types.OrderDelivered); err != nil {
+		t.Fatal(err)
+	}
+	rr = do(http.MethodPost, "/products/1/reviews", login.Token, types.ReviewPayload{Rating: 5, Title: "great", Body: "clicky"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("review: status %d, body %s", rr.Code, rr.Body)
+	}
+	rr = do(http.MethodGet, "/products?sort=rating", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("products by rating: status %d, body %s", rr.Code, rr.Body)
+	}
+	if products[0].RatingCount != 0 {
+		t.Fatalf("expected the pending review not to count, got %+v", products[0])
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/service/catalog"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/order"
+	"github.com/Albert-tru/ecom/service/wishlist"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+)
+
+// 输出时不包含密码哈希
+type userView struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Firstname string    `json:"firstname"`
+	Lastname  string    `json:"lastname"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	Password  string    `json:"password,omitempty"` // 只在生成随机密码时输出一次
+}
+
+func newUserView(u types.User) userView {
+	return userView{
+		ID: u.ID, Email: u.Email, Firstname: u.Firstname, Lastname: u.Lastname,
+		Role: u.Role, Disabled: u.Disabled, CreatedAt: u.CreatedAt,
+	}
+}
+
+// printUsers 以表格输出 users，或在 -json 时输出 v（单个用户或数组）
+func (a *app) printUsers(v any, users ...userView) error {
+	header := []string{"ID", "EMAIL", "NAME", "ROLE", "DISABLED", "CREATED"}
+	showPassword := false
+	for _, u := range users {
+		showPassword = showPassword || u.Password != ""
+	}
+	if showPassword {
+		header = append(header, "PASSWORD")
+	}
+
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		row := []string{strconv.Itoa(u.ID), u.Email, u.Firstname + " " + u.Lastname, u.Role,
+			strconv.FormatBool(u.Disabled), u.CreatedAt.Local().Format(time.DateTime)}
+		if showPassword {
+			row = append(row, u.Password)
+		}
+		rows = append(rows, row)
+	}
+	return a.print(v, header, rows...)
+}
+
+func usersList(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("users list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	users, err := a.stores.Users.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	views := make([]userView, 0, len(users))
+	for _, u := range users {
+		views = append(views, newUserView(u))
+	}
+	return a.printUsers(views, views...)
+}
+
+func usersCreate(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("users create")
+	email := fs.String("email", "", "email address (required)")
+	first := fs.String("first", "", "first name (required)")
+	last := fs.String("last", "", "last name (required)")
+	password := fs.String("password", "", "password; a random one is generated when empty")
+	admin := fs.Bool("admin", false, "create an admin account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "email", "first", "last"); err != nil {
+		return err
+	}
+
+	plain, generated, err := passwordOrRandom(*password)
+	if err != nil {
+		return err
+	}
+	// 与注册接口使用相同的校验规则
+	if err := utils.Validate.Struct(types.RegisterUserPayload{Firstname: *first, Lastname: *last, Email: *email, Password: plain}); err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(plain)
+	if err != nil {
+		return err
+	}
+
+	u := &types.User{Firstname: *first, Lastname: *last, Email: *email, Password: hashed, Role: types.RoleCustomer}
+	if *admin {
+		u.Role = types.RoleAdmin
+	}
+	if err := a.stores.Users.CreateUser(ctx, u); err != nil {
+		return err
+	}
+
+	created, err := a.stores.Users.GetUserByID(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	view := newUserView(*created)
+	if generated {
+		view.Password = plain
+	}
+	return a.printUsers(view, view)
+}
+
+func usersResetPassword(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("users reset-password")
+	email := fs.String("email", "", "email address (required)")
+	password := fs.String("password", "", "new password; a random one is generated when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+
+	plain, generated, err := passwordOrRandom(*password)
+	if err != nil {
+		return err
+	}
+	if len(plain) < 6 {
+		return fmt.Errorf("password must be at least 6 characters")
+	}
+
+	u, err := a.stores.Users.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(plain)
+	if err != nil {
+		return err
+	}
+	if err := a.stores.Users.UpdatePassword(ctx, u.ID, hashed); err != nil {
+		return err
+	}
+
+	view := newUserView(*u)
+	if generated {
+		view.Password = plain
+	}
+	return a.printUsers(view, view)
+}
+
+func usersSetDisabled(disabled bool) command {
+	name := "users enable"
+	if disabled {
+		name = "users disable"
+	}
+	return func(ctx context.Context, a *app, args []string) error {
+		fs := a.newFlags(name)
+		email := fs.String("email", "", "email address (required)")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if err := required(fs, "email"); err != nil {
+			return err
+		}
+
+		u, err := a.stores.Users.GetUserByEmail(ctx, *email)
+		if err != nil {
+			return err
+		}
+		if err := a.stores.Users.SetDisabled(ctx, u.ID, disabled); err != nil {
+			return err
+		}
+		u.Disabled = disabled
+		view := newUserView(*u)
+		return a.printUsers(view, view)
+	}
+}
+
+func stockAdjust(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("stock adjust")
+	productID := fs.Int("product", 0, "product ID, for products with a single variant")
+	sku := fs.String("sku", "", "SKU of the variant to adjust")
+	delta := fs.Int("delta", 0, "change in stock, negative to remove (required)")
+	reason := fs.String("reason", "", "why the stock is changed, e.g. \"stocktake\" (required)")
+	kind := fs.String("type", types.StockAdjustment, "movement type: restock, return or adjustment")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "delta", "reason"); err != nil {
+		return err
+	}
+	if (*productID == 0) == (*sku == "") {
+		return fmt.Errorf("stock adjust: exactly one of -product and -sku is required")
+	}
+	// 与 API 使用同一套校验规则
+	if err := utils.Validate.Struct(types.StockAdjustmentPayload{Delta: *delta, Type: *kind, Reason: *reason}); err != nil {
+		return err
+	}
+	change := types.StockChange{Type: *kind, Reason: *reason}
+
+	var (
+		quantity int
+		err      error
+	)
+	if *sku != "" {
+		v, err := a.stores.Variants.GetVariantBySKU(ctx, *sku)
+		if err != nil {
+			return err
+		}
+		*productID = v.ProductID
+		quantity, err = a.stores.Variants.AdjustVariantStock(ctx, v.ID, *delta, change)
+		if err != nil {
+			return err
+		}
+	} else {
+		quantity, err = a.stores.Products.AdjustStock(ctx, *productID, *delta, change)
+		if err != nil {
+			return err
+		}
+	}
+
+	result := struct {
+		ProductID int    `json:"productId"`
+		SKU       string `json:"sku,omitempty"`
+		Delta     int    `json:"delta"`
+		Quantity  int    `json:"quantity"`
+		Reason    string `json:"reason"`
+	}{*productID, *sku, *delta, quantity, *reason}
+	return a.print(result, []string{"PRODUCT", "SKU", "DELTA", "QUANTITY", "REASON"},
+		[]string{strconv.Itoa(*productID), *sku, fmt.Sprintf("%+d", *delta), strconv.Itoa(quantity), *reason})
+}
+
+func stockReconcile(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("stock reconcile")
+	fix := fs.Bool("fix", false, "record an adjustment movement for each difference")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	diffs, err := inventory.Reconcile(ctx, a.stores, *fix, 0)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(diffs))
+	for _, d := range diffs {
+		rows = append(rows, []string{strconv.Itoa(d.ProductID), strconv.Itoa(d.VariantID), d.SKU,
+			strconv.Itoa(d.Quantity), strconv.Itoa(d.Ledger), fmt.Sprintf("%+d", d.Quantity-d.Ledger)})
+	}
+	return a.print(diffs, []string{"PRODUCT", "VARIANT", "SKU", "QUANTITY", "LEDGER", "DIFF"}, rows...)
+}
+
+func stockNotify(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("stock notify")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sent, err := inventory.NotifySubscribers(ctx, a.stores, notify.FromConfig(config.Envs))
+	if err != nil {
+		return fmt.Errorf("sent %d notifications before failing: %w", sent, err)
+	}
+	return a.print(map[string]int{"sent": sent}, []string{"SENT"}, []string{strconv.Itoa(sent)})
+}
+
+func ordersSetStatus(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("orders set-status")
+	orderID := fs.Int("order", 0, "order ID (required)")
+	status := fs.String("status", "", "new status: pending, completed, delivered or cancelled (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "order", "status"); err != nil {
+		return err
+	}
+	if err := utils.Validate.Struct(types.UpdateOrderStatusPayload{Status: *status}); err != nil {
+		return err
+	}
+
+	// 与 API 使用同一套状态流转规则
+	o, err := order.ChangeStatus(ctx, a.stores, *orderID, *status, 0)
+	if err != nil {
+		return err
+	}
+	return a.print(o, []string{"ID", "USER", "TOTAL", "STATUS", "ADDRESS", "CREATED"}, []string{
+		strconv.Itoa(o.ID), strconv.Itoa(o.UserID), strconv.FormatFloat(o.Total, 'f', 2, 64),
+		o.Status, o.Address, o.CreatedAt.Local().Format(time.DateTime),
+	})
+}
+
+// wishlistsNotify 检查一次心愿单中的产品，到货或降价时发出通知，适合放在 cron 中定期执行
+func wishlistsNotify(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("wishlists notify")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sent, err := wishlist.Check(ctx, a.stores, notify.FromConfig(config.Envs))
+	if err != nil {
+		return fmt.Errorf("sent %d notifications before failing: %w", sent, err)
+	}
+	return a.print(map[string]int{"sent": sent}, []string{"SENT"}, []string{strconv.Itoa(sent)})
+}
+
+func issueToken(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("token")
+	email := fs.String("email", "", "email of the user the token is issued for (required)")
+	ttl := fs.Duration("ttl", 15*time.Minute, "how long the token is valid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+	if *ttl <= 0 || *ttl > 24*time.Hour {
+		return fmt.Errorf("token: -ttl must be between 0 and 24h")
+	}
+
+	u, err := a.stores.Users.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+	if u.Disabled {
+		return auth.ErrAccountDisabled
+	}
+	token, err := auth.GenerateJWTWithTTL([]byte(config.Envs.JWTSecret), u.ID, *ttl)
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(*ttl).Truncate(time.Second)
+	result := struct {
+		UserID    int       `json:"userId"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}{u.ID, token, expires}
+	return a.print(result, []string{"USER", "EXPIRES", "TOKEN"},
+		[]string{strconv.Itoa(u.ID), expires.Local().Format(time.DateTime), token})
+}
+
+// 没有指定密码时生成一个随机密码
+func passwordOrRandom(password string) (plain string, generated bool, err error) {
+	if password != "" {
+		return password, false, nil
+	}
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
+
+func productsImport(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("products import")
+	file := fs.String("file", "", "CSV or JSON Lines file to import (required)")
+	formatFlag := fs.String("format", "", "csv or jsonl (defaults to the file extension)")
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "file"); err != nil {
+		return err
+	}
+	format, err := fileFormat(*formatFlag, *file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	// 与 API 使用同一套解析、校验和导入逻辑，命令行中直接同步执行
+	batch, err := catalog.Parse(format, data, utils.ValidateStruct)
+	if err != nil {
+		return err
+	}
+	result, err := catalog.Import(ctx, a.stores, batch, *dryRun, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	err = a.print(result, []string{"DRY-RUN", "TOTAL", "CREATED", "UPDATED", "UNCHANGED", "FAILED"}, []string{
+		strconv.FormatBool(result.DryRun), strconv.Itoa(result.Total), strconv.Itoa(result.Created),
+		strconv.Itoa(result.Updated), strconv.Itoa(result.Unchanged), strconv.Itoa(result.Failed),
+	})
+	// 表格输出时在汇总之后列出失败的行，-json 时它们已经包含在结果中
+	if err == nil && !a.json && len(result.Errors) > 0 {
+		fmt.Fprintln(a.out)
+		rows := make([][]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			rows = append(rows, []string{strconv.Itoa(e.Line), e.SKU, e.Code, rowMessage(e)})
+		}
+		err = a.print(nil, []string{"LINE", "SKU", "CODE", "MESSAGE"}, rows...)
+	}
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("products import: %d of %d rows failed", result.Failed, result.Total)
+	}
+	return nil
+}
+
+func productsExport(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlags("products export")
+	formatFlag := fs.String("format", "", "csv or jsonl (defaults to the -o extension, or csv)")
+	outFile := fs.String("o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := fileFormat(*formatFlag, *outFile)
+	if err != nil {
+		return err
+	}
+
+	if *outFile == "" {
+		return catalog.Export(ctx, a.stores, catalog.NewWriter(format, a.out))
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		return err
+	}
+	if err := catalog.Export(ctx, a.stores, catalog.NewWriter(format, f)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fileFormat 没有 -format 时根据文件扩展名判断格式，无法判断时使用 CSV
+func fileFormat(flagValue, file string) (catalog.Format, error) {
+	if flagValue == "" {
+		flagValue = strings.TrimPrefix(filepath.Ext(file), ".")
+		if flagValue != "jsonl" && flagValue != "ndjson" {
+			flagValue = "csv"
+		}
+	}
+	return catalog.ParseFormat(flagValue)
+}
+
+// rowMessage 把字段错误附加在行错误的信息后面
+func rowMessage(e types.ImportRowError) string {
+	msg := e.Message
+	for _, f := range e.Fields {
+		msg += "; " + f.Field + ": " + f.Message
+	}
+	return msg
+}
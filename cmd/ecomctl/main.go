@@ -0,0 +1,150 @@
+package main
+
+//运维管理命令，直接使用与服务相同的 store 操作数据库
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/storage"
+	"github.com/Albert-tru/ecom/types"
+)
+
+const usage = `Usage: ecomctl [-storage mysql|postgres|sqlite] [-json] <command> [flags]
+
+Commands:
+  users list
+  users create -email E -first F -last L [-password P] [-admin]
+  users reset-password -email E [-password P]
+  users disable -email E
+  users enable -email E
+  stock adjust (-product ID | -sku SKU) -delta N -reason TEXT [-type restock|return|adjustment]
+  stock reconcile [-fix]
+  stock notify
+  products import -file F [-format csv|jsonl] [-dry-run]
+  products export [-format csv|jsonl] [-o FILE]
+  orders set-status -order ID -status pending|completed|delivered|cancelled
+  wishlists notify
+  token -email E [-ttl 15m]
+
+Without -password a random password is generated and printed once.
+stock adjust -product only works for products with a single variant.
+stock reconcile lists variants whose stock differs from the sum of their stock movements;
+-fix records an adjustment movement for each difference without changing the stock.
+stock notify sends one back-in-stock notification to each customer whose subscribed product
+is in stock again; the API server already does this every STOCK_NOTIFY_INTERVAL (1m by default).
+products import creates missing SKUs and updates only the non-empty fields of existing ones;
+it exits with an error if any row failed.
+wishlists notify sends back-in-stock and price-drop notifications for wishlisted products
+once through NOTIFY_WEBHOOK_URL, SMTP_ADDR or the log; run it from cron or set WISHLIST_CHECK_INTERVAL instead.
+Run "ecomctl <command> -h" for the flags of a command.
+`
+
+// 每个命令接收去掉命令名之后的参数
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"users list":           usersList,
+	"users create":         usersCreate,
+	"users reset-password": usersResetPassword,
+	"users disable":        usersSetDisabled(true),
+	"users enable":         usersSetDisabled(false),
+	"stock adjust":         stockAdjust,
+	"stock reconcile":      stockReconcile,
+	"stock notify":         stockNotify,
+	"products import":      productsImport,
+	"products export":      productsExport,
+	"orders set-status":    ordersSetStatus,
+	"wishlists notify":     wishlistsNotify,
+	"token":                issueToken,
+}
+
+// app 命令运行时共用的状态
+type app struct {
+	stores types.Stores
+	json   bool
+	out    io.Writer
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	storageFlag := flag.String("storage", "", "mysql, postgres or sqlite (defaults to DB_DRIVER)")
+	jsonOut := flag.Bool("json", false, "print JSON instead of a table")
+	flag.Parse()
+
+	cfg := config.Envs
+	if *storageFlag != "" {
+		cfg.DBDriver = *storageFlag
+	}
+	if cfg.DBDriver == storage.Memory {
+		log.Fatal("ecomctl needs a database: the memory store only lives inside the server process")
+	}
+	backend, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer backend.Close()
+
+	a := &app{stores: backend.Stores, json: *jsonOut, out: os.Stdout}
+	if err := run(context.Background(), a, flag.Args()); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if errors.Is(err, errUsage) {
+			flag.Usage()
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+var errUsage = errors.New("usage")
+
+// run 找到最长匹配的命令并执行
+func run(ctx context.Context, a *app, args []string) error {
+	for n := min(len(args), 2); n > 0; n-- {
+		if cmd, ok := commands[strings.Join(args[:n], " ")]; ok {
+			return cmd(ctx, a, args[n:])
+		}
+	}
+	if len(args) > 0 {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available: %s", strings.Join(args, " "), strings.Join(names, ", "))
+	}
+	return errUsage
+}
+
+// newFlags 创建子命令的 flag 集合，子命令中同样可以使用 -json
+func (a *app) newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&a.json, "json", a.json, "print JSON instead of a table")
+	return fs
+}
+
+// required 检查必填的参数
+func required(fs *flag.FlagSet, names ...string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var missing []string
+	for _, name := range names {
+		if !set[name] {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: missing %s", fs.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(types.Product{Name: "keyboard", Price: 10, Quantity: 5})
+	exec := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(ctx, &app{stores: store.Stores(), out: &out}, args)
+		return out.String(), err
+	}
+
+	out, err := exec("users", "create", "-email", "ops@example.com", "-first", "Ops", "-last", "Team", "-admin", "-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created userView
+	if err := json.Unmarshal([]byte(out), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Role != types.RoleAdmin || created.Password == "" {
+		t.Errorf("expected an admin with a generated password, got %+v", created)
+	}
+	u, _ := store.GetUserByEmail(ctx, "ops@example.com")
+	if auth.ComparePassword(u.Password, created.Password) != nil {
+		t.Error("printed password does not match the stored hash")
+	}
+
+	t.Run("重置密码", func(t *testing.T) {
+		if _, err := exec("users", "reset-password", "-email", "ops@example.com", "-password", "new-secret"); err != nil {
+			t.Fatal(err)
+		}
+		u, _ := store.GetUserByEmail(ctx, "ops@example.com")
+		if auth.ComparePassword(u.Password, "new-secret") != nil {
+			t.Error("password was not changed")
+		}
+	})
+
+	t.Run("表格输出不包含密码哈希", func(t *testing.T) {
+		out, err := exec("users", "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out, "ID ") || !strings.Contains(out, "ops@example.com") || strings.Contains(out, "$2a$") {
+			t.Errorf("unexpected table:\n%s", out)
+		}
+	})
+
+	t.Run("禁用后不能签发 token", func(t *testing.T) {
+		if _, err := exec("token", "-email", "ops@example.com", "-ttl", "5m"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec("users", "disable", "-email", "ops@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec("token", "-email", "ops@example.com"); !apperr.IsKind(err, apperr.KindForbidden) {
+			t.Errorf("expected account_disabled, got %v", err)
+		}
+		if _, err := exec("users", "enable", "-email", "ops@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("调整库存需要原因", func(t *testing.T) {
+		if _, err := exec("stock", "adjust", "-product", "1", "-delta", "3"); err == nil {
+			t.Error("expected an error without -reason")
+		}
+		out, err := exec("stock", "adjust", "-product", "1", "-delta", "3", "-reason", "stocktake", "-json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `"quantity": 8`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+
+		// 按 SKU 调整变体的库存，产品的总库存同步变化
+		if _, err := exec("stock", "adjust", "-product", "1", "-sku", "SKU-1", "-delta", "1", "-reason", "x"); err == nil {
+			t.Error("expected an error with both -product and -sku")
+		}
+		out, err = exec("stock", "adjust", "-sku", "SKU-1", "-delta", "-1", "-reason", "damaged", "-json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `"quantity": 7`) || !strings.Contains(out, `"productId": 1`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if _, err := exec("stock", "adjust", "-sku", "SKU-1", "-delta", "1", "-reason", "restock", "-type", "restock"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec("stock", "adjust", "-sku", "SKU-1", "-delta", "1", "-reason", "x", "-type", "sale"); err == nil {
+			t.Error("expected an error for a sale adjustment")
+		}
+	})
+
+	t.Run("修改订单状态遵循流转规则", func(t *testing.T) {
+		orderID, _ := store.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 20, Status: types.OrderPending})
+		if err := store.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: 1, Quantity: 2, Price: 10}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec("orders", "set-status", "-order", "1", "-status", "cancelled"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exec("orders", "set-status", "-order", "1", "-status", "completed"); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected invalid transition, got %v", err)
+		}
+		if _, err := exec("orders", "set-status", "-order", "1", "-status", "shipped"); err == nil {
+			t.Error("expected an error for an unknown status")
+		}
+		if ps, _ := store.GetProductByIDs(ctx, []int{1}); ps[0].Quantity != 8 {
+			t.Errorf("expected stock to be restored to 8, got %d", ps[0].Quantity)
+		}
+	})
+
+	t.Run("库存与流水对账", func(t *testing.T) {
+		var diffs []types.StockBalance
+		out, err := exec("stock", "reconcile", "-json")
+		if err != nil || json.Unmarshal([]byte(out), &diffs) != nil || len(diffs) != 0 {
+			t.Fatalf("expected the ledger to match the stock, got %s %v", out, err)
+		}
+
+		// 一条不改变库存的流水让两者不一致，-fix 补记差异
+		if err := store.RecordStockMovement(ctx, &types.StockMovement{ProductID: 1, VariantID: 1, Type: types.StockAdjustment, Delta: 2, Balance: 10}); err != nil {
+			t.Fatal(err)
+		}
+		out, err = exec("stock", "reconcile", "-fix")
+		if err != nil || !strings.Contains(out, "SKU-1") || !strings.Contains(out, "-2") {
+			t.Errorf("unexpected output: %s %v", out, err)
+		}
+		out, _ = exec("stock", "reconcile", "-json")
+		if json.Unmarshal([]byte(out), &diffs); len(diffs) != 0 {
+			t.Errorf("expected no differences after -fix, got %s", out)
+		}
+	})
+
+	t.Run("从文件导入和导出产品", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "products.csv")
+		os.WriteFile(file, []byte("sku,name,price,quantity\nSKU-1,,12.5,\nNEW-1,mouse,5,3\nNEW-2,,,\n"), 0o644)
+
+		// 试运行不写入，NEW-2 缺少名称和价格
+		out, err := exec("products", "import", "-file", file, "-dry-run", "-json")
+		if err == nil || !strings.Contains(err.Error(), "1 of 3 rows failed") {
+			t.Errorf("expected a failed row, got %v", err)
+		}
+		var result types.ImportResult
+		if err := json.Unmarshal([]byte(out), &result); err != nil {
+			t.Fatal(err)
+		}
+		if !result.DryRun || result.Created != 1 || result.Updated != 1 || result.Failed != 1 || result.Errors[0].Line != 4 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if _, err := store.GetVariantBySKU(ctx, "NEW-1"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected the dry run not to create NEW-1, got %v", err)
+		}
+
+		os.WriteFile(file, []byte("sku,name,price,quantity\nSKU-1,,12.5,\nNEW-1,mouse,5,3\n"), 0o644)
+		if _, err := exec("products", "import", "-file", file); err != nil {
+			t.Fatal(err)
+		}
+		if ps, _ := store.GetProductByIDs(ctx, []int{1}); ps[0].Price != 12.5 || ps[0].Name != "keyboard" {
+			t.Errorf("unexpected product: %+v", ps[0])
+		}
+
+		exported := filepath.Join(dir, "export.jsonl")
+		if _, err := exec("products", "export", "-o", exported); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(exported)
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"sku":"NEW-1"`) {
+			t.Errorf("unexpected export:\n%s", data)
+		}
+	})
+
+	if _, err := exec("users", "frobnicate"); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("expected unknown command error, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"text/tabwriter"
+)
+
+// print 在 -json 时输出 v，否则输出带表头的表格
+func (a *app) print(v any, header []string, rows ...[]string) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	tw.Write([]byte(strings.Join(header, "\t") + "\n"))
+	for _, row := range rows {
+		tw.Write([]byte(strings.Join(row, "\t") + "\n"))
+	}
+	return tw.Flush()
+}
@@ -1,47 +1,72 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"flag"
 	"log"
 
 	"github.com/Albert-tru/ecom/cmd/api"
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
 	"github.com/Albert-tru/ecom/config"
-	"github.com/Albert-tru/ecom/db"
-	"github.com/go-sql-driver/mysql"
+	"github.com/Albert-tru/ecom/storage"
+	"github.com/Albert-tru/ecom/tracing"
 )
 
 // 程序入口
 func main() {
+	// --storage 覆盖 DB_DRIVER，--storage=memory 时不需要数据库，方便演示
+	storageFlag := flag.String("storage", "", "storage backend: mysql, postgres, sqlite or memory (defaults to DB_DRIVER)")
+	flag.Parse()
+
+	cfg := config.Envs
+	if *storageFlag != "" {
+		cfg.DBDriver = *storageFlag
+	}
+
 	// 添加调试输出
-	log.Printf("数据库配置: 用户=%s, 地址=%s, 数据库名=%s",
-		config.Envs.DBUser, config.Envs.DBAddress, config.Envs.DBName)
-
-	db, err := db.NewMySQLStorage(mysql.Config{
-		User:                 config.Envs.DBUser,
-		Passwd:               config.Envs.DBPassword,
-		Net:                  config.Envs.DBNet,
-		Addr:                 "127.0.0.1:3306",
-		DBName:               config.Envs.DBName,
-		AllowNativePasswords: true,
-		ParseTime:            true,
-	})
+	log.Printf("数据库配置: 类型=%s, 用户=%s, 地址=%s, 数据库名=%s",
+		cfg.DBDriver, cfg.DBUser, cfg.DBAddress, cfg.DBName)
 
+	backend, err := storage.Open(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer backend.Close()
 
-	initStorge(db)
+	initStorge(backend)
+
+	// 按需在启动时执行数据库迁移（内存存储没有表结构）
+	if cfg.AutoMigrate && backend.DB != nil {
+		if err := migrations.AutoMigrate(cfg, backend.DB, backend.Dialect); err != nil {
+			log.Fatal("数据库迁移失败:", err)
+		}
+		log.Println("数据库已迁移到最新版本")
+	}
+
+	// 初始化链路追踪
+	tracing.SetDBSystem(backend.Name())
+	shutdownTracing, err := tracing.Init(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
 
 	//创建并运行API服务器
-	server := api.NewAPIServer(":8080", db)
+	server := api.NewAPIServer(":8080", backend.Stores)
 	if err := server.Run(); err != nil {
+		shutdownTracing(context.Background())
 		log.Fatal(err)
 	}
 
 }
 
-func initStorge(db *sql.DB) {
-	err := db.Ping()
+func initStorge(backend *storage.Backend) {
+	if backend.DB == nil {
+		log.Println("使用内存存储，数据在进程退出后丢失")
+		return
+	}
+
+	err := backend.DB.Ping()
 	if err != nil {
 		log.Fatal("无法连接到数据库:", err)
 	}
@@ -3,68 +3,219 @@ package main
 //数据库迁移的入口
 
 import (
-        "log"
-        "os"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
-        "github.com/Albert-tru/ecom/db"
-
-        "github.com/Albert-tru/ecom/config"
-        mysqlCfg "github.com/go-sql-driver/mysql"
-        "github.com/golang-migrate/migrate/v4"
-        "github.com/golang-migrate/migrate/v4/database/mysql"
-        _ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/golang-migrate/migrate/v4"
 )
 
+const usage = `用法: migrate [flags] <command> [arg]
+
+命令:
+  status         列出所有迁移及是否已执行
+  version        显示当前版本
+  up [N]         执行全部或接下来的 N 个迁移
+  down [N]       回滚 N 个迁移；不带 N 会回滚全部，需要确认或 -y
+  goto V         迁移到指定版本（向上或向下）
+  force V        把版本强制标记为 V 并清除 dirty 状态，不执行任何 SQL
+  create NAME    在每种数据库的迁移目录下生成一对空的迁移文件
+
+flags:
+`
+
 func main() {
-        //读取数据库的连接配置
-        db, err := db.NewMySQLStorage(mysqlCfg.Config{
-                User:                 config.Envs.DBUser,
-                Passwd:               config.Envs.DBPassword,
-                Net:                  config.Envs.DBNet,
-                Addr:                 "127.0.0.1:3306",
-                DBName:               config.Envs.DBName,
-                AllowNativePasswords: true,
-                ParseTime:            true,
-        })
-
-        if err != nil {
-                log.Fatal(err)
-        }
-
-        //创建一个新的数据库驱动实例
-        driver, err := mysql.WithInstance(db, &mysql.Config{})
-        if err != nil {
-                log.Fatal(err)
-        }
-
-        //创建一个新的迁移实例
-        m, err := migrate.NewWithDatabaseInstance(
-                "file://cmd/migrate/migrations",
-                "mysql",
-                driver,
-        )
-
-        if err != nil {
-                log.Fatal(err)
-        }
-
-        //根据命令行参数执行相应的迁移操作
-        cmd := os.Args[(len(os.Args) - 1)]
-        switch cmd {
-        case "up":
-                //执行向上迁移
-                if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-                        log.Fatal(err)
-                }
-                log.Println("Successfully applied up migrations")
-        case "down":
-                //执行向下迁移
-                if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-                        log.Fatal(err)
-                }
-                log.Println("Successfully applied down migrations")
-        default:
-                log.Fatalf("unknown command: %s", cmd)
-        }
+	storageFlag := flag.String("storage", "", "mysql, postgres or sqlite (defaults to DB_DRIVER)")
+	yes := flag.Bool("y", false, "do not ask for confirmation before a full down migration")
+	dir := flag.String("dir", "cmd/migrate/migrations", "migrations source directory used by create")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cmd, arg := flag.Arg(0), flag.Arg(1)
+	if cmd == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// create 只生成文件，不需要连接数据库
+	if cmd == "create" {
+		if err := create(*dir, arg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg := config.Envs
+	if *storageFlag != "" {
+		cfg.DBDriver = *storageFlag
+	}
+
+	//根据 DB_DRIVER 打开对应的数据库
+	conn, dialect, err := db.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//创建一个新的迁移实例，迁移文件已经内嵌在二进制中
+	m, err := migrations.New(conn, dialect)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := run(m, cmd, arg, *yes); err != nil {
+		m.Close()
+		log.Fatal(err)
+	}
+}
+
+// 根据命令行参数执行相应的迁移操作
+func run(m *migrations.Migrator, cmd, arg string, yes bool) error {
+	switch cmd {
+	case "status":
+		list, err := m.Statuses()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-16s %-8s %s\n", "VERSION", "STATE", "NAME")
+		for _, s := range list {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			if s.Dirty {
+				state = "dirty"
+			}
+			fmt.Printf("%-16d %-8s %s\n", s.Version, state, s.Name)
+		}
+		return nil
+
+	case "version":
+		v, dirty, err := m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("no migrations applied")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", v)
+		} else {
+			fmt.Println(v)
+		}
+		return nil
+
+	case "up":
+		if arg == "" {
+			return report(m.Up(), "Successfully applied up migrations")
+		}
+		n, err := positive(arg)
+		if err != nil {
+			return err
+		}
+		return report(m.Steps(n), fmt.Sprintf("Successfully applied %d up migrations", n))
+
+	case "down":
+		if arg == "" {
+			//不带 N 会删除所有表，需要确认
+			if !yes && !confirm("This will roll back ALL migrations and drop every table. Continue? [y/N] ") {
+				return errors.New("aborted")
+			}
+			return report(m.Down(), "Successfully applied down migrations")
+		}
+		n, err := positive(arg)
+		if err != nil {
+			return err
+		}
+		return report(m.Steps(-n), fmt.Sprintf("Successfully rolled back %d migrations", n))
+
+	case "goto":
+		v, err := version(arg)
+		if err != nil {
+			return err
+		}
+		return report(m.Migrate.Migrate(v), fmt.Sprintf("Successfully migrated to version %d", v))
+
+	case "force":
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("force needs a version, got %q", arg)
+		}
+		return report(m.Force(v), fmt.Sprintf("Forced version to %d", v))
+
+	default:
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+func report(err error, msg string) error {
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("No change")
+		return nil
+	}
+	log.Println(msg)
+	return nil
+}
+
+func positive(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive number of steps, got %q", arg)
+	}
+	return n, nil
+}
+
+func version(arg string) (uint, error) {
+	v, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a version, got %q", arg)
+	}
+	return uint(v), nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
 
+// create 在每种数据库的目录下生成 <时间戳>_<名称>.up.sql / .down.sql
+func create(dir, name string) error {
+	if !migrationName.MatchString(name) {
+		return fmt.Errorf("migration name must match %s, got %q", migrationName, name)
+	}
+	version := time.Now().UTC().Format("20060102150405")
+	for _, dialect := range []db.Dialect{db.MySQL, db.Postgres, db.SQLite} {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, string(dialect), fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+			if err != nil {
+				return err
+			}
+			f.Close()
+			log.Println("created", path)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,132 @@
+// Package migrations 内嵌了各数据库的迁移文件，二进制文件不再依赖运行目录
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"os"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// 每种数据库一个目录，文件名格式为 <版本>_<名称>.<up|down>.sql
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migrator 在 migrate.Migrate 的基础上增加了列出迁移状态的能力
+type Migrator struct {
+	*migrate.Migrate
+	source source.Driver
+}
+
+// Status 单个迁移文件的状态
+type Status struct {
+	Version uint
+	Name    string
+	Applied bool
+	Dirty   bool // 上次执行到这个版本时失败了，需要手动修复后 force
+}
+
+// New 用已有连接创建迁移实例
+// 注意：Close 会一并关闭 conn（golang-migrate 的行为）
+func New(conn *sql.DB, dialect db.Dialect) (*Migrator, error) {
+	src, err := iofs.New(files, string(dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	var driver database.Driver
+	switch dialect {
+	case db.Postgres:
+		driver, err = pgx.WithInstance(conn, &pgx.Config{})
+	case db.SQLite:
+		driver, err = sqlite.WithInstance(conn, &sqlite.Config{})
+	default:
+		driver, err = mysql.WithInstance(conn, &mysql.Config{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, string(dialect), driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{Migrate: m, source: src}, nil
+}
+
+// Statuses 列出所有迁移文件以及它们是否已经执行
+func (m *Migrator) Statuses() ([]Status, error) {
+	current, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		current, err = 0, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Status
+	v, err := m.source.First()
+	for err == nil {
+		r, name, readErr := m.source.ReadUp(v)
+		if readErr != nil {
+			return nil, readErr
+		}
+		r.Close()
+
+		list = append(list, Status{
+			Version: v,
+			Name:    name,
+			Applied: v <= current && current > 0,
+			Dirty:   dirty && v == current,
+		})
+		v, err = m.source.Next(v)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpAll 执行所有未执行的迁移，已经是最新版本时不报错
+func (m *Migrator) UpAll() error {
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// AutoMigrate 在服务启动时把数据库迁移到最新版本
+// MySQL/PostgreSQL 的迁移驱动会独占并在结束时关闭连接，所以使用单独打开的连接；
+// SQLite（特别是 :memory:）必须和服务共用同一个连接，且它的驱动不会占用连接，迁移后不关闭
+func AutoMigrate(cfg config.Config, conn *sql.DB, dialect db.Dialect) error {
+	if dialect == db.SQLite {
+		m, err := New(conn, dialect)
+		if err != nil {
+			return err
+		}
+		return m.UpAll()
+	}
+
+	own, dialect, err := db.Open(cfg)
+	if err != nil {
+		return err
+	}
+	m, err := New(own, dialect)
+	if err != nil {
+		own.Close()
+		return err
+	}
+	defer m.Close()
+	return m.UpAll()
+}
@@ -0,0 +1,185 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/product"
+)
+
+// 每个内嵌的迁移都必须能执行、回滚、再执行，且三种数据库的版本号一一对应
+func TestSQLiteRoundTrip(t *testing.T) {
+	conn, err := db.NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := New(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.UpAll(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	list, err := m.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range list {
+		if !s.Applied || s.Dirty {
+			t.Errorf("expected %d_%s to be applied", s.Version, s.Name)
+		}
+	}
+
+	if err := m.Down(); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := m.UpAll(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
+
+func TestDialectsHaveSameVersions(t *testing.T) {
+	versions := map[db.Dialect][]string{}
+	for _, d := range []db.Dialect{db.MySQL, db.Postgres, db.SQLite} {
+		entries, err := files.ReadDir(string(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			versions[d] = append(versions[d], e.Name())
+		}
+	}
+
+	for _, d := range []db.Dialect{db.Postgres, db.SQLite} {
+		if len(versions[d]) != len(versions[db.MySQL]) {
+			t.Fatalf("%s has %d migration files, mysql has %d", d, len(versions[d]), len(versions[db.MySQL]))
+		}
+		for i, name := range versions[db.MySQL] {
+			if versions[d][i] != name {
+				t.Errorf("%s: expected %s, got %s", d, name, versions[d][i])
+			}
+		}
+	}
+}
+
+// 加入变体之前的产品和订单项，迁移后应该指向各自的默认变体
+func TestExistingProductsGetDefaultVariants(t *testing.T) {
+	conn, err := db.NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := New(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Migrate.Migrate(20251102090100); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO users (firstname, lastname, email, password) VALUES ('a', 'b', 'a@example.com', 'x')",
+		"INSERT INTO products (name, price, quantity) VALUES ('keyboard', 49.9, 7), ('mouse', 19.5, 0)",
+		"INSERT INTO orders (user_id, total, status, address) VALUES (1, 49.9, 'pending', 'x')",
+		"INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (1, 1, 1, 49.9)",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	if err := m.UpAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.Query("SELECT product_id, sku, quantity FROM product_variants ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var (
+			productID, quantity int
+			sku                 string
+		)
+		if err := rows.Scan(&productID, &sku, &quantity); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s:%d", productID, sku, quantity))
+	}
+	if want := []string{"1:SKU-1:7", "2:SKU-2:0"}; !slices.Equal(got, want) {
+		t.Errorf("expected variants %v, got %v", want, got)
+	}
+
+	var variantID int
+	if err := conn.QueryRow("SELECT variant_id FROM order_items WHERE id = 1").Scan(&variantID); err != nil || variantID != 1 {
+		t.Errorf("expected the order item to point to variant 1, got %d (%v)", variantID, err)
+	}
+
+	// 已有产品的价格记为从创建时开始的长期价格
+	ps, err := product.NewStore(conn, db.SQLite).GetProductByIDs(context.Background(), []int{1, 2})
+	if err != nil || len(ps) != 2 {
+		t.Fatalf("get products: %+v %v", ps, err)
+	}
+	for _, p := range ps {
+		if p.PriceID == nil || p.CompareAtPrice != nil {
+			t.Errorf("expected product %d to use an opening price, got %+v", p.ID, p)
+		}
+	}
+	if ps[0].Price != 49.9 || ps[1].Price != 19.5 {
+		t.Errorf("unexpected prices: %+v", ps)
+	}
+}
+
+// 重建 orders 表以放开状态约束时，订单项和库存流水对订单的引用必须保留
+func TestOrdersRebuildKeepsReferences(t *testing.T) {
+	conn, err := db.NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := New(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Migrate.Migrate(20251110090200); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO users (firstname, lastname, email, password) VALUES ('a', 'b', 'a@example.com', 'x')",
+		"INSERT INTO products (name, price, quantity) VALUES ('keyboard', 49.9, 7)",
+		"INSERT INTO product_variants (product_id, sku, quantity) VALUES (1, 'KB-1', 7)",
+		"INSERT INTO orders (user_id, total, status, address) VALUES (1, 49.9, 'completed', 'x'), (1, 49.9, 'pending', 'y')",
+		"INSERT INTO order_items (order_id, product_id, variant_id, quantity, price) VALUES (1, 1, 1, 1, 49.9)",
+		"INSERT INTO stock_movements (product_id, variant_id, type, delta, balance, order_id) VALUES (1, 1, 'sale', -1, 6, 2)",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	if err := m.UpAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("UPDATE orders SET status = 'delivered' WHERE id = 1"); err != nil {
+		t.Errorf("expected delivered to be allowed: %v", err)
+	}
+	var orderID int
+	if err := conn.QueryRow("SELECT order_id FROM stock_movements WHERE id = 1").Scan(&orderID); err != nil || orderID != 2 {
+		t.Errorf("expected the movement to keep order 2, got %d (%v)", orderID, err)
+	}
+	if _, err := conn.Exec("INSERT INTO order_items (order_id, product_id, variant_id, quantity, price) VALUES (99, 1, 1, 1, 49.9)"); err == nil {
+		t.Error("expected order_items to still reference orders")
+	}
+	if _, err := conn.Exec("INSERT INTO orders (user_id, total, status, address) VALUES (1, 1, 'shipped', 'z')"); err == nil {
+		t.Error("expected unknown statuses to be rejected")
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS orders (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` INT UNSIGNED NOT NULL,
+    `total` DECIMAL(10, 2) NOT NULL,
+    `status` ENUM('pending', 'completed', 'cancelled') NOT NULL DEFAULT 'pending',
+    `address` TEXT NOT NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`user_id`) REFERENCES users(`id`)
+);
@@ -0,0 +1,4 @@
+# role: customer / admin；disabled 的账号不能登录，已签发的 token 也会失效
+ALTER TABLE users
+    ADD COLUMN `role` VARCHAR(20) NOT NULL DEFAULT 'customer',
+    ADD COLUMN `disabled` BOOLEAN NOT NULL DEFAULT FALSE;
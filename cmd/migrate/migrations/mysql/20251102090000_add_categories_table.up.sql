@@ -0,0 +1,13 @@
+# 多级分类，parent_id 为空的是顶级分类
+CREATE TABLE IF NOT EXISTS categories (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `parent_id` INT UNSIGNED NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `slug` VARCHAR(100) NOT NULL,
+    `sort_order` INT NOT NULL DEFAULT 0,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `slug_unique` (`slug`),
+    FOREIGN KEY (`parent_id`) REFERENCES categories(`id`)
+);
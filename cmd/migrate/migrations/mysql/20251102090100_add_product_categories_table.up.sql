@@ -0,0 +1,10 @@
+# 产品和分类多对多
+CREATE TABLE IF NOT EXISTS product_categories (
+    `product_id` INT UNSIGNED NOT NULL,
+    `category_id` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY (`product_id`, `category_id`),
+    KEY `category_idx` (`category_id`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`category_id`) REFERENCES categories(`id`) ON DELETE CASCADE
+);
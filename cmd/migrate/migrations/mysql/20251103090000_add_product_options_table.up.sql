@@ -0,0 +1,9 @@
+# 产品的规格类型（如 size、colour），position 决定展示顺序
+CREATE TABLE IF NOT EXISTS product_options (
+    `product_id` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(50) NOT NULL,
+    `position` INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (`product_id`, `name`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+# 产品变体：库存在变体上，price 为空时使用产品的价格
+CREATE TABLE IF NOT EXISTS product_variants (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `product_id` INT UNSIGNED NOT NULL,
+    `sku` VARCHAR(64) NOT NULL,
+    `price` DECIMAL(10, 2) NULL,
+    `quantity` INT NOT NULL DEFAULT 0,
+    `image` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `sku_unique` (`sku`),
+    KEY `product_idx` (`product_id`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+# 变体在每个规格上的取值，如 size = M
+CREATE TABLE IF NOT EXISTS variant_options (
+    `variant_id` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(50) NOT NULL,
+    `value` VARCHAR(100) NOT NULL,
+
+    PRIMARY KEY (`variant_id`, `name`),
+    FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`) ON DELETE CASCADE
+);
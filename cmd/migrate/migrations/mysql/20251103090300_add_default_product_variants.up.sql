@@ -0,0 +1,3 @@
+# 已有的产品各生成一个默认变体，承接原来的库存
+INSERT INTO product_variants (product_id, sku, quantity)
+SELECT id, CONCAT('SKU-', id), quantity FROM products;
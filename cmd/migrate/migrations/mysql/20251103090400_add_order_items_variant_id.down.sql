@@ -0,0 +1,3 @@
+ALTER TABLE order_items
+    DROP FOREIGN KEY `order_items_variant_fk`,
+    DROP COLUMN `variant_id`;
@@ -0,0 +1,3 @@
+ALTER TABLE order_items
+    ADD COLUMN `variant_id` INT UNSIGNED NULL AFTER `product_id`,
+    ADD CONSTRAINT `order_items_variant_fk` FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`);
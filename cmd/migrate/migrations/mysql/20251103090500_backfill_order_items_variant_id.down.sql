@@ -0,0 +1 @@
+UPDATE order_items SET variant_id = NULL;
@@ -0,0 +1,4 @@
+# 历史订单项指向产品的默认变体
+UPDATE order_items SET variant_id = (
+    SELECT MIN(v.id) FROM product_variants v WHERE v.product_id = order_items.product_id
+) WHERE variant_id IS NULL;
@@ -0,0 +1,3 @@
+# 搜索时名称上的相关度单独加权，需要只包含 name 的 FULLTEXT 索引
+# InnoDB 一次只能创建一个 FULLTEXT 索引，所以和 name、description 上的索引分成两个迁移
+ALTER TABLE products ADD FULLTEXT INDEX `ft_products_name` (`name`);
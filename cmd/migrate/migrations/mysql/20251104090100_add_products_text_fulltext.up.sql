@@ -0,0 +1,2 @@
+# 全文检索产品的名称和描述，见 service/search
+ALTER TABLE products ADD FULLTEXT INDEX `ft_products_text` (`name`, `description`);
@@ -0,0 +1,16 @@
+# 产品图片，文件保存在 IMAGE_STORAGE 配置的存储中，这里只记录路径和元数据
+CREATE TABLE IF NOT EXISTS product_images (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `product_id` INT UNSIGNED NOT NULL,
+    `position` INT NOT NULL DEFAULT 0,
+    `object_key` VARCHAR(255) NOT NULL,
+    `content_type` VARCHAR(50) NOT NULL,
+    `width` INT NOT NULL,
+    `height` INT NOT NULL,
+    `size` BIGINT NOT NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `product_position_idx` (`product_id`, `position`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+# 每张图片按 IMAGE_THUMBNAIL_SIZES 生成的缩略图
+CREATE TABLE IF NOT EXISTS product_image_thumbnails (
+    `image_id` INT UNSIGNED NOT NULL,
+    `size` INT NOT NULL,
+    `object_key` VARCHAR(255) NOT NULL,
+    `width` INT NOT NULL,
+    `height` INT NOT NULL,
+
+    PRIMARY KEY (`image_id`, `size`),
+    FOREIGN KEY (`image_id`) REFERENCES product_images(`id`) ON DELETE CASCADE
+);
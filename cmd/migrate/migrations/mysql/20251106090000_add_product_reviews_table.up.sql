@@ -0,0 +1,18 @@
+# 产品评价，每个用户对每个产品只能评价一次；只有 approved 的评价公开展示并计入评分
+CREATE TABLE IF NOT EXISTS product_reviews (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `product_id` INT UNSIGNED NOT NULL,
+    `user_id` INT UNSIGNED NOT NULL,
+    `rating` TINYINT UNSIGNED NOT NULL,
+    `title` VARCHAR(255) NOT NULL,
+    `body` TEXT NOT NULL,
+    `status` VARCHAR(16) NOT NULL DEFAULT 'pending',
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `product_user_unique` (`product_id`, `user_id`),
+    KEY `status_idx` (`status`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+# 已通过审核的评价的平均分和数量，在评价变化时重新计算
+ALTER TABLE products
+    ADD COLUMN `rating_average` DECIMAL(3, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN `rating_count` INT NOT NULL DEFAULT 0;
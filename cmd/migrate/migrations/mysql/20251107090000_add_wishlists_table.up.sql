@@ -0,0 +1,13 @@
+# 心愿单，每个用户可以有多个名称不同的心愿单；share_token 不为空时可以通过分享链接公开查看
+CREATE TABLE IF NOT EXISTS wishlists (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `share_token` VARCHAR(64) NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `user_name_unique` (`user_id`, `name`),
+    UNIQUE KEY `share_token_unique` (`share_token`),
+    FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+# 心愿单中的产品，variant_id 为空表示整个产品；last_price / last_in_stock 是上次检查到货和降价时的状态
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `wishlist_id` INT UNSIGNED NOT NULL,
+    `product_id` INT UNSIGNED NOT NULL,
+    `variant_id` INT UNSIGNED NULL,
+    `last_price` DECIMAL(10, 2) NOT NULL,
+    `last_in_stock` BOOLEAN NOT NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `wishlist_idx` (`wishlist_id`),
+    FOREIGN KEY (`wishlist_id`) REFERENCES wishlists(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`) ON DELETE CASCADE
+);
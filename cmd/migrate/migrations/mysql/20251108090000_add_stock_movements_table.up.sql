@@ -0,0 +1,21 @@
+# 库存流水，只追加不修改；balance 是变动后变体的库存，按变体累计 delta 应当等于变体的库存
+CREATE TABLE IF NOT EXISTS stock_movements (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `product_id` INT UNSIGNED NOT NULL,
+    `variant_id` INT UNSIGNED NOT NULL,
+    `type` VARCHAR(20) NOT NULL,
+    `delta` INT NOT NULL,
+    `balance` INT NOT NULL,
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `actor_id` INT UNSIGNED NULL,
+    `order_id` INT UNSIGNED NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `product_idx` (`product_id`),
+    KEY `variant_idx` (`variant_id`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`actor_id`) REFERENCES users(`id`) ON DELETE SET NULL,
+    FOREIGN KEY (`order_id`) REFERENCES orders(`id`) ON DELETE SET NULL
+);
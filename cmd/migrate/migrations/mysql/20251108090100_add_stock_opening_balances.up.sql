@@ -0,0 +1,3 @@
+# 已有的库存记为期初余额，之后的变动都通过流水记录
+INSERT INTO stock_movements (product_id, variant_id, type, delta, balance, reason)
+SELECT product_id, id, 'adjustment', quantity, quantity, 'opening balance' FROM product_variants WHERE quantity <> 0;
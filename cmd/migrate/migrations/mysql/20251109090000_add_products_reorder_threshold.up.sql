@@ -0,0 +1,2 @@
+# 补货阈值：结账后产品的库存从阈值以上降到阈值以下时发出告警，0 表示不告警
+ALTER TABLE products ADD COLUMN `reorder_threshold` INT NOT NULL DEFAULT 0;
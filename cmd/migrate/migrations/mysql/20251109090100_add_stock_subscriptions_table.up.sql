@@ -0,0 +1,15 @@
+# 到货订阅：variant_id 为空表示产品的任意变体；notified_at 不为空表示已经通知过，不再通知
+CREATE TABLE IF NOT EXISTS stock_subscriptions (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `user_id` INT UNSIGNED NOT NULL,
+    `product_id` INT UNSIGNED NOT NULL,
+    `variant_id` INT UNSIGNED NULL,
+    `notified_at` TIMESTAMP NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `product_idx` (`product_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+# 价格计划：effective_to 为空表示一直有效；compare_at_price 是促销时展示的划线价
+CREATE TABLE IF NOT EXISTS product_prices (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `product_id` INT UNSIGNED NOT NULL,
+    `price` DECIMAL(10, 2) NOT NULL,
+    `compare_at_price` DECIMAL(10, 2) NULL,
+    `effective_from` DATETIME NOT NULL,
+    `effective_to` DATETIME NULL,
+    `createdat` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `product_effective_idx` (`product_id`, `effective_from`),
+    FOREIGN KEY (`product_id`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+DELETE FROM product_prices WHERE compare_at_price IS NULL AND effective_to IS NULL
+    AND effective_from = (SELECT COALESCE(p.createdat, product_prices.effective_from) FROM products p WHERE p.id = product_prices.product_id);
@@ -0,0 +1,3 @@
+# 已有产品的价格记为从创建时开始的长期价格，之后的改价和促销都通过价格计划记录
+INSERT INTO product_prices (product_id, price, effective_from)
+SELECT id, price, COALESCE(createdat, CURRENT_TIMESTAMP) FROM products;
@@ -0,0 +1,3 @@
+ALTER TABLE order_items
+    DROP FOREIGN KEY `order_items_price_fk`,
+    DROP COLUMN `price_id`;
@@ -0,0 +1,4 @@
+# 订单项的价格来自哪条价格记录，变体单独定价时为空
+ALTER TABLE order_items
+    ADD COLUMN `price_id` INT UNSIGNED NULL AFTER `price`,
+    ADD CONSTRAINT `order_items_price_fk` FOREIGN KEY (`price_id`) REFERENCES product_prices(`id`) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+UPDATE orders SET `status` = 'completed' WHERE `status` = 'delivered';
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'completed', 'cancelled') NOT NULL DEFAULT 'pending';
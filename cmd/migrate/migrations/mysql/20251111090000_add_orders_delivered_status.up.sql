@@ -0,0 +1 @@
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'completed', 'delivered', 'cancelled') NOT NULL DEFAULT 'pending';
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    image VARCHAR(255) NOT NULL DEFAULT '',
+    price NUMERIC(10, 2) NOT NULL,
+    quantity INT NOT NULL DEFAULT 0,
+    createdat TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
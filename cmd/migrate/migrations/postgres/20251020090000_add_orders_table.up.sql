@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    total NUMERIC(10, 2) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled')),
+    address TEXT NOT NULL,
+    createdat TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
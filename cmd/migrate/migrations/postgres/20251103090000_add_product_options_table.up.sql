@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS product_options (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (product_id, name)
+);
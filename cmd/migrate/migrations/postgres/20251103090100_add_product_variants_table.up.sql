@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    price NUMERIC(10, 2),
+    quantity INT NOT NULL DEFAULT 0,
+    image VARCHAR(255) NOT NULL DEFAULT '',
+    createdat TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS product_variants_product_idx ON product_variants (product_id);
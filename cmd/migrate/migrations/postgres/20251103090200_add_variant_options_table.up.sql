@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS variant_options (
+    variant_id INT NOT NULL REFERENCES product_variants(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    value VARCHAR(100) NOT NULL,
+    PRIMARY KEY (variant_id, name)
+);
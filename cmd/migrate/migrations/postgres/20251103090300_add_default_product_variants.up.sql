@@ -0,0 +1,2 @@
+INSERT INTO product_variants (product_id, sku, quantity)
+SELECT id, 'SKU-' || id, quantity FROM products;
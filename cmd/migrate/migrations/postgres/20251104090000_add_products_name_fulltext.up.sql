@@ -0,0 +1,3 @@
+-- 只有 MySQL 使用 FULLTEXT 索引，其它数据库使用 service/search 中的倒排索引
+-- 保留同名的空迁移，让各个数据库的版本号保持一致
+SELECT 1;
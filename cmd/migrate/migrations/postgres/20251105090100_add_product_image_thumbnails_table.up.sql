@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS product_image_thumbnails (
+    image_id INT NOT NULL REFERENCES product_images(id) ON DELETE CASCADE,
+    size INT NOT NULL,
+    object_key VARCHAR(255) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    PRIMARY KEY (image_id, size)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE products
+    ADD COLUMN rating_average NUMERIC(3, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN rating_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id SERIAL PRIMARY KEY,
+    wishlist_id INT NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+    last_price NUMERIC(10, 2) NOT NULL,
+    last_in_stock BOOLEAN NOT NULL,
+    createdat TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS wishlist_items_wishlist_idx ON wishlist_items (wishlist_id);
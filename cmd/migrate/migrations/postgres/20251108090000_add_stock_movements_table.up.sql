@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT NOT NULL REFERENCES product_variants(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    delta INT NOT NULL,
+    balance INT NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    createdat TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id);
+CREATE INDEX IF NOT EXISTS stock_movements_variant_idx ON stock_movements (variant_id);
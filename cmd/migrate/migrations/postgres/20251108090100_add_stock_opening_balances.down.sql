@@ -0,0 +1 @@
+DELETE FROM stock_movements WHERE reason = 'opening balance' AND actor_id IS NULL AND order_id IS NULL;
@@ -0,0 +1,2 @@
+INSERT INTO product_prices (product_id, price, effective_from)
+SELECT id, price, createdat FROM products;
@@ -0,0 +1,3 @@
+UPDATE orders SET status = 'completed' WHERE status = 'delivered';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'completed', 'cancelled'));
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    total REAL NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled')),
+    address TEXT NOT NULL,
+    createdat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
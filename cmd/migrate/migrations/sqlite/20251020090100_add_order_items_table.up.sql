@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    price REAL NOT NULL,
+    createdat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS product_options (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (product_id, name)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS variant_options (
+    variant_id INTEGER NOT NULL REFERENCES product_variants(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (variant_id, name)
+);
@@ -0,0 +1 @@
+DELETE FROM product_variants;
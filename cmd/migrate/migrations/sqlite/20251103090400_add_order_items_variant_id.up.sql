@@ -0,0 +1 @@
+ALTER TABLE order_items ADD COLUMN variant_id INTEGER REFERENCES product_variants(id);
@@ -0,0 +1,3 @@
+UPDATE order_items SET variant_id = (
+    SELECT MIN(v.id) FROM product_variants v WHERE v.product_id = order_items.product_id
+) WHERE variant_id IS NULL;
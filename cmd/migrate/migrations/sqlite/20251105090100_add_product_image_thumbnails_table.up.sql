@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS product_image_thumbnails (
+    image_id INTEGER NOT NULL REFERENCES product_images(id) ON DELETE CASCADE,
+    size INTEGER NOT NULL,
+    object_key TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    PRIMARY KEY (image_id, size)
+);
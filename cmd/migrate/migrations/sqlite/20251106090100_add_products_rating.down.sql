@@ -0,0 +1,2 @@
+ALTER TABLE products DROP COLUMN rating_count;
+ALTER TABLE products DROP COLUMN rating_average;
@@ -0,0 +1,2 @@
+ALTER TABLE products ADD COLUMN rating_average REAL NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN rating_count INTEGER NOT NULL DEFAULT 0;
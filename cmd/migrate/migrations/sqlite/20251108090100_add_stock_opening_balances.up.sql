@@ -0,0 +1,2 @@
+INSERT INTO stock_movements (product_id, variant_id, type, delta, balance, reason)
+SELECT product_id, id, 'adjustment', quantity, quantity, 'opening balance' FROM product_variants WHERE quantity <> 0;
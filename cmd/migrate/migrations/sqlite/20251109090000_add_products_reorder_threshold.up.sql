@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN reorder_threshold INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price REAL NOT NULL,
+    compare_at_price REAL,
+    effective_from DATETIME NOT NULL,
+    effective_to DATETIME,
+    createdat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS product_prices_product_effective_idx ON product_prices (product_id, effective_from);
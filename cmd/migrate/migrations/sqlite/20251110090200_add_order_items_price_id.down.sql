@@ -0,0 +1,14 @@
+-- SQLite 不能删除带外键约束的列，只能重建表
+CREATE TABLE order_items_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    price REAL NOT NULL,
+    createdat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    variant_id INTEGER REFERENCES product_variants(id)
+);
+INSERT INTO order_items_old (id, order_id, product_id, quantity, price, createdat, variant_id)
+SELECT id, order_id, product_id, quantity, price, createdat, variant_id FROM order_items;
+DROP TABLE order_items;
+ALTER TABLE order_items_old RENAME TO order_items;
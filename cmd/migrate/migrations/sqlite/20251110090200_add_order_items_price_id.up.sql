@@ -0,0 +1 @@
+ALTER TABLE order_items ADD COLUMN price_id INTEGER REFERENCES product_prices(id) ON DELETE SET NULL;
@@ -0,0 +1,21 @@
+-- SQLite 不能修改 CHECK 约束，只能重建表。迁移在事务中执行，不能关闭外键检查：
+-- 推迟外键检查到提交时，重新插入订单后 order_items 的引用恢复有效；
+-- 删除旧表会把 stock_movements.order_id 置空，先记下再写回
+PRAGMA defer_foreign_keys = ON;
+CREATE TABLE orders_copy AS SELECT id, user_id, total, status, address, createdat FROM orders;
+CREATE TABLE stock_movement_orders AS SELECT id, order_id FROM stock_movements WHERE order_id IS NOT NULL;
+DROP TABLE orders;
+CREATE TABLE orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    total REAL NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'delivered', 'cancelled')),
+    address TEXT NOT NULL,
+    createdat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO orders (id, user_id, total, status, address, createdat)
+SELECT id, user_id, total, status, address, createdat FROM orders_copy;
+UPDATE stock_movements SET order_id = (SELECT m.order_id FROM stock_movement_orders m WHERE m.id = stock_movements.id)
+WHERE id IN (SELECT id FROM stock_movement_orders);
+DROP TABLE orders_copy;
+DROP TABLE stock_movement_orders;
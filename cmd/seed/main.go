@@ -0,0 +1,103 @@
+package main
+
+//开发和演示数据的导入入口
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/seed"
+	"github.com/Albert-tru/ecom/storage"
+)
+
+// 可以重复的 -f 参数
+type fileList []string
+
+func (f *fileList) String() string     { return strings.Join(*f, ",") }
+func (f *fileList) Set(v string) error { *f = append(*f, v); return nil }
+
+func main() {
+	var files fileList
+	flag.Var(&files, "f", "fixture file (.yaml, .yml or .json); can be repeated. Defaults to the built-in demo data")
+	storageFlag := flag.String("storage", "", "mysql, postgres or sqlite (defaults to DB_DRIVER)")
+	users := flag.Int("users", 0, "number of fake users to generate")
+	products := flag.Int("products", 0, "number of fake products to generate")
+	orders := flag.Int("orders", 0, "number of fake orders to generate")
+	seedValue := flag.Uint64("seed", 1, "random seed for fake data; the same seed always produces the same data")
+	reset := flag.Bool("reset", false, "delete all users, products and orders before seeding (test databases only)")
+	yes := flag.Bool("y", false, "do not ask for confirmation before --reset")
+	migrate := flag.Bool("migrate", false, "apply database migrations before seeding")
+	flag.Parse()
+
+	cfg := config.Envs
+	if *storageFlag != "" {
+		cfg.DBDriver = *storageFlag
+	}
+	backend, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer backend.Close()
+	if backend.DB == nil {
+		log.Fatal("seeding the memory store is pointless: it is discarded when this process exits")
+	}
+
+	ctx := context.Background()
+
+	if *migrate {
+		if err := migrations.AutoMigrate(cfg, backend.DB, backend.Dialect); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *reset {
+		if !*yes && !confirm(fmt.Sprintf("This deletes ALL users, products and orders in the %s database %q. Continue? [y/N] ", backend.Dialect, cfg.DBName)) {
+			log.Fatal("aborted")
+		}
+		if err := seed.Reset(ctx, backend.DB, backend.Dialect); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("database reset")
+	}
+
+	var fixtures []seed.Fixture
+	for _, f := range files {
+		fx, err := seed.Load(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixtures = append(fixtures, fx)
+	}
+	if *users > 0 || *products > 0 || *orders > 0 {
+		fixtures = append(fixtures, seed.Generate(seed.FakeOptions{
+			Users: *users, Products: *products, Orders: *orders, Seed: *seedValue,
+		}))
+	}
+	if len(fixtures) == 0 {
+		demo, err := seed.Demo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixtures = append(fixtures, demo)
+	}
+
+	report, err := seed.Apply(ctx, backend.Stores, seed.Merge(fixtures...))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println(report)
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
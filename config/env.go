@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,13 +14,90 @@ import (
 type Config struct {
 	PublicHost           string
 	Port                 string
+	DBDriver             string // mysql / postgres / sqlite / memory
 	DBUser               string
 	DBPassword           string
 	DBAddress            string
 	DBName               string
 	DBNet                string
+	PostgresURL          string
+	SQLitePath           string
+	DBReadTimeout        time.Duration
+	DBWriteTimeout       time.Duration
+	DBOpTimeouts         string // 按操作覆盖超时，如 "order.Store.CreateOrder=5s,product.Store.GetProducts=2s"
+	TxIsolation          string // 事务隔离级别，如 "read_committed"、"serializable"，为空时使用数据库默认值
+	TxMaxRetries         int    // 死锁或序列化失败时整个事务的最大重试次数
+	TxRetryBackoff       time.Duration
+	AutoMigrate          bool // 服务启动时自动执行数据库迁移
 	JWTExpirationSeconds int
-	JWTSecret            string
+	MaxBodyBytes         int64 // 请求体最大字节数，超出返回 413
+	DisallowUnknownJSON  bool  // 默认是否拒绝请求体中未知的 JSON 字段
+
+	// 限流
+	RateLimits       string // 按路由模板配置，如 "/api/v1/login=5/1m:ip"
+	RateLimitDefault string // 未单独配置的路由使用的规则，为空表示不限流
+	TrustedProxies   string // 可信代理的 CIDR 列表，只有来自它们的 X-Forwarded-For 才会被采信
+	APIKeyHeader     string
+
+	// 跨域与安全响应头
+	CORSAllowedOrigins    string // 逗号分隔，支持 "*" 和 "https://*.example.com" 形式的通配
+	CORSAllowedMethods    string
+	CORSAllowedHeaders    string
+	CORSExposedHeaders    string
+	CORSAllowCredentials  bool
+	CORSMaxAge            time.Duration // 预检结果的缓存时间
+	HSTSMaxAge            time.Duration // 为 0 时不发送 Strict-Transport-Security
+	FrameOptions          string
+	ContentSecurityPolicy string
+
+	// 处理超时
+	HandlerTimeout    time.Duration // 默认的处理函数超时
+	RouteTimeouts     string        // 按路由模板覆盖，如 "/api/v1/cart/checkout=20s"
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	JWTSecret         string
+
+	// 产品图片的上传与存储
+	ImageStorage        string // local / s3
+	ImageLocalDir       string // local 存储保存文件的目录
+	ImageBaseURL        string // local 存储访问图片的地址前缀，为空时使用 PublicHost:Port/media
+	ImageURLSigningKey  string // 不为空时 local 存储返回带签名的临时地址，否则返回公开地址
+	ImageURLTTL         time.Duration
+	ImageMaxBytes       int64  // 单张图片的最大字节数，超出返回 413
+	ImageMaxPixels      int    // 单张图片的最大像素数（宽 × 高），防止解码超大图片耗尽内存
+	ImageThumbnailSizes string // 逗号分隔的缩略图边长（像素），如 "150,600"
+
+	// S3 兼容的对象存储（IMAGE_STORAGE=s3 时使用）
+	S3Endpoint        string // 如 https://s3.us-east-1.amazonaws.com 或 http://localhost:9000
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool   // 使用 endpoint/bucket/key 形式的地址，MinIO 等通常需要开启
+	S3PublicURL       string // 不为空时返回公开地址 S3PublicURL/key，否则返回预签名的临时地址
+
+	// 产品批量导入
+	ImportMaxBytes  int64 // 导入文件的最大字节数，超出返回 413
+	ImportAsyncRows int   // 超过这个行数的导入在后台执行，返回任务供轮询进度
+
+	// 事件通知（心愿单中的产品到货、降价，库存低于补货阈值，订阅的产品到货）
+	// 配置了 Webhook 时发到 Webhook，否则配置了 SMTP 时发邮件，都没有配置时只写日志
+	NotifyWebhookURL      string // 为空时不使用 Webhook
+	NotifyWebhookSecret   string // 不为空时用它对请求体签名
+	SMTPAddr              string // host:port，为空时不发邮件
+	SMTPUsername          string // 为空时不认证
+	SMTPPassword          string
+	SMTPFrom              string
+	NotifyEmailTo         string        // 逗号分隔，接收没有指定收件人的事件（如库存告警）
+	WishlistCheckInterval time.Duration // 服务内定期检查心愿单的间隔，为 0 时不检查（可以用 ecomctl wishlists notify 代替）
+	StockNotifyInterval   time.Duration // 服务内定期检查到货订阅的间隔，默认 1 分钟；为 0 时只在管理员补货后检查（可以用 ecomctl stock notify 代替）
+
+	// 链路追踪（OpenTelemetry）
+	ServiceName       string
+	TraceExporters    string // 逗号分隔：otlp, stdout, file；为空或 none 表示不导出
+	TraceOTLPEndpoint string // 为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或默认的 localhost:4318
+	TraceFile         string
+	TraceSampleRatio  float64
 }
 
 var Envs = initConfig()
@@ -28,15 +108,69 @@ func initConfig() Config {
 		log.Printf("未能加载 .env 文件: %v", err)
 	}
 	return Config{
-		PublicHost:           getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:                 getEnv("PORT", "8080"),
-		DBUser:               getEnv("DB_USER", "root"),
-		DBPassword:           getEnv("DB_PASSWORD", "password"),
-		DBAddress:            fmt.Sprintf("%s:%s", getEnv("DB_HOST", "localhost"), getEnv("DB_PORT", "3306")),
-		DBName:               getEnv("DB_NAME", "ecom"),
-		DBNet:                getEnv("DB_NET", "tcp"),
+		PublicHost: getEnv("PUBLIC_HOST", "http://localhost"),
+		Port:       getEnv("PORT", "8080"),
+		DBDriver:   getEnv("DB_DRIVER", "mysql"),
+		DBUser:     getEnv("DB_USER", "root"),
+		DBPassword: getEnv("DB_PASSWORD", "password"),
+		DBAddress:  fmt.Sprintf("%s:%s", getEnv("DB_HOST", "localhost"), getEnv("DB_PORT", "3306")),
+		DBName:     getEnv("DB_NAME", "ecom"),
+		DBNet:      getEnv("DB_NET", "tcp"),
+		PostgresURL: getEnv("POSTGRES_URL", (&url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(getEnv("DB_USER", "root"), getEnv("DB_PASSWORD", "password")),
+			Host:     fmt.Sprintf("%s:%s", getEnv("DB_HOST", "localhost"), getEnv("POSTGRES_PORT", "5432")),
+			Path:     getEnv("DB_NAME", "ecom"),
+			RawQuery: "sslmode=disable",
+		}).String()),
+		SQLitePath:           getEnv("SQLITE_PATH", "ecom.db"),
+		DBReadTimeout:        getEnvDuration("DB_READ_TIMEOUT", 3*time.Second),
+		DBWriteTimeout:       getEnvDuration("DB_WRITE_TIMEOUT", 5*time.Second),
+		DBOpTimeouts:         getEnv("DB_OP_TIMEOUTS", ""),
+		TxIsolation:          getEnv("TX_ISOLATION", ""),
+		TxMaxRetries:         getEnvInt("TX_MAX_RETRIES", 3),
+		TxRetryBackoff:       getEnvDuration("TX_RETRY_BACKOFF", 20*time.Millisecond),
+		AutoMigrate:          getEnvBool("AUTO_MIGRATE", false),
 		JWTExpirationSeconds: getEnvInt("JWT_EXP", 3600*24*7), // 24 hours
-		JWTSecret:            getEnv("JWT_SECRET", "your_jwt_secret_key"),
+		MaxBodyBytes:         int64(getEnvInt("MAX_BODY_BYTES", 1<<20)),
+		DisallowUnknownJSON:  getEnvBool("JSON_DISALLOW_UNKNOWN_FIELDS", false),
+		RateLimits: getEnv("RATE_LIMITS",
+			"/api/v1/login=5/1m:ip,/api/v1/register=3/1m:ip,/api/v1/cart/checkout=10/1m:user"),
+		RateLimitDefault:      getEnv("RATE_LIMIT_DEFAULT", ""),
+		TrustedProxies:        getEnv("TRUSTED_PROXIES", ""),
+		APIKeyHeader:          getEnv("API_KEY_HEADER", "X-API-Key"),
+		JWTSecret:             getEnv("JWT_SECRET", "your_jwt_secret_key"),
+		ImageStorage:          getEnv("IMAGE_STORAGE", "local"),
+		ImageLocalDir:         getEnv("IMAGE_LOCAL_DIR", "uploads"),
+		ImageBaseURL:          getEnv("IMAGE_BASE_URL", ""),
+		ImageURLSigningKey:    getEnv("IMAGE_URL_SIGNING_KEY", ""),
+		ImageURLTTL:           getEnvDuration("IMAGE_URL_TTL", time.Hour),
+		ImageMaxBytes:         int64(getEnvInt("IMAGE_MAX_BYTES", 5<<20)),
+		ImageMaxPixels:        getEnvInt("IMAGE_MAX_PIXELS", 40_000_000),
+		ImageThumbnailSizes:   getEnv("IMAGE_THUMBNAIL_SIZES", "150,600"),
+		S3Endpoint:            getEnv("S3_ENDPOINT", ""),
+		S3Region:              getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:              getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:         getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:     getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:           getEnvBool("S3_PATH_STYLE", false),
+		S3PublicURL:           getEnv("S3_PUBLIC_URL", ""),
+		ImportMaxBytes:        int64(getEnvInt("IMPORT_MAX_BYTES", 32<<20)),
+		ImportAsyncRows:       getEnvInt("IMPORT_ASYNC_ROWS", 500),
+		NotifyWebhookURL:      getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifyWebhookSecret:   getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+		SMTPAddr:              getEnv("SMTP_ADDR", ""),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:              getEnv("SMTP_FROM", "ecom@localhost"),
+		NotifyEmailTo:         getEnv("NOTIFY_EMAIL_TO", ""),
+		WishlistCheckInterval: getEnvDuration("WISHLIST_CHECK_INTERVAL", 0),
+		StockNotifyInterval:   getEnvDuration("STOCK_NOTIFY_INTERVAL", time.Minute),
+		ServiceName:           getEnv("OTEL_SERVICE_NAME", "ecom"),
+		TraceExporters:        getEnv("TRACE_EXPORTERS", "none"),
+		TraceOTLPEndpoint:     getEnv("TRACE_OTLP_ENDPOINT", ""),
+		TraceFile:             getEnv("TRACE_FILE", "traces.json"),
+		TraceSampleRatio:      getEnvFloat("TRACE_SAMPLE_RATIO", 1.0),
 	}
 }
 
@@ -57,3 +191,34 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		var floatValue float64
+		_, err := fmt.Sscanf(value, "%g", &floatValue)
+		if err == nil {
+			return floatValue
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		b, err := strconv.ParseBool(value)
+		if err == nil {
+			return b
+		}
+	}
+	return fallback
+}
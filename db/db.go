@@ -2,9 +2,13 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 
+	"github.com/Albert-tru/ecom/config"
 	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 func NewMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
@@ -14,3 +18,58 @@ func NewMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+func NewPostgresStorage(url string) (*sql.DB, error) {
+	return sql.Open("pgx", url)
+}
+
+// NewSQLiteStorage 打开 SQLite 数据库，path 为 ":memory:" 时使用内存数据库
+func NewSQLiteStorage(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者；内存数据库每个连接都是独立的库，所以只保留一个连接
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Open 根据配置中的 DB_DRIVER 打开对应的数据库
+func Open(cfg config.Config) (*sql.DB, Dialect, error) {
+	dialect, err := ParseDialect(cfg.DBDriver)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var conn *sql.DB
+	switch dialect {
+	case Postgres:
+		conn, err = NewPostgresStorage(cfg.PostgresURL)
+	case SQLite:
+		conn, err = NewSQLiteStorage(cfg.SQLitePath)
+	default:
+		conn, err = NewMySQLStorage(MySQLConfig(cfg))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("open %s: %w", dialect, err)
+	}
+
+	return conn, dialect, nil
+}
+
+// MySQLConfig 由环境配置生成 MySQL 驱动的连接参数
+func MySQLConfig(cfg config.Config) mysql.Config {
+	return mysql.Config{
+		User:                 cfg.DBUser,
+		Passwd:               cfg.DBPassword,
+		Net:                  cfg.DBNet,
+		Addr:                 cfg.DBAddress,
+		DBName:               cfg.DBName,
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	}
+}
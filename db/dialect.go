@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect 存储后端的 SQL 方言
+// store 中统一使用 "?" 作为占位符，由 Rebind 转换成各数据库的写法
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// ParseDialect 解析配置中的存储类型
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "mysql", "":
+		return MySQL, nil
+	case "postgres", "postgresql", "pg":
+		return Postgres, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported storage driver: %s", name)
+	}
+}
+
+// Rebind 把 "?" 占位符转换为当前方言的写法（PostgreSQL 使用 $1, $2 ...）
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			inString = !inString
+		}
+		if c == '?' && !inString {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Placeholders 生成 n 个以逗号分隔的 "?"，用于 IN 查询
+func Placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// InsertID 执行 INSERT 并返回新行的自增ID
+// PostgreSQL 的驱动不支持 LastInsertId，需要改用 RETURNING id
+func (d Dialect) InsertID(ctx context.Context, conn Querier, query string, args ...any) (int64, error) {
+	if d == Postgres {
+		var id int64
+		err := conn.QueryRowContext(ctx, d.Rebind(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := conn.ExecContext(ctx, d.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/config"
+)
+
+// OpKind 区分读写操作，两者使用不同的默认超时
+type OpKind int
+
+const (
+	Read OpKind = iota
+	Write
+)
+
+// Timeouts 数据库操作的超时配置，值 <= 0 表示不限制
+type Timeouts struct {
+	Read      time.Duration
+	Write     time.Duration
+	Overrides map[string]time.Duration // 按操作名（如 "order.Store.CreateOrder"）覆盖
+}
+
+// QueryTimeouts 所有 store 共用的超时配置，启动时从环境变量加载
+var QueryTimeouts = loadTimeouts(config.Envs)
+
+func loadTimeouts(cfg config.Config) Timeouts {
+	t := Timeouts{
+		Read:      cfg.DBReadTimeout,
+		Write:     cfg.DBWriteTimeout,
+		Overrides: map[string]time.Duration{},
+	}
+
+	for _, pair := range strings.Split(cfg.DBOpTimeouts, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		op, value, ok := strings.Cut(pair, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil {
+			log.Printf("ignoring invalid DB_OP_TIMEOUTS entry %q", pair)
+			continue
+		}
+		t.Overrides[strings.TrimSpace(op)] = d
+	}
+
+	return t
+}
+
+// For 返回某个操作应使用的超时时间
+func (t Timeouts) For(op string, kind OpKind) time.Duration {
+	if d, ok := t.Overrides[op]; ok {
+		return d
+	}
+	if kind == Write {
+		return t.Write
+	}
+	return t.Read
+}
+
+// WithTimeout 按操作名给 ctx 加上超时，调用方必须 defer cancel()
+func WithTimeout(ctx context.Context, op string, kind OpKind) (context.Context, context.CancelFunc) {
+	d := QueryTimeouts.For(op, kind)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
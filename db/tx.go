@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/config"
+)
+
+// Querier 是 *sql.DB 和 *sql.Tx 共有的方法，store 通过它执行 SQL
+// 这样同一个 store 既可以直接访问数据库，也可以在事务中使用
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxOptions 事务的配置
+type TxOptions struct {
+	Isolation    sql.IsolationLevel
+	MaxRetries   int           // 遇到死锁、锁等待超时或序列化失败时的重试次数
+	RetryBackoff time.Duration // 第一次重试前的等待时间，之后每次翻倍并加上随机抖动
+}
+
+// TxDefaults 所有事务共用的配置，启动时从环境变量加载
+var TxDefaults = loadTxOptions(config.Envs)
+
+func loadTxOptions(cfg config.Config) TxOptions {
+	level, err := ParseIsolation(cfg.TxIsolation)
+	if err != nil {
+		log.Printf("ignoring invalid TX_ISOLATION: %v", err)
+	}
+	return TxOptions{
+		Isolation:    level,
+		MaxRetries:   cfg.TxMaxRetries,
+		RetryBackoff: cfg.TxRetryBackoff,
+	}
+}
+
+// ParseIsolation 解析隔离级别，如 "read_committed"、"repeatable read"、"serializable"
+func ParseIsolation(name string) (sql.IsolationLevel, error) {
+	switch strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(strings.TrimSpace(name))) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "snapshot":
+		return sql.LevelSnapshot, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level: %s", name)
+	}
+}
+
+// RunInTx 在一个事务中执行 fn，fn 返回错误时回滚，否则提交
+// 死锁等可重试的错误会让整个事务从头重试，所以 fn 必须可以安全地重复执行
+func (d Dialect) RunInTx(ctx context.Context, conn *sql.DB, opts TxOptions, fn func(tx *sql.Tx) error) error {
+	txOpts := &sql.TxOptions{Isolation: opts.Isolation}
+	if d == SQLite {
+		// SQLite 的事务总是可串行化的，驱动不接受其它隔离级别
+		txOpts.Isolation = sql.LevelDefault
+	}
+
+	backoff := max(opts.RetryBackoff, 0)
+	for attempt := 0; ; attempt++ {
+		err := runOnce(ctx, conn, txOpts, fn)
+		if err == nil || attempt >= opts.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		log.Printf("retrying transaction after attempt %d: %v", attempt+1, err)
+		wait := backoff + rand.N(backoff+1)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func runOnce(ctx context.Context, conn *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunInSavepoint 在已有事务中用保存点执行 fn，fn 失败时只回滚到保存点，外层事务可以继续
+func RunInSavepoint(ctx context.Context, tx *sql.Tx, name string, fn func() error) (err error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint: %w", rbErr))
+		}
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
@@ -0,0 +1,74 @@
+// Package filestore 保存上传的文件（目前是产品图片），并生成访问它们的地址
+// 有本地文件系统和 S3 兼容对象存储两种实现，通过 IMAGE_STORAGE 选择
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Albert-tru/ecom/config"
+)
+
+// Storage 文件存储，key 是以 "/" 分隔的相对路径，如 "products/1/3f9a.jpg"
+type Storage interface {
+	// Put 保存文件，key 已存在时覆盖
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 返回访问文件的地址：公开地址，或者带有效期的签名地址
+	URL(key string) (string, error)
+}
+
+// ErrInvalidKey key 为空、以 "/" 开头或者包含 ".." 等路径片段
+var ErrInvalidKey = errors.New("filestore: invalid key")
+
+// Open 根据配置创建存储
+func Open(cfg config.Config) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.ImageStorage)) {
+	case "", "local":
+		baseURL := cfg.ImageBaseURL
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("%s:%s%s", cfg.PublicHost, cfg.Port, MediaPath)
+		}
+		return NewLocal(cfg.ImageLocalDir, baseURL, []byte(cfg.ImageURLSigningKey), cfg.ImageURLTTL), nil
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+			PublicURL:       cfg.S3PublicURL,
+			URLTTL:          cfg.ImageURLTTL,
+		})
+	default:
+		return nil, fmt.Errorf("filestore: unknown IMAGE_STORAGE %q, expected local or s3", cfg.ImageStorage)
+	}
+}
+
+// checkKey 拒绝可能逃出存储目录的 key
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "." || seg == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// escapeKey 逐段转义 key，保留分隔用的 "/"
+func escapeKey(key string) string {
+	segs := strings.Split(key, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,139 @@
+package filestore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/utils"
+)
+
+// MediaPath 本地存储的文件通过 API 服务的这个路径对外提供，见 Local.Handler
+const MediaPath = "/media"
+
+// Local 把文件保存在本地目录中
+// signingKey 为空时 URL 返回公开地址；否则返回带 expires 和 signature 参数的临时地址，由 Handler 校验
+type Local struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+func NewLocal(dir, baseURL string, signingKey []byte, ttl time.Duration) *Local {
+	return &Local{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), signingKey: signingKey, ttl: ttl, now: time.Now}
+}
+
+// Put 先写临时文件再重命名，读取方不会看到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	path := filepath.Join(l.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) URL(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	u := l.baseURL + "/" + escapeKey(key)
+	if len(l.signingKey) == 0 {
+		return u, nil
+	}
+	expires := strconv.FormatInt(l.now().Add(l.ttl).Unix(), 10)
+	return u + "?expires=" + expires + "&signature=" + l.sign(key, expires), nil
+}
+
+func (l *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler 对外提供文件，请求路径（去掉前缀之后）就是 key；开启签名时校验签名和有效期
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if checkKey(key) != nil {
+			utils.WriteError(w, r, apperr.NotFound("file_not_found", "file not found"))
+			return
+		}
+
+		if len(l.signingKey) > 0 {
+			q := r.URL.Query()
+			expires, signature := q.Get("expires"), q.Get("signature")
+			unix, err := strconv.ParseInt(expires, 10, 64)
+			if err != nil || !hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) {
+				utils.WriteError(w, r, apperr.Forbidden("invalid_signature", "the URL signature is missing or invalid"))
+				return
+			}
+			if l.now().Unix() > unix {
+				utils.WriteError(w, r, apperr.Forbidden("url_expired", "the URL has expired"))
+				return
+			}
+			w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(unix-l.now().Unix(), 0), 10))
+		} else {
+			// 文件名是随机生成的，内容不会变化
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		f, err := os.Open(filepath.Join(l.dir, filepath.FromSlash(key)))
+		if err != nil {
+			utils.WriteError(w, r, apperr.NotFound("file_not_found", "file not found"))
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			utils.WriteError(w, r, apperr.NotFound("file_not_found", "file not found"))
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
@@ -0,0 +1,94 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(t.TempDir(), "http://localhost:8080/media/", nil, time.Hour)
+	srv := http.StripPrefix(MediaPath, l.Handler())
+	get := func(rawURL string) *httptest.ResponseRecorder {
+		u, _ := url.Parse(rawURL)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		return rr
+	}
+
+	if err := l.Put(ctx, "products/1/a.png", []byte("png-bytes"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := l.URL("products/1/a.png")
+	if err != nil || u != "http://localhost:8080/media/products/1/a.png" {
+		t.Fatalf("unexpected URL: %s %v", u, err)
+	}
+	if rr := get(u); rr.Code != http.StatusOK || rr.Body.String() != "png-bytes" || rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("unexpected response: %d %q %s", rr.Code, rr.Body, rr.Header().Get("Content-Type"))
+	}
+
+	if err := l.Delete(ctx, "products/1/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if rr := get(u); rr.Code != http.StatusNotFound {
+		t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+	}
+	if err := l.Delete(ctx, "products/1/a.png"); err != nil {
+		t.Errorf("deleting a missing file should succeed, got %v", err)
+	}
+	// 目录不能被访问
+	if rr := get("/media/products/1"); rr.Code != http.StatusNotFound {
+		t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+	}
+
+	for _, key := range []string{"", "/abs", "../escape", "a/../../b", "a//b", `a\b`} {
+		if err := l.Put(ctx, key, nil, "image/png"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("expected %q to be rejected, got %v", key, err)
+		}
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLocal(t.TempDir(), "http://localhost:8080/media", []byte("secret"), time.Hour)
+	l.now = func() time.Time { return now }
+	srv := http.StripPrefix(MediaPath, l.Handler())
+	get := func(rawURL string) int {
+		u, _ := url.Parse(rawURL)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		return rr.Code
+	}
+
+	if err := l.Put(ctx, "products/1/a.png", []byte("png-bytes"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := l.URL("products/1/a.png")
+	if !strings.Contains(u, "expires=") || !strings.Contains(u, "signature=") {
+		t.Fatalf("expected a signed URL, got %s", u)
+	}
+
+	if code := get(u); code != http.StatusOK {
+		t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusOK, code)
+	}
+	if code := get("/media/products/1/a.png"); code != http.StatusForbidden {
+		t.Errorf("unsigned: 期望状态码 %d, 实际状态码 %d", http.StatusForbidden, code)
+	}
+	// 签名只对这个 key 有效
+	other := strings.Replace(u, "a.png", "b.png", 1)
+	if code := get(other); code != http.StatusForbidden {
+		t.Errorf("other key: 期望状态码 %d, 实际状态码 %d", http.StatusForbidden, code)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if code := get(u); code != http.StatusForbidden {
+		t.Errorf("expired: 期望状态码 %d, 实际状态码 %d", http.StatusForbidden, code)
+	}
+}
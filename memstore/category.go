@@ -0,0 +1,250 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// GetCategories 返回所有分类，按 sort_order、名称排序（与 SQL 实现一致）
+func (s *Store) GetCategories(ctx context.Context) ([]types.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := make([]types.Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		categories = append(categories, copyCategory(c))
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return categories, nil
+}
+
+func (s *Store) GetCategoryByID(ctx context.Context, id int) (*types.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, categoryNotFound(fmt.Sprintf("category ID %d not found", id))
+	}
+	c = copyCategory(c)
+	return &c, nil
+}
+
+func (s *Store) GetCategoryBySlug(ctx context.Context, slug string) (*types.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.categoryIDBySlug(slug)
+	if !ok {
+		return nil, categoryNotFound(fmt.Sprintf("category %q not found", slug))
+	}
+	c := copyCategory(s.categories[id])
+	return &c, nil
+}
+
+// CreateCategory 创建分类，slug 唯一，父分类必须存在（对应 SQL 中的外键）
+func (s *Store) CreateCategory(ctx context.Context, c *types.Category) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkCategory(c); err != nil {
+		return err
+	}
+
+	s.lastCategoryID++
+	stored := copyCategory(*c)
+	stored.ID = s.lastCategoryID
+	stored.CreatedAt = s.now()
+	stored.Children = nil
+	s.categories[stored.ID] = stored
+
+	c.ID = stored.ID
+	return nil
+}
+
+func (s *Store) UpdateCategory(ctx context.Context, c *types.Category) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.categories[c.ID]
+	if !ok {
+		return categoryNotFound(fmt.Sprintf("category ID %d not found", c.ID))
+	}
+	if err := s.checkCategory(c); err != nil {
+		return err
+	}
+
+	stored := copyCategory(*c)
+	stored.CreatedAt = existing.CreatedAt
+	stored.Children = nil
+	s.categories[c.ID] = stored
+	return nil
+}
+
+// DeleteCategory 删除分类及其和产品的关联，还有子分类时拒绝
+func (s *Store) DeleteCategory(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return apperr.Conflict("category_has_children", fmt.Sprintf("category ID %d still has subcategories", id))
+		}
+	}
+	if _, ok := s.categories[id]; !ok {
+		return categoryNotFound(fmt.Sprintf("category ID %d not found", id))
+	}
+
+	delete(s.categories, id)
+	for productID, ids := range s.productCategories {
+		if slices.Contains(ids, id) {
+			s.productCategories[productID] = slices.DeleteFunc(slices.Clone(ids), func(c int) bool { return c == id })
+		}
+	}
+	return nil
+}
+
+// SetProductCategories 用 categoryIDs 替换产品现有的分类
+func (s *Store) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return productNotFound(productID)
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(categoryIDs)))
+	for _, id := range ids {
+		if _, ok := s.categories[id]; !ok {
+			return categoryNotFound(fmt.Sprintf("some of the categories %v do not exist", ids))
+		}
+	}
+
+	if len(ids) == 0 {
+		delete(s.productCategories, productID)
+		return nil
+	}
+	s.productCategories[productID] = ids
+	return nil
+}
+
+func (s *Store) GetProductCategoryIDs(ctx context.Context, productID int) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := slices.Clone(s.productCategories[productID])
+	if ids == nil {
+		ids = []int{}
+	}
+	return ids, nil
+}
+
+// GetProductsByCategoryIDs 返回属于任意一个分类的产品，按ID排序
+func (s *Store) GetProductsByCategoryIDs(ctx context.Context, categoryIDs []int) ([]types.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := []types.Product{}
+	for productID, ids := range s.productCategories {
+		for _, id := range ids {
+			if slices.Contains(categoryIDs, id) {
+				products = append(products, s.products[productID])
+				break
+			}
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	s.applyPrices(products)
+	return products, nil
+}
+
+func (s *Store) GetCategoryIDsByProductIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := map[int][]int{}
+	for _, id := range productIDs {
+		if ids := s.productCategories[id]; len(ids) > 0 {
+			result[id] = slices.Clone(ids)
+		}
+	}
+	return result, nil
+}
+
+// 检查 slug 唯一和父分类存在，调用方需要持有写锁
+func (s *Store) checkCategory(c *types.Category) error {
+	if id, ok := s.categoryIDBySlug(c.Slug); ok && id != c.ID {
+		return apperr.Conflict("slug_taken", fmt.Sprintf("category slug %q already exists", c.Slug))
+	}
+	if c.ParentID != nil {
+		if _, ok := s.categories[*c.ParentID]; !ok {
+			return categoryNotFound(fmt.Sprintf("parent category ID %d not found", *c.ParentID))
+		}
+	}
+	return nil
+}
+
+func (s *Store) categoryIDBySlug(slug string) (int, bool) {
+	for id, c := range s.categories {
+		if c.Slug == slug {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ParentID 是指针，拷贝时也要复制一份，避免调用方修改到存储中的数据
+func copyCategory(c types.Category) types.Category {
+	if c.ParentID != nil {
+		parent := *c.ParentID
+		c.ParentID = &parent
+	}
+	return c
+}
+
+func categoryNotFound(message string) error {
+	return apperr.NotFound("category_not_found", message)
+}
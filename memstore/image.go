@@ -0,0 +1,137 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/image"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// GetImages 返回产品的图片，按 position 排序
+func (s *Store) GetImages(ctx context.Context, productID int) ([]types.ProductImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.productImages(productID), nil
+}
+
+func (s *Store) GetImage(ctx context.Context, id int) (*types.ProductImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	img, ok := s.images[id]
+	if !ok {
+		return nil, imageNotFound(id)
+	}
+	img = copyImage(img)
+	return &img, nil
+}
+
+func (s *Store) CreateImage(ctx context.Context, img *types.ProductImage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[img.ProductID]; !ok {
+		return productNotFound(img.ProductID)
+	}
+
+	s.lastImageID++
+	stored := copyImage(*img)
+	stored.ID = s.lastImageID
+	stored.Position = len(s.productImages(img.ProductID))
+	stored.CreatedAt = s.now()
+	s.images[stored.ID] = stored
+
+	img.ID = stored.ID
+	img.Position = stored.Position
+	img.CreatedAt = stored.CreatedAt
+	return nil
+}
+
+// DeleteImage 删除图片，排在它后面的图片依次前移
+func (s *Store) DeleteImage(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, ok := s.images[id]
+	if !ok {
+		return imageNotFound(id)
+	}
+	delete(s.images, id)
+	for otherID, other := range s.images {
+		if other.ProductID == img.ProductID && other.Position > img.Position {
+			other.Position--
+			s.images[otherID] = other
+		}
+	}
+	return nil
+}
+
+func (s *Store) ReorderImages(ctx context.Context, productID int, imageIDs []int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current []int
+	for _, img := range s.productImages(productID) {
+		current = append(current, img.ID)
+	}
+	if err := image.CheckOrder(productID, current, imageIDs); err != nil {
+		return err
+	}
+	for i, id := range imageIDs {
+		img := s.images[id]
+		img.Position = i
+		s.images[id] = img
+	}
+	return nil
+}
+
+// 调用方需要持有锁
+func (s *Store) productImages(productID int) []types.ProductImage {
+	imgs := []types.ProductImage{}
+	for _, img := range s.images {
+		if img.ProductID == productID {
+			imgs = append(imgs, copyImage(img))
+		}
+	}
+	sort.Slice(imgs, func(i, j int) bool {
+		if imgs[i].Position != imgs[j].Position {
+			return imgs[i].Position < imgs[j].Position
+		}
+		return imgs[i].ID < imgs[j].ID
+	})
+	return imgs
+}
+
+// 缩略图按边长排序，与 SQL 实现一致
+func copyImage(img types.ProductImage) types.ProductImage {
+	img.Thumbnails = slices.Clone(img.Thumbnails)
+	if img.Thumbnails == nil {
+		img.Thumbnails = []types.ImageThumbnail{}
+	}
+	sort.Slice(img.Thumbnails, func(i, j int) bool { return img.Thumbnails[i].Size < img.Thumbnails[j].Size })
+	return img
+}
+
+func imageNotFound(id int) error {
+	return apperr.NotFound("image_not_found", fmt.Sprintf("image ID %d not found", id))
+}
@@ -0,0 +1,528 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/service/search"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Store 内存中的存储实现，同时实现 UserStore、ProductStore、VariantStore、OrderStore、CategoryStore、ImageStore、ReviewStore、WishlistStore、StockStore 和 TxManager
+// 行为与 SQL 实现保持一致：邮箱唯一、ID 自增、下单时检查并扣减变体的库存
+// 所有方法都是并发安全的，返回的都是数据的拷贝
+type Store struct {
+	mu sync.RWMutex
+	state
+
+	now func() time.Time
+}
+
+// state 存储的全部数据，事务中会整体复制一份
+type state struct {
+	users       map[int]types.User
+	userByEmail map[string]int
+	products    map[int]types.Product
+	// 变体的 Options 修改时整体替换，不会原地修改，所以浅拷贝 map 即可
+	variants       map[int]types.ProductVariant
+	productOptions map[int][]string
+	orders         map[int]types.Order
+	orderItems     []types.OrderItem
+	categories     map[int]types.Category
+	// 产品ID -> 分类ID（有序），修改时整体替换切片，所以浅拷贝 map 即可
+	productCategories map[int][]int
+	// 图片的 Thumbnails 只在创建时写入，之后不会修改
+	images  map[int]types.ProductImage
+	reviews map[int]types.Review
+	// 心愿单中的 Items 不保存在 wishlists 里，查询时从 wishlistItems 中组装
+	wishlists     map[int]types.Wishlist
+	wishlistItems map[int]types.WishlistItem
+	// 库存流水只追加，按ID（即追加的顺序）排列
+	stockMovements []types.StockMovement
+	// 产品ID -> 补货阈值，没有设置的产品阈值为 0
+	reorderThresholds  map[int]int
+	stockSubscriptions map[int]types.StockSubscription
+	// 价格记录的指针字段修改时整体替换，所以浅拷贝 map 即可
+	prices map[int]types.ProductPrice
+
+	lastUserID      int
+	lastProductID   int
+	lastVariantID   int
+	lastOrderID     int
+	lastOrderItemID int
+	lastCategoryID  int
+	lastImageID     int
+	lastReviewID    int
+	lastWishlistID  int
+	lastWishItemID  int
+	lastMovementID  int
+	lastSubID       int
+	lastPriceID     int
+}
+
+func (st *state) clone() state {
+	cp := *st
+	cp.users = maps.Clone(st.users)
+	cp.userByEmail = maps.Clone(st.userByEmail)
+	cp.products = maps.Clone(st.products)
+	cp.variants = maps.Clone(st.variants)
+	cp.productOptions = maps.Clone(st.productOptions)
+	cp.orders = maps.Clone(st.orders)
+	cp.orderItems = slices.Clone(st.orderItems)
+	cp.categories = maps.Clone(st.categories)
+	cp.productCategories = maps.Clone(st.productCategories)
+	cp.images = maps.Clone(st.images)
+	cp.reviews = maps.Clone(st.reviews)
+	cp.wishlists = maps.Clone(st.wishlists)
+	cp.wishlistItems = maps.Clone(st.wishlistItems)
+	cp.stockMovements = slices.Clone(st.stockMovements)
+	cp.reorderThresholds = maps.Clone(st.reorderThresholds)
+	cp.stockSubscriptions = maps.Clone(st.stockSubscriptions)
+	cp.prices = maps.Clone(st.prices)
+	return cp
+}
+
+// New 创建一个空的内存存储，可以同时放入初始产品
+func New(products ...types.Product) *Store {
+	s := &Store{
+		state: state{
+			users:       map[int]types.User{},
+			userByEmail: map[string]int{},
+			products:    map[int]types.Product{},
+			orders:      map[int]types.Order{},
+			categories:  map[int]types.Category{},
+
+			variants:           map[int]types.ProductVariant{},
+			productOptions:     map[int][]string{},
+			productCategories:  map[int][]int{},
+			images:             map[int]types.ProductImage{},
+			reviews:            map[int]types.Review{},
+			wishlists:          map[int]types.Wishlist{},
+			wishlistItems:      map[int]types.WishlistItem{},
+			reorderThresholds:  map[int]int{},
+			stockSubscriptions: map[int]types.StockSubscription{},
+			prices:             map[int]types.ProductPrice{},
+		},
+		now: time.Now,
+	}
+	for _, p := range products {
+		s.AddProduct(p)
+	}
+	return s
+}
+
+// Stores 以 types.Stores 的形式返回，方便替换 SQL 实现
+func (s *Store) Stores() types.Stores {
+	return types.Stores{
+		Users: s, Products: s, Variants: s, Orders: s, Categories: s, Images: s, Reviews: s, Wishlists: s, Stock: s,
+		Search: search.NewIndex(s), Tx: s,
+	}
+}
+
+// WithinTx 在数据的副本上执行 fn，成功后整体替换，失败时丢弃副本
+// 事务期间持有写锁，其它操作会等待，相当于可串行化的隔离级别；
+// 因此 fn 中只能使用 tx 里的 store，不能再访问外层的 Store
+func (s *Store) WithinTx(ctx context.Context, fn func(tx types.Stores) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	child := &Store{state: s.state.clone(), now: s.now}
+	if err := fn(child.Stores()); err != nil {
+		return err
+	}
+	s.state = child.state
+	return nil
+}
+
+// AddProduct 添加一个产品（以及它的默认变体）并返回分配的ID；p.ID 不为 0 时沿用该ID
+func (s *Store) AddProduct(p types.Product) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.ID == 0 {
+		p.ID = s.lastProductID + 1
+	}
+	s.lastProductID = max(s.lastProductID, p.ID)
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = s.now()
+	}
+	s.products[p.ID] = p
+	s.addDefaultVariant(p)
+	s.addPrice(&types.ProductPrice{ProductID: p.ID, Price: p.Price, EffectiveFrom: p.CreatedAt})
+	return p.ID
+}
+
+// OrderItems 返回某个订单的所有订单项，主要用于测试中的断言
+func (s *Store) OrderItems(orderID int) []types.OrderItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []types.OrderItem
+	for _, oi := range s.orderItems {
+		if oi.OrderID == orderID {
+			items = append(items, oi)
+		}
+	}
+	return items
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.userByEmail[email]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	u := s.users[id]
+	return &u, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id int) (*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	return &u, nil
+}
+
+// CreateUser 创建用户，并把分配的ID写回 u.ID
+func (s *Store) CreateUser(ctx context.Context, u *types.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByEmail[u.Email]; ok {
+		return apperr.Conflict("email_taken", fmt.Sprintf("user with email %s already exists", u.Email))
+	}
+
+	if u.Role == "" {
+		u.Role = types.RoleCustomer
+	}
+	s.lastUserID++
+	stored := *u
+	stored.ID = s.lastUserID
+	stored.CreatedAt = s.now()
+	s.users[stored.ID] = stored
+	s.userByEmail[stored.Email] = stored.ID
+
+	u.ID = stored.ID
+	return nil
+}
+
+// GetUsers 按ID顺序返回所有用户
+func (s *Store) GetUsers(ctx context.Context) ([]types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]types.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	return s.updateUser(ctx, id, func(u *types.User) { u.Password = hashedPassword })
+}
+
+func (s *Store) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return s.updateUser(ctx, id, func(u *types.User) { u.Disabled = disabled })
+}
+
+func (s *Store) updateUser(ctx context.Context, id int, update func(u *types.User)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errUserNotFound
+	}
+	update(&u)
+	s.users[id] = u
+	return nil
+}
+
+func (s *Store) GetProducts(ctx context.Context) ([]types.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := make([]types.Product, 0, len(s.products))
+	for _, p := range s.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	s.applyPrices(products)
+	return products, nil
+}
+
+func (s *Store) GetProductsAfter(ctx context.Context, afterID, limit int) ([]types.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := []types.Product{}
+	for _, p := range s.products {
+		if p.ID > afterID {
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	if len(products) > limit {
+		products = products[:limit]
+	}
+	s.applyPrices(products)
+	return products, nil
+}
+
+// GetProductByIDs 返回存在的那部分产品，不存在的ID直接忽略（与 SQL 的 IN 查询一致）
+func (s *Store) GetProductByIDs(ctx context.Context, ids []int) ([]types.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := []types.Product{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if p, ok := s.products[id]; ok && !seen[id] {
+			seen[id] = true
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	s.applyPrices(products)
+	return products, nil
+}
+
+// CreateProduct 创建产品和它的默认变体，并把分配的ID写回 p.ID；p.ID 不为 0 时使用指定的ID
+func (s *Store) CreateProduct(ctx context.Context, p *types.Product) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[p.ID]; ok {
+		return apperr.Conflict("product_exists", fmt.Sprintf("product ID %d already exists", p.ID))
+	}
+
+	stored := *p
+	if stored.ID == 0 {
+		stored.ID = s.lastProductID + 1
+	}
+	if s.skuTaken(variant.DefaultSKU(stored.ID), 0) {
+		return skuTaken(variant.DefaultSKU(stored.ID))
+	}
+	s.lastProductID = max(s.lastProductID, stored.ID)
+	// 评分只由评价同步
+	stored.RatingAverage, stored.RatingCount = 0, 0
+	stored.CreatedAt = s.now()
+	s.products[stored.ID] = stored
+	s.addDefaultVariant(stored)
+	s.addPrice(&types.ProductPrice{ProductID: stored.ID, Price: stored.Price, EffectiveFrom: stored.CreatedAt})
+
+	p.ID = stored.ID
+	return nil
+}
+
+// UpdateProduct 修改产品的名称、描述、图片和价格，不修改库存；价格与当前售价不同时记录一条从现在开始的长期价格
+func (s *Store) UpdateProduct(ctx context.Context, p *types.Product) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.products[p.ID]
+	if !ok {
+		return productNotFound(p.ID)
+	}
+	stored.Name = p.Name
+	stored.Description = p.Description
+	stored.ImageURL = p.ImageURL
+	if !pricing.SamePrice(s.currentPrice(p.ID), p.Price) {
+		stored.Price = p.Price
+		s.addPrice(&types.ProductPrice{ProductID: p.ID, Price: p.Price, EffectiveFrom: s.now()})
+	}
+	s.products[p.ID] = stored
+	return nil
+}
+
+// AdjustStock 调整只有一个变体的产品的库存，产品有多个变体时返回 variant_required
+func (s *Store) AdjustStock(ctx context.Context, id, delta int, change types.StockChange) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	variantID, err := s.soleVariantID(id)
+	if err != nil {
+		return 0, err
+	}
+	return s.adjustVariant(variantID, delta, change)
+}
+
+// CreateOrder 创建订单，用户必须存在（对应 SQL 中的外键）
+func (s *Store) CreateOrder(ctx context.Context, o types.Order) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[o.UserID]; !ok {
+		return 0, errUserNotFound
+	}
+
+	s.lastOrderID++
+	o.ID = s.lastOrderID
+	o.CreatedAt = s.now()
+	s.orders[o.ID] = o
+	return o.ID, nil
+}
+
+// CreateOrderItem 创建订单项，并扣减对应变体和产品的库存；oi.VariantID 为 0 时使用产品唯一的变体
+func (s *Store) CreateOrderItem(ctx context.Context, oi types.OrderItem) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[oi.OrderID]; !ok {
+		return orderNotFound(oi.OrderID)
+	}
+	if oi.VariantID == 0 {
+		id, err := s.soleVariantID(oi.ProductID)
+		if err != nil {
+			return err
+		}
+		oi.VariantID = id
+	}
+	if v, ok := s.variants[oi.VariantID]; !ok || v.ProductID != oi.ProductID {
+		return variantNotFound(fmt.Sprintf("variant ID %d of product ID %d not found", oi.VariantID, oi.ProductID))
+	}
+	// 对应 SQL 中的外键
+	if oi.PriceID != nil {
+		if _, ok := s.prices[*oi.PriceID]; !ok {
+			return pricing.NotFound(*oi.PriceID)
+		}
+	}
+	change := types.StockChange{
+		Type:    types.StockSale,
+		Reason:  fmt.Sprintf("order %d", oi.OrderID),
+		ActorID: s.orders[oi.OrderID].UserID,
+		OrderID: oi.OrderID,
+	}
+	if _, err := s.adjustVariant(oi.VariantID, -oi.Quantity, change); err != nil {
+		return err
+	}
+
+	s.lastOrderItemID++
+	oi.ID = s.lastOrderItemID
+	oi.CreatedAt = s.now()
+	s.orderItems = append(s.orderItems, oi)
+	return nil
+}
+
+func (s *Store) GetOrderByID(ctx context.Context, id int) (*types.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return nil, orderNotFound(id)
+	}
+	return &o, nil
+}
+
+// GetOrderItems 获取订单的所有订单项，按ID排序
+func (s *Store) GetOrderItems(ctx context.Context, orderID int) ([]types.OrderItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	items := s.OrderItems(orderID)
+	if items == nil {
+		items = []types.OrderItem{}
+	}
+	return items, nil
+}
+
+// UpdateOrderStatus 直接修改订单状态，不检查状态流转是否合法
+func (s *Store) UpdateOrderStatus(ctx context.Context, id int, status string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return orderNotFound(id)
+	}
+	o.Status = status
+	s.orders[id] = o
+	return nil
+}
+
+// HasPurchased 用户是否有包含该产品的已送达订单
+func (s *Store) HasPurchased(ctx context.Context, userID, productID int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, oi := range s.orderItems {
+		if o := s.orders[oi.OrderID]; oi.ProductID == productID && o.UserID == userID && o.Status == types.OrderDelivered {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var errUserNotFound = apperr.NotFound("user_not_found", "未找到用户")
+
+func orderNotFound(id int) error {
+	return apperr.NotFound("order_not_found", fmt.Sprintf("order ID %d not found", id))
+}
@@ -0,0 +1,91 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 并发下单时库存不能被扣成负数，成功的订单项数量必须等于初始库存
+func TestConcurrentCheckout(t *testing.T) {
+	ctx := context.Background()
+	s := New(types.Product{Name: "keyboard", Price: 10, Quantity: 20})
+	productID := 1
+
+	u := &types.User{Email: "buyer@example.com"}
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sold     int
+		rejected int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orderID, err := s.CreateOrder(ctx, types.Order{UserID: u.ID, Status: "pending"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			err = s.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: productID, Quantity: 1})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				sold++
+			case apperr.IsKind(err, apperr.KindConflict):
+				rejected++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sold != 20 || rejected != 30 {
+		t.Errorf("expected 20 sold and 30 rejected, got %d and %d", sold, rejected)
+	}
+	ps, _ := s.GetProductByIDs(ctx, []int{productID})
+	if ps[0].Quantity != 0 {
+		t.Errorf("expected stock 0, got %d", ps[0].Quantity)
+	}
+}
+
+// 并发注册同一个邮箱时只能有一个成功
+func TestConcurrentUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- s.CreateUser(ctx, &types.User{Firstname: fmt.Sprint(i), Email: "same@example.com"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		} else if !apperr.IsKind(err, apperr.KindConflict) {
+			t.Error(err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one user, got %d", created)
+	}
+}
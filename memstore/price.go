@@ -0,0 +1,133 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func (s *Store) GetPrices(ctx context.Context, productID int) ([]types.ProductPrice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pricesByProduct()[productID], nil
+}
+
+func (s *Store) ResolvePrices(ctx context.Context, productIDs []int, at time.Time) (map[int]types.ProductPrice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byProduct := s.pricesByProduct()
+	resolved := map[int]types.ProductPrice{}
+	for _, id := range productIDs {
+		if p, ok := pricing.Effective(byProduct[id], at); ok {
+			resolved[id] = p
+		}
+	}
+	return resolved, nil
+}
+
+func (s *Store) CreatePrice(ctx context.Context, price *types.ProductPrice) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[price.ProductID]; !ok {
+		return productNotFound(price.ProductID)
+	}
+	s.addPrice(price)
+	return nil
+}
+
+func (s *Store) EndPrice(ctx context.Context, id int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prices[id]
+	if !ok {
+		return pricing.NotFound(id)
+	}
+	at = at.UTC()
+	p.EffectiveTo = &at
+	s.prices[id] = p
+	return nil
+}
+
+func (s *Store) DeletePrice(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prices[id]; !ok {
+		return pricing.NotFound(id)
+	}
+	delete(s.prices, id)
+	return nil
+}
+
+// 写入价格记录并写回分配的ID；调用方需要持有写锁
+func (s *Store) addPrice(price *types.ProductPrice) {
+	s.lastPriceID++
+	price.ID = s.lastPriceID
+	price.CreatedAt = s.now()
+	s.prices[price.ID] = copyPrice(*price)
+}
+
+// 按产品分组的价格记录（副本），按生效时间排序；调用方需要持有锁
+func (s *Store) pricesByProduct() map[int][]types.ProductPrice {
+	byProduct := map[int][]types.ProductPrice{}
+	for _, p := range s.prices {
+		byProduct[p.ProductID] = append(byProduct[p.ProductID], copyPrice(p))
+	}
+	for _, ps := range byProduct {
+		pricing.Sort(ps)
+	}
+	return byProduct
+}
+
+// 把产品的售价替换为当前生效的价格，与 pricing.Apply 一致；调用方需要持有锁
+func (s *Store) applyPrices(products []types.Product) {
+	byProduct := s.pricesByProduct()
+	now := s.now()
+	for i := range products {
+		if p, ok := pricing.Effective(byProduct[products[i].ID], now); ok {
+			pricing.SetPrice(&products[i], p)
+		}
+	}
+}
+
+// 当前售价与 UpdateProduct 比较时使用；调用方需要持有锁
+func (s *Store) currentPrice(productID int) float64 {
+	if p, ok := pricing.Effective(s.pricesByProduct()[productID], s.now()); ok {
+		return p.Price
+	}
+	return s.products[productID].Price
+}
+
+// 返回的价格记录不能和存储中的共用指针
+func copyPrice(p types.ProductPrice) types.ProductPrice {
+	if p.CompareAtPrice != nil {
+		v := *p.CompareAtPrice
+		p.CompareAtPrice = &v
+	}
+	if p.EffectiveTo != nil {
+		t := *p.EffectiveTo
+		p.EffectiveTo = &t
+	}
+	return p
+}
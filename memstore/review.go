@@ -0,0 +1,156 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// GetReviews 返回产品的评价，status 为空时返回所有状态，按创建时间倒序
+func (s *Store) GetReviews(ctx context.Context, productID int, status string) ([]types.Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rs := s.filterReviews(func(r types.Review) bool {
+		return r.ProductID == productID && (status == "" || r.Status == status)
+	})
+	sort.Slice(rs, func(i, j int) bool {
+		if !rs[i].CreatedAt.Equal(rs[j].CreatedAt) {
+			return rs[i].CreatedAt.After(rs[j].CreatedAt)
+		}
+		return rs[i].ID > rs[j].ID
+	})
+	return rs, nil
+}
+
+// GetReviewsByStatus 返回某个状态的评价，按创建时间正序
+func (s *Store) GetReviewsByStatus(ctx context.Context, status string) ([]types.Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rs := s.filterReviews(func(r types.Review) bool { return r.Status == status })
+	sort.Slice(rs, func(i, j int) bool {
+		if !rs[i].CreatedAt.Equal(rs[j].CreatedAt) {
+			return rs[i].CreatedAt.Before(rs[j].CreatedAt)
+		}
+		return rs[i].ID < rs[j].ID
+	})
+	return rs, nil
+}
+
+func (s *Store) GetReviewByID(ctx context.Context, id int) (*types.Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.reviews[id]
+	if !ok {
+		return nil, reviewNotFound(id)
+	}
+	return &r, nil
+}
+
+// CreateReview 创建评价，r.Status 为空时按 pending 创建
+func (s *Store) CreateReview(ctx context.Context, r *types.Review) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[r.ProductID]; !ok {
+		return productNotFound(r.ProductID)
+	}
+	for _, existing := range s.reviews {
+		if existing.ProductID == r.ProductID && existing.UserID == r.UserID {
+			return apperr.Conflict("review_exists",
+				fmt.Sprintf("you have already reviewed product ID %d, edit the existing review instead", r.ProductID))
+		}
+	}
+
+	s.lastReviewID++
+	stored := *r
+	stored.ID = s.lastReviewID
+	if stored.Status == "" {
+		stored.Status = types.ReviewPending
+	}
+	stored.CreatedAt = s.now()
+	stored.UpdatedAt = stored.CreatedAt
+	s.reviews[stored.ID] = stored
+	s.refreshRating(stored.ProductID)
+
+	r.ID = stored.ID
+	r.Status = stored.Status
+	r.CreatedAt = stored.CreatedAt
+	r.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// UpdateReview 修改评分、标题、正文和审核状态，并重新计算产品的评分
+func (s *Store) UpdateReview(ctx context.Context, r *types.Review) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.reviews[r.ID]
+	if !ok {
+		return reviewNotFound(r.ID)
+	}
+	stored.Rating = r.Rating
+	stored.Title = r.Title
+	stored.Body = r.Body
+	stored.Status = r.Status
+	stored.UpdatedAt = s.now()
+	s.reviews[r.ID] = stored
+	s.refreshRating(stored.ProductID)
+	return nil
+}
+
+// 调用方需要持有锁
+func (s *Store) filterReviews(keep func(types.Review) bool) []types.Review {
+	rs := []types.Review{}
+	for _, r := range s.reviews {
+		if keep(r) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// refreshRating 根据已通过审核的评价重新计算产品的平均分和评价数，调用方需要持有写锁
+func (s *Store) refreshRating(productID int) {
+	p, ok := s.products[productID]
+	if !ok {
+		return
+	}
+	sum, count := 0, 0
+	for _, r := range s.reviews {
+		if r.ProductID == productID && r.Status == types.ReviewApproved {
+			sum += r.Rating
+			count++
+		}
+	}
+	p.RatingAverage, p.RatingCount = 0, count
+	if count > 0 {
+		p.RatingAverage = math.Round(float64(sum)/float64(count)*100) / 100
+	}
+	s.products[productID] = p
+}
+
+func reviewNotFound(id int) error {
+	return apperr.NotFound("review_not_found", fmt.Sprintf("review ID %d not found", id))
+}
@@ -0,0 +1,190 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func (s *Store) GetStockMovements(ctx context.Context, productID int) ([]types.StockMovement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ms := []types.StockMovement{}
+	for i := len(s.stockMovements) - 1; i >= 0; i-- {
+		if m := s.stockMovements[i]; m.ProductID == productID {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// GetStockBalances 没有流水的变体累计值为 0
+func (s *Store) GetStockBalances(ctx context.Context) ([]types.StockBalance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ledger := map[int]int{}
+	for _, m := range s.stockMovements {
+		ledger[m.VariantID] += m.Delta
+	}
+	bs := []types.StockBalance{}
+	for _, v := range s.variants {
+		bs = append(bs, types.StockBalance{ProductID: v.ProductID, VariantID: v.ID, SKU: v.SKU, Quantity: v.Quantity, Ledger: ledger[v.ID]})
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].VariantID < bs[j].VariantID })
+	return bs, nil
+}
+
+func (s *Store) RecordStockMovement(ctx context.Context, m *types.StockMovement) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 对应 SQL 中的外键
+	if _, ok := s.variants[m.VariantID]; !ok {
+		return variantNotFound(fmt.Sprintf("variant ID %d not found", m.VariantID))
+	}
+	s.recordMovement(m)
+	return nil
+}
+
+// 追加一条流水并写回分配的ID，调用方需要持有写锁
+func (s *Store) recordMovement(m *types.StockMovement) {
+	s.lastMovementID++
+	m.ID = s.lastMovementID
+	m.CreatedAt = s.now()
+	s.stockMovements = append(s.stockMovements, *m)
+}
+
+func (s *Store) GetReorderThresholds(ctx context.Context, productIDs []int) (map[int]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thresholds := map[int]int{}
+	for _, id := range productIDs {
+		if _, ok := s.products[id]; ok {
+			thresholds[id] = s.reorderThresholds[id]
+		}
+	}
+	return thresholds, nil
+}
+
+func (s *Store) SetReorderThreshold(ctx context.Context, productID, threshold int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return productNotFound(productID)
+	}
+	s.reorderThresholds[productID] = threshold
+	return nil
+}
+
+func (s *Store) CreateStockSubscription(ctx context.Context, sub *types.StockSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 对应 SQL 中的外键
+	if _, ok := s.users[sub.UserID]; !ok {
+		return errUserNotFound
+	}
+	if _, ok := s.products[sub.ProductID]; !ok {
+		return productNotFound(sub.ProductID)
+	}
+	if sub.VariantID != nil {
+		if _, ok := s.variants[*sub.VariantID]; !ok {
+			return variantNotFound(fmt.Sprintf("variant ID %d not found", *sub.VariantID))
+		}
+	}
+	for _, existing := range s.stockSubscriptions {
+		if existing.UserID == sub.UserID && existing.ProductID == sub.ProductID && existing.NotifiedAt == nil && sameVariant(existing.VariantID, sub.VariantID) {
+			return inventory.SubscriptionExists(sub.ProductID)
+		}
+	}
+
+	s.lastSubID++
+	sub.ID = s.lastSubID
+	sub.NotifiedAt = nil
+	sub.CreatedAt = s.now()
+	s.stockSubscriptions[sub.ID] = *sub
+	return nil
+}
+
+func (s *Store) GetPendingStockSubscriptions(ctx context.Context) ([]types.StockSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := []types.StockSubscription{}
+	for _, sub := range s.stockSubscriptions {
+		if sub.NotifiedAt == nil {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+func (s *Store) ClaimStockSubscription(ctx context.Context, id int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.stockSubscriptions[id]
+	if !ok {
+		return false, subscriptionNotFound(id)
+	}
+	if sub.NotifiedAt != nil {
+		return false, nil
+	}
+	now := s.now()
+	sub.NotifiedAt = &now
+	s.stockSubscriptions[id] = sub
+	return true, nil
+}
+
+func (s *Store) ReleaseStockSubscription(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.stockSubscriptions[id]
+	if !ok {
+		return subscriptionNotFound(id)
+	}
+	sub.NotifiedAt = nil
+	s.stockSubscriptions[id] = sub
+	return nil
+}
+
+func subscriptionNotFound(id int) error {
+	return apperr.NotFound("subscription_not_found", fmt.Sprintf("stock subscription ID %d not found", id))
+}
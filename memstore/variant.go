@@ -0,0 +1,290 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func (s *Store) GetOptions(ctx context.Context, productID int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := slices.Clone(s.productOptions[productID])
+	if names == nil {
+		names = []string{}
+	}
+	return names, nil
+}
+
+// SetOptions 替换产品的规格类型，被删除的规格在各个变体上的取值也会删除
+func (s *Store) SetOptions(ctx context.Context, productID int, names []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return productNotFound(productID)
+	}
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			return apperr.Validation("duplicate_option", fmt.Sprintf("option %q is listed more than once", name))
+		}
+	}
+
+	for id, v := range s.variants {
+		if v.ProductID != productID {
+			continue
+		}
+		options := maps.Clone(v.Options)
+		maps.DeleteFunc(options, func(name, _ string) bool { return !slices.Contains(names, name) })
+		v.Options = options
+		s.variants[id] = v
+	}
+	s.productOptions[productID] = slices.Clone(names)
+	return nil
+}
+
+// GetVariants 返回产品的所有变体，按ID排序
+func (s *Store) GetVariants(ctx context.Context, productID int) ([]types.ProductVariant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variants := []types.ProductVariant{}
+	for _, v := range s.variants {
+		if v.ProductID == productID {
+			variants = append(variants, copyVariant(v))
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+// GetVariantsByIDs 返回存在的那部分变体，不存在的ID直接忽略（与 SQL 的 IN 查询一致）
+func (s *Store) GetVariantsByIDs(ctx context.Context, ids []int) ([]types.ProductVariant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variants := []types.ProductVariant{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if v, ok := s.variants[id]; ok && !seen[id] {
+			seen[id] = true
+			variants = append(variants, copyVariant(v))
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+func (s *Store) GetVariantsByProductIDs(ctx context.Context, productIDs []int) ([]types.ProductVariant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variants := []types.ProductVariant{}
+	for _, v := range s.variants {
+		if slices.Contains(productIDs, v.ProductID) {
+			variants = append(variants, copyVariant(v))
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		if variants[i].ProductID != variants[j].ProductID {
+			return variants[i].ProductID < variants[j].ProductID
+		}
+		return variants[i].ID < variants[j].ID
+	})
+	return variants, nil
+}
+
+func (s *Store) GetVariantBySKU(ctx context.Context, sku string) (*types.ProductVariant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.variants {
+		if v.SKU == sku {
+			v = copyVariant(v)
+			return &v, nil
+		}
+	}
+	return nil, variantNotFound(fmt.Sprintf("variant with SKU %q not found", sku))
+}
+
+// CreateVariant 创建变体，SKU 唯一，产品必须存在；变体的初始库存同时计入产品的库存
+func (s *Store) CreateVariant(ctx context.Context, v *types.ProductVariant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[v.ProductID]
+	if !ok {
+		return productNotFound(v.ProductID)
+	}
+	if s.skuTaken(v.SKU, 0) {
+		return skuTaken(v.SKU)
+	}
+
+	s.lastVariantID++
+	stored := copyVariant(*v)
+	stored.ID = s.lastVariantID
+	stored.CreatedAt = s.now()
+	s.variants[stored.ID] = stored
+	p.Quantity += stored.Quantity
+	s.products[p.ID] = p
+	if stored.Quantity != 0 {
+		s.recordMovement(inventory.Movement(p.ID, stored.ID, stored.Quantity, stored.Quantity, inventory.InitialStock))
+	}
+
+	v.ID = stored.ID
+	return nil
+}
+
+// UpdateVariant 修改变体的 SKU、价格、图片和规格取值，不修改库存
+func (s *Store) UpdateVariant(ctx context.Context, v *types.ProductVariant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.variants[v.ID]
+	if !ok {
+		return variantNotFound(fmt.Sprintf("variant ID %d not found", v.ID))
+	}
+	if s.skuTaken(v.SKU, v.ID) {
+		return skuTaken(v.SKU)
+	}
+
+	updated := copyVariant(*v)
+	stored.SKU = updated.SKU
+	stored.Price = updated.Price
+	stored.ImageURL = updated.ImageURL
+	stored.Options = updated.Options
+	s.variants[v.ID] = stored
+	return nil
+}
+
+// AdjustVariantStock 把变体的库存增加 delta 并返回调整后的库存，产品的总库存同步调整
+func (s *Store) AdjustVariantStock(ctx context.Context, variantID, delta int, change types.StockChange) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.adjustVariant(variantID, delta, change)
+}
+
+// 调整库存并记录流水，delta 为 0 时不记；调用方需要持有写锁
+func (s *Store) adjustVariant(variantID, delta int, change types.StockChange) (int, error) {
+	v, ok := s.variants[variantID]
+	if !ok {
+		return 0, variantNotFound(fmt.Sprintf("variant ID %d not found", variantID))
+	}
+	if v.Quantity+delta < 0 {
+		return 0, apperr.Conflict("insufficient_stock", fmt.Sprintf("insufficient stock for variant ID %d", variantID))
+	}
+	v.Quantity += delta
+	s.variants[variantID] = v
+
+	p := s.products[v.ProductID]
+	p.Quantity += delta
+	s.products[p.ID] = p
+	if delta != 0 {
+		s.recordMovement(inventory.Movement(p.ID, variantID, delta, v.Quantity, change))
+	}
+	return v.Quantity, nil
+}
+
+// 产品创建时生成默认变体，承接产品的初始库存；调用方需要持有写锁
+func (s *Store) addDefaultVariant(p types.Product) {
+	s.lastVariantID++
+	s.variants[s.lastVariantID] = types.ProductVariant{
+		ID:        s.lastVariantID,
+		ProductID: p.ID,
+		SKU:       variant.DefaultSKU(p.ID),
+		Quantity:  p.Quantity,
+		Options:   map[string]string{},
+		CreatedAt: p.CreatedAt,
+	}
+	if p.Quantity != 0 {
+		s.recordMovement(inventory.Movement(p.ID, s.lastVariantID, p.Quantity, p.Quantity, inventory.InitialStock))
+	}
+}
+
+// 与 variant.SoleVariantID 一致；调用方需要持有锁
+func (s *Store) soleVariantID(productID int) (int, error) {
+	var ids []int
+	for id, v := range s.variants {
+		if v.ProductID == productID {
+			ids = append(ids, id)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return 0, productNotFound(productID)
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, variant.VariantRequired(productID)
+	}
+}
+
+func (s *Store) skuTaken(sku string, exceptID int) bool {
+	for id, v := range s.variants {
+		if v.SKU == sku && id != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回的变体不能和存储中的共用 Options
+func copyVariant(v types.ProductVariant) types.ProductVariant {
+	v.Options = maps.Clone(v.Options)
+	if v.Options == nil {
+		v.Options = map[string]string{}
+	}
+	if v.Price != nil {
+		price := *v.Price
+		v.Price = &price
+	}
+	return v
+}
+
+func productNotFound(id int) error {
+	return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", id))
+}
+
+func variantNotFound(message string) error {
+	return apperr.NotFound("variant_not_found", message)
+}
+
+func skuTaken(sku string) error {
+	return apperr.Conflict("sku_taken", fmt.Sprintf("SKU %q already exists", sku))
+}
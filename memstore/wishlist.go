@@ -0,0 +1,220 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/wishlist"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func (s *Store) GetWishlists(ctx context.Context, userID int) ([]types.Wishlist, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ws := []types.Wishlist{}
+	for _, w := range s.wishlists {
+		if w.UserID == userID {
+			ws = append(ws, s.withItems(w))
+		}
+	}
+	sort.Slice(ws, func(i, j int) bool { return ws[i].ID < ws[j].ID })
+	return ws, nil
+}
+
+func (s *Store) GetWishlist(ctx context.Context, id int) (*types.Wishlist, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w, ok := s.wishlists[id]
+	if !ok {
+		return nil, wishlistNotFound(id)
+	}
+	w = s.withItems(w)
+	return &w, nil
+}
+
+func (s *Store) GetWishlistByShareToken(ctx context.Context, token string) (*types.Wishlist, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.wishlists {
+		if token != "" && w.ShareToken == token {
+			w = s.withItems(w)
+			return &w, nil
+		}
+	}
+	return nil, wishlist.SharedWishlistNotFound()
+}
+
+func (s *Store) CreateWishlist(ctx context.Context, w *types.Wishlist) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.wishlists {
+		if existing.UserID == w.UserID && existing.Name == w.Name {
+			return wishlist.WishlistExists(w.Name)
+		}
+	}
+	s.lastWishlistID++
+	w.ID = s.lastWishlistID
+	w.ShareToken = ""
+	w.CreatedAt = s.now()
+	stored := *w
+	stored.Items = nil
+	s.wishlists[w.ID] = stored
+	w.Items = []types.WishlistItem{}
+	return nil
+}
+
+// DeleteWishlist 删除心愿单以及其中的产品
+func (s *Store) DeleteWishlist(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.wishlists[id]; !ok {
+		return wishlistNotFound(id)
+	}
+	delete(s.wishlists, id)
+	for itemID, item := range s.wishlistItems {
+		if item.WishlistID == id {
+			delete(s.wishlistItems, itemID)
+		}
+	}
+	return nil
+}
+
+func (s *Store) SetShareToken(ctx context.Context, id int, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.wishlists[id]
+	if !ok {
+		return wishlistNotFound(id)
+	}
+	w.ShareToken = token
+	s.wishlists[id] = w
+	return nil
+}
+
+func (s *Store) AddWishlistItem(ctx context.Context, item *types.WishlistItem) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.wishlists[item.WishlistID]; !ok {
+		return wishlistNotFound(item.WishlistID)
+	}
+	if _, ok := s.products[item.ProductID]; !ok {
+		return productNotFound(item.ProductID)
+	}
+	for _, existing := range s.wishlistItems {
+		if existing.WishlistID == item.WishlistID && existing.ProductID == item.ProductID && sameVariant(existing.VariantID, item.VariantID) {
+			return wishlist.ItemExists(item.ProductID)
+		}
+	}
+
+	s.lastWishItemID++
+	item.ID = s.lastWishItemID
+	item.CreatedAt = s.now()
+	stored := *item
+	stored.Product = nil
+	s.wishlistItems[item.ID] = stored
+	return nil
+}
+
+func (s *Store) RemoveWishlistItem(ctx context.Context, wishlistID, itemID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.wishlistItems[itemID]
+	if !ok || item.WishlistID != wishlistID {
+		return wishlistItemNotFound(itemID)
+	}
+	delete(s.wishlistItems, itemID)
+	return nil
+}
+
+func (s *Store) GetAllWishlistItems(ctx context.Context) ([]types.WishlistItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.filterWishlistItems(func(types.WishlistItem) bool { return true }), nil
+}
+
+func (s *Store) UpdateWishlistItemState(ctx context.Context, itemID int, price float64, inStock bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.wishlistItems[itemID]
+	if !ok {
+		return nil
+	}
+	item.LastPrice, item.LastInStock = price, inStock
+	s.wishlistItems[itemID] = item
+	return nil
+}
+
+// withItems 返回填好 Items 的心愿单副本，调用方需要持有锁
+func (s *Store) withItems(w types.Wishlist) types.Wishlist {
+	w.Items = s.filterWishlistItems(func(item types.WishlistItem) bool { return item.WishlistID == w.ID })
+	return w
+}
+
+// 按ID排序，调用方需要持有锁
+func (s *Store) filterWishlistItems(keep func(types.WishlistItem) bool) []types.WishlistItem {
+	items := []types.WishlistItem{}
+	for _, item := range s.wishlistItems {
+		if keep(item) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func sameVariant(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func wishlistNotFound(id int) error {
+	return apperr.NotFound("wishlist_not_found", fmt.Sprintf("wishlist ID %d not found", id))
+}
+
+func wishlistItemNotFound(id int) error {
+	return apperr.NotFound("wishlist_item_not_found", fmt.Sprintf("wishlist item ID %d not found", id))
+}
@@ -0,0 +1,220 @@
+// Package notify 把业务事件（心愿单中的产品到货、降价，库存告警等）发送给外部系统
+// 默认写日志；配置了 NOTIFY_WEBHOOK_URL 时以 JSON POST 到该地址，由接收方负责发邮件、推送等；
+// 没有 Webhook 但配置了 SMTP_ADDR 时直接发邮件
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/config"
+)
+
+// Event 一个需要通知的事件，Data 是事件相关的数据，会原样编码成 JSON
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// FromConfig 根据配置选择通知方式：Webhook、邮件或日志
+func FromConfig(cfg config.Config) Notifier {
+	if cfg.NotifyWebhookURL != "" {
+		return &Webhook{URL: cfg.NotifyWebhookURL, Secret: cfg.NotifyWebhookSecret}
+	}
+	if cfg.SMTPAddr != "" {
+		var to []string
+		for _, addr := range strings.Split(cfg.NotifyEmailTo, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		mailer := &SMTP{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.SMTPFrom}
+		return &Email{Mailer: mailer, To: to}
+	}
+	return Log{}
+}
+
+// Log 把事件写到日志
+type Log struct{}
+
+func (Log) Notify(_ context.Context, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	log.Printf("[notify] %s %s", e.Type, data)
+	return nil
+}
+
+// SignatureHeader 配置了 Secret 时携带请求体的 HMAC-SHA256 签名，格式为 sha256=<hex>
+const SignatureHeader = "X-Ecom-Signature"
+
+// Webhook 把事件 POST 到 URL，非 2xx 响应视为失败
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client // 为空时使用 10 秒超时的默认客户端
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Recipient 由事件数据实现，表示事件应该发给谁（如订阅到货通知的用户的邮箱）
+type Recipient interface {
+	Recipient() string
+}
+
+// Message 一封邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件，方便替换为第三方邮件服务或在测试中记录
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Email 把事件作为邮件发送：事件数据实现了 Recipient 时发给它，否则发给 To
+// 邮件正文是事件数据的 JSON；没有收件人时跳过
+type Email struct {
+	Mailer Mailer
+	To     []string
+}
+
+func (e *Email) Notify(ctx context.Context, ev Event) error {
+	to := e.To
+	if r, ok := ev.Data.(Recipient); ok && r.Recipient() != "" {
+		to = []string{r.Recipient()}
+	}
+	if len(to) == 0 {
+		log.Printf("[notify] %s has no email recipient, skipped", ev.Type)
+		return nil
+	}
+	body, err := json.MarshalIndent(ev.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(ctx, Message{To: to, Subject: "[ecom] " + ev.Type, Body: string(body)})
+}
+
+// SMTP 通过 SMTP 服务器发送纯文本邮件，服务器支持 STARTTLS 时加密；Username 为空时不认证
+type SMTP struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration // 连接和发送一封邮件的总时长上限，为 0 时使用 30 秒；ctx 的截止时间更早时以 ctx 为准
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp 不接受 ctx：用连接的截止时间限制每次读写，ctx 提前取消时直接关闭连接
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + strings.Join(m.To, ", ") + "\r\n" +
+		"Subject: " + m.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + m.Body
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var (
+		body      []byte
+		signature string
+		status    = http.StatusNoContent
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	hook := &Webhook{URL: srv.URL, Secret: "s3cret"}
+	e := Event{Type: "wishlist.price_drop", Time: time.Unix(0, 0).UTC(), Data: map[string]int{"productId": 1}}
+	if err := hook.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(body, &got); err != nil || got.Type != e.Type {
+		t.Errorf("unexpected body %s: %v", body, err)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("expected signature %s, got %s", want, signature)
+	}
+
+	// 非 2xx 的响应视为失败
+	status = http.StatusInternalServerError
+	if err := hook.Notify(context.Background(), e); err == nil {
+		t.Error("expected an error for a 500 response")
+	}
+}
+
+type mailbox struct{ sent []Message }
+
+func (b *mailbox) Send(_ context.Context, m Message) error {
+	b.sent = append(b.sent, m)
+	return nil
+}
+
+type customer struct{ Email string }
+
+func (c customer) Recipient() string { return c.Email }
+
+func TestEmail(t *testing.T) {
+	box := &mailbox{}
+	mail := &Email{Mailer: box, To: []string{"ops@example.com"}}
+
+	// 没有指定收件人的事件发给 To，实现了 Recipient 的发给事件中的收件人
+	for _, e := range []Event{
+		{Type: "stock.low", Data: map[string]int{"productId": 1}},
+		{Type: "stock.back_in_stock", Data: customer{Email: "buyer@example.com"}},
+	} {
+		if err := mail.Notify(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(box.sent) != 2 {
+		t.Fatalf("expected 2 emails, got %+v", box.sent)
+	}
+	if m := box.sent[0]; len(m.To) != 1 || m.To[0] != "ops@example.com" || m.Subject != "[ecom] stock.low" {
+		t.Errorf("unexpected email: %+v", m)
+	}
+	if m := box.sent[1]; len(m.To) != 1 || m.To[0] != "buyer@example.com" {
+		t.Errorf("unexpected email: %+v", m)
+	}
+
+	// 没有收件人时跳过
+	if err := (&Email{Mailer: box}).Notify(context.Background(), Event{Type: "stock.low"}); err != nil || len(box.sent) != 2 {
+		t.Errorf("expected the event to be skipped, got %v", err)
+	}
+}
+
+// fakeSMTP 一个只实现了发信所需命令的 SMTP 服务器，把收到的邮件写入 mail
+func fakeSMTP(t *testing.T, mail chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case cmd == "DATA":
+				fmt.Fprint(conn, "354 go ahead\r\n")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				mail <- body.String()
+				fmt.Fprint(conn, "250 queued\r\n")
+			case cmd == "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSMTP(t *testing.T) {
+	t.Run("发送邮件", func(t *testing.T) {
+		mail := make(chan string, 1)
+		s := &SMTP{Addr: fakeSMTP(t, mail), From: "ecom@localhost"}
+		err := s.Send(context.Background(), Message{To: []string{"buyer@example.com"}, Subject: "[ecom] stock.back_in_stock", Body: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := <-mail; !strings.Contains(got, "To: buyer@example.com\r\n") || !strings.Contains(got, "Subject: [ecom] stock.back_in_stock\r\n") {
+			t.Errorf("unexpected mail: %q", got)
+		}
+	})
+
+	t.Run("服务器没有响应时超时", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		// 接受连接但不发问候语
+		go func() {
+			if conn, err := ln.Accept(); err == nil {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}
+		}()
+
+		start := time.Now()
+		s := &SMTP{Addr: ln.Addr().String(), From: "ecom@localhost", Timeout: 100 * time.Millisecond}
+		if err := s.Send(context.Background(), Message{To: []string{"ops@example.com"}}); err == nil {
+			t.Fatal("expected a timeout")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("expected to give up after the timeout, took %s", elapsed)
+		}
+
+		// ctx 的截止时间更早时以 ctx 为准
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		s.Timeout = time.Hour
+		start = time.Now()
+		if err := s.Send(ctx, Message{To: []string{"ops@example.com"}}); err == nil || time.Since(start) > 2*time.Second {
+			t.Errorf("expected the ctx deadline to apply, got %v after %s", err, time.Since(start))
+		}
+	})
+}
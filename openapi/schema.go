@@ -0,0 +1,238 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema JSON Schema（OpenAPI 3.1 使用 2020-12 版本）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string 或 []string
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// 把 Go 类型转换为 JSON Schema，具名结构体放进 components 并返回 $ref
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}}
+}
+
+func (g *generator) schemaFor(v any) *Schema {
+	return g.schemaForType(reflect.TypeOf(v))
+}
+
+func (g *generator) schemaForType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaForType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaForType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = &Schema{} // 先占位，防止递归类型死循环
+			g.schemas[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		// 匿名嵌入的结构体展开到当前对象中
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(f.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		prop := g.schemaForType(f.Type)
+		// 指针字段可以为 null；没有类型的 schema（如 *any）本来就接受任意值
+		if typ, ok := prop.Type.(string); ok && f.Type.Kind() == reflect.Pointer && prop.Ref == "" {
+			prop.Type = []string{typ, "null"}
+		}
+		if desc := f.Tag.Get("doc"); desc != "" {
+			prop.Description = desc
+		}
+		if applyValidateTag(prop, f.Type, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		} else if !strings.Contains(opts, "omitempty") && f.Tag.Get("validate") == "" && !isRequestOnly(t) {
+			// 响应结构体中没有 omitempty 的字段总会出现
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// 请求体结构体以 Payload 结尾，它们的必填字段完全由 validate 标签决定
+func isRequestOnly(t reflect.Type) bool {
+	return strings.HasSuffix(t.Name(), "Payload")
+}
+
+// 把 validate 标签中的规则映射到 JSON Schema 约束，返回字段是否必填
+func applyValidateTag(s *Schema, t reflect.Type, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "omitempty":
+		case "dive":
+			// dive 之后的规则作用于数组元素
+			if s.Items != nil && t.Kind() == reflect.Slice {
+				applyValidateTag(s.Items, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(t, v))
+			}
+		case "min", "gte":
+			setLowerBound(s, t, param, false)
+		case "gt":
+			setLowerBound(s, t, param, true)
+		case "max", "lte":
+			setUpperBound(s, t, param, false)
+		case "lt":
+			setUpperBound(s, t, param, true)
+		case "len":
+			setLowerBound(s, t, param, false)
+			setUpperBound(s, t, param, false)
+		}
+	}
+	return required
+}
+
+func setLowerBound(s *Schema, t reflect.Type, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.String:
+		l := int(n)
+		s.MinLength = &l
+	case reflect.Slice, reflect.Array, reflect.Map:
+		l := int(n)
+		s.MinItems = &l
+	default:
+		if exclusive {
+			s.ExclusiveMinimum = &n
+		} else {
+			s.Minimum = &n
+		}
+	}
+}
+
+func setUpperBound(s *Schema, t reflect.Type, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.String:
+		l := int(n)
+		s.MaxLength = &l
+	case reflect.Slice, reflect.Array, reflect.Map:
+		l := int(n)
+		s.MaxItems = &l
+	default:
+		if exclusive {
+			s.ExclusiveMaximum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+func enumValue(t reflect.Type, v string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaItem struct {
+	SKU string `json:"sku"`
+}
+
+type schemaSample struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Price     *float64          `json:"price"`
+	Item      *schemaItem       `json:"item"`
+	Extra     *any              `json:"extra"`
+	Meta      map[string]any    `json:"meta"`
+	Tags      []string          `json:"tags"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Labels    map[string]string `json:"-"`
+	internal  int
+}
+
+type SamplePayload struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Quantity int      `json:"quantity" validate:"required,min=1,max=10"`
+	Status   string   `json:"status" validate:"omitempty,oneof=pending done"`
+	SKUs     []string `json:"skus" validate:"required,min=1,dive,max=8"`
+	Note     *string  `json:"note"`
+}
+
+func TestStructSchema(t *testing.T) {
+	g := newGenerator()
+	s := g.schemaFor(schemaSample{})
+	if s.Ref != "#/components/schemas/schemaSample" {
+		t.Fatalf("expected a $ref, got %+v", s)
+	}
+	sample := g.schemas["schemaSample"]
+
+	for name, want := range map[string]any{
+		"id":        "integer",
+		"name":      "string",
+		"price":     []string{"number", "null"},
+		"meta":      "object",
+		"tags":      "array",
+		"createdAt": "string",
+	} {
+		if got := sample.Properties[name].Type; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected type %v, got %v", name, want, got)
+		}
+	}
+	// 结构体指针用 $ref，没有类型的指针保持不限类型
+	if item := sample.Properties["item"]; item.Ref != "#/components/schemas/schemaItem" || item.Type != nil {
+		t.Errorf("unexpected item schema: %+v", item)
+	}
+	if extra := sample.Properties["extra"]; extra.Type != nil {
+		t.Errorf("expected an untyped schema for *any, got %+v", extra)
+	}
+	for _, name := range []string{"Labels", "internal"} {
+		if _, ok := sample.Properties[name]; ok {
+			t.Errorf("expected %s to be skipped", name)
+		}
+	}
+	if _, ok := g.schemas["schemaItem"]; !ok {
+		t.Error("expected the nested struct in components")
+	}
+	if want := []string{"id", "price", "item", "extra", "meta", "tags", "createdAt"}; !reflect.DeepEqual(sample.Required, want) {
+		t.Errorf("expected required %v, got %v", want, sample.Required)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	g := newGenerator()
+	g.schemaFor(SamplePayload{})
+	s := g.schemas["SamplePayload"]
+
+	if want := []string{"email", "quantity", "skus"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("expected required %v, got %v", want, s.Required)
+	}
+	if s.Properties["email"].Format != "email" {
+		t.Error("expected format: email")
+	}
+	q := s.Properties["quantity"]
+	if q.Minimum == nil || *q.Minimum != 1 || q.Maximum == nil || *q.Maximum != 10 {
+		t.Errorf("unexpected quantity bounds: %+v", q)
+	}
+	if enum := s.Properties["status"].Enum; !reflect.DeepEqual(enum, []any{"pending", "done"}) {
+		t.Errorf("unexpected enum: %v", enum)
+	}
+	skus := s.Properties["skus"]
+	if skus.MinItems == nil || *skus.MinItems != 1 || skus.Items.MaxLength == nil || *skus.Items.MaxLength != 8 {
+		t.Errorf("unexpected skus constraints: %+v %+v", skus, skus.Items)
+	}
+	if note := s.Properties["note"]; !reflect.DeepEqual(note.Type, []string{"string", "null"}) {
+		t.Errorf("unexpected note type: %v", note.Type)
+	}
+}
@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Document OpenAPI 3.1 文档（只包含本项目用到的字段）
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem 以小写 HTTP 方法为 key
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                    `json:"operationId"`
+	Summary     string                    `json:"summary,omitempty"`
+	Tags        []string                  `json:"tags,omitempty"`
+	Parameters  []ParameterObject         `json:"parameters,omitempty"`
+	RequestBody *RequestBody              `json:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+	Security    []map[string][]string     `json:"security,omitempty"`
+}
+
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation 描述一个接口，用于生成文档
+type Operation struct {
+	Method      string
+	Path        string // mux 路由模板，如 "/products/{id:[0-9]+}"
+	OperationID string
+	Summary     string
+	Tags        []string
+	Auth        bool        // 是否需要 Bearer JWT
+	Query       []Parameter // 查询参数
+	Request     any         // 请求体类型的零值，nil 表示没有请求体；也可以直接给出 *Schema
+	RequestType string      // 请求体的 Content-Type，默认为 application/json
+	Responses   []Response
+}
+
+// Parameter 查询参数或路径参数
+type Parameter struct {
+	Name        string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Response 一种响应，Body 为 nil 表示没有响应体
+type Response struct {
+	Status      int
+	Description string
+	Body        any
+	ContentType string // 默认为 application/json
+}
+
+const bearerAuth = "bearerAuth"
+
+var muxVarPattern = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*(\{[^{}]*\}[^{}]*)*)?\}`)
+
+// PathFromMux 把 mux 的路由模板转换为 OpenAPI 路径，去掉变量上的正则
+func PathFromMux(tpl string) string {
+	return muxVarPattern.ReplaceAllString(tpl, "{$1}")
+}
+
+// Build 根据接口列表生成文档，errorBody 是所有错误响应使用的类型
+func Build(info Info, basePath string, errorBody any, ops []Operation) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Servers: []Server{{URL: basePath}},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	errorSchema := g.schemaFor(errorBody)
+	problem := MediaType{Schema: errorSchema}
+
+	for _, op := range ops {
+		path := PathFromMux(op.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+
+		o := &OperationObject{
+			OperationID: op.OperationID,
+			Summary:     op.Summary,
+			Tags:        op.Tags,
+			Responses:   map[string]ResponseObject{},
+		}
+
+		for _, name := range pathParams(op.Path) {
+			o.Parameters = append(o.Parameters, ParameterObject{
+				Name: name, In: "path", Required: true, Schema: pathParamSchema(op.Path, name),
+			})
+		}
+		for _, p := range op.Query {
+			o.Parameters = append(o.Parameters, ParameterObject{
+				Name: p.Name, In: "query", Required: p.Required, Description: p.Description, Schema: p.Schema,
+			})
+		}
+
+		if op.Request != nil {
+			ct := op.RequestType
+			if ct == "" {
+				ct = "application/json"
+			}
+			schema, ok := op.Request.(*Schema)
+			if !ok {
+				schema = g.schemaFor(op.Request)
+			}
+			o.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{ct: {Schema: schema}},
+			}
+			o.Responses["400"] = ResponseObject{Description: "Invalid request body", Content: map[string]MediaType{"application/problem+json": problem}}
+			o.Responses["413"] = ResponseObject{Description: "Request body too large", Content: map[string]MediaType{"application/problem+json": problem}}
+			o.Responses["415"] = ResponseObject{Description: "Content-Type is not " + ct, Content: map[string]MediaType{"application/problem+json": problem}}
+		}
+		if op.Auth {
+			o.Security = []map[string][]string{{bearerAuth: {}}}
+			o.Responses["401"] = ResponseObject{Description: "Missing or invalid token", Content: map[string]MediaType{"application/problem+json": problem}}
+		}
+
+		for _, resp := range op.Responses {
+			ro := ResponseObject{Description: resp.Description}
+			if resp.Body != nil {
+				ct := resp.ContentType
+				if ct == "" {
+					ct = "application/json"
+				}
+				if resp.Status >= 400 {
+					ct = "application/problem+json"
+				}
+				ro.Content = map[string]MediaType{ct: {Schema: g.schemaFor(resp.Body)}}
+			}
+			o.Responses[strconv.Itoa(resp.Status)] = ro
+		}
+		o.Responses["default"] = ResponseObject{Description: "Error", Content: map[string]MediaType{"application/problem+json": problem}}
+
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// 按出现顺序取出路由模板中的变量名
+func pathParams(tpl string) []string {
+	var names []string
+	for _, m := range muxVarPattern.FindAllStringSubmatch(tpl, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// 带数字正则的路径变量按整数处理，其余按字符串处理
+func pathParamSchema(tpl, name string) *Schema {
+	for _, m := range muxVarPattern.FindAllStringSubmatch(tpl, -1) {
+		if m[1] == name && strings.Contains(m[2], "0-9") {
+			return &Schema{Type: "integer"}
+		}
+	}
+	return &Schema{Type: "string"}
+}
+
+// HasOperation 判断文档中是否描述了某个接口
+func (d *Document) HasOperation(method, path string) bool {
+	item, ok := d.Paths[path]
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// KeyFunc 从请求中提取限流的 key，返回 false 表示该请求不适用这个 key
+type KeyFunc func(r *http.Request) (string, bool)
+
+// ByIP 按客户端 IP 限流
+// 只有当直连的对端属于 trusted 代理时才会解析 X-Forwarded-For，
+// 从右往左跳过可信代理，取第一个不可信的地址，防止客户端伪造该请求头
+func ByIP(trusted []netip.Prefix) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return "ip:" + ClientIP(r, trusted), true
+	}
+}
+
+// ClientIP 返回请求的真实客户端地址
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteAddr(r)
+	if !isTrusted(remote, trusted) {
+		return remote.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = addr.Unmap()
+		if !isTrusted(addr, trusted) {
+			return addr.String()
+		}
+		remote = addr
+	}
+	return remote.String()
+}
+
+// ByUser 按已认证的用户ID限流，userID 返回 false 时（未登录）该 key 不适用
+func ByUser(userID func(r *http.Request) (int, bool)) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		id, ok := userID(r)
+		if !ok {
+			return "", false
+		}
+		return "user:" + strconv.Itoa(id), true
+	}
+}
+
+// ByAPIKey 按请求头中的 API key 限流，只保存 key 的哈希
+func ByAPIKey(header string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		key := strings.TrimSpace(r.Header.Get(header))
+		if key == "" {
+			return "", false
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:" + hex.EncodeToString(sum[:8]), true
+	}
+}
+
+// FirstOf 依次尝试多个 KeyFunc，使用第一个适用的
+// 例如 FirstOf(ByUser(...), ByIP(...))：登录用户按用户ID，匿名用户按IP
+func FirstOf(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		for _, key := range keys {
+			if k, ok := key(r); ok {
+				return k, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ParseTrustedProxies 解析逗号分隔的 CIDR 或 IP 列表
+func ParseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.IPv4Unspecified()
+	}
+	return addr.Unmap()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
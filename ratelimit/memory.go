@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// 每处理这么多次请求清理一次长时间未使用的桶
+const sweepEvery = 1024
+
+type bucketState struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // 桶在这个时间点之后会重新装满，之后可以安全删除
+}
+
+// MemoryStore 进程内的令牌桶存储，只适用于单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+	ops     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucketState{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, b Bucket, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops++
+	if s.ops%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	st, ok := s.buckets[key]
+	if !ok {
+		st = &bucketState{tokens: float64(b.Burst), updated: now}
+		s.buckets[key] = st
+	}
+
+	// 按流逝的时间补充令牌
+	elapsed := now.Sub(st.updated).Seconds()
+	if elapsed > 0 {
+		st.tokens = math.Min(float64(b.Burst), st.tokens+elapsed*b.Rate)
+		st.updated = now
+	}
+
+	res := Result{Limit: b.Burst}
+	if st.tokens >= 1 {
+		st.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - st.tokens) / b.Rate)
+	}
+
+	res.Remaining = int(st.tokens)
+	res.ResetAfter = secondsToDuration((float64(b.Burst) - st.tokens) / b.Rate)
+	st.full = now.Add(res.ResetAfter)
+
+	return res, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, st := range s.buckets {
+		if now.After(st.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
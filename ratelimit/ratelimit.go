@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+// Bucket 令牌桶参数：桶容量为 Burst，每秒补充 Rate 个令牌
+type Bucket struct {
+	Rate  float64
+	Burst int
+}
+
+// Result 一次取令牌的结果
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // 桶重新装满还需要的时间
+	RetryAfter time.Duration // 被拒绝时，下一个令牌可用前需要等待的时间
+}
+
+// Store 保存令牌桶状态的后端
+// 进程内使用 MemoryStore；多实例部署时可以实现一个共享存储（如 Redis + Lua 脚本），
+// 只要保证同一个 key 的 Take 是原子的即可
+type Store interface {
+	Take(ctx context.Context, key string, b Bucket, now time.Time) (Result, error)
+}
+
+// Rule 一条限流规则：每个 Period 最多 Limit 个请求，按 Key 区分调用方
+type Rule struct {
+	Limit   int
+	Period  time.Duration
+	Burst   int    // 允许的突发请求数，为 0 时等于 Limit
+	KeyName string // 规则中使用的 key 类型名，如 "ip"、"user"，只用于日志和调试
+	Key     KeyFunc
+}
+
+func (r Rule) bucket() Bucket {
+	burst := r.Burst
+	if burst <= 0 {
+		burst = r.Limit
+	}
+	return Bucket{Rate: float64(r.Limit) / r.Period.Seconds(), Burst: burst}
+}
+
+// Limiter 按 mux 路由模板选择限流规则的中间件
+type Limiter struct {
+	store       Store
+	rules       map[string]Rule // key 为路由模板，如 "/api/v1/login"
+	defaultRule *Rule
+	now         func() time.Time
+}
+
+// New 创建限流器，defaultRule 为空时未配置的路由不限流
+func New(store Store, rules map[string]Rule, defaultRule *Rule) *Limiter {
+	return &Limiter{
+		store:       store,
+		rules:       rules,
+		defaultRule: defaultRule,
+		now:         time.Now,
+	}
+}
+
+func (l *Limiter) ruleFor(r *http.Request) (string, *Rule) {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if rule, ok := l.rules[tpl]; ok {
+				return tpl, &rule
+			}
+		}
+	}
+	return "*", l.defaultRule
+}
+
+// Middleware 作为 router.Use 的中间件使用
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, rule := l.ruleFor(r)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := rule.Key(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := l.store.Take(r.Context(), route+"|"+key, rule.bucket(), l.now())
+		if err != nil {
+			// 限流后端故障时放行，避免把整个 API 拖垮
+			log.Printf("[%s] rate limit store error: %v", utils.GetRequestID(r.Context()), err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Period)))
+
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			utils.WriteError(w, r, apperr.TooManyRequests("rate_limited", "too many requests, please retry later"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ParseRule 解析形如 "5/1m:ip" 的规则：每分钟 5 次，按 IP 限流
+// 可选的突发值写在次数后面，如 "5+10/1m:user"
+func ParseRule(spec string, keys map[string]KeyFunc) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	rate, keyName, ok := strings.Cut(spec, ":")
+	if !ok {
+		keyName = "ip"
+	}
+	key, found := keys[keyName]
+	if !found {
+		return Rule{}, fmt.Errorf("unknown rate limit key %q in %q", keyName, spec)
+	}
+
+	count, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rate limit %q, expected LIMIT/PERIOD", spec)
+	}
+	limitStr, burstStr, hasBurst := strings.Cut(count, "+")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit count in %q", spec)
+	}
+	burst := 0
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Rule{}, fmt.Errorf("invalid rate limit burst in %q", spec)
+		}
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit period in %q", spec)
+	}
+
+	return Rule{Limit: limit, Period: d, Burst: burst, KeyName: keyName, Key: key}, nil
+}
+
+// ParseRules 解析以逗号分隔的 "路由模板=规则" 列表，如
+// "/api/v1/login=5/1m:ip,/api/v1/cart/checkout=10/1m:user"
+func ParseRules(spec string, keys map[string]KeyFunc) (map[string]Rule, error) {
+	rules := map[string]Rule{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, ruleSpec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit entry %q, expected ROUTE=RULE", entry)
+		}
+		rule, err := ParseRule(ruleSpec, keys)
+		if err != nil {
+			return nil, err
+		}
+		rules[strings.TrimSpace(route)] = rule
+	}
+	return rules, nil
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	b := Bucket{Rate: 1, Burst: 2} // 每秒补充 1 个，最多 2 个
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Take(context.Background(), "k", b, now); !res.Allowed {
+			t.Fatalf("第 %d 次请求应该被放行", i+1)
+		}
+	}
+
+	res, _ := store.Take(context.Background(), "k", b, now)
+	if res.Allowed {
+		t.Fatal("令牌耗尽后应该被拒绝")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("期望 RetryAfter 为 1s, 实际为 %v", res.RetryAfter)
+	}
+
+	// 过 1 秒补充一个令牌
+	if res, _ := store.Take(context.Background(), "k", b, now.Add(time.Second)); !res.Allowed {
+		t.Error("补充令牌后应该被放行")
+	}
+
+	// 不同的 key 互不影响
+	if res, _ := store.Take(context.Background(), "other", b, now); !res.Allowed {
+		t.Error("其他 key 应该有独立的令牌桶")
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	keys := map[string]KeyFunc{"ip": ByIP(nil)}
+	rules, err := ParseRules("/login=2/1m:ip", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := New(NewMemoryStore(), rules, nil)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/login", ok).Methods("POST")
+	router.HandleFunc("/products", ok).Methods("GET")
+
+	do := func(method, path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := do("POST", "/login", "1.2.3.4:5000")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("第 %d 次登录应该成功, 实际状态码 %d", i+1, rr.Code)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("RateLimit-Limit 应为 2, 实际为 %q", rr.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	rr := do("POST", "/login", "1.2.3.4:5000")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("期望状态码 429, 实际 %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "30" {
+		t.Errorf("期望 Retry-After 为 30, 实际为 %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("期望 RateLimit-Remaining 为 0, 实际为 %q", rr.Header().Get("RateLimit-Remaining"))
+	}
+
+	// 另一个 IP 不受影响
+	if rr := do("POST", "/login", "5.6.7.8:5000"); rr.Code != http.StatusOK {
+		t.Errorf("其他 IP 应该被放行, 实际状态码 %d", rr.Code)
+	}
+
+	// 未配置规则的路由不限流
+	for i := 0; i < 5; i++ {
+		if rr := do("GET", "/products", "1.2.3.4:5000"); rr.Code != http.StatusOK {
+			t.Fatalf("未配置规则的路由不应被限流, 实际状态码 %d", rr.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"直连客户端忽略 XFF", "1.2.3.4:80", "9.9.9.9", "1.2.3.4"},
+		{"可信代理取 XFF", "10.0.0.1:80", "1.2.3.4", "1.2.3.4"},
+		{"跳过多层可信代理", "127.0.0.1:80", "1.2.3.4, 10.0.0.5", "1.2.3.4"},
+		{"伪造的最左侧地址不被采信", "10.0.0.1:80", "9.9.9.9, 1.2.3.4", "1.2.3.4"},
+		{"XFF 中全是可信代理", "10.0.0.1:80", "10.0.0.2", "10.0.0.2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.remote
+			if c.xff != "" {
+				req.Header.Set("X-Forwarded-For", c.xff)
+			}
+			if got := ClientIP(req, trusted); got != c.want {
+				t.Errorf("期望 %s, 实际 %s", c.want, got)
+			}
+		})
+	}
+}
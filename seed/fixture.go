@@ -0,0 +1,106 @@
+package seed
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture 一组要写入数据库的固定数据
+type Fixture struct {
+	Users    []User    `json:"users" yaml:"users"`
+	Products []Product `json:"products" yaml:"products"`
+	Orders   []Order   `json:"orders" yaml:"orders"`
+}
+
+// User 明文密码，写入前会用 bcrypt 哈希；Role 为空时是普通用户
+type User struct {
+	Firstname string `json:"firstname" yaml:"firstname"`
+	Lastname  string `json:"lastname" yaml:"lastname"`
+	Email     string `json:"email" yaml:"email"`
+	Password  string `json:"password" yaml:"password"`
+	Role      string `json:"role,omitempty" yaml:"role,omitempty"`
+}
+
+// Product ID 为 0 时由数据库分配，并按名称判断是否已经存在
+type Product struct {
+	ID          int     `json:"id,omitempty" yaml:"id,omitempty"`
+	Name        string  `json:"name" yaml:"name"`
+	Description string  `json:"description" yaml:"description"`
+	Image       string  `json:"image" yaml:"image"`
+	Price       float64 `json:"price" yaml:"price"`
+	Quantity    int     `json:"quantity" yaml:"quantity"`
+}
+
+// Order 通过邮箱引用用户，订单项通过ID或名称引用产品
+type Order struct {
+	User    string      `json:"user" yaml:"user"`
+	Status  string      `json:"status,omitempty" yaml:"status,omitempty"`
+	Address string      `json:"address" yaml:"address"`
+	Items   []OrderItem `json:"items" yaml:"items"`
+}
+
+type OrderItem struct {
+	ProductID int    `json:"productId,omitempty" yaml:"productId,omitempty"`
+	Product   string `json:"product,omitempty" yaml:"product,omitempty"`
+	Quantity  int    `json:"quantity" yaml:"quantity"`
+}
+
+//go:embed demo.yaml
+var demoFixture []byte
+
+// Demo 内置的演示数据，与 .http 示例文件中使用的账号和产品ID对应
+func Demo() (Fixture, error) {
+	return decode(demoFixture, ".yaml")
+}
+
+// Load 读取 YAML（.yaml/.yml）或 JSON（.json）格式的数据文件，未知字段视为错误
+func Load(path string) (Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	fx, err := decode(data, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return Fixture{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return fx, nil
+}
+
+func decode(data []byte, ext string) (Fixture, error) {
+	var fx Fixture
+	switch ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fx); err != nil {
+			return Fixture{}, err
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fx); err != nil {
+			return Fixture{}, err
+		}
+	default:
+		return Fixture{}, fmt.Errorf("unsupported fixture format %q, use .yaml, .yml or .json", ext)
+	}
+	return fx, nil
+}
+
+// Merge 把多组数据合并为一组
+func Merge(fixtures ...Fixture) Fixture {
+	var out Fixture
+	for _, fx := range fixtures {
+		out.Users = append(out.Users, fx.Users...)
+		out.Products = append(out.Products, fx.Products...)
+		out.Orders = append(out.Orders, fx.Orders...)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Albert-tru/ecom/db"
+)
+
+// 按外键依赖的逆序排列
+var tables = []string{
+	"stock_subscriptions", "stock_movements", "wishlist_items", "wishlists",
+	"order_items", "orders", "product_prices", "product_reviews", "product_image_thumbnails", "product_images",
+	"variant_options", "product_variants", "product_options",
+	"product_categories", "categories", "products", "users",
+}
+
+// Reset 清空所有业务表并重置自增ID，只应该用于测试和开发数据库
+func Reset(ctx context.Context, conn *sql.DB, dialect db.Dialect) error {
+	switch dialect {
+	case db.Postgres:
+		_, err := conn.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE")
+		return err
+	case db.SQLite:
+		return dialect.RunInTx(ctx, conn, db.TxOptions{}, func(tx *sql.Tx) error {
+			for _, t := range tables {
+				if _, err := tx.ExecContext(ctx, "DELETE FROM "+t); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM sqlite_sequence WHERE name IN ('"+strings.Join(tables, "', '")+"')")
+			return err
+		})
+	default:
+		// MySQL 的 DDL 会隐式提交事务，这里逐条执行；有外键时不能 TRUNCATE，所以先 DELETE 再重置 AUTO_INCREMENT
+		// MySQL 逐行检查外键，删除分类前先断开父子关系
+		if _, err := conn.ExecContext(ctx, "UPDATE categories SET parent_id = NULL"); err != nil {
+			return err
+		}
+		for _, t := range tables {
+			if _, err := conn.ExecContext(ctx, "DELETE FROM "+t); err != nil {
+				return err
+			}
+		}
+		for _, t := range tables {
+			if _, err := conn.ExecContext(ctx, "ALTER TABLE "+t+" AUTO_INCREMENT = 1"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+// Package seed 把固定数据或生成的假数据写入存储，用于本地开发、演示和测试
+package seed
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Report 一次导入的结果
+type Report struct {
+	UsersCreated, UsersSkipped       int
+	ProductsCreated, ProductsSkipped int
+	OrdersCreated, OrdersSkipped     int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("users: %d created, %d skipped; products: %d created, %d skipped; orders: %d created, %d skipped",
+		r.UsersCreated, r.UsersSkipped, r.ProductsCreated, r.ProductsSkipped, r.OrdersCreated, r.OrdersSkipped)
+}
+
+// Apply 写入数据，可以重复执行：
+//   - 用户按邮箱判断，已存在的跳过
+//   - 产品有ID时按ID判断，否则按名称判断
+//   - 订单没有天然的唯一键，只为本次新创建的用户写入，所以重复执行不会产生重复订单
+func Apply(ctx context.Context, stores types.Stores, fx Fixture) (Report, error) {
+	var r Report
+
+	newUsers := map[string]int{}
+	for _, u := range fx.Users {
+		_, err := stores.Users.GetUserByEmail(ctx, u.Email)
+		if err == nil {
+			r.UsersSkipped++
+			continue
+		}
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			return r, err
+		}
+
+		hashed, err := auth.HashPassword(u.Password)
+		if err != nil {
+			return r, err
+		}
+		user := &types.User{Firstname: u.Firstname, Lastname: u.Lastname, Email: u.Email, Password: hashed, Role: u.Role}
+		if err := stores.Users.CreateUser(ctx, user); err != nil {
+			return r, fmt.Errorf("create user %s: %w", u.Email, err)
+		}
+		newUsers[u.Email] = user.ID
+		r.UsersCreated++
+	}
+
+	existing, err := stores.Products.GetProducts(ctx)
+	if err != nil {
+		return r, err
+	}
+	byID := map[int]bool{}
+	byName := map[string]int{}
+	for _, p := range existing {
+		byID[p.ID] = true
+		byName[p.Name] = p.ID
+	}
+	for _, p := range fx.Products {
+		if (p.ID != 0 && byID[p.ID]) || (p.ID == 0 && byName[p.Name] != 0) {
+			r.ProductsSkipped++
+			continue
+		}
+		product := &types.Product{ID: p.ID, Name: p.Name, Description: p.Description, ImageURL: p.Image, Price: p.Price, Quantity: p.Quantity}
+		if err := stores.Products.CreateProduct(ctx, product); err != nil {
+			return r, fmt.Errorf("create product %q: %w", p.Name, err)
+		}
+		byID[product.ID] = true
+		byName[product.Name] = product.ID
+		r.ProductsCreated++
+	}
+
+	for i, o := range fx.Orders {
+		userID, ok := newUsers[o.User]
+		if !ok {
+			r.OrdersSkipped++
+			continue
+		}
+		err := createOrder(ctx, stores, userID, o, byName)
+		if apperr.IsKind(err, apperr.KindConflict) || apperr.IsKind(err, apperr.KindNotFound) {
+			// 库存不足或产品不存在时跳过这一单，不影响其它数据
+			log.Printf("skipping order %d for %s: %v", i+1, o.User, err)
+			r.OrdersSkipped++
+			continue
+		}
+		if err != nil {
+			return r, fmt.Errorf("create order %d: %w", i+1, err)
+		}
+		r.OrdersCreated++
+	}
+
+	return r, nil
+}
+
+// 在一个事务中创建订单和订单项（同时扣减库存），订单价格取产品的当前价格
+func createOrder(ctx context.Context, stores types.Stores, userID int, o Order, byName map[string]int) error {
+	if len(o.Items) == 0 {
+		return apperr.Validation("empty_order", "order has no items")
+	}
+	return stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		ids := make([]int, 0, len(o.Items))
+		for _, item := range o.Items {
+			id := item.ProductID
+			if id == 0 {
+				id = byName[item.Product]
+			}
+			ids = append(ids, id)
+		}
+
+		ps, err := tx.Products.GetProductByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		prices := map[int]float64{}
+		for _, p := range ps {
+			prices[p.ID] = p.Price
+		}
+
+		total := 0.0
+		for i, item := range o.Items {
+			price, ok := prices[ids[i]]
+			if !ok {
+				return apperr.NotFound("product_not_found", fmt.Sprintf("product %d %q not found", item.ProductID, item.Product))
+			}
+			total += price * float64(item.Quantity)
+		}
+
+		status := o.Status
+		if status == "" {
+			status = types.OrderPending
+		}
+		orderID, err := tx.Orders.CreateOrder(ctx, types.Order{UserID: userID, Total: total, Status: status, Address: o.Address})
+		if err != nil {
+			return err
+		}
+		for i, item := range o.Items {
+			err := tx.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: ids[i], Quantity: item.Quantity, Price: prices[ids[i]]})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
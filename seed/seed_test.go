@@ -0,0 +1,143 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/storage"
+)
+
+// 重复导入时已有的数据全部跳过，不会产生重复订单
+func TestApplyIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	fx := Merge(mustDemo(t), Generate(FakeOptions{Users: 5, Products: 8, Orders: 10, Seed: 42}))
+
+	first, err := Apply(ctx, store.Stores(), fx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.UsersCreated != 7 || first.ProductsCreated != 10 || first.OrdersCreated+first.OrdersSkipped != 11 {
+		t.Fatalf("unexpected first report: %v", first)
+	}
+
+	second, err := Apply(ctx, store.Stores(), fx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{UsersSkipped: 7, ProductsSkipped: 10, OrdersSkipped: 11}
+	if second != want {
+		t.Errorf("expected %v, got %v", want, second)
+	}
+
+	products, _ := store.GetProducts(ctx)
+	if len(products) != 10 || products[0].ID != 1 || products[0].Name != "Mechanical Keyboard" {
+		t.Errorf("unexpected products: %+v", products)
+	}
+	if items := store.OrderItems(first.OrdersCreated + 1); len(items) != 0 {
+		t.Errorf("expected no extra orders, got items %+v", items)
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	opts := FakeOptions{Users: 3, Products: 4, Orders: 5, Seed: 7}
+	if a, b := Generate(opts), Generate(opts); !reflect.DeepEqual(a, b) {
+		t.Error("same seed produced different data")
+	}
+	opts.Seed = 8
+	if a, b := Generate(FakeOptions{Users: 3, Products: 4, Orders: 5, Seed: 7}), Generate(opts); reflect.DeepEqual(a, b) {
+		t.Error("different seeds produced the same data")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	fx, err := Load(write("a.yml", "users:\n  - email: a@example.com\n    password: secret\n"))
+	if err != nil || len(fx.Users) != 1 || fx.Users[0].Email != "a@example.com" {
+		t.Errorf("yaml: got %+v, %v", fx, err)
+	}
+	fx, err = Load(write("b.json", `{"products": [{"name": "Pen", "price": 1.5, "quantity": 3}]}`))
+	if err != nil || len(fx.Products) != 1 || fx.Products[0].Price != 1.5 {
+		t.Errorf("json: got %+v, %v", fx, err)
+	}
+
+	for _, path := range []string{
+		write("c.yaml", "users:\n  - emial: typo@example.com\n"),
+		write("d.json", `{"product": []}`),
+		write("e.txt", "users: []"),
+	} {
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected an error", filepath.Base(path))
+		}
+	}
+}
+
+// 清空数据后自增ID从 1 重新开始
+func TestResetSQLite(t *testing.T) {
+	ctx := context.Background()
+	conn, err := db.NewSQLiteStorage(filepath.Join(t.TempDir(), "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := migrations.AutoMigrate(config.Config{}, conn, db.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	stores := storage.SQL(conn, db.SQLite)
+
+	fx := Generate(FakeOptions{Users: 2, Products: 2, Orders: 2, Seed: 1})
+	if _, err := Apply(ctx, stores, fx); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reset(ctx, conn, db.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if n := count(t, conn, table); n != 0 {
+			t.Errorf("%s: expected 0 rows after reset, got %d", table, n)
+		}
+	}
+
+	r, err := Apply(ctx, stores, fx)
+	if err != nil || r.UsersCreated != 2 {
+		t.Fatalf("reseed: %v, %v", r, err)
+	}
+	u, err := stores.Users.GetUserByEmail(ctx, fx.Users[0].Email)
+	if err != nil || u.ID != 1 {
+		t.Errorf("expected ID 1 after reset, got %+v, %v", u, err)
+	}
+}
+
+func mustDemo(t *testing.T) Fixture {
+	t.Helper()
+	fx, err := Demo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fx
+}
+
+func count(t *testing.T, conn *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
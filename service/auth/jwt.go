@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Albert-tru/ecom/apperr"
 	"github.com/Albert-tru/ecom/config"
 	"github.com/Albert-tru/ecom/types"
 	"github.com/Albert-tru/ecom/utils"
@@ -23,11 +24,15 @@ const UserKey contextKey = "user_id"
 func GenerateJWT(secret []byte, userID int) (string, error) {
 	//设置过期时间
 	expiration := time.Second * time.Duration(config.Envs.JWTExpirationSeconds)
+	return GenerateJWTWithTTL(secret, userID, expiration)
+}
 
+// GenerateJWTWithTTL 生成指定有效期的 token，ecomctl 用它签发调试用的短期 token
+func GenerateJWTWithTTL(secret []byte, userID int, ttl time.Duration) (string, error) {
 	// 创建 token				生成签名				map形式存储载荷
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": strconv.Itoa(userID),
-		"exp":     time.Now().Add(expiration).Unix(), // 过期时间戳
+		"exp":     time.Now().Add(ttl).Unix(), // 过期时间戳
 	})
 
 	// 生成并返回签名字符串
@@ -54,53 +59,111 @@ func validateJWT(tokenString string) (*jwt.Token, error) {
 
 func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 1. 从请求中获取token
-		tokenString := utils.GetTokenFromRequest(r)
-
-		// 2. 验证token
-		token, err := validateJWT(tokenString)
-		if err != nil {
-			log.Printf("failed to validate token: %v", err)
-			permissionDenied(w)
+		u, ok := authenticate(w, r, store)
+		if !ok {
 			return
 		}
 
-		if !token.Valid {
-			log.Println("invalid token")
-			permissionDenied(w)
+		// 将用户ID存入Context
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, UserKey, u.ID)
+		r = r.WithContext(ctx)
+
+		// 执行实际的处理函数
+		handlerFunc(w, r)
+	}
+}
+
+// WithAdmin 在 WithJWTAuth 的基础上要求用户是管理员，否则返回 403
+func WithAdmin(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := authenticate(w, r, store)
+		if !ok {
 			return
 		}
-
-		// 3. 提取用户ID
-		claims := token.Claims.(jwt.MapClaims)
-		str := claims["user_id"].(string)
-		userID, err := strconv.Atoi(str)
-		if err != nil {
-			log.Printf("failed to convert userID to int: %v", err)
-			permissionDenied(w)
+		if u.Role != types.RoleAdmin {
+			utils.WriteError(w, r, apperr.Forbidden("admin_required", "this endpoint requires an admin account"))
 			return
 		}
 
-		// 4. 验证用户是否存在
-		u, err := store.GetUserByID(userID)
-		if err != nil {
-			log.Printf("failed to get user by id: %v", err)
-			permissionDenied(w)
-			return
+		ctx := context.WithValue(r.Context(), UserKey, u.ID)
+		handlerFunc(w, r.WithContext(ctx))
+	}
+}
+
+// 校验请求中的 token 并加载用户，失败时已经写好了错误响应
+func authenticate(w http.ResponseWriter, r *http.Request, store types.UserStore) (*types.User, bool) {
+	// 1. 从请求中获取token
+	tokenString := utils.GetTokenFromRequest(r)
+
+	// 2. 验证token并提取用户ID
+	userID, err := userIDFromToken(tokenString)
+	if err != nil {
+		log.Printf("[%s] rejected token: %v", utils.GetRequestID(r.Context()), err)
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	// 3. 验证用户是否存在
+	u, err := store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			utils.WriteError(w, r, err)
+			return nil, false
 		}
+		log.Printf("failed to get user by id: %v", err)
+		permissionDenied(w, r)
+		return nil, false
+	}
 
-		// 5. 将用户ID存入Context
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, UserKey, u.ID)
-		r = r.WithContext(ctx)
+	// 4. 被禁用的账号之前签发的 token 也不再有效
+	if u.Disabled {
+		utils.WriteError(w, r, ErrAccountDisabled)
+		return nil, false
+	}
 
-		// 6. 执行实际的处理函数
-		handlerFunc(w, r)
+	return u, true
+}
+
+// UserIDFromRequest 只校验请求中 JWT 的签名和有效期并取出用户ID，不查询数据库
+// 适用于限流等只需要识别调用方、不需要确认用户仍然存在的场景
+func UserIDFromRequest(r *http.Request) (int, bool) {
+	userID, err := userIDFromToken(utils.GetTokenFromRequest(r))
+	return userID, err == nil
+}
+
+// 校验 token 并取出 user_id，任何不符合预期的 claims 都返回错误而不是 panic
+func userIDFromToken(tokenString string) (int, error) {
+	token, err := validateJWT(tokenString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to validate token: %w", err)
 	}
+	if !token.Valid {
+		return 0, fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, fmt.Errorf("unexpected claims type %T", token.Claims)
+	}
+	str, ok := claims["user_id"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or non-string user_id claim")
+	}
+	userID, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert userID to int: %w", err)
+	}
+
+	return userID, nil
 }
 
-func permissionDenied(w http.ResponseWriter) {
-	http.Error(w, "Permission denied", http.StatusForbidden)
+// ErrAccountDisabled 账号已被禁用
+var ErrAccountDisabled = apperr.Forbidden("account_disabled", "this account has been disabled")
+
+// 缺少或无效的凭证属于未认证（401），而不是无权限（403）
+func permissionDenied(w http.ResponseWriter, r *http.Request) {
+	utils.WriteError(w, r, apperr.Unauthorized("unauthorized", "missing or invalid token"))
 }
 
 func GetUserIDFromContext(ctx context.Context) int {
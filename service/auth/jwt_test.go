@@ -189,7 +189,7 @@ func TestWithJWTAuth(t *testing.T) {
 		// 创建带有 JWT 的请求
 		req, _ := http.NewRequest("GET", "/test", nil)
 		token, _ := GenerateJWT([]byte(config.Envs.JWTSecret), 123)
-		req.Header.Set("Authorization", "Bearer " + token) // 假设 validateJWT 已被模拟
+		req.Header.Set("Authorization", "Bearer "+token) // 假设 validateJWT 已被模拟
 
 		// 创建响应记录器
 		rr := httptest.NewRecorder()
@@ -213,7 +213,7 @@ type MockUserStore struct {
 	// 可以根据需要添加更多方法
 }
 
-func (m *MockUserStore) GetUserByID(id int) (*types.User, error) {
+func (m *MockUserStore) GetUserByID(ctx context.Context, id int) (*types.User, error) {
 	if m.getUserByIDFunc != nil {
 		return m.getUserByIDFunc(id)
 	}
@@ -221,10 +221,88 @@ func (m *MockUserStore) GetUserByID(id int) (*types.User, error) {
 }
 
 // 实现接口所需的其他方法
-func (m *MockUserStore) GetUserByEmail(email string) (*types.User, error) {
+func (m *MockUserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
 	return nil, nil
 }
 
-func (m *MockUserStore) CreateUser(u *types.User) error {
+func (m *MockUserStore) CreateUser(ctx context.Context, u *types.User) error {
 	return nil
 }
+
+func (m *MockUserStore) GetUsers(ctx context.Context) ([]types.User, error) {
+	return nil, nil
+}
+
+func (m *MockUserStore) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	return nil
+}
+
+func (m *MockUserStore) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return nil
+}
+
+// 被禁用的账号即使 token 有效也返回 403；非管理员访问管理接口返回 403
+func TestDisabledAndAdmin(t *testing.T) {
+	token, _ := GenerateJWT([]byte(config.Envs.JWTSecret), 7)
+	cases := []struct {
+		name       string
+		user       types.User
+		middleware func(http.HandlerFunc, types.UserStore) http.HandlerFunc
+		want       int
+	}{
+		{"禁用的用户", types.User{ID: 7, Disabled: true}, WithJWTAuth, http.StatusForbidden},
+		{"普通用户访问管理接口", types.User{ID: 7, Role: types.RoleCustomer}, WithAdmin, http.StatusForbidden},
+		{"禁用的管理员", types.User{ID: 7, Role: types.RoleAdmin, Disabled: true}, WithAdmin, http.StatusForbidden},
+		{"管理员", types.User{ID: 7, Role: types.RoleAdmin}, WithAdmin, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &MockUserStore{getUserByIDFunc: func(id int) (*types.User, error) { u := c.user; return &u, nil }}
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			c.middleware(func(w http.ResponseWriter, r *http.Request) {
+				if GetUserIDFromContext(r.Context()) != 7 {
+					t.Error("context 中缺少用户ID")
+				}
+			}, store)(rr, req)
+
+			if rr.Code != c.want {
+				t.Errorf("期望状态码 %d, 实际状态码 %d", c.want, rr.Code)
+			}
+		})
+	}
+}
+
+// 缺少 user_id 或类型不对的 token 不能让中间件 panic，而应返回 401
+func TestWithJWTAuthMalformedClaims(t *testing.T) {
+	secret := []byte(config.Envs.JWTSecret)
+	claimsCases := map[string]jwt.MapClaims{
+		"缺少 user_id":    {"exp": time.Now().Add(time.Hour).Unix()},
+		"user_id 不是字符串": {"user_id": 123, "exp": time.Now().Add(time.Hour).Unix()},
+		"user_id 不是数字":  {"user_id": "abc", "exp": time.Now().Add(time.Hour).Unix()},
+	}
+
+	for name, claims := range claimsCases {
+		t.Run(name, func(t *testing.T) {
+			tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			rr := httptest.NewRecorder()
+
+			handlerCalled := false
+			handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) { handlerCalled = true }, &MockUserStore{})
+			handler(rr, req)
+
+			if handlerCalled {
+				t.Error("无效的 token 不应调用原始处理函数")
+			}
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+			}
+		})
+	}
+}
@@ -1,69 +1,94 @@
 package cart
 
 import (
+	"context"
+	"log"
 	"net/http"
 
+	"github.com/Albert-tru/ecom/notify"
 	"github.com/Albert-tru/ecom/service/auth" // ✅ 添加这行
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/tracing"
 	"github.com/Albert-tru/ecom/types"
 	"github.com/Albert-tru/ecom/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Handler struct {
-	store        types.OrderStore
-	productStore types.ProductStore
-	userStore    types.UserStore
+	stores   types.Stores
+	notifier notify.Notifier
 }
 
-func NewHandler(store types.OrderStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+// notifier 用于库存降到补货阈值以下时发出告警
+func NewHandler(stores types.Stores, notifier notify.Notifier) *Handler {
 	return &Handler{
-		store:        store,
-		productStore: productStore,
-		userStore:    userStore,
+		stores:   stores,
+		notifier: notifier,
 	}
 }
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/cart/checkout", auth.WithJWTAuth(h.handleCheckout, h.userStore)).Methods("POST")
+	router.HandleFunc("/cart/checkout", auth.WithJWTAuth(h.handleCheckout, h.stores.Users)).Methods("POST")
 }
 
 func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserIDFromContext(r.Context())
 	var cart types.CartCheckoutPayload
-	if err := utils.ParseJson(r, &cart); err != nil {
-		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	if err := utils.ParseJson(w, r, &cart); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	if err := utils.Validate.Struct(cart); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteJson(w, http.StatusBadRequest, map[string]interface{}{"error": "Validation failed", "details": errors.Error()})
+	if err := utils.ValidatePayload(r, cart); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	//获取产品
-	productIDs, err := getCartItemsIDs(cart.Items)
-	if err != nil {
-		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid cart items"})
-		return
-	}
+	// 确定变体、读取产品、检查库存、创建订单和订单项在同一个事务中完成，任何一步失败都不会留下半个订单
+	var (
+		orderID    int
+		totalPrice float64
+		// 结账前的产品和解析后的购物车项，用于检查补货阈值
+		before   []types.Product
+		resolved []types.CartItem
+	)
+	err := h.stores.Tx.WithinTx(r.Context(), func(tx types.Stores) error {
+		items, variants, err := resolveVariants(r.Context(), tx.Variants, cart.Items)
+		if err != nil {
+			return err
+		}
 
-	ps, err := h.productStore.GetProductByIDs(productIDs)
-	if err != nil {
-		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve products"})
-		return
-	}
+		productIDs := make([]int, len(items))
+		for i, item := range items {
+			productIDs[i] = item.ProductID
+		}
+		ctx, span := tracing.Start(r.Context(), "cart.GetProductByIDs", attribute.Int("cart.items", len(productIDs)))
+		ps, err := tx.Products.GetProductByIDs(ctx, productIDs)
+		tracing.End(span, err)
+		if err != nil {
+			return err
+		}
 
-	orderID, totalPrice, err := h.CreateOrder(ps, cart.Items, userID)
+		orderID, totalPrice, err = CreateOrder(r.Context(), tx.Orders, ps, variants, items, userID)
+		before, resolved = ps, items
+		return err
+	})
 	if err != nil {
-		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	utils.WriteJson(w, http.StatusOK, map[string]interface{}{
-		"status":     "success",
-		"orderId":    orderID,
-		"totalPrice": totalPrice,
+	// 库存告警在后台发送，不影响结账的响应
+	go func() {
+		if _, err := inventory.CheckLowStock(context.WithoutCancel(r.Context()), h.stores, h.notifier, before, resolved); err != nil {
+			log.Printf("low stock check for order %d failed: %v", orderID, err)
+		}
+	}()
+
+	utils.WriteJson(w, http.StatusOK, types.CheckoutResponse{
+		Status:     "success",
+		OrderID:    orderID,
+		TotalPrice: totalPrice,
 	})
 
 }
@@ -0,0 +1,158 @@
+package cart_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/cart"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestCheckout(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 5},
+		types.Product{Name: "t-shirt", Price: 20, Quantity: 2},
+	)
+	// t-shirt 有两个变体：默认变体使用产品价格，蓝色单独定价
+	price := 25.0
+	blue := &types.ProductVariant{ProductID: 2, SKU: "TS-BLUE", Price: &price, Quantity: 3, Options: map[string]string{"colour": "blue"}}
+	if err := store.CreateVariant(ctx, blue); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	cart.NewHandler(store.Stores(), notify.Log{}).RegisterRoutes(router)
+
+	buyer := &types.User{Email: "buyer@example.com"}
+	if err := store.CreateUser(ctx, buyer); err != nil {
+		t.Fatal(err)
+	}
+
+	api := apitest.New(router)
+	stock := func(t *testing.T, variantID int) int {
+		t.Helper()
+		vs, err := store.GetVariantsByIDs(ctx, []int{variantID})
+		if err != nil || len(vs) != 1 {
+			t.Fatalf("get variant: %+v %v", vs, err)
+		}
+		return vs[0].Quantity
+	}
+	lampVariant, shirtVariant := 1, 2
+
+	t.Run("多个变体的产品必须指定变体", func(t *testing.T) {
+		var p apperr.Problem
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", `{"items":[{"productId":2,"quantity":1}]}`),
+			http.StatusBadRequest, &p)
+		if p.Code != "variant_required" {
+			t.Errorf("expected variant_required, got %+v", p)
+		}
+
+		// 变体不属于该产品
+		if rr := api.Do(buyer, http.MethodPost, "/cart/checkout", `{"items":[{"productId":1,"variantId":3,"quantity":1}]}`); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := api.Do(nil, http.MethodPost, "/cart/checkout", `{"items":[{"productId":1,"quantity":1}]}`); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("按变体价格计算总价并扣减库存", func(t *testing.T) {
+		var res types.CheckoutResponse
+		body := `{"items":[{"variantId":3,"quantity":2},{"productId":2,"variantId":2,"quantity":1},{"productId":1,"quantity":1}]}`
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", body), http.StatusOK, &res)
+		// 25*2 + 20 + 15
+		if res.Status != "success" || res.OrderID == 0 || res.TotalPrice != 85 {
+			t.Fatalf("unexpected response: %+v", res)
+		}
+
+		items, err := store.GetOrderItems(ctx, res.OrderID)
+		if err != nil || len(items) != 3 {
+			t.Fatalf("get order items: %+v %v", items, err)
+		}
+		for i, want := range []types.OrderItem{
+			{ProductID: 2, VariantID: blue.ID, Quantity: 2, Price: 25},
+			{ProductID: 2, VariantID: shirtVariant, Quantity: 1, Price: 20},
+			{ProductID: 1, VariantID: lampVariant, Quantity: 1, Price: 15},
+		} {
+			got := items[i]
+			if got.ProductID != want.ProductID || got.VariantID != want.VariantID || got.Quantity != want.Quantity || got.Price != want.Price {
+				t.Errorf("item %d: expected %+v, got %+v", i, want, got)
+			}
+		}
+		if got := stock(t, blue.ID); got != 1 {
+			t.Errorf("expected 1 blue left, got %d", got)
+		}
+		if got := stock(t, lampVariant); got != 4 {
+			t.Errorf("expected 4 lamps left, got %d", got)
+		}
+	})
+
+	t.Run("某个变体库存不足时整单回滚", func(t *testing.T) {
+		var before types.CheckoutResponse
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", `{"items":[{"productId":1,"quantity":1}]}`), http.StatusOK, &before)
+		lamps := stock(t, lampVariant)
+
+		var p apperr.Problem
+		body := `{"items":[{"productId":1,"quantity":1},{"variantId":3,"quantity":1},{"variantId":3,"quantity":1}]}`
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", body), http.StatusConflict, &p)
+		if p.Code != "insufficient_stock" {
+			t.Errorf("expected insufficient_stock, got %+v", p)
+		}
+		if got := stock(t, lampVariant); got != lamps {
+			t.Errorf("expected lamp stock to stay %d, got %d", lamps, got)
+		}
+		if got := stock(t, blue.ID); got != 1 {
+			t.Errorf("expected blue stock to stay 1, got %d", got)
+		}
+		if _, err := store.GetOrderByID(ctx, before.OrderID+1); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected no order to be created, got %v", err)
+		}
+	})
+	t.Run("促销期间单独定价的变体也按促销价结算", func(t *testing.T) {
+		if _, err := store.AdjustVariantStock(ctx, blue.ID, 2, types.StockChange{Type: types.StockRestock}); err != nil {
+			t.Fatal(err)
+		}
+		compareAt := 20.0
+		ends := time.Now().Add(time.Hour)
+		sale := &types.ProductPrice{ProductID: 2, Price: 16, CompareAtPrice: &compareAt, EffectiveFrom: time.Now().Add(-time.Hour), EffectiveTo: &ends}
+		if err := store.CreatePrice(ctx, sale); err != nil {
+			t.Fatal(err)
+		}
+
+		var res types.CheckoutResponse
+		body := `{"items":[{"variantId":3,"quantity":1},{"productId":2,"variantId":2,"quantity":1}]}`
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", body), http.StatusOK, &res)
+		if res.TotalPrice != 32 {
+			t.Fatalf("expected both variants at the sale price, got %+v", res)
+		}
+		items, err := store.GetOrderItems(ctx, res.OrderID)
+		if err != nil || len(items) != 2 {
+			t.Fatalf("get order items: %+v %v", items, err)
+		}
+		for _, item := range items {
+			if item.Price != 16 || item.PriceID == nil || *item.PriceID != sale.ID {
+				t.Errorf("expected item at sale price %d, got %+v", sale.ID, item)
+			}
+		}
+
+		// 促销结束后恢复变体自己的价格
+		if err := store.EndPrice(ctx, sale.ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", `{"items":[{"variantId":3,"quantity":1}]}`), http.StatusOK, &res)
+		items, err = store.GetOrderItems(ctx, res.OrderID)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("get order items: %+v %v", items, err)
+		}
+		if res.TotalPrice != 25 || items[0].Price != 25 || items[0].PriceID != nil {
+			t.Errorf("expected the variant price after the sale, got %+v %+v", res, items[0])
+		}
+	})
+}
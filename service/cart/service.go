@@ -1,91 +1,175 @@
 package cart
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/tracing"
 	"github.com/Albert-tru/ecom/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func getCartItemsIDs(items []types.CartItem) ([]int, error) {
-	ids := make([]int, 0, len(items))
+// resolveVariants 找到每个购物车项对应的变体，返回填好 VariantID 的购物车项
+// 只传了 productId 的项使用产品唯一的变体，产品有多个变体时返回 variant_required
+func resolveVariants(ctx context.Context, store types.VariantStore, items []types.CartItem) ([]types.CartItem, []types.ProductVariant, error) {
+	var ids []int
 	for _, item := range items {
-		if item.ProductID <= 0 {
-			return nil, fmt.Errorf("invalid product ID: %d", item.ProductID)
+		if item.ProductID < 0 {
+			return nil, nil, apperr.Validation("invalid_product_id", fmt.Sprintf("invalid product ID: %d", item.ProductID))
 		}
-		ids = append(ids, item.ProductID)
+		if item.VariantID < 0 {
+			return nil, nil, apperr.Validation("invalid_variant_id", fmt.Sprintf("invalid variant ID: %d", item.VariantID))
+		}
+		if item.VariantID > 0 {
+			ids = append(ids, item.VariantID)
+		}
+	}
+
+	variants, err := store.GetVariantsByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	variantMap := make(map[int]types.ProductVariant)
+	for _, v := range variants {
+		variantMap[v.ID] = v
+	}
+
+	resolved := make([]types.CartItem, len(items))
+	for i, item := range items {
+		if item.VariantID == 0 {
+			vs, err := store.GetVariants(ctx, item.ProductID)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch len(vs) {
+			case 0:
+				return nil, nil, apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", item.ProductID))
+			case 1:
+				item.VariantID = vs[0].ID
+				if _, ok := variantMap[vs[0].ID]; !ok {
+					variantMap[vs[0].ID] = vs[0]
+					variants = append(variants, vs[0])
+				}
+			default:
+				return nil, nil, variant.VariantRequired(item.ProductID)
+			}
+		}
+
+		v, ok := variantMap[item.VariantID]
+		if !ok || item.ProductID != 0 && v.ProductID != item.ProductID {
+			return nil, nil, apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d not found", item.VariantID))
+		}
+		item.ProductID = v.ProductID
+		resolved[i] = item
 	}
-	return ids, nil
+	return resolved, variants, nil
 }
 
-// CreateOrder 创建订单，返回订单ID和总金额
-func (h *Handler) CreateOrder(ps []types.Product, items []types.CartItem, userID int) (int, float64, error) {
+// CreateOrder 创建订单，返回订单ID和总金额；items 中的 VariantID 必须已经确定（见 resolveVariants）
+// 每个结账步骤都会开启一个子 span，方便定位慢在哪一步；需要原子性时应传入事务中的 store
+func CreateOrder(ctx context.Context, store types.OrderStore, ps []types.Product, variants []types.ProductVariant, items []types.CartItem, userID int) (_ int, _ float64, err error) {
+	ctx, span := tracing.Start(ctx, "cart.CreateOrder",
+		attribute.Int("user.id", userID),
+		attribute.Int("cart.items", len(items)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	// 将产品列表转换为map，方便后续查找
 	productMap := make(map[int]types.Product)
 	for _, p := range ps {
 		productMap[p.ID] = p
 	}
+	variantMap := make(map[int]types.ProductVariant)
+	for _, v := range variants {
+		variantMap[v.ID] = v
+	}
 
 	// 检查库存
-	if err := checkStock(productMap, items); err != nil {
+	_, stockSpan := tracing.Start(ctx, "cart.checkStock")
+	err = checkStock(productMap, variantMap, items)
+	tracing.End(stockSpan, err)
+	if err != nil {
 		return 0, 0, err
 	}
 
 	// 计算总价
-	totalPrice := calculateTotalPrice(productMap, items)
+	totalPrice := calculateTotalPrice(productMap, variantMap, items)
+	span.SetAttributes(attribute.Float64("order.total", totalPrice))
 
 	// 创建订单
-	orderID, err := h.store.CreateOrder(types.Order{
+	orderCtx, orderSpan := tracing.Start(ctx, "cart.createOrder")
+	orderID, err := store.CreateOrder(orderCtx, types.Order{
 		UserID:  userID,
 		Total:   totalPrice,
-		Status:  "pending",
+		Status:  types.OrderPending,
 		Address: "some address",
 	})
+	tracing.End(orderSpan, err)
 	if err != nil {
 		return 0, 0, err
 	}
+	span.SetAttributes(attribute.Int("order.id", orderID))
 
 	// 创建订单项
+	itemsCtx, itemsSpan := tracing.Start(ctx, "cart.createOrderItems", attribute.Int("cart.items", len(items)))
 	for _, cartItem := range items {
-		p := productMap[cartItem.ProductID]
-		err := h.store.CreateOrderItem(types.OrderItem{
+		v := variantMap[cartItem.VariantID]
+		price, priceID := pricing.VariantPrice(productMap[v.ProductID], v)
+		err = store.CreateOrderItem(itemsCtx, types.OrderItem{
 			OrderID:   orderID,
-			ProductID: p.ID,
+			ProductID: v.ProductID,
+			VariantID: v.ID,
 			Quantity:  cartItem.Quantity,
-			Price:     p.Price,
+			Price:     price,
+			PriceID:   priceID,
 		})
 		if err != nil {
-			return 0, 0, err
+			break
 		}
 	}
+	tracing.End(itemsSpan, err)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	return orderID, totalPrice, nil
 }
 
-func checkStock(productMap map[int]types.Product, items []types.CartItem) error {
+// checkStock 按变体检查库存，同一个变体出现在多个购物车项中时合计数量
+func checkStock(productMap map[int]types.Product, variantMap map[int]types.ProductVariant, items []types.CartItem) error {
 	if len(productMap) == 0 {
-		return fmt.Errorf("product map is empty")
+		return apperr.NotFound("product_not_found", "none of the requested products exist")
 	}
+	requested := map[int]int{}
 	for _, item := range items {
-		p, exists := productMap[item.ProductID]
+		v, exists := variantMap[item.VariantID]
 		if !exists {
-			return fmt.Errorf("product ID %d not found", item.ProductID)
+			return apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d not found", item.VariantID))
+		}
+		if _, exists := productMap[v.ProductID]; !exists {
+			return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", v.ProductID))
 		}
-		if p.Quantity < item.Quantity {
-			return fmt.Errorf("insufficient stock for product ID %d", item.ProductID)
+		requested[v.ID] += item.Quantity
+		if v.Quantity < requested[v.ID] {
+			return apperr.Conflict("insufficient_stock", fmt.Sprintf("insufficient stock for product ID %d (SKU %s)", v.ProductID, v.SKU))
 		}
 	}
 
 	return nil
 }
 
-func calculateTotalPrice(productMap map[int]types.Product, items []types.CartItem) float64 {
+func calculateTotalPrice(productMap map[int]types.Product, variantMap map[int]types.ProductVariant, items []types.CartItem) float64 {
 	total := 0.0
 	for _, item := range items {
-		p, exists := productMap[item.ProductID]
+		v, exists := variantMap[item.VariantID]
 		if !exists {
 			continue
 		}
-		total += p.Price * float64(item.Quantity)
+		price, _ := pricing.VariantPrice(productMap[v.ProductID], v)
+		total += price * float64(item.Quantity)
 	}
 	return total
 }
@@ -0,0 +1,332 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Format 导入导出的文件格式
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+// 列名与 types.ProductRecord 的 json 字段一致，CSV 导出时按这个顺序输出
+var columns = []string{"sku", "name", "description", "price", "quantity", "imageUrl"}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// ParseFormat 解析 format 参数，为空时返回空字符串
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", CSV, JSONL:
+		return f, nil
+	case "ndjson":
+		return JSONL, nil
+	default:
+		return "", apperr.BadRequest("invalid_format", fmt.Sprintf("unknown format %q, expected csv or jsonl", s))
+	}
+}
+
+// FormatFromContentType 根据 Content-Type 判断格式，无法识别时返回 415
+func FormatFromContentType(contentType string) (Format, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return CSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines", "application/jsonlines":
+		return JSONL, nil
+	}
+	return "", apperr.UnsupportedMediaType("unsupported_media_type",
+		"Content-Type must be text/csv or application/x-ndjson, or pass ?format=csv|jsonl")
+}
+
+// ContentType 导出时使用的 Content-Type
+func (f Format) ContentType() string {
+	if f == JSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Row 解析并校验通过的一行
+type Row struct {
+	Line   int
+	Record types.ProductRecord
+}
+
+// Batch 解析后的导入文件：可以导入的行，以及无法解析或校验失败的行
+type Batch struct {
+	Rows   []Row
+	Errors []types.ImportRowError
+}
+
+// Total 数据行数
+func (b *Batch) Total() int {
+	return len(b.Rows) + len(b.Errors)
+}
+
+// Parse 解析整个文件并逐行校验，validate 通常是 utils.ValidatePayload 或 utils.ValidateStruct
+// 单行的问题记录在 Batch.Errors 中；表头不正确、文件为空等整体性问题返回 400
+func Parse(format Format, data []byte, validate func(any) error) (*Batch, error) {
+	var (
+		b   *Batch
+		err error
+	)
+	if format == JSONL {
+		b = parseJSONL(data)
+	} else {
+		b, err = parseCSV(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b.Total() == 0 {
+		return nil, apperr.BadRequest("empty_file", "the file contains no rows")
+	}
+
+	// 校验字段，同一个 SKU 只能出现一次
+	rows := b.Rows[:0]
+	firstLine := map[string]int{}
+	for _, row := range b.Rows {
+		if err := validate(row.Record); err != nil {
+			b.Errors = append(b.Errors, rowError(row.Line, row.Record.SKU, err))
+			continue
+		}
+		if line, ok := firstLine[row.Record.SKU]; ok {
+			b.Errors = append(b.Errors, types.ImportRowError{Line: row.Line, SKU: row.Record.SKU, Code: "duplicate_sku",
+				Message: fmt.Sprintf("SKU %q already appears on line %d", row.Record.SKU, line)})
+			continue
+		}
+		firstLine[row.Record.SKU] = row.Line
+		rows = append(rows, row)
+	}
+	b.Rows = rows
+	slices.SortFunc(b.Errors, func(a, b types.ImportRowError) int { return a.Line - b.Line })
+	return b, nil
+}
+
+func parseCSV(data []byte) (*Batch, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, apperr.BadRequest("empty_file", "the file contains no rows")
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	index, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{}
+	for {
+		cells, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := r.FieldPos(0)
+		if blank(cells) {
+			continue
+		}
+		if len(cells) != len(header) {
+			b.Errors = append(b.Errors, types.ImportRowError{Line: line, Code: "invalid_row",
+				Message: fmt.Sprintf("expected %d fields, got %d", len(header), len(cells))})
+			continue
+		}
+
+		rec, fields := recordFromCells(cells, index)
+		if len(fields) > 0 {
+			b.Errors = append(b.Errors, types.ImportRowError{Line: line, SKU: rec.SKU, Code: "validation_failed",
+				Message: "row validation failed", Fields: fields})
+			continue
+		}
+		b.Rows = append(b.Rows, Row{Line: line, Record: rec})
+	}
+	return b, nil
+}
+
+// parseHeader 返回列名到列下标的映射，列名不区分大小写，必须包含 sku
+func parseHeader(header []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		j := slices.IndexFunc(columns, func(c string) bool { return strings.EqualFold(c, name) })
+		if j < 0 {
+			return nil, apperr.BadRequest("unknown_column",
+				fmt.Sprintf("unknown column %q, expected %s", name, strings.Join(columns, ", ")))
+		}
+		if _, ok := index[columns[j]]; ok {
+			return nil, apperr.BadRequest("duplicate_column", fmt.Sprintf("column %q appears more than once", columns[j]))
+		}
+		index[columns[j]] = i
+	}
+	if _, ok := index["sku"]; !ok {
+		return nil, apperr.BadRequest("missing_column", "the header must contain a sku column")
+	}
+	return index, nil
+}
+
+// recordFromCells 把一行单元格转换为记录，空单元格表示保持不变
+func recordFromCells(cells []string, index map[string]int) (types.ProductRecord, []apperr.FieldError) {
+	cell := func(name string) (string, bool) {
+		i, ok := index[name]
+		if !ok {
+			return "", false
+		}
+		v := strings.TrimSpace(cells[i])
+		return v, v != ""
+	}
+	text := func(name string) *string {
+		if v, ok := cell(name); ok {
+			return &v
+		}
+		return nil
+	}
+
+	var (
+		rec    types.ProductRecord
+		fields []apperr.FieldError
+	)
+	rec.SKU, _ = cell("sku")
+	rec.Name = text("name")
+	rec.Description = text("description")
+	rec.ImageURL = text("imageUrl")
+	if v, ok := cell("price"); ok {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			fields = append(fields, apperr.FieldError{Field: "price", Rule: "number", Param: v, Message: "price must be a number"})
+		} else {
+			rec.Price = &price
+		}
+	}
+	if v, ok := cell("quantity"); ok {
+		quantity, err := strconv.Atoi(v)
+		if err != nil {
+			fields = append(fields, apperr.FieldError{Field: "quantity", Rule: "integer", Param: v, Message: "quantity must be an integer"})
+		} else {
+			rec.Quantity = &quantity
+		}
+	}
+	return rec, fields
+}
+
+func parseJSONL(data []byte) *Batch {
+	b := &Batch{}
+	for i, raw := range bytes.Split(data, []byte("\n")) {
+		line := i + 1
+		raw = bytes.TrimSpace(bytes.TrimPrefix(raw, utf8BOM))
+		if len(raw) == 0 {
+			continue
+		}
+
+		var rec types.ProductRecord
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			b.Errors = append(b.Errors, types.ImportRowError{Line: line, Code: "invalid_json", Message: err.Error()})
+			continue
+		}
+		rec.SKU = strings.TrimSpace(rec.SKU)
+		if dec.More() {
+			b.Errors = append(b.Errors, types.ImportRowError{Line: line, SKU: rec.SKU, Code: "invalid_json",
+				Message: "each line must contain a single JSON object"})
+			continue
+		}
+		b.Rows = append(b.Rows, Row{Line: line, Record: rec})
+	}
+	return b
+}
+
+func csvError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return apperr.BadRequest("invalid_csv", fmt.Sprintf("invalid CSV on line %d: %v", perr.Line, perr.Err))
+	}
+	return apperr.BadRequest("invalid_csv", "invalid CSV").WithCause(err)
+}
+
+func blank(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// rowError 把导入一行时的错误转换为行错误
+func rowError(line int, sku string, err error) types.ImportRowError {
+	e := apperr.From(err)
+	return types.ImportRowError{Line: line, SKU: sku, Code: e.Code, Message: e.Message, Fields: e.Fields}
+}
+
+// Writer 按格式逐行写出记录，CSV 会先写表头
+type Writer struct {
+	csv *csv.Writer
+	enc *json.Encoder
+}
+
+func NewWriter(format Format, w io.Writer) *Writer {
+	if format == JSONL {
+		return &Writer{enc: json.NewEncoder(w)}
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	return &Writer{csv: cw}
+}
+
+func (w *Writer) Write(rec types.ProductRecord) error {
+	if w.enc != nil {
+		return w.enc.Encode(rec)
+	}
+	return w.csv.Write([]string{
+		rec.SKU, deref(rec.Name), deref(rec.Description),
+		formatOptional(rec.Price, func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }),
+		formatOptional(rec.Quantity, strconv.Itoa),
+		deref(rec.ImageURL),
+	})
+}
+
+// Flush 把缓冲的内容写出，返回之前写入时发生的错误
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatOptional[T any](v *T, format func(T) string) string {
+	if v == nil {
+		return ""
+	}
+	return format(*v)
+}
@@ -0,0 +1,144 @@
+package catalog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+)
+
+func TestParseCSV(t *testing.T) {
+	t.Run("空单元格保持不变，列名不区分大小写", func(t *testing.T) {
+		data := "\xef\xbb\xbfSKU,Price,quantity,name\r\n" +
+			"A-1,9.5,,\r\n" +
+			"\r\n" +
+			`B-1,,3,"Desk, oak"` + "\r\n"
+		b, err := Parse(CSV, []byte(data), utils.ValidateStruct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Rows) != 2 || len(b.Errors) != 0 {
+			t.Fatalf("unexpected batch: %+v", b)
+		}
+		a := b.Rows[0].Record
+		if a.SKU != "A-1" || a.Price == nil || *a.Price != 9.5 || a.Quantity != nil || a.Name != nil || a.Description != nil {
+			t.Errorf("unexpected record: %+v", a)
+		}
+		if r := b.Rows[1]; r.Line != 4 || *r.Record.Name != "Desk, oak" || *r.Record.Quantity != 3 {
+			t.Errorf("unexpected row: %+v", r)
+		}
+	})
+
+	t.Run("逐行报告错误", func(t *testing.T) {
+		data := "sku,price,quantity\n" +
+			"A-1,abc,1\n" + // 价格不是数字
+			"A-2,-1,1\n" + // 价格必须大于 0
+			",5,1\n" + // 缺少 SKU
+			"A-3,5\n" + // 列数不对
+			"A-4,5,1\n" +
+			"A-4,6,1\n" // 重复的 SKU
+		b, err := Parse(CSV, []byte(data), utils.ValidateStruct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Rows) != 1 || b.Rows[0].Record.SKU != "A-4" || b.Total() != 6 {
+			t.Fatalf("unexpected rows: %+v", b.Rows)
+		}
+		want := []struct {
+			line int
+			code string
+		}{{2, "validation_failed"}, {3, "validation_failed"}, {4, "validation_failed"}, {5, "invalid_row"}, {7, "duplicate_sku"}}
+		if len(b.Errors) != len(want) {
+			t.Fatalf("unexpected errors: %+v", b.Errors)
+		}
+		for i, w := range want {
+			if e := b.Errors[i]; e.Line != w.line || e.Code != w.code {
+				t.Errorf("error %d: expected line %d %s, got %+v", i, w.line, w.code, e)
+			}
+		}
+		if f := b.Errors[0].Fields; len(f) != 1 || f[0].Field != "price" {
+			t.Errorf("unexpected fields: %+v", f)
+		}
+	})
+
+	t.Run("表头不正确", func(t *testing.T) {
+		cases := map[string]string{
+			"sku,colour\nA,red\n": "unknown_column",
+			"sku,SKU\nA,B\n":      "duplicate_column",
+			"name,price\nA,1\n":   "missing_column",
+			"sku,name\n":          "empty_file",
+			"":                    "empty_file",
+		}
+		for data, code := range cases {
+			_, err := Parse(CSV, []byte(data), utils.ValidateStruct)
+			if !apperr.IsKind(err, apperr.KindBadRequest) || apperr.From(err).Code != code {
+				t.Errorf("%q: expected %s, got %v", data, code, err)
+			}
+		}
+	})
+}
+
+func TestParseJSONL(t *testing.T) {
+	data := `{"sku":"A-1","price":9.5}` + "\n" +
+		"\n" +
+		`{"sku":"A-2","colour":"red"}` + "\n" +
+		`{"sku":"A-3"} {"sku":"A-4"}` + "\n" +
+		`{"sku":"A-5","quantity":-1}`
+	b, err := Parse(JSONL, []byte(data), utils.ValidateStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Rows) != 1 || *b.Rows[0].Record.Price != 9.5 {
+		t.Fatalf("unexpected rows: %+v", b.Rows)
+	}
+	codes := []string{}
+	for _, e := range b.Errors {
+		codes = append(codes, e.Code)
+	}
+	if len(b.Errors) != 3 || b.Errors[0].Line != 3 || codes[0] != "invalid_json" || codes[1] != "invalid_json" || codes[2] != "validation_failed" {
+		t.Errorf("unexpected errors: %+v", b.Errors)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	name, desc, image := "Desk, oak", "", "https://example.com/desk.png"
+	price, quantity := 120.5, 4
+	rec := types.ProductRecord{SKU: "DESK-1", Name: &name, Description: &desc, Price: &price, Quantity: &quantity, ImageURL: &image}
+
+	for _, format := range []Format{CSV, JSONL} {
+		var buf bytes.Buffer
+		w := NewWriter(format, &buf)
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := Parse(format, buf.Bytes(), utils.ValidateStruct)
+		if err != nil || len(b.Rows) != 1 {
+			t.Fatalf("%s: unexpected result: %+v %v", format, b, err)
+		}
+		got := b.Rows[0].Record
+		if got.SKU != rec.SKU || *got.Name != name || *got.Price != price || *got.Quantity != quantity || *got.ImageURL != image {
+			t.Errorf("%s: unexpected record: %+v", format, got)
+		}
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	if f, err := FormatFromContentType("text/csv; charset=utf-8"); err != nil || f != CSV {
+		t.Errorf("unexpected format: %s %v", f, err)
+	}
+	if f, err := FormatFromContentType("application/x-ndjson"); err != nil || f != JSONL {
+		t.Errorf("unexpected format: %s %v", f, err)
+	}
+	if _, err := FormatFromContentType("application/json"); !apperr.IsKind(err, apperr.KindUnsupportedMediaType) {
+		t.Errorf("expected unsupported media type, got %v", err)
+	}
+	if _, err := ParseFormat("xlsx"); !apperr.IsKind(err, apperr.KindBadRequest) {
+		t.Errorf("expected bad request, got %v", err)
+	}
+}
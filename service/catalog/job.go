@@ -0,0 +1,123 @@
+package catalog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// maxJobs 最多保留的已结束任务数，超出时丢弃最早的
+const maxJobs = 100
+
+// Jobs 在后台执行大批量导入，同一时间只执行一个导入，其余排队等待
+// 任务只保存在当前进程的内存中，服务重启后丢失，多实例部署时需要在同一个实例上查询
+type Jobs struct {
+	stores types.Stores
+	sem    chan struct{}
+
+	mu    sync.Mutex
+	jobs  map[string]*types.ImportJob
+	order []string
+}
+
+func NewJobs(stores types.Stores) *Jobs {
+	return &Jobs{stores: stores, sem: make(chan struct{}, 1), jobs: map[string]*types.ImportJob{}}
+}
+
+// Start 创建任务并在后台执行，返回任务的快照；任务不随请求的 ctx 取消
+func (j *Jobs) Start(ctx context.Context, b *Batch, dryRun bool, actorID int) types.ImportJob {
+	job := &types.ImportJob{
+		ID:        newJobID(),
+		Status:    types.ImportQueued,
+		DryRun:    dryRun,
+		Total:     b.Total(),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	j.mu.Lock()
+	j.jobs[job.ID] = job
+	j.order = append(j.order, job.ID)
+	j.prune()
+	snapshot := *job
+	j.mu.Unlock()
+
+	go j.run(context.WithoutCancel(ctx), job, b, actorID)
+	return snapshot
+}
+
+// Get 返回任务的快照
+func (j *Jobs) Get(id string) (*types.ImportJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, apperr.NotFound("import_job_not_found", "import job "+id+" not found")
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+func (j *Jobs) run(ctx context.Context, job *types.ImportJob, b *Batch, actorID int) {
+	j.sem <- struct{}{}
+	defer func() { <-j.sem }()
+
+	j.update(job, func() { job.Status = types.ImportRunning })
+
+	// 无法导入的行在解析时已经处理完
+	skipped := len(b.Errors)
+	result, err := Import(ctx, j.stores, b, job.DryRun, actorID, func(processed int) {
+		j.update(job, func() { job.Processed = skipped + processed })
+	})
+
+	j.update(job, func() {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if err != nil {
+			log.Printf("catalog: import job %s failed: %v", job.ID, err)
+			job.Status = types.ImportFailed
+			job.Error = apperr.From(err).Message
+			return
+		}
+		job.Status = types.ImportCompleted
+		job.Processed = job.Total
+		job.Result = result
+	})
+}
+
+func (j *Jobs) update(job *types.ImportJob, fn func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn()
+}
+
+// prune 丢弃超出数量的最早的已结束任务，调用方需要持有锁
+func (j *Jobs) prune() {
+	finished := 0
+	for _, id := range j.order {
+		if j.jobs[id].FinishedAt != nil {
+			finished++
+		}
+	}
+	kept := j.order[:0]
+	for _, id := range j.order {
+		if finished > maxJobs && j.jobs[id].FinishedAt != nil {
+			delete(j.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	j.order = kept
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
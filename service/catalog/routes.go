@@ -0,0 +1,161 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+// Options 导入的限制
+type Options struct {
+	// MaxBytes 导入文件的最大字节数
+	MaxBytes int64
+	// AsyncRows 行数超过这个值时在后台执行导入
+	AsyncRows int
+}
+
+// OptionsFromConfig 从配置中读取导入的限制
+func OptionsFromConfig(cfg config.Config) Options {
+	return Options{MaxBytes: cfg.ImportMaxBytes, AsyncRows: cfg.ImportAsyncRows}
+}
+
+type Handler struct {
+	stores types.Stores
+	opts   Options
+	jobs   *Jobs
+}
+
+func NewHandler(stores types.Stores, opts Options) *Handler {
+	return &Handler{stores: stores, opts: opts, jobs: NewJobs(stores)}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// 管理接口
+	router.HandleFunc("/admin/products/import", auth.WithAdmin(h.handleImport, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/admin/products/import/{id:[0-9a-f]+}", auth.WithAdmin(h.handleGetJob, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/admin/products/export", auth.WithAdmin(h.handleExport, h.stores.Users)).Methods("GET")
+}
+
+// 请求体是整个 CSV 或 JSON Lines 文件，格式由 ?format= 或 Content-Type 决定
+// ?dryRun=true 只校验并报告将要发生的修改；行数超过 AsyncRows 或 ?async=true 时在后台执行并返回 202
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, err := ParseFormat(q.Get("format"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if format == "" {
+		if format, err = FormatFromContentType(r.Header.Get("Content-Type")); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+	dryRun, err := boolParam(r, "dryRun")
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	async, err := boolParam(r, "async")
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.opts.MaxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = apperr.PayloadTooLarge("payload_too_large", fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+	batch, err := Parse(format, data, func(v any) error { return utils.ValidatePayload(r, v) })
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if async || batch.Total() > h.opts.AsyncRows {
+		job := h.jobs.Start(r.Context(), batch, dryRun, auth.GetUserIDFromContext(r.Context()))
+		w.Header().Set("Location", r.URL.Path+"/"+job.ID)
+		utils.WriteJson(w, http.StatusAccepted, job)
+		return
+	}
+
+	result, err := Import(r.Context(), h.stores, batch, dryRun, auth.GetUserIDFromContext(r.Context()), nil)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, result)
+}
+
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, job)
+}
+
+// 边查询边写出，开始写出之后发生的错误只能记录日志并中断响应
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	format, err := ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if format == "" {
+		format = CSV
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	cw := &countingWriter{w: w}
+	if err := Export(r.Context(), h.stores, NewWriter(format, cw)); err != nil {
+		if cw.n == 0 {
+			w.Header().Del("Content-Disposition")
+			utils.WriteError(w, r, err)
+			return
+		}
+		log.Printf("[%s] catalog: export aborted after %d bytes: %v", utils.GetRequestID(r.Context()), cw.n, err)
+	}
+}
+
+// countingWriter 记录已经写出的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, apperr.Validation("validation_failed", "request validation failed").
+			WithFields([]apperr.FieldError{{Field: name, Rule: "boolean", Message: name + " must be true or false"}})
+	}
+	return b, nil
+}
@@ -0,0 +1,227 @@
+package catalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/catalog"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestCatalogRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 5},
+		types.Product{Name: "t-shirt", Price: 20, Quantity: 2},
+	)
+	// 第二个产品有两个变体
+	blue := &types.ProductVariant{ProductID: 2, SKU: "TS-BLUE", Quantity: 1, Options: map[string]string{"colour": "blue"}}
+	if err := store.CreateVariant(ctx, blue); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	catalog.NewHandler(store.Stores(), catalog.Options{MaxBytes: 4 << 10, AsyncRows: 4}).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	customer := &types.User{Email: "customer@example.com"}
+	for _, u := range []*types.User{admin, customer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	importCSV := func(t *testing.T, path, body string) types.ImportResult {
+		t.Helper()
+		var result types.ImportResult
+		apitest.Decode(t, api.Raw(admin, http.MethodPost, path, "text/csv", body), http.StatusOK, &result)
+		return result
+	}
+	variantBySKU := func(t *testing.T, sku string) *types.ProductVariant {
+		t.Helper()
+		v, err := store.GetVariantBySKU(ctx, sku)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	t.Run("只有管理员可以导入导出", func(t *testing.T) {
+		if rr := api.Raw(customer, http.MethodPost, "/admin/products/import", "text/csv", "sku\nSKU-1\n"); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := api.Do(customer, http.MethodGet, "/admin/products/export", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	body := "sku,name,price,quantity\n" +
+		"SKU-1,,18,9\n" + // 修改价格和库存
+		"TS-BLUE,,22,\n" + // 多变体产品只修改变体的价格
+		"DESK-1,desk,120,\n" + // 新产品
+		"SKU-2,,20,2\n" // 没有变化
+
+	t.Run("试运行不写入", func(t *testing.T) {
+		result := importCSV(t, "/admin/products/import?dryRun=true", body)
+		if !result.DryRun || result.Total != 4 || result.Created != 1 || result.Updated != 2 || result.Unchanged != 1 || result.Failed != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if _, err := store.GetVariantBySKU(ctx, "DESK-1"); err == nil {
+			t.Error("expected the dry run not to create DESK-1")
+		}
+		if v := variantBySKU(t, "SKU-1"); v.Quantity != 5 {
+			t.Errorf("expected stock to stay 5, got %d", v.Quantity)
+		}
+	})
+
+	t.Run("按 SKU 创建或修改", func(t *testing.T) {
+		result := importCSV(t, "/admin/products/import", body)
+		if result.DryRun || result.Created != 1 || result.Updated != 2 || result.Unchanged != 1 || len(result.Errors) != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+
+		ps, _ := store.GetProductByIDs(ctx, []int{1, 2, 3})
+		if len(ps) != 3 {
+			t.Fatalf("expected 3 products, got %d", len(ps))
+		}
+		if ps[0].Price != 18 || ps[0].Quantity != 9 || ps[0].Name != "lamp" {
+			t.Errorf("unexpected product: %+v", ps[0])
+		}
+		if ps[1].Price != 20 {
+			t.Errorf("expected the product price of a multi-variant product to stay 20, got %v", ps[1].Price)
+		}
+		if v := variantBySKU(t, "TS-BLUE"); v.Price == nil || *v.Price != 22 {
+			t.Errorf("expected the variant price to be 22, got %v", v.Price)
+		}
+		if ps[2].Name != "desk" || ps[2].Price != 120 || ps[2].Quantity != 0 || variantBySKU(t, "DESK-1").ProductID != ps[2].ID {
+			t.Errorf("unexpected new product: %+v", ps[2])
+		}
+
+		// 再导入一次不产生修改
+		if result := importCSV(t, "/admin/products/import", body); result.Unchanged != 4 {
+			t.Errorf("expected every row to be unchanged, got %+v", result)
+		}
+	})
+
+	t.Run("逐行报告错误", func(t *testing.T) {
+		var result types.ImportResult
+		rr := api.Raw(admin, http.MethodPost, "/admin/products/import?format=jsonl", "",
+			`{"sku":"NEW-1","price":5}`+"\n"+`{"sku":"SKU-1","quantity":-1}`+"\n"+`{"sku":"SKU-1","name":"desk lamp"}`)
+		apitest.Decode(t, rr, http.StatusOK, &result)
+		if result.Updated != 1 || result.Failed != 2 || result.Errors[0].Line != 1 || result.Errors[1].Line != 2 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		// 新 SKU 缺少名称
+		if e := result.Errors[0]; e.Code != "validation_failed" || len(e.Fields) != 1 || e.Fields[0].Field != "name" {
+			t.Errorf("unexpected error: %+v", e)
+		}
+	})
+
+	t.Run("拒绝不合法的请求", func(t *testing.T) {
+		cases := []struct {
+			name        string
+			path        string
+			contentType string
+			body        string
+			status      int
+		}{
+			{"无法识别的格式", "/admin/products/import", "application/json", "{}", http.StatusUnsupportedMediaType},
+			{"未知的列", "/admin/products/import", "text/csv", "sku,colour\nA,red\n", http.StatusBadRequest},
+			{"空文件", "/admin/products/import", "text/csv", "", http.StatusBadRequest},
+			{"文件过大", "/admin/products/import", "text/csv", "sku\n" + strings.Repeat("A\n", 4<<10), http.StatusRequestEntityTooLarge},
+			{"dryRun 不是布尔值", "/admin/products/import?dryRun=maybe", "text/csv", "sku\nA\n", http.StatusBadRequest},
+		}
+		for _, c := range cases {
+			if rr := api.Raw(admin, http.MethodPost, c.path, c.contentType, c.body); rr.Code != c.status {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d: %s", c.name, c.status, rr.Code, rr.Body)
+			}
+		}
+	})
+
+	t.Run("大批量导入在后台执行", func(t *testing.T) {
+		rr := api.Raw(admin, http.MethodPost, "/admin/products/import", "text/csv",
+			"sku,name,price\nBULK-1,a,1\nBULK-2,b,2\nBULK-3,c,3\nBULK-4,d,4\nBULK-5,,5\n")
+		var job types.ImportJob
+		apitest.Decode(t, rr, http.StatusAccepted, &job)
+		location := rr.Header().Get("Location")
+		if job.ID == "" || job.Total != 5 || location != "/admin/products/import/"+job.ID {
+			t.Fatalf("unexpected job: %+v %s", job, location)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for job.Status != types.ImportCompleted && job.Status != types.ImportFailed {
+			if time.Now().After(deadline) {
+				t.Fatalf("job did not finish: %+v", job)
+			}
+			time.Sleep(10 * time.Millisecond)
+			job = types.ImportJob{}
+			apitest.Decode(t, api.Do(admin, http.MethodGet, location, ""), http.StatusOK, &job)
+		}
+		if job.Status != types.ImportCompleted || job.Processed != 5 || job.FinishedAt == nil ||
+			job.Result == nil || job.Result.Created != 4 || job.Result.Failed != 1 {
+			t.Errorf("unexpected job: %+v %+v", job, job.Result)
+		}
+
+		if rr := api.Do(admin, http.MethodGet, "/admin/products/import/abc123", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("导出", func(t *testing.T) {
+		rr := api.Do(admin, http.MethodGet, "/admin/products/export", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("unexpected Content-Type: %s", ct)
+		}
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		if lines[0] != "sku,name,description,price,quantity,imageUrl" || len(lines) != 9 {
+			t.Fatalf("unexpected export:\n%s", rr.Body)
+		}
+		// 每个变体一行，价格是变体实际的售价
+		if lines[1] != "SKU-1,desk lamp,,18,9," || lines[3] != "TS-BLUE,t-shirt,,22,1," {
+			t.Errorf("unexpected rows:\n%s", rr.Body)
+		}
+
+		rr = api.Do(admin, http.MethodGet, "/admin/products/export?format=jsonl", "")
+		var rec types.ProductRecord
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil || rec.SKU != "SKU-1" || *rec.Price != 18 {
+			t.Errorf("unexpected record: %+v %v", rec, err)
+		}
+		if rr := api.Do(admin, http.MethodGet, "/admin/products/export?format=xlsx", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestExportInBatches(t *testing.T) {
+	// 产品数超过一批，检查分批之间没有重复或遗漏
+	ps := make([]types.Product, 250)
+	for i := range ps {
+		ps[i] = types.Product{Name: "product " + strconv.Itoa(i+1), Price: 10, Quantity: 1}
+	}
+	store := memstore.New(ps...)
+
+	var buf strings.Builder
+	if err := catalog.Export(context.Background(), store.Stores(), catalog.NewWriter(catalog.CSV, &buf)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(ps)+1 {
+		t.Fatalf("expected %d rows, got %d", len(ps), len(lines)-1)
+	}
+	for i, line := range lines[1:] {
+		if want := "SKU-" + strconv.Itoa(i+1) + ","; !strings.HasPrefix(line, want) {
+			t.Fatalf("row %d: expected %s..., got %s", i+1, want, line)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package catalog 按 SKU 批量导入和导出产品（CSV 或 JSON Lines）
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 导入调整库存时在库存流水中记录的原因
+const stockReason = "import"
+
+// errDryRun 让试运行的事务回滚
+var errDryRun = errors.New("dry run")
+
+type outcome int
+
+const (
+	unchanged outcome = iota
+	created
+	updated
+)
+
+// Import 逐行导入，每行在单独的事务中执行，一行失败不影响其它行
+// dryRun 时每行执行完都回滚，结果表示实际导入时将要发生的修改
+// progress 不为空时每处理完一行调用一次；数据库不可用等内部错误会中止导入并返回
+// actorID 是执行导入的用户，作为库存调整的操作人记入流水，ecomctl 传 0
+func Import(ctx context.Context, stores types.Stores, b *Batch, dryRun bool, actorID int, progress func(processed int)) (*types.ImportResult, error) {
+	result := &types.ImportResult{DryRun: dryRun, Total: b.Total(), Errors: slices.Clone(b.Errors)}
+	if result.Errors == nil {
+		result.Errors = []types.ImportRowError{}
+	}
+
+	for i, row := range b.Rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var out outcome
+		err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+			var err error
+			out, err = apply(ctx, tx, row.Record, actorID)
+			if err == nil && dryRun {
+				return errDryRun
+			}
+			return err
+		})
+		switch {
+		case err == nil || errors.Is(err, errDryRun):
+			switch out {
+			case created:
+				result.Created++
+			case updated:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		case apperr.From(err).Kind == apperr.KindInternal:
+			return nil, fmt.Errorf("import line %d: %w", row.Line, err)
+		default:
+			result.Errors = append(result.Errors, rowError(row.Line, row.Record.SKU, err))
+		}
+
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+
+	result.Failed = len(result.Errors)
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+	return result, nil
+}
+
+// apply 导入一行：SKU 不存在时创建产品（默认变体使用这个 SKU），否则只修改给出的字段
+func apply(ctx context.Context, tx types.Stores, rec types.ProductRecord, actorID int) (outcome, error) {
+	v, err := tx.Variants.GetVariantBySKU(ctx, rec.SKU)
+	if apperr.IsKind(err, apperr.KindNotFound) {
+		return created, create(ctx, tx, rec)
+	}
+	if err != nil {
+		return unchanged, err
+	}
+
+	ps, err := tx.Products.GetProductByIDs(ctx, []int{v.ProductID})
+	if err != nil {
+		return unchanged, err
+	}
+	if len(ps) == 0 {
+		return unchanged, apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", v.ProductID))
+	}
+	p := ps[0]
+	siblings, err := tx.Variants.GetVariants(ctx, p.ID)
+	if err != nil {
+		return unchanged, err
+	}
+
+	productChanged, variantChanged := false, false
+	setText := func(dst *string, src *string) {
+		if src != nil && *dst != *src {
+			*dst = *src
+			productChanged = true
+		}
+	}
+	setText(&p.Name, rec.Name)
+	setText(&p.Description, rec.Description)
+	setText(&p.ImageURL, rec.ImageURL)
+	current, _ := pricing.VariantPrice(p, *v)
+	if rec.Price != nil && !samePrice(current, *rec.Price) {
+		if len(siblings) == 1 {
+			// 只有一个变体时价格就是产品的价格
+			p.Price = *rec.Price
+			productChanged = true
+			if v.Price != nil {
+				v.Price = nil
+				variantChanged = true
+			}
+		} else {
+			v.Price = rec.Price
+			variantChanged = true
+		}
+	}
+
+	if productChanged {
+		if err := tx.Products.UpdateProduct(ctx, &p); err != nil {
+			return unchanged, err
+		}
+	}
+	if variantChanged {
+		if err := tx.Variants.UpdateVariant(ctx, v); err != nil {
+			return unchanged, err
+		}
+	}
+	stockChanged := rec.Quantity != nil && *rec.Quantity != v.Quantity
+	if stockChanged {
+		change := types.StockChange{Type: types.StockAdjustment, Reason: stockReason, ActorID: actorID}
+		if _, err := tx.Variants.AdjustVariantStock(ctx, v.ID, *rec.Quantity-v.Quantity, change); err != nil {
+			return unchanged, err
+		}
+	}
+
+	if productChanged || variantChanged || stockChanged {
+		return updated, nil
+	}
+	return unchanged, nil
+}
+
+func create(ctx context.Context, tx types.Stores, rec types.ProductRecord) error {
+	var fields []apperr.FieldError
+	if rec.Name == nil {
+		fields = append(fields, apperr.FieldError{Field: "name", Rule: "required", Message: "name is required for a new SKU"})
+	}
+	if rec.Price == nil {
+		fields = append(fields, apperr.FieldError{Field: "price", Rule: "required", Message: "price is required for a new SKU"})
+	}
+	if len(fields) > 0 {
+		return apperr.Validation("validation_failed", fmt.Sprintf("SKU %q does not exist, name and price are required to create it", rec.SKU)).
+			WithFields(fields)
+	}
+
+	p := &types.Product{Name: *rec.Name, Price: *rec.Price}
+	if rec.Description != nil {
+		p.Description = *rec.Description
+	}
+	if rec.ImageURL != nil {
+		p.ImageURL = *rec.ImageURL
+	}
+	if rec.Quantity != nil {
+		p.Quantity = *rec.Quantity
+	}
+	if err := tx.Products.CreateProduct(ctx, p); err != nil {
+		return err
+	}
+
+	// 新产品只有默认变体，把它的 SKU 改为导入的 SKU
+	vs, err := tx.Variants.GetVariants(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	if len(vs) != 1 {
+		return fmt.Errorf("catalog: expected a single default variant for product ID %d, got %d", p.ID, len(vs))
+	}
+	vs[0].SKU = rec.SKU
+	return tx.Variants.UpdateVariant(ctx, &vs[0])
+}
+
+// exportBatchSize 是导出时每批读取的产品数
+const exportBatchSize = 100
+
+// Export 按产品ID、变体ID的顺序逐行写出所有变体，导出的文件可以原样导入。
+// 产品按ID分批读取，每批的变体一次查出，内存占用和查询次数不随产品总数膨胀
+func Export(ctx context.Context, stores types.Stores, w *Writer) error {
+	after := 0
+	for {
+		ps, err := stores.Products.GetProductsAfter(ctx, after, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ps) == 0 {
+			break
+		}
+
+		ids := make([]int, len(ps))
+		for i, p := range ps {
+			ids[i] = p.ID
+		}
+		vs, err := stores.Variants.GetVariantsByProductIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		byProduct := make(map[int][]types.ProductVariant, len(ps))
+		for _, v := range vs {
+			byProduct[v.ProductID] = append(byProduct[v.ProductID], v)
+		}
+
+		for _, p := range ps {
+			for _, v := range byProduct[p.ID] {
+				if err := w.Write(recordFor(p, v)); err != nil {
+					return err
+				}
+			}
+		}
+		if len(ps) < exportBatchSize {
+			break
+		}
+		after = ps[len(ps)-1].ID
+	}
+	return w.Flush()
+}
+
+func recordFor(p types.Product, v types.ProductVariant) types.ProductRecord {
+	price, _ := pricing.VariantPrice(p, v)
+	quantity := v.Quantity
+	return types.ProductRecord{
+		SKU:         v.SKU,
+		Name:        &p.Name,
+		Description: &p.Description,
+		Price:       &price,
+		Quantity:    &quantity,
+		ImageURL:    &p.ImageURL,
+	}
+}
+
+// 价格在数据库中保留两位小数
+func samePrice(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
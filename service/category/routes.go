@@ -0,0 +1,131 @@
+package category
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/categories", h.handleGetTree).Methods("GET")
+	router.HandleFunc("/categories/{slug}/products", h.handleGetProducts).Methods("GET")
+
+	// 管理接口
+	router.HandleFunc("/admin/categories", auth.WithAdmin(h.handleCreate, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/admin/categories/{id:[0-9]+}", auth.WithAdmin(h.handleUpdate, h.stores.Users)).Methods("PUT")
+	router.HandleFunc("/admin/categories/{id:[0-9]+}", auth.WithAdmin(h.handleDelete, h.stores.Users)).Methods("DELETE")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/categories", auth.WithAdmin(h.handleSetProductCategories, h.stores.Users)).Methods("PUT")
+}
+
+// 返回完整的分类树
+func (h *Handler) handleGetTree(w http.ResponseWriter, r *http.Request) {
+	all, err := h.stores.Categories.GetCategories(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, Tree(all))
+}
+
+// 返回分类及其所有子分类下的产品
+func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
+	ps, err := ProductsInCategory(r.Context(), h.stores.Categories, mux.Vars(r)["slug"])
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ps)
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var payload types.CategoryPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	c, err := Create(r.Context(), h.stores, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, c)
+}
+
+func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.CategoryPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	c, err := Update(r.Context(), h.stores, id, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := h.stores.Categories.DeleteCategory(r.Context(), id); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 用请求中的分类替换产品现有的分类
+func (h *Handler) handleSetProductCategories(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ProductCategoriesPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	var ids []int
+	err := h.stores.Tx.WithinTx(r.Context(), func(tx types.Stores) error {
+		if err := tx.Categories.SetProductCategories(r.Context(), productID, payload.CategoryIDs); err != nil {
+			return err
+		}
+		var err error
+		ids, err = tx.Categories.GetProductCategoryIDs(r.Context(), productID)
+		return err
+	})
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, types.ProductCategoriesPayload{CategoryIDs: ids})
+}
@@ -0,0 +1,103 @@
+package category
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestCategoryRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "keyboard", Price: 10, Quantity: 5},
+		types.Product{Name: "novel", Price: 5, Quantity: 5},
+	)
+	router := mux.NewRouter()
+	NewHandler(store.Stores()).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	customer := &types.User{Email: "customer@example.com"}
+	for _, u := range []*types.User{admin, customer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	create := func(payload types.CategoryPayload) types.Category {
+		t.Helper()
+		var c types.Category
+		apitest.Decode(t, api.JSON(admin, http.MethodPost, "/admin/categories", payload), http.StatusCreated, &c)
+		return c
+	}
+
+	electronics := create(types.CategoryPayload{Name: "Electronics"})
+	keyboards := create(types.CategoryPayload{Name: "Keyboards & Mice", ParentID: &electronics.ID})
+	create(types.CategoryPayload{Name: "Books", Slug: "books"})
+
+	t.Run("slug 由名称生成", func(t *testing.T) {
+		if electronics.Slug != "electronics" || keyboards.Slug != "keyboards-mice" {
+			t.Errorf("unexpected slugs %q, %q", electronics.Slug, keyboards.Slug)
+		}
+	})
+
+	t.Run("只有管理员可以修改", func(t *testing.T) {
+		if rr := api.JSON(customer, http.MethodPost, "/admin/categories", types.CategoryPayload{Name: "Toys"}); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("无效的 slug", func(t *testing.T) {
+		if rr := api.JSON(admin, http.MethodPost, "/admin/categories", types.CategoryPayload{Name: "数码"}); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("分类树", func(t *testing.T) {
+		var tree []types.Category
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/categories", ""), http.StatusOK, &tree)
+		if len(tree) != 2 || tree[0].Slug != "books" || tree[1].Slug != "electronics" ||
+			len(tree[1].Children) != 1 || tree[1].Children[0].ID != keyboards.ID {
+			t.Errorf("unexpected tree: %+v", tree)
+		}
+	})
+
+	t.Run("父分类包含子分类的产品", func(t *testing.T) {
+		rr := api.JSON(admin, http.MethodPut, "/admin/products/1/categories", types.ProductCategoriesPayload{CategoryIDs: []int{keyboards.ID}})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var ps []types.Product
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/categories/electronics/products", ""), http.StatusOK, &ps)
+		if len(ps) != 1 || ps[0].ID != 1 {
+			t.Errorf("unexpected products: %+v", ps)
+		}
+		if rr := api.Do(nil, http.MethodGet, "/categories/missing/products", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("不能移动到子分类下面", func(t *testing.T) {
+		rr := api.JSON(admin, http.MethodPut, "/admin/categories/"+strconv.Itoa(electronics.ID),
+			types.CategoryPayload{Name: "Electronics", ParentID: &keyboards.ID})
+		if rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("有子分类时不能删除", func(t *testing.T) {
+		if rr := api.Do(admin, http.MethodDelete, "/admin/categories/"+strconv.Itoa(electronics.ID), ""); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		if rr := api.Do(admin, http.MethodDelete, "/admin/categories/"+strconv.Itoa(keyboards.ID), ""); rr.Code != http.StatusNoContent {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNoContent, rr.Code)
+		}
+	})
+}
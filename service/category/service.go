@@ -0,0 +1,146 @@
+package category
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// Slugify 把名称转换为 slug：小写字母和数字，其余字符替换为 "-"
+// 名称中没有 ASCII 字母和数字（如中文名称）时返回空字符串，需要手动指定 slug
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// Tree 把平铺的分类组装成树，同一层保持输入的顺序
+func Tree(flat []types.Category) []types.Category {
+	children := map[int][]types.Category{}
+	var roots []types.Category
+	for _, c := range flat {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func(list []types.Category) []types.Category
+	build = func(list []types.Category) []types.Category {
+		out := make([]types.Category, len(list))
+		for i, c := range list {
+			c.Children = build(children[c.ID])
+			out[i] = c
+		}
+		return out
+	}
+	if roots == nil {
+		return []types.Category{}
+	}
+	return build(roots)
+}
+
+// Descendants 返回 id 本身以及它的所有子孙分类的ID
+func Descendants(flat []types.Category, id int) []int {
+	children := map[int][]int{}
+	for _, c := range flat {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// ProductsInCategory 返回分类及其所有子分类下的产品
+func ProductsInCategory(ctx context.Context, store types.CategoryStore, slug string) ([]types.Product, error) {
+	c, err := store.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	all, err := store.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetProductsByCategoryIDs(ctx, Descendants(all, c.ID))
+}
+
+// Create 校验并创建分类
+func Create(ctx context.Context, stores types.Stores, p types.CategoryPayload) (*types.Category, error) {
+	c, err := fromPayload(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := stores.Categories.CreateCategory(ctx, c); err != nil {
+		return nil, err
+	}
+	return stores.Categories.GetCategoryByID(ctx, c.ID)
+}
+
+// Update 校验并修改分类，分类不能移动到它自己或它的子孙分类下面
+func Update(ctx context.Context, stores types.Stores, id int, p types.CategoryPayload) (*types.Category, error) {
+	c, err := fromPayload(p)
+	if err != nil {
+		return nil, err
+	}
+	c.ID = id
+
+	var updated *types.Category
+	err = stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if c.ParentID != nil {
+			all, err := tx.Categories.GetCategories(ctx)
+			if err != nil {
+				return err
+			}
+			for _, d := range Descendants(all, id) {
+				if d == *c.ParentID {
+					return apperr.Conflict("category_cycle", fmt.Sprintf("category %d cannot be moved under itself or its subcategory %d", id, d))
+				}
+			}
+		}
+
+		if err := tx.Categories.UpdateCategory(ctx, c); err != nil {
+			return err
+		}
+		updated, err = tx.Categories.GetCategoryByID(ctx, id)
+		return err
+	})
+	return updated, err
+}
+
+func fromPayload(p types.CategoryPayload) (*types.Category, error) {
+	slug := p.Slug
+	if slug == "" {
+		slug = Slugify(p.Name)
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, apperr.Validation("validation_failed", "request validation failed").WithFields([]apperr.FieldError{{
+			Field:   "slug",
+			Rule:    "slug",
+			Message: "slug must contain only lowercase letters, digits and single dashes",
+		}})
+	}
+	return &types.Category{ParentID: p.ParentID, Name: p.Name, Slug: slug, SortOrder: p.SortOrder}, nil
+}
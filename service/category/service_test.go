@@ -0,0 +1,58 @@
+package category
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Albert-tru/ecom/types"
+)
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Electronics":        "electronics",
+		"  Home & Garden  ":  "home-garden",
+		"USB-C Cables (2m)":  "usb-c-cables-2m",
+		"数码配件":               "",
+		"T恤 T-Shirts":        "t-t-shirts",
+		"--already-a-slug--": "already-a-slug",
+	}
+	for in, want := range cases {
+		if got := Slugify(in); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTreeAndDescendants(t *testing.T) {
+	id := func(n int) *int { return &n }
+	flat := []types.Category{
+		{ID: 3, ParentID: id(1), Slug: "keyboards"},
+		{ID: 1, Slug: "electronics"},
+		{ID: 4, ParentID: id(3), Slug: "mechanical"},
+		{ID: 2, Slug: "books"},
+		{ID: 5, ParentID: id(1), Slug: "mice"},
+	}
+
+	tree := Tree(flat)
+	if len(tree) != 2 || tree[0].ID != 1 || tree[1].ID != 2 {
+		t.Fatalf("unexpected roots: %+v", tree)
+	}
+	if kids := tree[0].Children; len(kids) != 2 || kids[0].ID != 3 || kids[1].ID != 5 || len(kids[0].Children) != 1 || kids[0].Children[0].ID != 4 {
+		t.Errorf("unexpected children: %+v", kids)
+	}
+	if len(tree[1].Children) != 0 {
+		t.Errorf("leaf should have no children, got %+v", tree[1].Children)
+	}
+	if got := Tree(nil); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty, non-nil tree, got %#v", got)
+	}
+
+	got := Descendants(flat, 1)
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 3, 4, 5}) {
+		t.Errorf("unexpected descendants: %v", got)
+	}
+	if got := Descendants(flat, 2); !slices.Equal(got, []int{2}) {
+		t.Errorf("unexpected descendants: %v", got)
+	}
+}
@@ -0,0 +1,361 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询分类时的列，顺序与 scanCategory 一致
+const categoryColumns = "id, parent_id, name, slug, sort_order, createdat"
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// GetCategories 返回所有分类（平铺），按 sort_order、名称排序
+func (s *Store) GetCategories(ctx context.Context) (_ []types.Category, err error) {
+	const query = "SELECT " + categoryColumns + " FROM categories ORDER BY sort_order, name, id"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetCategories", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetCategories", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []types.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+	return categories, rows.Err()
+}
+
+func (s *Store) GetCategoryByID(ctx context.Context, id int) (_ *types.Category, err error) {
+	const query = "SELECT " + categoryColumns + " FROM categories WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetCategoryByID", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetCategoryByID", db.Read)
+	defer cancel()
+
+	c, err := scanCategory(s.db.QueryRowContext(ctx, s.dialect.Rebind(query), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, categoryNotFound(fmt.Sprintf("category ID %d not found", id))
+	}
+	return c, err
+}
+
+func (s *Store) GetCategoryBySlug(ctx context.Context, slug string) (_ *types.Category, err error) {
+	const query = "SELECT " + categoryColumns + " FROM categories WHERE slug = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetCategoryBySlug", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetCategoryBySlug", db.Read)
+	defer cancel()
+
+	c, err := scanCategory(s.db.QueryRowContext(ctx, s.dialect.Rebind(query), slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, categoryNotFound(fmt.Sprintf("category %q not found", slug))
+	}
+	return c, err
+}
+
+// CreateCategory 创建分类，并把分配的ID写回 c.ID；slug 重复时返回 slug_taken
+func (s *Store) CreateCategory(ctx context.Context, c *types.Category) (err error) {
+	const query = "INSERT INTO categories (parent_id, name, slug, sort_order) VALUES (?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.CreateCategory", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.CreateCategory", db.Write)
+	defer cancel()
+
+	if err := s.checkParent(ctx, c.ParentID); err != nil {
+		return err
+	}
+	id, err := s.dialect.InsertID(ctx, s.db, query, c.ParentID, c.Name, c.Slug, c.SortOrder)
+	if db.IsUniqueViolation(err) {
+		return slugTaken(c.Slug).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.ID = int(id)
+	return nil
+}
+
+// UpdateCategory 修改分类的父分类、名称、slug 和排序
+func (s *Store) UpdateCategory(ctx context.Context, c *types.Category) (err error) {
+	const query = "UPDATE categories SET parent_id = ?, name = ?, slug = ?, sort_order = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.UpdateCategory", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.UpdateCategory", db.Write)
+	defer cancel()
+
+	if err := s.checkParent(ctx, c.ParentID); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), c.ParentID, c.Name, c.Slug, c.SortOrder, c.ID)
+	if db.IsUniqueViolation(err) {
+		return slugTaken(c.Slug).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL 在值没有变化时 RowsAffected 也是 0
+		_, err := s.GetCategoryByID(ctx, c.ID)
+		return err
+	}
+	return nil
+}
+
+// DeleteCategory 删除分类以及它和产品的关联；还有子分类时返回 category_has_children
+func (s *Store) DeleteCategory(ctx context.Context, id int) (err error) {
+	const query = "DELETE FROM categories WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.DeleteCategory", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.DeleteCategory", db.Write)
+	defer cancel()
+
+	var children int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM categories WHERE parent_id = ?"), id).Scan(&children)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errHasChildren(id)
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM product_categories WHERE category_id = ?"), id); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return categoryNotFound(fmt.Sprintf("category ID %d not found", id))
+	}
+	return nil
+}
+
+// SetProductCategories 用 categoryIDs 替换产品现有的分类，产品和分类都必须存在
+// 先删除再插入，调用方应该把它放在事务中
+func (s *Store) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) (err error) {
+	const query = "INSERT INTO product_categories (product_id, category_id) VALUES (?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.SetProductCategories", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.SetProductCategories", db.Write)
+	defer cancel()
+
+	var count int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), productID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+
+	ids := slices.Compact(slices.Sorted(slices.Values(categoryIDs)))
+	if len(ids) > 0 {
+		args := make([]any, len(ids))
+		for i, id := range ids {
+			args[i] = id
+		}
+		err = s.db.QueryRowContext(ctx, s.dialect.Rebind(
+			"SELECT COUNT(*) FROM categories WHERE id IN ("+db.Placeholders(len(ids))+")"), args...).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != len(ids) {
+			return categoryNotFound(fmt.Sprintf("some of the categories %v do not exist", ids))
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM product_categories WHERE product_id = ?"), productID); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), productID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProductCategoryIDs 返回产品所属的分类ID，按ID排序
+func (s *Store) GetProductCategoryIDs(ctx context.Context, productID int) (_ []int, err error) {
+	const query = "SELECT category_id FROM product_categories WHERE product_id = ? ORDER BY category_id"
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetProductCategoryIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetProductCategoryIDs", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetProductsByCategoryIDs 返回属于任意一个分类的产品，同一个产品只返回一次
+func (s *Store) GetProductsByCategoryIDs(ctx context.Context, categoryIDs []int) (_ []types.Product, err error) {
+	if len(categoryIDs) == 0 {
+		return []types.Product{}, nil
+	}
+
+	query := "SELECT id, name, description, image, price, quantity, rating_average, rating_count, createdat FROM products WHERE id IN (" +
+		"SELECT product_id FROM product_categories WHERE category_id IN (" + db.Placeholders(len(categoryIDs)) + ")) ORDER BY id"
+	args := make([]any, len(categoryIDs))
+	for i, id := range categoryIDs {
+		args[i] = id
+	}
+
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetProductsByCategoryIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetProductsByCategoryIDs", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []types.Product{}
+	for rows.Next() {
+		var p types.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description,
+			&p.ImageURL, &p.Price, &p.Quantity, &p.RatingAverage, &p.RatingCount, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := pricing.Apply(ctx, s.db, s.dialect, products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetCategoryIDsByProductIDs 批量返回产品所属的分类ID，没有分类的产品不出现在结果中
+func (s *Store) GetCategoryIDsByProductIDs(ctx context.Context, productIDs []int) (_ map[int][]int, err error) {
+	result := map[int][]int{}
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	query := "SELECT product_id, category_id FROM product_categories WHERE product_id IN (" +
+		db.Placeholders(len(productIDs)) + ") ORDER BY product_id, category_id"
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	ctx, span := tracing.StartStoreSpan(ctx, "category.Store.GetCategoryIDsByProductIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "category.Store.GetCategoryIDsByProductIDs", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, categoryID int
+		if err := rows.Scan(&productID, &categoryID); err != nil {
+			return nil, err
+		}
+		result[productID] = append(result[productID], categoryID)
+	}
+	return result, rows.Err()
+}
+
+// 父分类不存在时返回 category_not_found，而不是让外键约束报出数据库错误
+func (s *Store) checkParent(ctx context.Context, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	var count int
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM categories WHERE id = ?"), *parentID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return categoryNotFound(fmt.Sprintf("parent category ID %d not found", *parentID))
+	}
+	return nil
+}
+
+// *sql.Row 和 *sql.Rows 都可以用来扫描
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCategory(row scanner) (*types.Category, error) {
+	c := new(types.Category)
+	var parentID sql.NullInt64
+	if err := row.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.SortOrder, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
+
+func categoryNotFound(message string) *apperr.Error {
+	return apperr.NotFound("category_not_found", message)
+}
+
+func slugTaken(slug string) *apperr.Error {
+	return apperr.Conflict("slug_taken", fmt.Sprintf("category slug %q already exists", slug))
+}
+
+func errHasChildren(id int) *apperr.Error {
+	return apperr.Conflict("category_has_children", fmt.Sprintf("category ID %d still has subcategories", id))
+}
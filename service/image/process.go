@@ -0,0 +1,186 @@
+package image
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+)
+
+// 允许上传的图片类型及保存时使用的扩展名
+// 类型由文件内容判断，不信任客户端传来的 Content-Type 和文件名
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// 缩略图使用的 JPEG 质量
+const jpegQuality = 85
+
+// Options 上传图片的限制和要生成的缩略图
+type Options struct {
+	MaxBytes       int64 // 单张图片的最大字节数
+	MaxPixels      int   // 单张图片的最大像素数（宽 × 高）
+	ThumbnailSizes []int // 缩略图的最大边长（像素），按从小到大排列
+}
+
+// ParseSizes 解析逗号分隔的缩略图边长，如 "150,600"，结果去重并排序
+func ParseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("image: invalid thumbnail size %q", part)
+		}
+		sizes = append(sizes, n)
+	}
+	slices.Sort(sizes)
+	return slices.Compact(sizes), nil
+}
+
+// processed 校验和处理之后的一张上传图片
+type processed struct {
+	contentType string
+	ext         string
+	width       int
+	height      int
+	data        []byte
+	thumbnails  []thumbnail
+}
+
+type thumbnail struct {
+	size   int
+	width  int
+	height int
+	data   []byte
+}
+
+// process 校验图片类型和尺寸，并生成缩略图
+// 不支持的类型返回 415，无法解码或像素数超出上限返回 400
+func process(data []byte, opts Options) (*processed, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return nil, apperr.UnsupportedMediaType("unsupported_image_type",
+			fmt.Sprintf("image type %s is not supported, expected JPEG, PNG or GIF", contentType))
+	}
+
+	// 先只读取图片头，避免解码超大图片
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, apperr.BadRequest("invalid_image", "the file could not be decoded as an image")
+	}
+	if opts.MaxPixels > 0 && cfg.Width*cfg.Height > opts.MaxPixels {
+		return nil, apperr.BadRequest("image_too_large",
+			fmt.Sprintf("image is %dx%d pixels, at most %d pixels are allowed", cfg.Width, cfg.Height, opts.MaxPixels))
+	}
+
+	p := &processed{contentType: contentType, ext: ext, width: cfg.Width, height: cfg.Height, data: data}
+	if len(opts.ThumbnailSizes) == 0 {
+		return p, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, apperr.BadRequest("invalid_image", "the file could not be decoded as an image")
+	}
+	for _, size := range opts.ThumbnailSizes {
+		w, h := fit(cfg.Width, cfg.Height, size)
+		var buf bytes.Buffer
+		if err := encode(&buf, resize(src, w, h), contentType); err != nil {
+			return nil, err
+		}
+		p.thumbnails = append(p.thumbnails, thumbnail{size: size, width: w, height: h, data: buf.Bytes()})
+	}
+	return p, nil
+}
+
+// fit 返回等比缩放到 size × size 以内的尺寸，不会放大
+func fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// resize 使用盒式滤波缩放：目标的每个像素取源图中对应区域的平均值
+// 只用于缩小，放大时退化为最近邻
+func resize(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	if width == b.Dx() && height == b.Dy() {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * b.Dy() / height
+		y1 := max(y0+1, (y+1)*b.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := x * b.Dx() / width
+			x1 := max(x0+1, (x+1)*b.Dx()/width)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += uint64(px[0])
+					g += uint64(px[1])
+					bl += uint64(px[2])
+					a += uint64(px[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// encode JPEG 原图的缩略图仍然保存为 JPEG，PNG 和 GIF 保存为 PNG（保留透明度）
+func encode(buf *bytes.Buffer, img image.Image, contentType string) error {
+	if contentType == "image/jpeg" {
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return png.Encode(buf, img)
+}
+
+// thumbnailType 返回缩略图的类型和扩展名
+func thumbnailType(contentType string) (string, string) {
+	if contentType == "image/jpeg" {
+		return "image/jpeg", ".jpg"
+	}
+	return "image/png", ".png"
+}
+
+// newKeyBase 返回 products/<产品ID>/<随机名>，原图和缩略图在此基础上加后缀和扩展名
+func newKeyBase(productID int) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("products/%d/%s", productID, hex.EncodeToString(b))
+}
@@ -0,0 +1,122 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apperr"
+)
+
+func newPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSizes(t *testing.T) {
+	sizes, err := ParseSizes(" 600, 150,,600 ")
+	if err != nil || len(sizes) != 2 || sizes[0] != 150 || sizes[1] != 600 {
+		t.Errorf("unexpected sizes: %v %v", sizes, err)
+	}
+	if sizes, err := ParseSizes(""); err != nil || len(sizes) != 0 {
+		t.Errorf("expected no sizes, got %v %v", sizes, err)
+	}
+	for _, s := range []string{"abc", "0", "-5"} {
+		if _, err := ParseSizes(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	cases := []struct{ w, h, size, wantW, wantH int }{
+		{800, 600, 150, 150, 112},
+		{600, 800, 150, 112, 150},
+		{100, 50, 150, 100, 50}, // 不放大
+		{3000, 1, 150, 150, 1},
+	}
+	for _, c := range cases {
+		if w, h := fit(c.w, c.h, c.size); w != c.wantW || h != c.wantH {
+			t.Errorf("fit(%d, %d, %d) = %d, %d, want %d, %d", c.w, c.h, c.size, w, h, c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	opts := Options{MaxPixels: 1_000_000, ThumbnailSizes: []int{16, 64}}
+
+	t.Run("PNG 生成 PNG 缩略图", func(t *testing.T) {
+		p, err := process(newPNG(t, 80, 40), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.contentType != "image/png" || p.ext != ".png" || p.width != 80 || p.height != 40 || len(p.thumbnails) != 2 {
+			t.Fatalf("unexpected result: %+v", p)
+		}
+		for _, th := range p.thumbnails {
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(th.data))
+			if err != nil || format != "png" || cfg.Width != th.width || cfg.Height != th.height {
+				t.Errorf("unexpected thumbnail %d: %+v %s %v", th.size, cfg, format, err)
+			}
+		}
+		if th := p.thumbnails[0]; th.width != 16 || th.height != 8 {
+			t.Errorf("unexpected thumbnail size: %dx%d", th.width, th.height)
+		}
+	})
+
+	t.Run("JPEG 生成 JPEG 缩略图", func(t *testing.T) {
+		src, _ := png.Decode(bytes.NewReader(newPNG(t, 100, 100)))
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, src, nil)
+		p, err := process(buf.Bytes(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.contentType != "image/jpeg" || p.ext != ".jpg" {
+			t.Fatalf("unexpected type: %s", p.contentType)
+		}
+		if _, format, _ := image.DecodeConfig(bytes.NewReader(p.thumbnails[1].data)); format != "jpeg" {
+			t.Errorf("expected a JPEG thumbnail, got %s", format)
+		}
+	})
+
+	t.Run("GIF", func(t *testing.T) {
+		var buf bytes.Buffer
+		gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 20, 10), color.Palette{color.Black, color.White}), nil)
+		p, err := process(buf.Bytes(), opts)
+		if err != nil || p.contentType != "image/gif" || p.thumbnails[0].width != 16 {
+			t.Errorf("unexpected result: %+v %v", p, err)
+		}
+	})
+
+	t.Run("拒绝其它类型和损坏的图片", func(t *testing.T) {
+		if _, err := process([]byte("%PDF-1.4 not an image"), opts); !apperr.IsKind(err, apperr.KindUnsupportedMediaType) {
+			t.Errorf("expected unsupported media type, got %v", err)
+		}
+		truncated := newPNG(t, 10, 10)[:20]
+		if _, err := process(truncated, opts); !apperr.IsKind(err, apperr.KindBadRequest) {
+			t.Errorf("expected bad request, got %v", err)
+		}
+	})
+
+	t.Run("像素数超出上限", func(t *testing.T) {
+		_, err := process(newPNG(t, 200, 100), Options{MaxPixels: 10_000})
+		if !apperr.IsKind(err, apperr.KindBadRequest) || apperr.From(err).Code != "image_too_large" {
+			t.Errorf("expected image_too_large, got %v", err)
+		}
+	})
+}
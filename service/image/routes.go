@@ -0,0 +1,158 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/filestore"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+// MaxFiles 一次请求最多上传的图片数
+const MaxFiles = 10
+
+// multipart 分隔行和每个部分的头部的额外开销
+const multipartOverhead = 64 << 10
+
+type Handler struct {
+	stores types.Stores
+	files  filestore.Storage
+	opts   Options
+}
+
+func NewHandler(stores types.Stores, files filestore.Storage, opts Options) *Handler {
+	return &Handler{stores: stores, files: files, opts: opts}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/images", h.handleList).Methods("GET")
+
+	// 管理接口
+	router.HandleFunc("/products/{id:[0-9]+}/images", auth.WithAdmin(h.handleUpload, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/images/order", auth.WithAdmin(h.handleReorder, h.stores.Users)).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/images/{imageId:[0-9]+}", auth.WithAdmin(h.handleDelete, h.stores.Users)).Methods("DELETE")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	imgs, err := List(r.Context(), h.stores, h.files, productID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, imgs)
+}
+
+// 接收 multipart/form-data，每个名为 file 的部分是一张图片，可以重复
+func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	uploads, err := h.readUploads(w, r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	imgs, err := Add(r.Context(), h.stores, h.files, h.opts, productID, uploads)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, imgs)
+}
+
+func (h *Handler) handleReorder(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ReorderImagesPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	imgs, err := Reorder(r.Context(), h.stores, h.files, productID, payload.ImageIDs)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, imgs)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	imageID, _ := strconv.Atoi(mux.Vars(r)["imageId"])
+
+	if err := Delete(r.Context(), h.stores, h.files, productID, imageID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readUploads 流式读取 multipart 请求体，每张图片最多读取 MaxBytes+1 个字节
+// 整个请求体的大小也有上限，防止客户端发送大量其他部分
+func (h *Handler) readUploads(w http.ResponseWriter, r *http.Request) ([]Upload, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, apperr.UnsupportedMediaType("unsupported_media_type", "Content-Type must be multipart/form-data")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxFiles*h.opts.MaxBytes+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, apperr.BadRequest("invalid_multipart", "request body is not valid multipart/form-data")
+	}
+
+	var uploads []Upload
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, readError(err)
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		if len(uploads) == MaxFiles {
+			return nil, apperr.BadRequest("too_many_files", fmt.Sprintf("at most %d files can be uploaded at once", MaxFiles))
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, h.opts.MaxBytes+1))
+		part.Close()
+		if err != nil {
+			return nil, readError(err)
+		}
+		if int64(len(data)) > h.opts.MaxBytes {
+			return nil, apperr.PayloadTooLarge("file_too_large",
+				fmt.Sprintf("each file must not exceed %d bytes", h.opts.MaxBytes)).
+				WithFields([]apperr.FieldError{{Field: "file", Rule: "max", Param: part.FileName(),
+					Message: fmt.Sprintf("file must not exceed %d bytes", h.opts.MaxBytes)}})
+		}
+		uploads = append(uploads, Upload{Filename: part.FileName(), Data: data})
+	}
+	return uploads, nil
+}
+
+func readError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return apperr.PayloadTooLarge("payload_too_large", fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+	}
+	return apperr.BadRequest("invalid_multipart", "request body is not valid multipart/form-data").WithCause(err)
+}
@@ -0,0 +1,170 @@
+package image_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/filestore"
+	"github.com/Albert-tru/ecom/memstore"
+	imagesvc "github.com/Albert-tru/ecom/service/image"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageRoutes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := memstore.New(types.Product{Name: "lamp", Price: 15, Quantity: 5})
+	files := filestore.NewLocal(dir, "http://localhost:8080/media", nil, time.Hour)
+	router := mux.NewRouter()
+	imagesvc.NewHandler(store.Stores(), files, imagesvc.Options{MaxBytes: 64 << 10, MaxPixels: 1_000_000, ThumbnailSizes: []int{50}}).
+		RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	customer := &types.User{Email: "customer@example.com"}
+	for _, u := range []*types.User{admin, customer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	upload := func(user *types.User, path string, parts ...[]byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("note", "ignored")
+		for i, data := range parts {
+			fw, _ := mw.CreateFormFile("file", fmt.Sprintf("image-%d.png", i))
+			fw.Write(data)
+		}
+		mw.Close()
+		return api.Raw(user, http.MethodPost, path, mw.FormDataContentType(), body.String())
+	}
+	list := func() []types.ProductImage {
+		var imgs []types.ProductImage
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/products/1/images", ""), http.StatusOK, &imgs)
+		return imgs
+	}
+	exists := func(key string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key)))
+		return err == nil
+	}
+
+	t.Run("只有管理员可以上传", func(t *testing.T) {
+		if rr := upload(customer, "/products/1/images", pngBytes(t, 10, 10)); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	var uploaded []types.ProductImage
+	t.Run("上传多张图片并生成缩略图", func(t *testing.T) {
+		apitest.Decode(t, upload(admin, "/products/1/images", pngBytes(t, 200, 100), pngBytes(t, 30, 30)), http.StatusCreated, &uploaded)
+		if len(uploaded) != 2 || uploaded[0].Position != 0 || uploaded[1].Position != 1 {
+			t.Fatalf("unexpected images: %+v", uploaded)
+		}
+		first := uploaded[0]
+		if first.ContentType != "image/png" || first.Width != 200 || first.Height != 100 || len(first.Thumbnails) != 1 {
+			t.Errorf("unexpected image: %+v", first)
+		}
+		if th := first.Thumbnails[0]; th.Size != 50 || th.Width != 50 || th.Height != 25 || th.URL == "" {
+			t.Errorf("unexpected thumbnail: %+v", th)
+		}
+		// 比缩略图小的图片不放大
+		if th := uploaded[1].Thumbnails[0]; th.Width != 30 || th.Height != 30 {
+			t.Errorf("unexpected thumbnail: %+v", th)
+		}
+		if u, _ := url.Parse(first.URL); u.Path != "/media/"+first.Key {
+			t.Errorf("unexpected URL: %s", first.URL)
+		}
+		if !exists(first.Key) || !exists(first.Thumbnails[0].Key) {
+			t.Error("expected the image and thumbnail to be written")
+		}
+	})
+
+	t.Run("拒绝不合法的上传", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			path   string
+			parts  [][]byte
+			status int
+		}{
+			{"不是图片", "/products/1/images", [][]byte{[]byte("just some text")}, http.StatusUnsupportedMediaType},
+			{"超出大小", "/products/1/images", [][]byte{bytes.Repeat([]byte{0}, 65<<10)}, http.StatusRequestEntityTooLarge},
+			{"没有文件", "/products/1/images", nil, http.StatusBadRequest},
+			{"产品不存在", "/products/99/images", [][]byte{pngBytes(t, 10, 10)}, http.StatusNotFound},
+			// 第二张不合法时第一张也不保存
+			{"部分不合法", "/products/1/images", [][]byte{pngBytes(t, 10, 10), []byte("GIF89a broken")}, http.StatusBadRequest},
+		}
+		for _, c := range cases {
+			if rr := upload(admin, c.path, c.parts...); rr.Code != c.status {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d: %s", c.name, c.status, rr.Code, rr.Body)
+			}
+		}
+
+		if rr := api.Raw(admin, http.MethodPost, "/products/1/images", "image/png", string(pngBytes(t, 10, 10))); rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnsupportedMediaType, rr.Code)
+		}
+		if imgs := list(); len(imgs) != 2 {
+			t.Errorf("expected the rejected uploads not to be saved, got %d images", len(imgs))
+		}
+	})
+
+	t.Run("调整顺序", func(t *testing.T) {
+		send := func(ids ...int) *httptest.ResponseRecorder {
+			return api.JSON(admin, http.MethodPut, "/products/1/images/order", types.ReorderImagesPayload{ImageIDs: ids})
+		}
+		if rr := send(uploaded[0].ID); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(uploaded[1].ID, uploaded[0].ID); rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if imgs := list(); imgs[0].ID != uploaded[1].ID || imgs[1].ID != uploaded[0].ID {
+			t.Errorf("unexpected order: %+v", imgs)
+		}
+	})
+
+	t.Run("删除图片和文件", func(t *testing.T) {
+		path := fmt.Sprintf("/products/1/images/%d", uploaded[0].ID)
+		if rr := api.Do(customer, http.MethodDelete, path, ""); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+		if exists(uploaded[0].Key) || exists(uploaded[0].Thumbnails[0].Key) {
+			t.Error("expected the files to be deleted")
+		}
+		if imgs := list(); len(imgs) != 1 || imgs[0].Position != 0 {
+			t.Errorf("unexpected images: %+v", imgs)
+		}
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+		// 图片属于其它产品时视为不存在
+		if rr := api.Do(admin, http.MethodDelete, fmt.Sprintf("/products/2/images/%d", uploaded[1].ID), ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/filestore"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// OptionsFromConfig 从配置中读取上传限制和缩略图尺寸
+func OptionsFromConfig(cfg config.Config) (Options, error) {
+	sizes, err := ParseSizes(cfg.ImageThumbnailSizes)
+	if err != nil {
+		return Options{}, err
+	}
+	return Options{MaxBytes: cfg.ImageMaxBytes, MaxPixels: cfg.ImageMaxPixels, ThumbnailSizes: sizes}, nil
+}
+
+// Upload 一张上传的文件
+type Upload struct {
+	Filename string
+	Data     []byte
+}
+
+// List 返回产品的图片，并填上访问地址
+func List(ctx context.Context, stores types.Stores, files filestore.Storage, productID int) ([]types.ProductImage, error) {
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+	imgs, err := stores.Images.GetImages(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	return imgs, fillURLs(files, imgs)
+}
+
+// Add 校验并保存上传的图片和缩略图，按上传顺序追加到产品图片的末尾
+// 任何一张图片不合法时都不保存；写数据库失败时删除已经写入存储的文件
+func Add(ctx context.Context, stores types.Stores, files filestore.Storage, opts Options, productID int, uploads []Upload) ([]types.ProductImage, error) {
+	if len(uploads) == 0 {
+		return nil, apperr.BadRequest("missing_file", "at least one file part is required")
+	}
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+
+	// 先处理所有图片，有一张不合法就整体拒绝
+	ps := make([]*processed, len(uploads))
+	for i, u := range uploads {
+		p, err := process(u.Data, opts)
+		if err != nil {
+			return nil, withFile(err, u.Filename)
+		}
+		ps[i] = p
+	}
+
+	var written []string
+	cleanup := func() {
+		for _, key := range written {
+			if err := files.Delete(context.WithoutCancel(ctx), key); err != nil {
+				log.Printf("image: failed to delete %s: %v", key, err)
+			}
+		}
+	}
+
+	imgs := make([]*types.ProductImage, len(ps))
+	for i, p := range ps {
+		base := newKeyBase(productID)
+		img := &types.ProductImage{
+			ProductID:   productID,
+			Key:         base + p.ext,
+			ContentType: p.contentType,
+			Width:       p.width,
+			Height:      p.height,
+			Size:        int64(len(p.data)),
+			Thumbnails:  []types.ImageThumbnail{},
+		}
+		if err := files.Put(ctx, img.Key, p.data, p.contentType); err != nil {
+			cleanup()
+			return nil, err
+		}
+		written = append(written, img.Key)
+
+		thumbType, thumbExt := thumbnailType(p.contentType)
+		for _, t := range p.thumbnails {
+			key := fmt.Sprintf("%s_%d%s", base, t.size, thumbExt)
+			if err := files.Put(ctx, key, t.data, thumbType); err != nil {
+				cleanup()
+				return nil, err
+			}
+			written = append(written, key)
+			img.Thumbnails = append(img.Thumbnails, types.ImageThumbnail{Size: t.size, Key: key, Width: t.width, Height: t.height})
+		}
+		imgs[i] = img
+	}
+
+	var created []types.ProductImage
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		created = created[:0]
+		for _, img := range imgs {
+			if err := tx.Images.CreateImage(ctx, img); err != nil {
+				return err
+			}
+			created = append(created, *img)
+		}
+		return nil
+	})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return created, fillURLs(files, created)
+}
+
+// Delete 删除图片记录，再删除存储中的原图和缩略图
+// 文件删除失败只记录日志，不影响结果
+func Delete(ctx context.Context, stores types.Stores, files filestore.Storage, productID, imageID int) error {
+	var img *types.ProductImage
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		var err error
+		img, err = tx.Images.GetImage(ctx, imageID)
+		if err != nil {
+			return err
+		}
+		// 图片不属于这个产品时同样视为不存在
+		if img.ProductID != productID {
+			return imageNotFound(imageID)
+		}
+		return tx.Images.DeleteImage(ctx, imageID)
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{img.Key}
+	for _, t := range img.Thumbnails {
+		keys = append(keys, t.Key)
+	}
+	for _, key := range keys {
+		if err := files.Delete(ctx, key); err != nil {
+			log.Printf("image: failed to delete %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Reorder 按 imageIDs 的顺序重排产品的图片，第一张成为主图
+func Reorder(ctx context.Context, stores types.Stores, files filestore.Storage, productID int, imageIDs []int) ([]types.ProductImage, error) {
+	var imgs []types.ProductImage
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := productExists(ctx, tx, productID); err != nil {
+			return err
+		}
+		if err := tx.Images.ReorderImages(ctx, productID, imageIDs); err != nil {
+			return err
+		}
+		var err error
+		imgs, err = tx.Images.GetImages(ctx, productID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return imgs, fillURLs(files, imgs)
+}
+
+func fillURLs(files filestore.Storage, imgs []types.ProductImage) error {
+	for i := range imgs {
+		url, err := files.URL(imgs[i].Key)
+		if err != nil {
+			return err
+		}
+		imgs[i].URL = url
+		for j := range imgs[i].Thumbnails {
+			t := &imgs[i].Thumbnails[j]
+			if t.URL, err = files.URL(t.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func productExists(ctx context.Context, stores types.Stores, productID int) error {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+	return nil
+}
+
+// withFile 在错误信息中注明是哪个文件
+func withFile(err error, filename string) error {
+	var e *apperr.Error
+	if errors.As(err, &e) && filename != "" {
+		return e.WithFields([]apperr.FieldError{{Field: "file", Rule: e.Code, Param: filename, Message: e.Message}})
+	}
+	return err
+}
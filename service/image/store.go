@@ -0,0 +1,218 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询图片时的列，顺序与 query 中的 Scan 一致
+const imageColumns = "id, product_id, position, object_key, content_type, width, height, size, createdat"
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) GetImages(ctx context.Context, productID int) (_ []types.ProductImage, err error) {
+	const query = "SELECT " + imageColumns + " FROM product_images WHERE product_id = ? ORDER BY position, id"
+	ctx, span := tracing.StartStoreSpan(ctx, "image.Store.GetImages", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "image.Store.GetImages", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, productID)
+}
+
+func (s *Store) GetImage(ctx context.Context, id int) (_ *types.ProductImage, err error) {
+	const query = "SELECT " + imageColumns + " FROM product_images WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "image.Store.GetImage", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "image.Store.GetImage", db.Read)
+	defer cancel()
+
+	imgs, err := s.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return nil, imageNotFound(id)
+	}
+	return &imgs[0], nil
+}
+
+// CreateImage 把图片追加到末尾并写入缩略图，调用方应该把它放在事务中
+func (s *Store) CreateImage(ctx context.Context, img *types.ProductImage) (err error) {
+	const query = "INSERT INTO product_images (product_id, position, object_key, content_type, width, height, size) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "image.Store.CreateImage", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "image.Store.CreateImage", db.Write)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), img.ProductID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", img.ProductID))
+	}
+
+	var position int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = ?"), img.ProductID).
+		Scan(&position)
+	if err != nil {
+		return err
+	}
+	id, err := s.dialect.InsertID(ctx, s.db, query, img.ProductID, position, img.Key, img.ContentType, img.Width, img.Height, img.Size)
+	if err != nil {
+		return err
+	}
+	img.ID = int(id)
+	img.Position = position
+
+	for _, t := range img.Thumbnails {
+		_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+			"INSERT INTO product_image_thumbnails (image_id, size, object_key, width, height) VALUES (?, ?, ?, ?, ?)"),
+			img.ID, t.Size, t.Key, t.Width, t.Height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteImage 删除图片（缩略图通过外键级联删除），并让后面的图片前移
+func (s *Store) DeleteImage(ctx context.Context, id int) (err error) {
+	const query = "DELETE FROM product_images WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "image.Store.DeleteImage", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "image.Store.DeleteImage", db.Write)
+	defer cancel()
+
+	imgs, err := s.query(ctx, "SELECT "+imageColumns+" FROM product_images WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if len(imgs) == 0 {
+		return imageNotFound(id)
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind("UPDATE product_images SET position = position - 1 WHERE product_id = ? AND position > ?"),
+		imgs[0].ProductID, imgs[0].Position)
+	return err
+}
+
+// ReorderImages 按 imageIDs 的顺序重新编号，调用方应该把它放在事务中
+func (s *Store) ReorderImages(ctx context.Context, productID int, imageIDs []int) (err error) {
+	const query = "UPDATE product_images SET position = ? WHERE id = ? AND product_id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "image.Store.ReorderImages", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "image.Store.ReorderImages", db.Write)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind("SELECT id FROM product_images WHERE product_id = ?"), productID)
+	if err != nil {
+		return err
+	}
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := CheckOrder(productID, current, imageIDs); err != nil {
+		return err
+	}
+
+	for i, id := range imageIDs {
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), i, id, productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckOrder 检查新的顺序恰好包含产品现有的所有图片，每张一次
+func CheckOrder(productID int, current, imageIDs []int) error {
+	sorted := slices.Sorted(slices.Values(imageIDs))
+	if len(slices.Compact(slices.Clone(sorted))) != len(imageIDs) || !slices.Equal(sorted, slices.Sorted(slices.Values(current))) {
+		return apperr.Validation("image_order_mismatch",
+			fmt.Sprintf("imageIds must list each image of product ID %d exactly once", productID))
+	}
+	return nil
+}
+
+// 查询图片并填充缩略图
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]types.ProductImage, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imgs := []types.ProductImage{}
+	for rows.Next() {
+		var img types.ProductImage
+		if err := rows.Scan(&img.ID, &img.ProductID, &img.Position, &img.Key, &img.ContentType,
+			&img.Width, &img.Height, &img.Size, &img.CreatedAt); err != nil {
+			return nil, err
+		}
+		img.Thumbnails = []types.ImageThumbnail{}
+		imgs = append(imgs, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return imgs, nil
+	}
+
+	// 再一次性查出这些图片的缩略图
+	index := make(map[int]int, len(imgs))
+	ids := make([]any, len(imgs))
+	for i, img := range imgs {
+		index[img.ID] = i
+		ids[i] = img.ID
+	}
+	thumbRows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		"SELECT image_id, size, object_key, width, height FROM product_image_thumbnails WHERE image_id IN ("+
+			db.Placeholders(len(ids))+") ORDER BY image_id, size"), ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer thumbRows.Close()
+	for thumbRows.Next() {
+		var (
+			imageID int
+			t       types.ImageThumbnail
+		)
+		if err := thumbRows.Scan(&imageID, &t.Size, &t.Key, &t.Width, &t.Height); err != nil {
+			return nil, err
+		}
+		img := &imgs[index[imageID]]
+		img.Thumbnails = append(img.Thumbnails, t)
+	}
+	return imgs, thumbRows.Err()
+}
+
+func imageNotFound(id int) *apperr.Error {
+	return apperr.NotFound("image_not_found", fmt.Sprintf("image ID %d not found", id))
+}
@@ -0,0 +1,162 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 库存通知的事件类型
+const (
+	EventLowStock    = "stock.low"
+	EventBackInStock = "stock.back_in_stock"
+)
+
+// LowStockAlert 库存告警事件的数据，发邮件时发给 NOTIFY_EMAIL_TO
+type LowStockAlert struct {
+	ProductID   int    `json:"productId"`
+	ProductName string `json:"productName"`
+	Quantity    int    `json:"quantity"`
+	Threshold   int    `json:"threshold"`
+}
+
+// SubscriptionAlert 到货通知事件的数据，发邮件时发给订阅的用户
+type SubscriptionAlert struct {
+	SubscriptionID int    `json:"subscriptionId"`
+	UserID         int    `json:"userId"`
+	Email          string `json:"email"`
+	ProductID      int    `json:"productId"`
+	VariantID      *int   `json:"variantId,omitempty"`
+	ProductName    string `json:"productName"`
+	Quantity       int    `json:"quantity"`
+}
+
+func (a SubscriptionAlert) Recipient() string { return a.Email }
+
+// CheckLowStock 在结账成功后调用：before 是结账前读到的产品，items 是这次结账的购物车项
+// 库存因为这次结账从补货阈值以上降到阈值以下的产品各发出一条告警，返回发出的告警数
+func CheckLowStock(ctx context.Context, stores types.Stores, n notify.Notifier, before []types.Product, items []types.CartItem) (int, error) {
+	sold := map[int]int{}
+	for _, item := range items {
+		sold[item.ProductID] += item.Quantity
+	}
+	ids := make([]int, 0, len(before))
+	for _, p := range before {
+		ids = append(ids, p.ID)
+	}
+	thresholds, err := stores.Stock.GetReorderThresholds(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	seen := map[int]bool{}
+	for _, p := range before {
+		threshold := thresholds[p.ID]
+		after := p.Quantity - sold[p.ID]
+		if seen[p.ID] || threshold == 0 || p.Quantity < threshold || after >= threshold {
+			continue
+		}
+		seen[p.ID] = true
+		alert := LowStockAlert{ProductID: p.ID, ProductName: p.Name, Quantity: after, Threshold: threshold}
+		if err := n.Notify(ctx, notify.Event{Type: EventLowStock, Time: time.Now().UTC(), Data: alert}); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// NotifySubscribers 给所订阅的产品（或变体）已经有货的订阅发出到货通知，每个订阅只通知一次，返回发出的通知数
+// 发送前先领取订阅，同时运行的几次检查不会重复通知；通知失败时放回订阅，下次检查时重试
+func NotifySubscribers(ctx context.Context, stores types.Stores, n notify.Notifier) (int, error) {
+	subs, err := stores.Stock.GetPendingStockSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var firstErr error
+	for _, sub := range subs {
+		p, quantity, err := stockOf(ctx, stores, sub.ProductID, sub.VariantID)
+		if err != nil {
+			return sent, err
+		}
+		if quantity <= 0 {
+			continue
+		}
+		u, err := stores.Users.GetUserByID(ctx, sub.UserID)
+		if err != nil {
+			return sent, err
+		}
+
+		alert := SubscriptionAlert{
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			Email:          u.Email,
+			ProductID:      sub.ProductID,
+			VariantID:      sub.VariantID,
+			ProductName:    p.Name,
+			Quantity:       quantity,
+		}
+		claimed, err := stores.Stock.ClaimStockSubscription(ctx, sub.ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := n.Notify(ctx, notify.Event{Type: EventBackInStock, Time: time.Now().UTC(), Data: alert}); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if err := stores.Stock.ReleaseStockSubscription(ctx, sub.ID); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, firstErr
+}
+
+// Watch 每隔 interval 执行一次 NotifySubscribers，直到 ctx 结束；出错只写日志
+func Watch(ctx context.Context, stores types.Stores, n notify.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if sent, err := NotifySubscribers(ctx, stores, n); err != nil {
+				log.Printf("stock subscription check failed after %d notifications: %v", sent, err)
+			}
+		}
+	}
+}
+
+// stockOf 返回产品和订阅关心的库存：指定了变体时是变体的库存，否则是产品所有变体的库存之和
+func stockOf(ctx context.Context, stores types.Stores, productID int, variantID *int) (types.Product, int, error) {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return types.Product{}, 0, err
+	}
+	if len(ps) == 0 {
+		return types.Product{}, 0, productNotFound(productID)
+	}
+	if variantID == nil {
+		return ps[0], ps[0].Quantity, nil
+	}
+	vs, err := stores.Variants.GetVariantsByIDs(ctx, []int{*variantID})
+	if err != nil {
+		return types.Product{}, 0, err
+	}
+	if len(vs) == 0 || vs[0].ProductID != productID {
+		return types.Product{}, 0, variantNotFound(productID, *variantID)
+	}
+	return ps[0], vs[0].Quantity, nil
+}
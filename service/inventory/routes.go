@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores   types.Stores
+	notifier notify.Notifier
+}
+
+// notifier 用于补货后发出到货通知
+func NewHandler(stores types.Stores, notifier notify.Notifier) *Handler {
+	return &Handler{stores: stores, notifier: notifier}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/products/{id:[0-9]+}/stock-adjustments", auth.WithAdmin(h.handleAdjust, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/stock-history", auth.WithAdmin(h.handleHistory, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/reorder-threshold", auth.WithAdmin(h.handleSetThreshold, h.stores.Users)).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/notify-me", auth.WithJWTAuth(h.handleNotifyMe, h.stores.Users)).Methods("POST")
+}
+
+func (h *Handler) handleAdjust(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.StockAdjustmentPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	m, err := Adjust(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), productID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	// 补货后在后台检查到货订阅，不影响响应
+	if m.Delta > 0 {
+		go func() {
+			if _, err := NotifySubscribers(context.WithoutCancel(r.Context()), h.stores, h.notifier); err != nil {
+				log.Printf("stock subscription check failed: %v", err)
+			}
+		}()
+	}
+	utils.WriteJson(w, http.StatusCreated, m)
+}
+
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	ms, err := History(r.Context(), h.stores, productID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ms)
+}
+
+func (h *Handler) handleSetThreshold(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ReorderThresholdPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	level, err := SetThreshold(r.Context(), h.stores, productID, payload.Threshold)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, level)
+}
+
+// 请求体可以省略，表示订阅产品的任意变体
+func (h *Handler) handleNotifyMe(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.StockSubscriptionPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJson(w, r, &payload); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		if err := utils.ValidatePayload(r, payload); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	sub, err := Subscribe(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), productID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, sub)
+}
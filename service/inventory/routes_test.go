@@ -0,0 +1,297 @@
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/order"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestInventoryRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 5},
+		types.Product{Name: "t-shirt", Price: 20},
+	)
+	// 第二个产品有两个变体
+	blue := &types.ProductVariant{ProductID: 2, SKU: "TS-BLUE", Quantity: 1, Options: map[string]string{"colour": "blue"}}
+	if err := store.CreateVariant(ctx, blue); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	inventory.NewHandler(store.Stores(), notify.Log{}).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	buyer := &types.User{Email: "buyer@example.com"}
+	for _, u := range []*types.User{admin, buyer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+
+	t.Run("只有管理员可以调整库存", func(t *testing.T) {
+		body := `{"delta":1,"reason":"stocktake"}`
+		if rr := api.Do(nil, http.MethodPost, "/admin/products/1/stock-adjustments", body); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+		if rr := api.Do(buyer, http.MethodPost, "/admin/products/1/stock-adjustments", body); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := api.Do(buyer, http.MethodGet, "/admin/products/1/stock-history", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("调整库存", func(t *testing.T) {
+		var m types.StockMovement
+		apitest.Decode(t, api.Do(admin, http.MethodPost, "/admin/products/1/stock-adjustments", `{"delta":-2,"reason":"damaged"}`), http.StatusCreated, &m)
+		if m.ID == 0 || m.ProductID != 1 || m.Type != types.StockAdjustment || m.Delta != -2 || m.Balance != 3 ||
+			m.Reason != "damaged" || m.ActorID == nil || *m.ActorID != admin.ID {
+			t.Errorf("unexpected movement: %+v", m)
+		}
+
+		path := "/admin/products/2/stock-adjustments"
+		apitest.Decode(t, api.Do(admin, http.MethodPost, path, `{"variantId":`+strconv.Itoa(blue.ID)+`,"delta":4,"type":"restock","reason":"delivery"}`),
+			http.StatusCreated, &m)
+		if m.VariantID != blue.ID || m.Type != types.StockRestock || m.Balance != 5 {
+			t.Errorf("unexpected movement: %+v", m)
+		}
+
+		for _, tc := range []struct {
+			name, path, body string
+			status           int
+		}{
+			{"缺少原因", path, `{"variantId":` + strconv.Itoa(blue.ID) + `,"delta":1}`, http.StatusBadRequest},
+			{"变化为 0", path, `{"variantId":` + strconv.Itoa(blue.ID) + `,"delta":0,"reason":"x"}`, http.StatusBadRequest},
+			{"不能手工记为销售", path, `{"variantId":` + strconv.Itoa(blue.ID) + `,"delta":1,"type":"sale","reason":"x"}`, http.StatusBadRequest},
+			{"多个变体时必须指定变体", path, `{"delta":1,"reason":"x"}`, http.StatusBadRequest},
+			{"变体不属于该产品", "/admin/products/1/stock-adjustments", `{"variantId":` + strconv.Itoa(blue.ID) + `,"delta":1,"reason":"x"}`, http.StatusNotFound},
+			{"产品不存在", "/admin/products/99/stock-adjustments", `{"delta":1,"reason":"x"}`, http.StatusNotFound},
+			{"库存不足", "/admin/products/1/stock-adjustments", `{"delta":-4,"reason":"x"}`, http.StatusConflict},
+		} {
+			if rr := api.Do(admin, http.MethodPost, tc.path, tc.body); rr.Code != tc.status {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d", tc.name, tc.status, rr.Code)
+			}
+		}
+	})
+
+	t.Run("库存流水记录下单和取消订单", func(t *testing.T) {
+		orderID, err := store.CreateOrder(ctx, types.Order{UserID: buyer.ID, Total: 30, Status: types.OrderPending, Address: "addr"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: 1, Quantity: 2, Price: 15}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := order.ChangeStatus(ctx, store.Stores(), orderID, types.OrderCancelled, admin.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		var ms []types.StockMovement
+		apitest.Decode(t, api.Do(admin, http.MethodGet, "/admin/products/1/stock-history", ""), http.StatusOK, &ms)
+		want := []struct {
+			kind           string
+			delta, balance int
+		}{
+			{types.StockRelease, 2, 3},
+			{types.StockSale, -2, 1},
+			{types.StockAdjustment, -2, 3},
+			{types.StockRestock, 5, 5}, // 初始库存
+		}
+		if len(ms) != len(want) {
+			t.Fatalf("expected %d movements, got %+v", len(want), ms)
+		}
+		for i, w := range want {
+			if ms[i].Type != w.kind || ms[i].Delta != w.delta || ms[i].Balance != w.balance {
+				t.Errorf("movement %d: expected %s %+d -> %d, got %+v", i, w.kind, w.delta, w.balance, ms[i])
+			}
+		}
+		release, sale := ms[0], ms[1]
+		if release.OrderID == nil || *release.OrderID != orderID || release.ActorID == nil || *release.ActorID != admin.ID {
+			t.Errorf("unexpected release: %+v", release)
+		}
+		if sale.OrderID == nil || *sale.OrderID != orderID || sale.ActorID == nil || *sale.ActorID != buyer.ID {
+			t.Errorf("unexpected sale: %+v", sale)
+		}
+
+		if rr := api.Do(admin, http.MethodGet, "/admin/products/99/stock-history", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("对账", func(t *testing.T) {
+		diffs, err := inventory.Reconcile(ctx, store.Stores(), false, 0)
+		if err != nil || len(diffs) != 0 {
+			t.Fatalf("expected the ledger to match the stock, got %+v %v", diffs, err)
+		}
+	})
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (r *recorder) Notify(_ context.Context, e notify.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+type failing struct{}
+
+func (failing) Notify(context.Context, notify.Event) error { return errors.New("smtp unavailable") }
+
+func TestStockNotifications(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 5},
+		types.Product{Name: "mug", Price: 8},
+	)
+	rec := &recorder{}
+	router := mux.NewRouter()
+	inventory.NewHandler(store.Stores(), rec).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	buyer := &types.User{Email: "buyer@example.com"}
+	for _, u := range []*types.User{admin, buyer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := apitest.New(router)
+
+	t.Run("设置补货阈值", func(t *testing.T) {
+		if rr := api.Do(buyer, http.MethodPut, "/admin/products/1/reorder-threshold", `{"threshold":3}`); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+		var level types.StockLevel
+		apitest.Decode(t, api.Do(admin, http.MethodPut, "/admin/products/1/reorder-threshold", `{"threshold":3}`), http.StatusOK, &level)
+		if level != (types.StockLevel{ProductID: 1, Quantity: 5, ReorderThreshold: 3}) {
+			t.Errorf("unexpected stock level: %+v", level)
+		}
+		if rr := api.Do(admin, http.MethodPut, "/admin/products/1/reorder-threshold", `{"threshold":-1}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := api.Do(admin, http.MethodPut, "/admin/products/99/reorder-threshold", `{"threshold":1}`); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("结账后库存低于阈值时告警一次", func(t *testing.T) {
+		before, _ := store.GetProductByIDs(ctx, []int{1, 2})
+		// 5 -> 4 仍不低于阈值
+		sent, err := inventory.CheckLowStock(ctx, store.Stores(), rec, before, []types.CartItem{{ProductID: 1, Quantity: 1}})
+		if err != nil || sent != 0 {
+			t.Fatalf("expected no alert, got %d %v", sent, err)
+		}
+		// 5 -> 2 跨过阈值
+		sent, err = inventory.CheckLowStock(ctx, store.Stores(), rec, before, []types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 1, Quantity: 2}})
+		if err != nil || sent != 1 || len(rec.events) != 1 {
+			t.Fatalf("expected one alert, got %d %v", sent, err)
+		}
+		alert, ok := rec.events[0].Data.(inventory.LowStockAlert)
+		if rec.events[0].Type != inventory.EventLowStock || !ok || alert.ProductID != 1 || alert.Quantity != 2 || alert.Threshold != 3 {
+			t.Errorf("unexpected event: %+v", rec.events[0])
+		}
+		// 之前已经低于阈值时不再告警
+		before[0].Quantity = 2
+		if sent, _ := inventory.CheckLowStock(ctx, store.Stores(), rec, before, []types.CartItem{{ProductID: 1, Quantity: 1}}); sent != 0 {
+			t.Errorf("expected no alert below the threshold, got %d", sent)
+		}
+		rec.events = nil
+	})
+
+	t.Run("订阅到货通知", func(t *testing.T) {
+		if rr := api.Do(nil, http.MethodPost, "/products/2/notify-me", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+		var sub types.StockSubscription
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/products/2/notify-me", ""), http.StatusCreated, &sub)
+		if sub.ID == 0 || sub.UserID != buyer.ID || sub.ProductID != 2 || sub.VariantID != nil {
+			t.Errorf("unexpected subscription: %+v", sub)
+		}
+
+		for _, tc := range []struct {
+			name, path, body string
+			status           int
+		}{
+			{"重复订阅", "/products/2/notify-me", "", http.StatusConflict},
+			{"有货时不能订阅", "/products/1/notify-me", "", http.StatusConflict},
+			{"变体不属于该产品", "/products/2/notify-me", `{"variantId":1}`, http.StatusNotFound},
+			{"产品不存在", "/products/99/notify-me", "", http.StatusNotFound},
+		} {
+			if rr := api.Do(buyer, http.MethodPost, tc.path, tc.body); rr.Code != tc.status {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d", tc.name, tc.status, rr.Code)
+			}
+		}
+	})
+
+	t.Run("补货后通知一次", func(t *testing.T) {
+		if sent, err := inventory.NotifySubscribers(ctx, store.Stores(), rec); err != nil || sent != 0 {
+			t.Fatalf("expected no notification while out of stock, got %d %v", sent, err)
+		}
+		if _, err := store.AdjustStock(ctx, 2, 4, types.StockChange{Type: types.StockRestock, Reason: "delivery"}); err != nil {
+			t.Fatal(err)
+		}
+		if sent, err := inventory.NotifySubscribers(ctx, store.Stores(), rec); err != nil || sent != 1 || len(rec.events) != 1 {
+			t.Fatalf("expected one notification, got %d %v", sent, err)
+		}
+		alert, ok := rec.events[0].Data.(inventory.SubscriptionAlert)
+		if rec.events[0].Type != inventory.EventBackInStock || !ok || alert.Recipient() != buyer.Email || alert.ProductName != "mug" || alert.Quantity != 4 {
+			t.Errorf("unexpected event: %+v", rec.events[0])
+		}
+		if sent, _ := inventory.NotifySubscribers(ctx, store.Stores(), rec); sent != 0 {
+			t.Errorf("expected the subscription to be notified only once, got %d", sent)
+		}
+	})
+
+	t.Run("同时检查时只通知一次", func(t *testing.T) {
+		if err := store.CreateStockSubscription(ctx, &types.StockSubscription{UserID: admin.ID, ProductID: 2}); err != nil {
+			t.Fatal(err)
+		}
+		rec.events = nil
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := inventory.NotifySubscribers(ctx, store.Stores(), rec); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		if len(rec.events) != 1 {
+			t.Errorf("expected one notification, got %d", len(rec.events))
+		}
+	})
+
+	t.Run("通知失败时下次重试", func(t *testing.T) {
+		if err := store.CreateStockSubscription(ctx, &types.StockSubscription{UserID: buyer.ID, ProductID: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if sent, err := inventory.NotifySubscribers(ctx, store.Stores(), failing{}); err == nil || sent != 0 {
+			t.Fatalf("expected the notification to fail, got %d %v", sent, err)
+		}
+		if subs, _ := store.GetPendingStockSubscriptions(ctx); len(subs) != 1 {
+			t.Fatalf("expected the subscription to stay pending, got %+v", subs)
+		}
+		if sent, err := inventory.NotifySubscribers(ctx, store.Stores(), rec); err != nil || sent != 1 {
+			t.Errorf("expected a retry to succeed, got %d %v", sent, err)
+		}
+	})
+}
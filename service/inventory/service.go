@@ -0,0 +1,145 @@
+// Package inventory 库存流水：每次库存变动（下单、取消、进货、退货、人工调整）都追加一条记录，
+// 变体的库存应当等于它所有流水的 delta 之和，不一致时可以对账补记
+package inventory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// reconcileReason 对账补记差异时流水的原因
+const reconcileReason = "reconciliation"
+
+// History 返回产品的库存流水，最新的在前
+func History(ctx context.Context, stores types.Stores, productID int) ([]types.StockMovement, error) {
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+	return stores.Stock.GetStockMovements(ctx, productID)
+}
+
+// Adjust 由管理员调整库存并返回记下的流水，类型默认为 adjustment
+// 产品只有一个变体时可以不指定变体；变体不属于该产品时视为不存在
+func Adjust(ctx context.Context, stores types.Stores, actorID, productID int, payload types.StockAdjustmentPayload) (*types.StockMovement, error) {
+	change := types.StockChange{Type: payload.Type, Reason: payload.Reason, ActorID: actorID}
+	if change.Type == "" {
+		change.Type = types.StockAdjustment
+	}
+
+	var m *types.StockMovement
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := productExists(ctx, tx, productID); err != nil {
+			return err
+		}
+		if payload.VariantID == 0 {
+			if _, err := tx.Products.AdjustStock(ctx, productID, payload.Delta, change); err != nil {
+				return err
+			}
+		} else {
+			vs, err := tx.Variants.GetVariantsByIDs(ctx, []int{payload.VariantID})
+			if err != nil {
+				return err
+			}
+			if len(vs) == 0 || vs[0].ProductID != productID {
+				return variantNotFound(productID, payload.VariantID)
+			}
+			if _, err := tx.Variants.AdjustVariantStock(ctx, payload.VariantID, payload.Delta, change); err != nil {
+				return err
+			}
+		}
+
+		// 刚追加的流水是最新的一条
+		ms, err := tx.Stock.GetStockMovements(ctx, productID)
+		if err != nil {
+			return err
+		}
+		m = &ms[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reconcile 返回库存与流水累计值不一致的变体；fix 为 true 时为每个差异补记一条 adjustment 流水，
+// 让流水与现有库存一致（库存本身不变）。actorID 是执行对账的用户，ecomctl 传 0
+func Reconcile(ctx context.Context, stores types.Stores, fix bool, actorID int) ([]types.StockBalance, error) {
+	var diffs []types.StockBalance
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		bs, err := tx.Stock.GetStockBalances(ctx)
+		if err != nil {
+			return err
+		}
+		diffs = []types.StockBalance{}
+		for _, b := range bs {
+			if b.Quantity == b.Ledger {
+				continue
+			}
+			diffs = append(diffs, b)
+			if !fix {
+				continue
+			}
+			change := types.StockChange{Type: types.StockAdjustment, Reason: reconcileReason, ActorID: actorID}
+			if err := tx.Stock.RecordStockMovement(ctx, Movement(b.ProductID, b.VariantID, b.Quantity-b.Ledger, b.Quantity, change)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// Subscribe 为用户订阅产品（或变体）的到货通知，补货后通知一次
+// 产品或变体目前有货时不能订阅
+func Subscribe(ctx context.Context, stores types.Stores, userID, productID int, payload types.StockSubscriptionPayload) (*types.StockSubscription, error) {
+	var variantID *int
+	if payload.VariantID != 0 {
+		variantID = &payload.VariantID
+	}
+	p, quantity, err := stockOf(ctx, stores, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity > 0 {
+		return nil, apperr.Conflict("in_stock", fmt.Sprintf("product ID %d is in stock", p.ID))
+	}
+
+	sub := &types.StockSubscription{UserID: userID, ProductID: productID, VariantID: variantID}
+	if err := stores.Stock.CreateStockSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// SetThreshold 设置产品的补货阈值，0 表示不告警
+func SetThreshold(ctx context.Context, stores types.Stores, productID, threshold int) (*types.StockLevel, error) {
+	if err := stores.Stock.SetReorderThreshold(ctx, productID, threshold); err != nil {
+		return nil, err
+	}
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, productNotFound(productID)
+	}
+	return &types.StockLevel{ProductID: productID, Quantity: ps[0].Quantity, ReorderThreshold: threshold}, nil
+}
+
+func productExists(ctx context.Context, stores types.Stores, productID int) error {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return productNotFound(productID)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询库存流水时的列，顺序与 query 中的 Scan 一致
+const movementColumns = "id, product_id, variant_id, type, delta, balance, reason, actor_id, order_id, createdat"
+
+// InitialStock 创建产品或变体时带有的初始库存记为进货
+var InitialStock = types.StockChange{Type: types.StockRestock, Reason: "initial stock"}
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) GetStockMovements(ctx context.Context, productID int) (_ []types.StockMovement, err error) {
+	const query = "SELECT " + movementColumns + " FROM stock_movements WHERE product_id = ? ORDER BY id DESC"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.GetStockMovements", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.GetStockMovements", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ms := []types.StockMovement{}
+	for rows.Next() {
+		var (
+			m                types.StockMovement
+			actorID, orderID sql.NullInt64
+		)
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.Type, &m.Delta, &m.Balance, &m.Reason,
+			&actorID, &orderID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.ActorID, m.OrderID = nullableID(actorID), nullableID(orderID)
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
+
+// GetStockBalances 没有流水的变体累计值为 0
+func (s *Store) GetStockBalances(ctx context.Context) (_ []types.StockBalance, err error) {
+	const query = `SELECT v.product_id, v.id, v.sku, v.quantity, COALESCE(SUM(m.delta), 0)
+		FROM product_variants v LEFT JOIN stock_movements m ON m.variant_id = v.id
+		GROUP BY v.product_id, v.id, v.sku, v.quantity ORDER BY v.id`
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.GetStockBalances", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.GetStockBalances", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bs := []types.StockBalance{}
+	for rows.Next() {
+		var b types.StockBalance
+		if err := rows.Scan(&b.ProductID, &b.VariantID, &b.SKU, &b.Quantity, &b.Ledger); err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, rows.Err()
+}
+
+func (s *Store) RecordStockMovement(ctx context.Context, m *types.StockMovement) (err error) {
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.RecordStockMovement", insertMovement)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.RecordStockMovement", db.Write)
+	defer cancel()
+
+	return Record(ctx, s.db, s.dialect, m)
+}
+
+const insertMovement = "INSERT INTO stock_movements (product_id, variant_id, type, delta, balance, reason, actor_id, order_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// Record 追加一条库存流水并写回分配的 ID，供修改库存的 store 在同一个连接（事务）中调用
+func Record(ctx context.Context, q db.Querier, dialect db.Dialect, m *types.StockMovement) error {
+	id, err := dialect.InsertID(ctx, q, insertMovement, m.ProductID, m.VariantID, m.Type, m.Delta, m.Balance, m.Reason,
+		nullID(m.ActorID), nullID(m.OrderID))
+	if err != nil {
+		return err
+	}
+	m.ID = int(id)
+	return nil
+}
+
+// Movement 根据一次库存调整生成流水记录，balance 是调整后变体的库存
+func Movement(productID, variantID, delta, balance int, change types.StockChange) *types.StockMovement {
+	m := &types.StockMovement{
+		ProductID: productID,
+		VariantID: variantID,
+		Type:      change.Type,
+		Delta:     delta,
+		Balance:   balance,
+		Reason:    change.Reason,
+	}
+	if m.Type == "" {
+		m.Type = types.StockAdjustment
+	}
+	if change.ActorID != 0 {
+		m.ActorID = &change.ActorID
+	}
+	if change.OrderID != 0 {
+		m.OrderID = &change.OrderID
+	}
+	return m
+}
+
+func nullID(id *int) sql.NullInt64 {
+	if id == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*id), Valid: true}
+}
+
+func nullableID(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	id := int(n.Int64)
+	return &id
+}
+
+func (s *Store) GetReorderThresholds(ctx context.Context, productIDs []int) (_ map[int]int, err error) {
+	thresholds := map[int]int{}
+	if len(productIDs) == 0 {
+		return thresholds, nil
+	}
+	query := "SELECT id, reorder_threshold FROM products WHERE id IN (" + db.Placeholders(len(productIDs)) + ")"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.GetReorderThresholds", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.GetReorderThresholds", db.Read)
+	defer cancel()
+
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, threshold int
+		if err := rows.Scan(&id, &threshold); err != nil {
+			return nil, err
+		}
+		thresholds[id] = threshold
+	}
+	return thresholds, rows.Err()
+}
+
+func (s *Store) SetReorderThreshold(ctx context.Context, productID, threshold int) (err error) {
+	const query = "UPDATE products SET reorder_threshold = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.SetReorderThreshold", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.SetReorderThreshold", db.Write)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), threshold, productID); err != nil {
+		return err
+	}
+	// MySQL 在值没有变化时 RowsAffected 是 0，所以单独检查产品是否存在
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), productID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return productNotFound(productID)
+	}
+	return nil
+}
+
+func (s *Store) CreateStockSubscription(ctx context.Context, sub *types.StockSubscription) (err error) {
+	const query = "INSERT INTO stock_subscriptions (user_id, product_id, variant_id) VALUES (?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.CreateStockSubscription", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.CreateStockSubscription", db.Write)
+	defer cancel()
+
+	// variant_id 可以为空，唯一索引管不到，所以在插入前检查
+	dup := "SELECT COUNT(*) FROM stock_subscriptions WHERE user_id = ? AND product_id = ? AND notified_at IS NULL AND variant_id IS NULL"
+	args := []any{sub.UserID, sub.ProductID}
+	if sub.VariantID != nil {
+		dup = "SELECT COUNT(*) FROM stock_subscriptions WHERE user_id = ? AND product_id = ? AND notified_at IS NULL AND variant_id = ?"
+		args = append(args, *sub.VariantID)
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind(dup), args...).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return SubscriptionExists(sub.ProductID)
+	}
+
+	id, err := s.dialect.InsertID(ctx, s.db, query, sub.UserID, sub.ProductID, nullID(sub.VariantID))
+	if err != nil {
+		return err
+	}
+	sub.ID = int(id)
+	sub.NotifiedAt = nil
+	return nil
+}
+
+func (s *Store) GetPendingStockSubscriptions(ctx context.Context) (_ []types.StockSubscription, err error) {
+	const query = "SELECT id, user_id, product_id, variant_id, notified_at, createdat FROM stock_subscriptions WHERE notified_at IS NULL ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.GetPendingStockSubscriptions", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.GetPendingStockSubscriptions", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []types.StockSubscription{}
+	for rows.Next() {
+		var (
+			sub        types.StockSubscription
+			variantID  sql.NullInt64
+			notifiedAt sql.NullTime
+		)
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.ProductID, &variantID, &notifiedAt, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		sub.VariantID = nullableID(variantID)
+		if notifiedAt.Valid {
+			sub.NotifiedAt = &notifiedAt.Time
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// ClaimStockSubscription 只更新 notified_at 为空的行，同时检查的几个调用方只有一个能更新成功
+func (s *Store) ClaimStockSubscription(ctx context.Context, id int) (_ bool, err error) {
+	const query = "UPDATE stock_subscriptions SET notified_at = CURRENT_TIMESTAMP WHERE id = ? AND notified_at IS NULL"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.ClaimStockSubscription", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.ClaimStockSubscription", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+	// 没有更新到：订阅不存在，或者已经通知过
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM stock_subscriptions WHERE id = ?"), id).Scan(&count); err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, subscriptionNotFound(id)
+	}
+	return false, nil
+}
+
+func (s *Store) ReleaseStockSubscription(ctx context.Context, id int) (err error) {
+	const query = "UPDATE stock_subscriptions SET notified_at = NULL WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "inventory.Store.ReleaseStockSubscription", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "inventory.Store.ReleaseStockSubscription", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return subscriptionNotFound(id)
+	}
+	return nil
+}
+
+func subscriptionNotFound(id int) *apperr.Error {
+	return apperr.NotFound("subscription_not_found", fmt.Sprintf("stock subscription ID %d not found", id))
+}
+
+// SubscriptionExists 用户已经订阅了该产品（或变体）的到货通知且还没有通知时返回的错误
+func SubscriptionExists(productID int) *apperr.Error {
+	return apperr.Conflict("subscription_exists", fmt.Sprintf("already subscribed to product ID %d", productID))
+}
+
+func productNotFound(id int) error {
+	return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", id))
+}
+
+func variantNotFound(productID, variantID int) error {
+	return apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d of product ID %d not found", variantID, productID))
+}
@@ -0,0 +1,47 @@
+package order
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/orders/{id:[0-9]+}/status", auth.WithAdmin(h.handleUpdateStatus, h.stores.Users)).Methods("PUT")
+}
+
+// 管理员修改订单状态，只允许 ChangeStatus 中定义的流转
+func (h *Handler) handleUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	orderID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.UpdateOrderStatusPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	o, err := ChangeStatus(r.Context(), h.stores, orderID, payload.Status, auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, o)
+}
@@ -0,0 +1,118 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestUpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(types.Product{Name: "keyboard", Price: 10, Quantity: 10})
+	router := mux.NewRouter()
+	NewHandler(store.Stores()).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	customer := &types.User{Email: "customer@example.com"}
+	for _, u := range []*types.User{admin, customer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newOrder := func() int {
+		id, err := store.CreateOrder(ctx, types.Order{UserID: customer.ID, Total: 20, Status: types.OrderPending, Address: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateOrderItem(ctx, types.OrderItem{OrderID: id, ProductID: 1, Quantity: 2, Price: 10}); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	send := func(user *types.User, orderID int, status string) *httptest.ResponseRecorder {
+		token, _ := auth.GenerateJWT([]byte(config.Envs.JWTSecret), user.ID)
+		body, _ := json.Marshal(types.UpdateOrderStatusPayload{Status: status})
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/orders/%d/status", orderID), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("只有管理员可以修改", func(t *testing.T) {
+		if rr := send(customer, newOrder(), types.OrderCompleted); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("取消订单后库存恢复，且不能再修改", func(t *testing.T) {
+		id := newOrder()
+		before := quantity(t, store)
+
+		rr := send(admin, id, types.OrderCancelled)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var o types.Order
+		json.NewDecoder(rr.Body).Decode(&o)
+		if o.ID != id || o.Status != types.OrderCancelled {
+			t.Errorf("unexpected order: %+v", o)
+		}
+		if got := quantity(t, store); got != before+2 {
+			t.Errorf("expected stock %d, got %d", before+2, got)
+		}
+
+		if rr := send(admin, id, types.OrderCompleted); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("发货后才能标记为已送达", func(t *testing.T) {
+		id := newOrder()
+		if rr := send(admin, id, types.OrderDelivered); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		if rr := send(admin, id, types.OrderCompleted); rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if rr := send(admin, id, types.OrderDelivered); rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		for _, status := range []string{types.OrderCancelled, types.OrderCompleted} {
+			if rr := send(admin, id, status); rr.Code != http.StatusConflict {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d", status, http.StatusConflict, rr.Code)
+			}
+		}
+	})
+
+	t.Run("无效的状态和不存在的订单", func(t *testing.T) {
+		if rr := send(admin, newOrder(), "shipped"); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(admin, 9999, types.OrderCompleted); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func quantity(t *testing.T, store *memstore.Store) int {
+	t.Helper()
+	ps, err := store.GetProductByIDs(context.Background(), []int{1})
+	if err != nil || len(ps) != 1 {
+		t.Fatalf("get product: %v %v", ps, err)
+	}
+	return ps[0].Quantity
+}
@@ -0,0 +1,64 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 允许的订单状态流转，已发货的订单只能标记为已送达，已送达和已取消的订单不能再修改
+// API 和 ecomctl 都通过 ChangeStatus 修改状态，规则只在这里维护
+var transitions = map[string][]string{
+	types.OrderPending:   {types.OrderCompleted, types.OrderCancelled},
+	types.OrderCompleted: {types.OrderDelivered},
+}
+
+// CanTransition 判断订单能否从 from 变为 to
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// ChangeStatus 在一个事务中检查状态流转并修改订单状态，取消订单时把订单项的库存加回对应的变体
+// actorID 是操作的用户，记入库存流水，ecomctl 传 0
+func ChangeStatus(ctx context.Context, stores types.Stores, orderID int, to string, actorID int) (*types.Order, error) {
+	var updated *types.Order
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		o, err := tx.Orders.GetOrderByID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if !CanTransition(o.Status, to) {
+			return apperr.Conflict("invalid_status_transition",
+				fmt.Sprintf("order %d cannot change from %s to %s", orderID, o.Status, to))
+		}
+
+		if to == types.OrderCancelled {
+			items, err := tx.Orders.GetOrderItems(ctx, orderID)
+			if err != nil {
+				return err
+			}
+			change := types.StockChange{
+				Type:    types.StockRelease,
+				Reason:  fmt.Sprintf("order %d cancelled", orderID),
+				ActorID: actorID,
+				OrderID: orderID,
+			}
+			for _, item := range items {
+				if _, err := tx.Variants.AdjustVariantStock(ctx, item.VariantID, item.Quantity, change); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Orders.UpdateOrderStatus(ctx, orderID, to); err != nil {
+			return err
+		}
+		o.Status = to
+		updated = o
+		return nil
+	})
+	return updated, err
+}
@@ -1,36 +1,204 @@
 package order
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/tracing"
 	"github.com/Albert-tru/ecom/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
 }
 
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
 // CreateOrder 创建订单
-func (s *Store) CreateOrder(o types.Order) (int, error) {
-	res, err := s.db.Exec("INSERT INTO orders (user_id, total, status, address) VALUES (?, ?, ?, ?)",
-		o.UserID, o.Total, o.Status, o.Address)
+func (s *Store) CreateOrder(ctx context.Context, o types.Order) (_ int, err error) {
+	const query = "INSERT INTO orders (user_id, total, status, address) VALUES (?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.CreateOrder", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.CreateOrder", db.Write)
+	defer cancel()
+
+	id, err := s.dialect.InsertID(ctx, s.db, query, o.UserID, o.Total, o.Status, o.Address)
 	if err != nil {
 		return 0, err
 	}
-	id, err := res.LastInsertId()
+	return int(id), nil
+}
+
+// CreateOrderItem 创建订单项，并扣减对应变体和产品的库存
+// oi.VariantID 为 0 时使用产品唯一的变体；库存不足时不会写入订单项，返回 insufficient_stock
+func (s *Store) CreateOrderItem(ctx context.Context, oi types.OrderItem) (err error) {
+	const query = "INSERT INTO order_items (order_id, product_id, variant_id, quantity, price, price_id) VALUES (?, ?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.CreateOrderItem", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.CreateOrderItem", db.Write)
+	defer cancel()
+
+	if oi.VariantID == 0 {
+		oi.VariantID, err = variant.SoleVariantID(ctx, s.db, s.dialect, oi.ProductID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 条件更新保证并发下单时库存不会被扣成负数
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		"UPDATE product_variants SET quantity = quantity - ? WHERE id = ? AND product_id = ? AND quantity >= ?"),
+		oi.Quantity, oi.VariantID, oi.ProductID, oi.Quantity)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return int(id), nil
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return s.stockError(ctx, oi.ProductID, oi.VariantID)
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind("UPDATE products SET quantity = quantity - ? WHERE id = ?"), oi.Quantity, oi.ProductID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind(query), oi.OrderID, oi.ProductID, oi.VariantID, oi.Quantity, oi.Price, oi.PriceID)
+	if err != nil {
+		return err
+	}
+	return s.recordSale(ctx, oi)
+}
+
+// recordSale 在库存流水中记录下单扣减的库存，操作人是下单的用户
+func (s *Store) recordSale(ctx context.Context, oi types.OrderItem) error {
+	var balance, userID int
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		"SELECT v.quantity, o.user_id FROM product_variants v, orders o WHERE v.id = ? AND o.id = ?"), oi.VariantID, oi.OrderID).
+		Scan(&balance, &userID)
+	if err != nil {
+		return err
+	}
+	change := types.StockChange{Type: types.StockSale, Reason: fmt.Sprintf("order %d", oi.OrderID), ActorID: userID, OrderID: oi.OrderID}
+	return inventory.Record(ctx, s.db, s.dialect, inventory.Movement(oi.ProductID, oi.VariantID, -oi.Quantity, balance, change))
+}
+
+// 扣减库存失败时区分是变体不存在（或不属于该产品）还是库存不足
+func (s *Store) stockError(ctx context.Context, productID, variantID int) error {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM product_variants WHERE id = ? AND product_id = ?"),
+		variantID, productID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d of product ID %d not found", variantID, productID))
+	}
+	return apperr.Conflict("insufficient_stock", fmt.Sprintf("insufficient stock for variant ID %d", variantID))
+}
+
+// GetOrderByID 根据ID获取订单
+func (s *Store) GetOrderByID(ctx context.Context, id int) (_ *types.Order, err error) {
+	const query = "SELECT id, user_id, total, status, address, createdat FROM orders WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.GetOrderByID", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.GetOrderByID", db.Read)
+	defer cancel()
+
+	o := new(types.Order)
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind(query), id).
+		Scan(&o.ID, &o.UserID, &o.Total, &o.Status, &o.Address, &o.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, orderNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// GetOrderItems 获取订单的所有订单项，按ID排序
+func (s *Store) GetOrderItems(ctx context.Context, orderID int) (_ []types.OrderItem, err error) {
+	const query = "SELECT id, order_id, product_id, COALESCE(variant_id, 0), quantity, price, price_id, createdat FROM order_items WHERE order_id = ? ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.GetOrderItems", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.GetOrderItems", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.OrderItem{}
+	for rows.Next() {
+		var (
+			oi      types.OrderItem
+			priceID sql.NullInt64
+		)
+		if err := rows.Scan(&oi.ID, &oi.OrderID, &oi.ProductID, &oi.VariantID, &oi.Quantity, &oi.Price, &priceID, &oi.CreatedAt); err != nil {
+			return nil, err
+		}
+		if priceID.Valid {
+			id := int(priceID.Int64)
+			oi.PriceID = &id
+		}
+		items = append(items, oi)
+	}
+	return items, rows.Err()
+}
+
+// UpdateOrderStatus 直接修改订单状态，不检查状态流转是否合法（由 service/order 中的 ChangeStatus 负责）
+func (s *Store) UpdateOrderStatus(ctx context.Context, id int, status string) (err error) {
+	const query = "UPDATE orders SET status = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.UpdateOrderStatus", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.UpdateOrderStatus", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), status, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL 在状态没有变化时 RowsAffected 也是 0
+		_, err := s.GetOrderByID(ctx, id)
+		return err
+	}
+	return nil
+}
+
+// HasPurchased 用户是否有包含该产品的已送达订单
+func (s *Store) HasPurchased(ctx context.Context, userID, productID int) (_ bool, err error) {
+	const query = "SELECT COUNT(*) FROM order_items oi JOIN orders o ON o.id = oi.order_id " +
+		"WHERE o.user_id = ? AND o.status = ? AND oi.product_id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "order.Store.HasPurchased", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "order.Store.HasPurchased", db.Read)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind(query), userID, types.OrderDelivered, productID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
-// CreateOrderItem 创建订单项
-func (s *Store) CreateOrderItem(oi types.OrderItem) error {
-	_, err := s.db.Exec("INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)",
-		oi.OrderID, oi.ProductID, oi.Quantity, oi.Price)
-	return err
+func orderNotFound(id int) error {
+	return apperr.NotFound("order_not_found", fmt.Sprintf("order ID %d not found", id))
 }
@@ -0,0 +1,69 @@
+package pricing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices", auth.WithAdmin(h.handleList, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices", auth.WithAdmin(h.handleSchedule, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/prices/{priceId:[0-9]+}", auth.WithAdmin(h.handleCancel, h.stores.Users)).Methods("DELETE")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	prices, err := List(r.Context(), h.stores, productID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, prices)
+}
+
+func (h *Handler) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ProductPricePayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	price, err := Schedule(r.Context(), h.stores, productID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, price)
+}
+
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	priceID, _ := strconv.Atoi(mux.Vars(r)["priceId"])
+
+	if err := Cancel(r.Context(), h.stores, productID, priceID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
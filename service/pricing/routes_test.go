@@ -0,0 +1,171 @@
+package pricing_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/cart"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestPricingRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 5},
+		types.Product{Name: "desk", Price: 120, Quantity: 2},
+	)
+	router := mux.NewRouter()
+	pricing.NewHandler(store.Stores()).RegisterRoutes(router)
+	cart.NewHandler(store.Stores(), notify.Log{}).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	buyer := &types.User{Email: "buyer@example.com"}
+	for _, u := range []*types.User{admin, buyer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	at := func(d time.Duration) string {
+		return time.Now().Add(d).UTC().Format(time.RFC3339)
+	}
+	current := func(t *testing.T, id int) types.Product {
+		t.Helper()
+		ps, err := store.GetProductByIDs(ctx, []int{id})
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("get product: %+v %v", ps, err)
+		}
+		return ps[0]
+	}
+
+	t.Run("只有管理员可以管理价格", func(t *testing.T) {
+		if rr := api.Do(nil, http.MethodGet, "/admin/products/1/prices", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+		if rr := api.Do(buyer, http.MethodPost, "/admin/products/1/prices", `{"price":10}`); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := api.Do(buyer, http.MethodDelete, "/admin/products/1/prices/1", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("促销价立即生效并记入订单", func(t *testing.T) {
+		var sale types.ProductPrice
+		body := `{"price":12,"compareAtPrice":15,"effectiveTo":"` + at(time.Hour) + `"}`
+		apitest.Decode(t, api.Do(admin, http.MethodPost, "/admin/products/1/prices", body), http.StatusCreated, &sale)
+		if sale.ID == 0 || sale.ProductID != 1 || sale.Price != 12 || sale.CompareAtPrice == nil || *sale.CompareAtPrice != 15 ||
+			sale.EffectiveTo == nil || sale.CreatedAt.IsZero() {
+			t.Fatalf("unexpected price: %+v", sale)
+		}
+		if p := current(t, 1); p.Price != 12 || p.CompareAtPrice == nil || *p.CompareAtPrice != 15 || p.PriceID == nil || *p.PriceID != sale.ID {
+			t.Errorf("expected the sale price, got %+v", p)
+		}
+
+		var res types.CheckoutResponse
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/cart/checkout", `{"items":[{"productId":1,"quantity":2}]}`), http.StatusOK, &res)
+		if res.TotalPrice != 24 {
+			t.Errorf("expected total 24, got %v", res.TotalPrice)
+		}
+		items, err := store.GetOrderItems(ctx, res.OrderID)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("get order items: %+v %v", items, err)
+		}
+		if items[0].Price != 12 || items[0].PriceID == nil || *items[0].PriceID != sale.ID {
+			t.Errorf("expected the item to record the sale price, got %+v", items[0])
+		}
+	})
+
+	t.Run("计划价格到时生效", func(t *testing.T) {
+		var future types.ProductPrice
+		apitest.Decode(t, api.Do(admin, http.MethodPost, "/admin/products/2/prices", `{"price":99,"effectiveFrom":"`+at(24*time.Hour)+`"}`),
+			http.StatusCreated, &future)
+		if p := current(t, 2); p.Price != 120 {
+			t.Errorf("expected the scheduled price not to apply yet, got %+v", p)
+		}
+		resolved, err := store.ResolvePrices(ctx, []int{2}, time.Now().Add(25*time.Hour))
+		if err != nil || resolved[2].ID != future.ID || resolved[2].Price != 99 {
+			t.Errorf("expected the scheduled price tomorrow, got %+v %v", resolved, err)
+		}
+
+		var prices []types.ProductPrice
+		apitest.Decode(t, api.Do(admin, http.MethodGet, "/admin/products/2/prices", ""), http.StatusOK, &prices)
+		if len(prices) != 2 || prices[0].Price != 120 || prices[1].ID != future.ID {
+			t.Errorf("unexpected prices: %+v", prices)
+		}
+	})
+
+	t.Run("参数校验", func(t *testing.T) {
+		for _, tc := range []struct {
+			name, path, body string
+			status           int
+		}{
+			{"缺少价格", "/admin/products/1/prices", `{"compareAtPrice":20}`, http.StatusBadRequest},
+			{"生效时间已经过去", "/admin/products/1/prices", `{"price":10,"effectiveFrom":"` + at(-time.Hour) + `"}`, http.StatusBadRequest},
+			{"结束时间早于生效时间", "/admin/products/1/prices", `{"price":10,"effectiveFrom":"` + at(2*time.Hour) + `","effectiveTo":"` + at(time.Hour) + `"}`, http.StatusBadRequest},
+			{"划线价不高于售价", "/admin/products/1/prices", `{"price":10,"compareAtPrice":10}`, http.StatusBadRequest},
+			{"产品不存在", "/admin/products/99/prices", `{"price":10}`, http.StatusNotFound},
+		} {
+			if rr := api.Do(admin, http.MethodPost, tc.path, tc.body); rr.Code != tc.status {
+				t.Errorf("%s: 期望状态码 %d, 实际状态码 %d", tc.name, tc.status, rr.Code)
+			}
+		}
+		if rr := api.Do(admin, http.MethodGet, "/admin/products/99/prices", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("取消价格", func(t *testing.T) {
+		var future, sale types.ProductPrice
+		apitest.Decode(t, api.Do(admin, http.MethodPost, "/admin/products/2/prices", `{"price":80,"effectiveFrom":"`+at(time.Hour)+`"}`),
+			http.StatusCreated, &future)
+		apitest.Decode(t, api.Do(admin, http.MethodPost, "/admin/products/2/prices", `{"price":100,"compareAtPrice":120,"effectiveTo":"`+at(time.Hour)+`"}`),
+			http.StatusCreated, &sale)
+
+		// 还没生效的价格直接删除
+		path := "/admin/products/2/prices/" + strconv.Itoa(future.ID)
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+
+		// 正在生效的价格在现在结束，保留为历史
+		path = "/admin/products/2/prices/" + strconv.Itoa(sale.ID)
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+		if p := current(t, 2); p.Price != 120 || p.CompareAtPrice != nil {
+			t.Errorf("expected the regular price after cancelling the sale, got %+v", p)
+		}
+		var prices []types.ProductPrice
+		apitest.Decode(t, api.Do(admin, http.MethodGet, "/admin/products/2/prices", ""), http.StatusOK, &prices)
+		ended := false
+		for _, p := range prices {
+			if p.ID == sale.ID && p.EffectiveTo != nil && !p.EffectiveTo.After(time.Now()) {
+				ended = true
+			}
+		}
+		if !ended {
+			t.Errorf("expected the sale to be kept as ended, got %+v", prices)
+		}
+		if rr := api.Do(admin, http.MethodDelete, path, ""); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+
+		// 价格不属于该产品
+		if rr := api.Do(admin, http.MethodDelete, "/admin/products/1/prices/"+strconv.Itoa(sale.ID), ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
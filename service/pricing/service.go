@@ -0,0 +1,109 @@
+// Package pricing 价格计划：产品的售价由一组带生效时间段的价格记录决定，
+// 改价和促销都追加新的记录，旧的价格保留为历史，订单项记录下单时价格来自哪条记录
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// List 返回产品的所有价格记录，按生效时间排序
+func List(ctx context.Context, stores types.Stores, productID int) ([]types.ProductPrice, error) {
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+	return stores.Products.GetPrices(ctx, productID)
+}
+
+// Schedule 为产品安排一个价格：effectiveFrom 为空时立即生效，不能早于现在；effectiveTo 为空时一直有效
+// 设置了划线价时划线价必须高于售价
+func Schedule(ctx context.Context, stores types.Stores, productID int, payload types.ProductPricePayload) (*types.ProductPrice, error) {
+	now := Now()
+	price := &types.ProductPrice{ProductID: productID, Price: payload.Price, CompareAtPrice: payload.CompareAtPrice, EffectiveFrom: now}
+	if payload.EffectiveFrom != nil {
+		price.EffectiveFrom = payload.EffectiveFrom.UTC().Truncate(time.Second)
+	}
+	if payload.EffectiveTo != nil {
+		to := payload.EffectiveTo.UTC().Truncate(time.Second)
+		price.EffectiveTo = &to
+	}
+
+	var fields []apperr.FieldError
+	if price.EffectiveFrom.Before(now) {
+		fields = append(fields, apperr.FieldError{Field: "effectiveFrom", Rule: "future", Message: "effectiveFrom must not be in the past"})
+	}
+	if price.EffectiveTo != nil && !price.EffectiveTo.After(price.EffectiveFrom) {
+		fields = append(fields, apperr.FieldError{Field: "effectiveTo", Rule: "gtfield", Param: "effectiveFrom", Message: "effectiveTo must be after effectiveFrom"})
+	}
+	if price.CompareAtPrice != nil && *price.CompareAtPrice <= price.Price {
+		fields = append(fields, apperr.FieldError{Field: "compareAtPrice", Rule: "gtfield", Param: "price", Message: "compareAtPrice must be higher than price"})
+	}
+	if len(fields) > 0 {
+		return nil, apperr.Validation("validation_failed", "request validation failed").WithFields(fields)
+	}
+
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := tx.Products.CreatePrice(ctx, price); err != nil {
+			return err
+		}
+		// 重新读出，带上数据库生成的创建时间
+		prices, err := tx.Products.GetPrices(ctx, productID)
+		if err != nil {
+			return err
+		}
+		for _, p := range prices {
+			if p.ID == price.ID {
+				*price = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// Cancel 取消产品的一条价格记录：还没有生效的直接删除，正在生效的在现在结束（保留为历史），
+// 已经结束的返回 price_ended
+func Cancel(ctx context.Context, stores types.Stores, productID, priceID int) error {
+	return stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := productExists(ctx, tx, productID); err != nil {
+			return err
+		}
+		prices, err := tx.Products.GetPrices(ctx, productID)
+		if err != nil {
+			return err
+		}
+		for _, p := range prices {
+			if p.ID != priceID {
+				continue
+			}
+			now := Now()
+			switch {
+			case p.EffectiveFrom.After(now):
+				return tx.Products.DeletePrice(ctx, priceID)
+			case activeAt(p, now):
+				return tx.Products.EndPrice(ctx, priceID, now)
+			default:
+				return apperr.Conflict("price_ended", fmt.Sprintf("price ID %d has already ended", priceID))
+			}
+		}
+		return NotFound(priceID)
+	})
+}
+
+func productExists(ctx context.Context, stores types.Stores, productID int) error {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// SQL 的价格记录由 ProductStore 读写，这里是各个读取产品的 store 共用的查询
+const (
+	priceColumns = "id, product_id, price, compare_at_price, effective_from, effective_to, createdat"
+	// InsertQuery 写入一条价格记录
+	InsertQuery = "INSERT INTO product_prices (product_id, price, compare_at_price, effective_from, effective_to) VALUES (?, ?, ?, ?, ?)"
+	// ListQuery 一个产品的所有价格记录，按生效时间排序
+	ListQuery = "SELECT " + priceColumns + " FROM product_prices WHERE product_id = ? ORDER BY effective_from, id"
+)
+
+// Query 在 q 上执行返回价格记录的查询
+func Query(ctx context.Context, q db.Querier, query string, args ...any) ([]types.ProductPrice, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []types.ProductPrice{}
+	for rows.Next() {
+		var (
+			p           types.ProductPrice
+			compareAt   sql.NullFloat64
+			effectiveTo sql.NullTime
+		)
+		if err := rows.Scan(&p.ID, &p.ProductID, &p.Price, &compareAt, &p.EffectiveFrom, &effectiveTo, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		if compareAt.Valid {
+			p.CompareAtPrice = &compareAt.Float64
+		}
+		if effectiveTo.Valid {
+			p.EffectiveTo = &effectiveTo.Time
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// ResolveQuery 查询多个产品的所有价格记录
+func ResolveQuery(n int) string {
+	return "SELECT " + priceColumns + " FROM product_prices WHERE product_id IN (" + db.Placeholders(n) + ")"
+}
+
+// Resolve 在 q 上查询产品的价格记录并返回每个产品在 at 时刻生效的那条
+// 时间的比较在 Go 中完成，避免各个数据库存储时间的格式不同
+func Resolve(ctx context.Context, q db.Querier, dialect db.Dialect, productIDs []int, at time.Time) (map[int]types.ProductPrice, error) {
+	resolved := map[int]types.ProductPrice{}
+	if len(productIDs) == 0 {
+		return resolved, nil
+	}
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+	prices, err := Query(ctx, q, dialect.Rebind(ResolveQuery(len(productIDs))), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byProduct := map[int][]types.ProductPrice{}
+	for _, p := range prices {
+		byProduct[p.ProductID] = append(byProduct[p.ProductID], p)
+	}
+	for productID, ps := range byProduct {
+		if p, ok := Effective(ps, at); ok {
+			resolved[productID] = p
+		}
+	}
+	return resolved, nil
+}
+
+// Apply 把每个产品的售价替换为当前生效的价格；没有生效价格的产品保留 products 表中的价格
+func Apply(ctx context.Context, q db.Querier, dialect db.Dialect, products []types.Product) error {
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	resolved, err := Resolve(ctx, q, dialect, ids, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		if price, ok := resolved[products[i].ID]; ok {
+			SetPrice(&products[i], price)
+		}
+	}
+	return nil
+}
+
+// Insert 写入价格记录并写回分配的ID，调用方负责检查产品是否存在
+func Insert(ctx context.Context, q db.Querier, dialect db.Dialect, price *types.ProductPrice) error {
+	var effectiveTo any
+	if price.EffectiveTo != nil {
+		effectiveTo = price.EffectiveTo.UTC()
+	}
+	id, err := dialect.InsertID(ctx, q, InsertQuery, price.ProductID, price.Price, price.CompareAtPrice, price.EffectiveFrom.UTC(), effectiveTo)
+	if err != nil {
+		return err
+	}
+	price.ID = int(id)
+	return nil
+}
+
+// Effective 返回 prices 中在 at 时刻生效的价格：有结束时间的价格（促销）优先于长期价格，
+// 同类中开始时间最晚的优先，开始时间相同时后添加的优先
+func Effective(prices []types.ProductPrice, at time.Time) (types.ProductPrice, bool) {
+	var (
+		best  types.ProductPrice
+		found bool
+	)
+	for _, p := range prices {
+		if !activeAt(p, at) {
+			continue
+		}
+		if !found || outranks(p, best) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+func activeAt(p types.ProductPrice, at time.Time) bool {
+	return !p.EffectiveFrom.After(at) && (p.EffectiveTo == nil || p.EffectiveTo.After(at))
+}
+
+func outranks(a, b types.ProductPrice) bool {
+	if (a.EffectiveTo != nil) != (b.EffectiveTo != nil) {
+		return a.EffectiveTo != nil
+	}
+	if !a.EffectiveFrom.Equal(b.EffectiveFrom) {
+		return a.EffectiveFrom.After(b.EffectiveFrom)
+	}
+	return a.ID > b.ID
+}
+
+// SetPrice 把产品的售价设为价格记录中的价格
+func SetPrice(p *types.Product, price types.ProductPrice) {
+	id := price.ID
+	p.Price = price.Price
+	p.CompareAtPrice = price.CompareAtPrice
+	p.PriceID = &id
+	p.SaleEndsAt = price.EffectiveTo
+}
+
+// VariantPrice 变体的售价和它来自哪条价格记录，p 需要是已经按价格计划解析过的产品
+// 与 Effective 的规则一致：产品的促销价优先于变体的单独定价，变体的单独定价优先于产品的常规价格
+func VariantPrice(p types.Product, v types.ProductVariant) (float64, *int) {
+	if v.Price != nil && p.SaleEndsAt == nil {
+		return *v.Price, nil
+	}
+	return p.Price, p.PriceID
+}
+
+// Sort 按生效时间排序，与 ListQuery 的顺序一致
+func Sort(prices []types.ProductPrice) {
+	sort.Slice(prices, func(i, j int) bool {
+		if !prices[i].EffectiveFrom.Equal(prices[j].EffectiveFrom) {
+			return prices[i].EffectiveFrom.Before(prices[j].EffectiveFrom)
+		}
+		return prices[i].ID < prices[j].ID
+	})
+}
+
+// SamePrice 价格在数据库中保留两位小数，差距小于半分的视为相同
+func SamePrice(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// Now 新价格记录的时间：UTC，精确到秒（MySQL 的 DATETIME 会把小数部分四舍五入，可能让价格晚一秒生效）
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// NotFound 价格记录不存在（或不属于该产品）时返回的错误
+func NotFound(id int) *apperr.Error {
+	return apperr.NotFound("price_not_found", fmt.Sprintf("price ID %d not found", id))
+}
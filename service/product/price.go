@@ -0,0 +1,85 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+func (s *Store) GetPrices(ctx context.Context, productID int) (_ []types.ProductPrice, err error) {
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.GetPrices", pricing.ListQuery)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.GetPrices", db.Read)
+	defer cancel()
+
+	return pricing.Query(ctx, s.db, s.dialect.Rebind(pricing.ListQuery), productID)
+}
+
+func (s *Store) ResolvePrices(ctx context.Context, productIDs []int, at time.Time) (_ map[int]types.ProductPrice, err error) {
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.ResolvePrices", pricing.ResolveQuery(len(productIDs)))
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.ResolvePrices", db.Read)
+	defer cancel()
+
+	return pricing.Resolve(ctx, s.db, s.dialect, productIDs, at)
+}
+
+func (s *Store) CreatePrice(ctx context.Context, price *types.ProductPrice) (err error) {
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.CreatePrice", pricing.InsertQuery)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.CreatePrice", db.Write)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), price.ProductID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return productNotFound(price.ProductID)
+	}
+	return pricing.Insert(ctx, s.db, s.dialect, price)
+}
+
+func (s *Store) EndPrice(ctx context.Context, id int, at time.Time) (err error) {
+	const query = "UPDATE product_prices SET effective_to = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.EndPrice", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.EndPrice", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return priceAffected(res, id)
+}
+
+func (s *Store) DeletePrice(ctx context.Context, id int) (err error) {
+	const query = "DELETE FROM product_prices WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.DeletePrice", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.DeletePrice", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id)
+	if err != nil {
+		return err
+	}
+	return priceAffected(res, id)
+}
+
+func priceAffected(res sql.Result, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return pricing.NotFound(id)
+	}
+	return nil
+}
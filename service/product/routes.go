@@ -2,19 +2,24 @@ package product
 
 import (
 	"net/http"
+	"sort"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/category"
 	"github.com/Albert-tru/ecom/types"
 	"github.com/Albert-tru/ecom/utils"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
-	store types.ProductStore
+	store      types.ProductStore
+	categories types.CategoryStore
 }
 
-func NewHandler(store types.ProductStore) *Handler {
+func NewHandler(store types.ProductStore, categories types.CategoryStore) *Handler {
 	return &Handler{
-		store: store,
+		store:      store,
+		categories: categories,
 	}
 }
 
@@ -22,12 +27,48 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/products", h.handleGetProducts).Methods("GET")
 }
 
+// ?category=<slug> 只返回该分类及其子分类下的产品
+// ?sort=rating 按评分从高到低排列，评分相同时评价多的在前
 func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
-	ps, err := h.store.GetProducts()
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != sortRating {
+		utils.WriteError(w, r, apperr.Validation("validation_failed", "request validation failed").
+			WithFields([]apperr.FieldError{{Field: "sort", Rule: "oneof", Param: sortRating, Message: "sort must be rating"}}))
+		return
+	}
+
+	var (
+		ps  []types.Product
+		err error
+	)
+	if slug := r.URL.Query().Get("category"); slug != "" {
+		ps, err = category.ProductsInCategory(r.Context(), h.categories, slug)
+	} else {
+		ps, err = h.store.GetProducts(r.Context())
+	}
 	if err != nil {
-		http.Error(w, "Failed to get products", http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
+	if sortBy == sortRating {
+		sortByRating(ps)
+	}
+
 	utils.WriteJson(w, http.StatusOK, ps)
 }
+
+// 产品列表支持的排序方式
+const sortRating = "rating"
+
+func sortByRating(ps []types.Product) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].RatingAverage != ps[j].RatingAverage {
+			return ps[i].RatingAverage > ps[j].RatingAverage
+		}
+		if ps[i].RatingCount != ps[j].RatingCount {
+			return ps[i].RatingCount > ps[j].RatingCount
+		}
+		return ps[i].ID < ps[j].ID
+	})
+}
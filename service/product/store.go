@@ -1,40 +1,48 @@
 package product
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/tracing"
 	"github.com/Albert-tru/ecom/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
 }
 
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
-func (s *Store) GetProducts() ([]types.Product, error) {
-	rows, err := s.db.Query("SELECT id, name, description, image, price, quantity, createdat FROM products")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (s *Store) GetProducts(ctx context.Context) (_ []types.Product, err error) {
+	const query = "SELECT " + productColumns + " FROM products"
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.GetProducts", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.GetProducts", db.Read)
+	defer cancel()
 
-	products := []types.Product{}
-	for rows.Next() {
-		var p types.Product
-		// 注意：Scan 的顺序必须和 SELECT 的顺序一致
-		// SELECT: id, name, description, image, price, quantity, createdat
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description,
-			&p.ImageURL, &p.Price, &p.Quantity, &p.CreatedAt); err != nil {
-			return nil, err
-		}
-		products = append(products, p)
-	}
+	return s.query(ctx, query)
+}
 
-	return products, nil
+func (s *Store) GetProductsAfter(ctx context.Context, afterID, limit int) (_ []types.Product, err error) {
+	const query = "SELECT " + productColumns + " FROM products WHERE id > ? ORDER BY id LIMIT ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.GetProductsAfter", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.GetProductsAfter", db.Read)
+	defer cancel()
 
+	return s.query(ctx, query, afterID, limit)
 }
 
 func scanRowIntoProduct(row *sql.Row) (*types.Product, error) {
@@ -47,6 +55,8 @@ func scanRowIntoProduct(row *sql.Row) (*types.Product, error) {
 		&product.ImageURL,
 		&product.Price,
 		&product.Quantity,
+		&product.RatingAverage,
+		&product.RatingCount,
 		&product.CreatedAt,
 	)
 	if err != nil {
@@ -56,38 +66,158 @@ func scanRowIntoProduct(row *sql.Row) (*types.Product, error) {
 }
 
 // GetProductByIDs 根据产品ID列表获取产品
-func (s *Store) GetProductByIDs(ids []int) ([]types.Product, error) {
-if len(ids) == 0 {
-return []types.Product{}, nil
-}
+func (s *Store) GetProductByIDs(ctx context.Context, ids []int) (_ []types.Product, err error) {
+	if len(ids) == 0 {
+		return []types.Product{}, nil
+	}
+
+	// 构建 IN 查询的占位符字符串
+	query := "SELECT " + productColumns + " FROM products WHERE id IN (" + db.Placeholders(len(ids)) + ")"
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
 
-// 构建 IN 查询的占位符字符串
-query := "SELECT id, name, description, image, price, quantity, createdat FROM products WHERE id IN ("
-args := make([]interface{}, len(ids))
-for i, id := range ids {
-if i > 0 {
-query += ","
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.GetProductByIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.GetProductByIDs", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, args...)
 }
-query += "?"
-args[i] = id
+
+// productColumns 的顺序必须和 query 中 Scan 的顺序一致
+const productColumns = "id, name, description, image, price, quantity, rating_average, rating_count, createdat"
+
+// 查询产品并按价格计划解析当前售价
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]types.Product, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []types.Product{}
+	for rows.Next() {
+		var p types.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description,
+			&p.ImageURL, &p.Price, &p.Quantity, &p.RatingAverage, &p.RatingCount, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := pricing.Apply(ctx, s.db, s.dialect, products); err != nil {
+		return nil, err
+	}
+	return products, nil
 }
-query += ")"
 
-rows, err := s.db.Query(query, args...)
-if err != nil {
-return nil, err
+// CreateProduct 创建产品和它的默认变体，并把分配的ID写回 p.ID；p.Quantity 是默认变体的初始库存，
+// p.Price 记为从现在开始的长期价格
+// p.ID 不为 0 时使用指定的ID（用于导入固定数据），ID 已存在时返回 product_exists
+// 产品、默认变体和价格分多条语句写入，需要原子性时应在事务中调用
+func (s *Store) CreateProduct(ctx context.Context, p *types.Product) (err error) {
+	query := "INSERT INTO products (name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?)"
+	args := []any{p.Name, p.Description, p.ImageURL, p.Price, p.Quantity}
+	if p.ID != 0 {
+		query = "INSERT INTO products (id, name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?, ?)"
+		args = append([]any{p.ID}, args...)
+	}
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.CreateProduct", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.CreateProduct", db.Write)
+	defer cancel()
+
+	id, err := s.dialect.InsertID(ctx, s.db, query, args...)
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("product_exists", fmt.Sprintf("product ID %d already exists", p.ID)).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.ID != 0 && s.dialect == db.Postgres {
+		// 显式写入ID不会推进序列，否则之后自动分配的ID会冲突
+		_, err = s.db.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence('products', 'id'), (SELECT MAX(id) FROM products))")
+		if err != nil {
+			return err
+		}
+	}
+
+	variantID, err := s.dialect.InsertID(ctx, s.db, "INSERT INTO product_variants (product_id, sku, quantity) VALUES (?, ?, ?)",
+		id, variant.DefaultSKU(int(id)), p.Quantity)
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("sku_taken", fmt.Sprintf("SKU %q already exists", variant.DefaultSKU(int(id)))).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	if p.Quantity != 0 {
+		m := inventory.Movement(int(id), int(variantID), p.Quantity, p.Quantity, inventory.InitialStock)
+		if err := inventory.Record(ctx, s.db, s.dialect, m); err != nil {
+			return err
+		}
+	}
+	if err := pricing.Insert(ctx, s.db, s.dialect, &types.ProductPrice{ProductID: int(id), Price: p.Price, EffectiveFrom: pricing.Now()}); err != nil {
+		return err
+	}
+
+	p.ID = int(id)
+	return nil
 }
-defer rows.Close()
-
-products := []types.Product{}
-for rows.Next() {
-var p types.Product
-if err := rows.Scan(&p.ID, &p.Name, &p.Description,
-&p.ImageURL, &p.Price, &p.Quantity, &p.CreatedAt); err != nil {
-return nil, err
+
+// UpdateProduct 修改产品的名称、描述、图片和价格，不修改库存
+// 价格与当前售价不同时记录一条从现在开始的长期价格，products 表中的价格同时更新；
+// 读出的产品原样写回时售价不变，不会产生新的价格记录。多条语句写入，需要原子性时应在事务中调用
+func (s *Store) UpdateProduct(ctx context.Context, p *types.Product) (err error) {
+	const query = "UPDATE products SET name = ?, description = ?, image = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "product.Store.UpdateProduct", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "product.Store.UpdateProduct", db.Write)
+	defer cancel()
+
+	var current float64
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT price FROM products WHERE id = ?"), p.ID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return productNotFound(p.ID)
+	}
+	if err != nil {
+		return err
+	}
+	resolved, err := pricing.Resolve(ctx, s.db, s.dialect, []int{p.ID}, time.Now())
+	if err != nil {
+		return err
+	}
+	if price, ok := resolved[p.ID]; ok {
+		current = price.Price
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), p.Name, p.Description, p.ImageURL, p.ID); err != nil {
+		return err
+	}
+	if pricing.SamePrice(current, p.Price) {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("UPDATE products SET price = ? WHERE id = ?"), p.Price, p.ID); err != nil {
+		return err
+	}
+	return pricing.Insert(ctx, s.db, s.dialect, &types.ProductPrice{ProductID: p.ID, Price: p.Price, EffectiveFrom: pricing.Now()})
 }
-products = append(products, p)
+
+// AdjustStock 调整只有一个变体的产品的库存，返回调整后的库存
+// 产品有多个变体时返回 variant_required，应改用 VariantStore.AdjustVariantStock
+func (s *Store) AdjustStock(ctx context.Context, id, delta int, change types.StockChange) (int, error) {
+	variantID, err := variant.SoleVariantID(ctx, s.db, s.dialect, id)
+	if err != nil {
+		return 0, err
+	}
+	return variant.NewStore(s.db, s.dialect).AdjustVariantStock(ctx, variantID, delta, change)
 }
 
-return products, nil
+func productNotFound(id int) error {
+	return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", id))
 }
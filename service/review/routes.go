@@ -0,0 +1,127 @@
+package review
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/reviews", h.handleList).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/reviews", auth.WithJWTAuth(h.handleCreate, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/reviews/{reviewId:[0-9]+}", auth.WithJWTAuth(h.handleUpdate, h.stores.Users)).Methods("PUT")
+
+	// 管理接口
+	router.HandleFunc("/admin/reviews", auth.WithAdmin(h.handleModerationQueue, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/admin/reviews/{id:[0-9]+}/status", auth.WithAdmin(h.handleModerate, h.stores.Users)).Methods("PUT")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	rs, err := List(r.Context(), h.stores, productID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, rs)
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	payload, err := parsePayload(w, r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	review, err := Create(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), productID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, review)
+}
+
+func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	reviewID, _ := strconv.Atoi(mux.Vars(r)["reviewId"])
+
+	payload, err := parsePayload(w, r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	review, err := Update(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), productID, reviewID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, review)
+}
+
+// ?status= 默认返回待审核的评价
+func (h *Handler) handleModerationQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = types.ReviewPending
+	case types.ReviewPending, types.ReviewApproved, types.ReviewRejected:
+	default:
+		utils.WriteError(w, r, apperr.Validation("validation_failed", "request validation failed").
+			WithFields([]apperr.FieldError{{Field: "status", Rule: "oneof", Param: "pending approved rejected",
+				Message: fmt.Sprintf("status must be one of %s, %s, %s", types.ReviewPending, types.ReviewApproved, types.ReviewRejected)}}))
+		return
+	}
+
+	rs, err := h.stores.Reviews.GetReviewsByStatus(r.Context(), status)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, rs)
+}
+
+func (h *Handler) handleModerate(w http.ResponseWriter, r *http.Request) {
+	reviewID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ReviewStatusPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	review, err := Moderate(r.Context(), h.stores, reviewID, payload.Status)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, review)
+}
+
+func parsePayload(w http.ResponseWriter, r *http.Request) (types.ReviewPayload, error) {
+	var payload types.ReviewPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		return payload, err
+	}
+	return payload, utils.ValidatePayload(r, payload)
+}
@@ -0,0 +1,140 @@
+package review_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/review"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestReviewRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(types.Product{Name: "lamp", Price: 15, Quantity: 5})
+	router := mux.NewRouter()
+	review.NewHandler(store.Stores()).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	buyer := &types.User{Email: "buyer@example.com"}
+	other := &types.User{Email: "other@example.com"}
+	for _, u := range []*types.User{admin, buyer, other} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// buyer 有一个已送达的订单，other 的订单已发货但还没送达
+	for _, o := range []types.Order{
+		{UserID: buyer.ID, Total: 15, Status: types.OrderDelivered, Address: "addr"},
+		{UserID: other.ID, Total: 15, Status: types.OrderCompleted, Address: "addr"},
+	} {
+		id, err := store.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateOrderItem(ctx, types.OrderItem{OrderID: id, ProductID: 1, Quantity: 1, Price: 15}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	product := func(t *testing.T) types.Product {
+		t.Helper()
+		ps, err := store.GetProductByIDs(ctx, []int{1})
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("failed to get product: %v", err)
+		}
+		return ps[0]
+	}
+
+	const payload = `{"rating":4,"title":"good","body":"bright enough"}`
+	var created types.Review
+
+	t.Run("没有已送达的订单不能评价", func(t *testing.T) {
+		if rr := api.Do(other, http.MethodPost, "/products/1/reviews", payload); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d: %s", http.StatusForbidden, rr.Code, rr.Body)
+		}
+		if rr := api.Do(nil, http.MethodPost, "/products/1/reviews", payload); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+		if rr := api.Do(buyer, http.MethodPost, "/products/99/reviews", payload); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("发表评价", func(t *testing.T) {
+		if rr := api.Do(buyer, http.MethodPost, "/products/1/reviews", `{"rating":6,"title":"x","body":"y"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+
+		apitest.Decode(t, api.Do(buyer, http.MethodPost, "/products/1/reviews", payload), http.StatusCreated, &created)
+		if created.ID == 0 || created.UserID != buyer.ID || created.Rating != 4 || created.Status != types.ReviewPending {
+			t.Errorf("unexpected review: %+v", created)
+		}
+
+		if rr := api.Do(buyer, http.MethodPost, "/products/1/reviews", payload); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		// 待审核的评价不公开
+		var rs []types.Review
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/products/1/reviews", ""), http.StatusOK, &rs)
+		if len(rs) != 0 {
+			t.Errorf("expected no public reviews, got %d", len(rs))
+		}
+	})
+
+	t.Run("审核通过后公开并更新评分", func(t *testing.T) {
+		if rr := api.Do(buyer, http.MethodPut, "/admin/reviews/1/status", `{"status":"approved"}`); rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+
+		var queue []types.Review
+		apitest.Decode(t, api.Do(admin, http.MethodGet, "/admin/reviews", ""), http.StatusOK, &queue)
+		if len(queue) != 1 || queue[0].ID != created.ID {
+			t.Fatalf("unexpected moderation queue: %+v", queue)
+		}
+		if rr := api.Do(admin, http.MethodGet, "/admin/reviews?status=spam", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := api.Do(admin, http.MethodPut, "/admin/reviews/1/status", `{"status":"pending"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var approved types.Review
+		apitest.Decode(t, api.Do(admin, http.MethodPut, "/admin/reviews/1/status", `{"status":"approved"}`), http.StatusOK, &approved)
+		if approved.Status != types.ReviewApproved {
+			t.Errorf("unexpected review: %+v", approved)
+		}
+		if p := product(t); p.RatingAverage != 4 || p.RatingCount != 1 {
+			t.Errorf("expected rating 4 from 1 review, got %v from %d", p.RatingAverage, p.RatingCount)
+		}
+
+		var rs []types.Review
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/products/1/reviews", ""), http.StatusOK, &rs)
+		if len(rs) != 1 || rs[0].ID != created.ID {
+			t.Errorf("unexpected public reviews: %+v", rs)
+		}
+		if rr := api.Do(admin, http.MethodPut, "/admin/reviews/99/status", `{"status":"approved"}`); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("修改评价后重新审核", func(t *testing.T) {
+		if rr := api.Do(other, http.MethodPut, "/products/1/reviews/1", payload); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+
+		var updated types.Review
+		apitest.Decode(t, api.Do(buyer, http.MethodPut, "/products/1/reviews/1", `{"rating":2,"title":"dimmer","body":"bulb died"}`), http.StatusOK, &updated)
+		if updated.Rating != 2 || updated.Title != "dimmer" || updated.Status != types.ReviewPending {
+			t.Errorf("unexpected review: %+v", updated)
+		}
+		// 重新审核之前不计入评分
+		if p := product(t); p.RatingAverage != 0 || p.RatingCount != 0 {
+			t.Errorf("expected no rating, got %v from %d", p.RatingAverage, p.RatingCount)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+// Package review 产品评价：买过产品的用户发表评价，管理员审核后公开并计入产品评分
+package review
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// List 返回产品已通过审核的评价，最新的在前
+func List(ctx context.Context, stores types.Stores, productID int) ([]types.Review, error) {
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+	return stores.Reviews.GetReviews(ctx, productID, types.ReviewApproved)
+}
+
+// Create 发表评价，只有订单已送达、包含该产品的用户可以评价，每个用户对每个产品只能评价一次
+// 新评价处于 pending 状态，审核通过后才公开
+func Create(ctx context.Context, stores types.Stores, userID, productID int, payload types.ReviewPayload) (*types.Review, error) {
+	r := &types.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    payload.Rating,
+		Title:     payload.Title,
+		Body:      payload.Body,
+		Status:    types.ReviewPending,
+	}
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := productExists(ctx, tx, productID); err != nil {
+			return err
+		}
+		purchased, err := tx.Orders.HasPurchased(ctx, userID, productID)
+		if err != nil {
+			return err
+		}
+		if !purchased {
+			return apperr.Forbidden("purchase_required", "only customers with a delivered order containing this product can review it")
+		}
+		if err := tx.Reviews.CreateReview(ctx, r); err != nil {
+			return err
+		}
+		created, err := tx.Reviews.GetReviewByID(ctx, r.ID)
+		if err != nil {
+			return err
+		}
+		r = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update 修改自己的评价，修改后需要重新审核，在此之前不再计入产品评分
+// 评价不属于该用户或该产品时视为不存在
+func Update(ctx context.Context, stores types.Stores, userID, productID, reviewID int, payload types.ReviewPayload) (*types.Review, error) {
+	var r *types.Review
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		var err error
+		r, err = tx.Reviews.GetReviewByID(ctx, reviewID)
+		if err != nil {
+			return err
+		}
+		if r.ProductID != productID || r.UserID != userID {
+			return reviewNotFound(reviewID)
+		}
+		r.Rating, r.Title, r.Body, r.Status = payload.Rating, payload.Title, payload.Body, types.ReviewPending
+		if err := tx.Reviews.UpdateReview(ctx, r); err != nil {
+			return err
+		}
+		r, err = tx.Reviews.GetReviewByID(ctx, reviewID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Moderate 通过或拒绝评价，产品的评分随之更新
+func Moderate(ctx context.Context, stores types.Stores, reviewID int, status string) (*types.Review, error) {
+	if status != types.ReviewApproved && status != types.ReviewRejected {
+		return nil, apperr.Validation("invalid_review_status", fmt.Sprintf("status must be %s or %s", types.ReviewApproved, types.ReviewRejected))
+	}
+	var r *types.Review
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		var err error
+		r, err = tx.Reviews.GetReviewByID(ctx, reviewID)
+		if err != nil {
+			return err
+		}
+		r.Status = status
+		if err := tx.Reviews.UpdateReview(ctx, r); err != nil {
+			return err
+		}
+		r, err = tx.Reviews.GetReviewByID(ctx, reviewID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func productExists(ctx context.Context, stores types.Stores, productID int) error {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+	return nil
+}
+
+func reviewNotFound(id int) error {
+	return apperr.NotFound("review_not_found", fmt.Sprintf("review ID %d not found", id))
+}
@@ -0,0 +1,160 @@
+package review
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询评价时的列，顺序与 query 中的 Scan 一致
+const reviewColumns = "id, product_id, user_id, rating, title, body, status, createdat, updatedat"
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) GetReviews(ctx context.Context, productID int, status string) (_ []types.Review, err error) {
+	query := "SELECT " + reviewColumns + " FROM product_reviews WHERE product_id = ?"
+	args := []any{productID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY createdat DESC, id DESC"
+	ctx, span := tracing.StartStoreSpan(ctx, "review.Store.GetReviews", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "review.Store.GetReviews", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, args...)
+}
+
+func (s *Store) GetReviewsByStatus(ctx context.Context, status string) (_ []types.Review, err error) {
+	const query = "SELECT " + reviewColumns + " FROM product_reviews WHERE status = ? ORDER BY createdat, id"
+	ctx, span := tracing.StartStoreSpan(ctx, "review.Store.GetReviewsByStatus", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "review.Store.GetReviewsByStatus", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, status)
+}
+
+func (s *Store) GetReviewByID(ctx context.Context, id int) (_ *types.Review, err error) {
+	const query = "SELECT " + reviewColumns + " FROM product_reviews WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "review.Store.GetReviewByID", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "review.Store.GetReviewByID", db.Read)
+	defer cancel()
+
+	rs, err := s.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 {
+		return nil, reviewNotFound(id)
+	}
+	return &rs[0], nil
+}
+
+// CreateReview 创建评价，r.Status 为空时按 pending 创建；状态为 approved 时同步产品的评分
+func (s *Store) CreateReview(ctx context.Context, r *types.Review) (err error) {
+	const query = "INSERT INTO product_reviews (product_id, user_id, rating, title, body, status) VALUES (?, ?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "review.Store.CreateReview", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "review.Store.CreateReview", db.Write)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), r.ProductID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", r.ProductID))
+	}
+
+	status := r.Status
+	if status == "" {
+		status = types.ReviewPending
+	}
+	id, err := s.dialect.InsertID(ctx, s.db, query, r.ProductID, r.UserID, r.Rating, r.Title, r.Body, status)
+	if db.IsUniqueViolation(err) {
+		return reviewExists(r.ProductID).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	r.ID = int(id)
+	r.Status = status
+	if status == types.ReviewApproved {
+		return s.refreshRating(ctx, r.ProductID)
+	}
+	return nil
+}
+
+// UpdateReview 修改评价并重新计算产品的评分，调用方应该把它放在事务中
+func (s *Store) UpdateReview(ctx context.Context, r *types.Review) (err error) {
+	const query = "UPDATE product_reviews SET rating = ?, title = ?, body = ?, status = ?, updatedat = CURRENT_TIMESTAMP WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "review.Store.UpdateReview", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "review.Store.UpdateReview", db.Write)
+	defer cancel()
+
+	rs, err := s.query(ctx, "SELECT "+reviewColumns+" FROM product_reviews WHERE id = ?", r.ID)
+	if err != nil {
+		return err
+	}
+	if len(rs) == 0 {
+		return reviewNotFound(r.ID)
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), r.Rating, r.Title, r.Body, r.Status, r.ID); err != nil {
+		return err
+	}
+	// 评价之前或之后是 approved 时评分才会变化
+	if rs[0].Status == types.ReviewApproved || r.Status == types.ReviewApproved {
+		return s.refreshRating(ctx, rs[0].ProductID)
+	}
+	return nil
+}
+
+// refreshRating 根据已通过审核的评价重新计算产品的平均分和评价数
+func (s *Store) refreshRating(ctx context.Context, productID int) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		"UPDATE products SET "+
+			"rating_average = (SELECT COALESCE(ROUND(AVG(rating), 2), 0) FROM product_reviews WHERE product_id = ? AND status = ?), "+
+			"rating_count = (SELECT COUNT(*) FROM product_reviews WHERE product_id = ? AND status = ?) "+
+			"WHERE id = ?"),
+		productID, types.ReviewApproved, productID, types.ReviewApproved, productID)
+	return err
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]types.Review, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rs := []types.Review{}
+	for rows.Next() {
+		var r types.Review
+		if err := rows.Scan(&r.ID, &r.ProductID, &r.UserID, &r.Rating, &r.Title, &r.Body, &r.Status,
+			&r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, rows.Err()
+}
+
+func reviewExists(productID int) *apperr.Error {
+	return apperr.Conflict("review_exists", fmt.Sprintf("you have already reviewed product ID %d, edit the existing review instead", productID))
+}
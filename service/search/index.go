@@ -0,0 +1,152 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Albert-tru/ecom/types"
+)
+
+// DefaultMaxAge 倒排索引的默认有效期，超过后下一次搜索会重建索引
+const DefaultMaxAge = 30 * time.Second
+
+// 名称中命中的词比描述中的更重要
+const (
+	nameWeight        = 3.0
+	descriptionWeight = 1.0
+)
+
+// 拼写纠错命中的词按编辑距离降低分数
+var fuzzyPenalty = []float64{1, 0.5, 0.25}
+
+// Index 纯 Go 实现的倒排索引，用于 SQLite、PostgreSQL 和内存存储
+// 索引从 ProductStore 中全量构建，超过 MaxAge 后在下一次搜索时重建，
+// 因此新增或修改的产品最多延迟 MaxAge 才能被搜到；价格和库存不在索引中，总是实时的
+type Index struct {
+	products types.ProductStore
+	MaxAge   time.Duration // 为 0 时每次搜索都重建
+
+	mu      sync.Mutex
+	built   time.Time
+	now     func() time.Time
+	terms   map[string][]posting
+	lengths map[int]float64 // 产品ID -> 加权后的文档长度
+	avgLen  float64
+}
+
+// posting 一个词在某个产品中出现的次数（已按字段加权）
+type posting struct {
+	productID int
+	tf        float64
+}
+
+func NewIndex(products types.ProductStore) *Index {
+	return &Index{products: products, MaxAge: DefaultMaxAge, now: time.Now}
+}
+
+// SearchProducts 按 BM25 计算相关度，多个词之间是“或”的关系，命中的词越多分数越高
+func (idx *Index) SearchProducts(ctx context.Context, query string, fuzzy bool) ([]types.SearchMatch, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	scores := map[int]float64{}
+	for _, term := range queryTerms(query) {
+		for matched, penalty := range idx.expand(term, fuzzy) {
+			idx.score(scores, matched, penalty)
+		}
+	}
+
+	matches := make([]types.SearchMatch, 0, len(scores))
+	for id, score := range scores {
+		matches = append(matches, types.SearchMatch{ProductID: id, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ProductID < matches[j].ProductID
+	})
+	return matches, nil
+}
+
+// expand 返回查询词在索引中对应的词及其分数系数；fuzzy 时加入编辑距离以内的词
+func (idx *Index) expand(term string, fuzzy bool) map[string]float64 {
+	matched := map[string]float64{}
+	if _, ok := idx.terms[term]; ok {
+		matched[term] = fuzzyPenalty[0]
+	}
+	limit := MaxEdits(term)
+	if !fuzzy || limit == 0 {
+		return matched
+	}
+	for word := range idx.terms {
+		if word == term {
+			continue
+		}
+		if d := editDistance(word, term, limit); d <= limit {
+			matched[word] = fuzzyPenalty[d]
+		}
+	}
+	return matched
+}
+
+func (idx *Index) score(scores map[int]float64, term string, penalty float64) {
+	const k1, b = 1.2, 0.75
+
+	postings := idx.terms[term]
+	n := float64(len(idx.lengths))
+	df := float64(len(postings))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	for _, p := range postings {
+		norm := 1 - b + b*idx.lengths[p.productID]/idx.avgLen
+		scores[p.productID] += penalty * idf * p.tf * (k1 + 1) / (p.tf + k1*norm)
+	}
+}
+
+// refresh 在索引过期时重建；调用方需要持有锁
+func (idx *Index) refresh(ctx context.Context) error {
+	if !idx.built.IsZero() && idx.now().Sub(idx.built) < idx.MaxAge {
+		return nil
+	}
+
+	ps, err := idx.products.GetProducts(ctx)
+	if err != nil {
+		return err
+	}
+
+	terms := map[string][]posting{}
+	lengths := make(map[int]float64, len(ps))
+	var total float64
+	for _, p := range ps {
+		tf := map[string]float64{}
+		for _, t := range Tokenize(p.Name) {
+			tf[t] += nameWeight
+		}
+		for _, t := range Tokenize(p.Description) {
+			tf[t] += descriptionWeight
+		}
+		var length float64
+		for t, f := range tf {
+			terms[t] = append(terms[t], posting{productID: p.ID, tf: f})
+			length += f
+		}
+		lengths[p.ID] = length
+		total += length
+	}
+
+	idx.terms = terms
+	idx.lengths = lengths
+	idx.avgLen = 1
+	if len(ps) > 0 && total > 0 {
+		idx.avgLen = total / float64(len(ps))
+	}
+	idx.built = idx.now()
+	return nil
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/search", h.handleSearch).Methods("GET")
+}
+
+// ?q=<查询>&category=<slug>&price=<区间>&inStock=<true|false>&limit=&offset=
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params, err := parseParams(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	result, err := Search(r.Context(), h.stores, params)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, result)
+}
+
+func parseParams(r *http.Request) (types.SearchParams, error) {
+	q := r.URL.Query()
+	params := types.SearchParams{
+		Query:    q.Get("q"),
+		Category: q.Get("category"),
+		Price:    q.Get("price"),
+	}
+
+	var fields []apperr.FieldError
+	if v := q.Get("inStock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			fields = append(fields, apperr.FieldError{Field: "inStock", Rule: "boolean", Message: "inStock must be true or false"})
+		}
+		params.InStock = &inStock
+	}
+	intParam := func(name string, min int, dst *int) {
+		v := q.Get(name)
+		if v == "" {
+			return
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min {
+			fields = append(fields, apperr.FieldError{
+				Field: name, Rule: "min", Param: strconv.Itoa(min), Message: name + " must be an integer of at least " + strconv.Itoa(min),
+			})
+			return
+		}
+		*dst = n
+	}
+	intParam("limit", 1, &params.Limit)
+	intParam("offset", 0, &params.Offset)
+
+	if len(fields) > 0 {
+		return params, apperr.Validation("validation_failed", "request validation failed").WithFields(fields)
+	}
+	return params, nil
+}
@@ -0,0 +1,173 @@
+package search_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/search"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+// memstore 依赖 search 包，所以这里使用外部测试包
+func TestSearchRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "Wireless Mouse", Description: "2.4G wireless mouse", Price: 19.5, Quantity: 10},
+		types.Product{Name: "Gaming Mouse", Description: "RGB mouse for games", Price: 59, Quantity: 0},
+		types.Product{Name: "Mechanical Keyboard", Description: "87-key keyboard, pairs well with a mouse", Price: 49.9, Quantity: 3},
+		types.Product{Name: "Desk Lamp", Description: "warm light", Price: 15, Quantity: 3},
+	)
+	stores := store.Stores()
+
+	// 外设 > 鼠标，键盘只属于外设
+	peripherals := &types.Category{Name: "Peripherals", Slug: "peripherals"}
+	if err := store.CreateCategory(ctx, peripherals); err != nil {
+		t.Fatal(err)
+	}
+	mice := &types.Category{Name: "Mice", Slug: "mice", ParentID: &peripherals.ID}
+	if err := store.CreateCategory(ctx, mice); err != nil {
+		t.Fatal(err)
+	}
+	for productID, categoryID := range map[int]int{1: mice.ID, 2: mice.ID, 3: peripherals.ID} {
+		if err := store.SetProductCategories(ctx, productID, []int{categoryID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := mux.NewRouter()
+	search.NewHandler(stores).RegisterRoutes(router)
+	get := func(query string) (*httptest.ResponseRecorder, types.SearchResult) {
+		req := httptest.NewRequest(http.MethodGet, "/products/search?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var result types.SearchResult
+		json.NewDecoder(rr.Body).Decode(&result)
+		return rr, result
+	}
+	ids := func(result types.SearchResult) []int {
+		var ids []int
+		for _, h := range result.Hits {
+			ids = append(ids, h.Product.ID)
+		}
+		return ids
+	}
+	facet := func(counts []types.FacetCount, value string) int {
+		for _, c := range counts {
+			if c.Value == value {
+				return c.Count
+			}
+		}
+		return 0
+	}
+
+	t.Run("按相关度排序并高亮", func(t *testing.T) {
+		rr, result := get("q=mouse")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d", http.StatusOK, rr.Code)
+		}
+		got := ids(result)
+		if result.Total != 3 || len(got) != 3 || got[2] != 3 {
+			t.Fatalf("expected mice before the keyboard, got %v (total %d)", got, result.Total)
+		}
+		if h := result.Hits[0].Highlights; h.Name != "Wireless <mark>Mouse</mark>" || h.Description != "2.4G wireless <mark>mouse</mark>" {
+			t.Errorf("unexpected highlights: %+v", h)
+		}
+	})
+
+	t.Run("短查询容忍拼写错误", func(t *testing.T) {
+		_, result := get("q=keybaord")
+		if got := ids(result); len(got) != 1 || got[0] != 3 {
+			t.Fatalf("expected the keyboard, got %v", got)
+		}
+		if name := result.Hits[0].Highlights.Name; name != "Mechanical <mark>Keyboard</mark>" {
+			t.Errorf("unexpected highlight: %q", name)
+		}
+	})
+
+	t.Run("分面统计", func(t *testing.T) {
+		_, result := get("q=mouse")
+		f := result.Facets
+		// 子分类下的产品同时计入父分类
+		if facet(f.Categories, "peripherals") != 3 || facet(f.Categories, "mice") != 2 {
+			t.Errorf("unexpected category facets: %+v", f.Categories)
+		}
+		if facet(f.Price, "0-25") != 1 || facet(f.Price, "25-50") != 1 || facet(f.Price, "50-100") != 1 || len(f.Price) != len(search.PriceBuckets) {
+			t.Errorf("unexpected price facets: %+v", f.Price)
+		}
+		if facet(f.InStock, "true") != 2 || facet(f.InStock, "false") != 1 {
+			t.Errorf("unexpected stock facets: %+v", f.InStock)
+		}
+	})
+
+	t.Run("过滤条件不影响自身分面的计数", func(t *testing.T) {
+		_, result := get("q=mouse&category=mice&inStock=true")
+		if got := ids(result); result.Total != 1 || len(got) != 1 || got[0] != 1 {
+			t.Fatalf("expected only the wireless mouse, got %v", got)
+		}
+		f := result.Facets
+		if facet(f.Categories, "peripherals") != 2 || facet(f.Categories, "mice") != 1 {
+			t.Errorf("unexpected category facets: %+v", f.Categories)
+		}
+		if facet(f.InStock, "true") != 1 || facet(f.InStock, "false") != 1 {
+			t.Errorf("unexpected stock facets: %+v", f.InStock)
+		}
+
+		_, result = get("q=mouse&price=25-50")
+		if got := ids(result); len(got) != 1 || got[0] != 3 {
+			t.Errorf("expected the keyboard, got %v", got)
+		}
+	})
+
+	t.Run("库存变化实时反映在结果中", func(t *testing.T) {
+		if _, err := stores.Products.AdjustStock(ctx, 2, 5, types.StockChange{Type: types.StockRestock, Reason: "restock"}); err != nil {
+			t.Fatal(err)
+		}
+		_, result := get("q=mouse&inStock=true")
+		if result.Total != 3 {
+			t.Errorf("expected 3 in-stock hits, got %v", ids(result))
+		}
+	})
+
+	t.Run("分页", func(t *testing.T) {
+		_, result := get("q=mouse&limit=1&offset=1")
+		if got := ids(result); result.Total != 3 || len(got) != 1 {
+			t.Errorf("expected one hit out of 3, got %v (total %d)", got, result.Total)
+		}
+		_, result = get("q=mouse&offset=10")
+		if result.Total != 3 || len(result.Hits) != 0 {
+			t.Errorf("expected an empty page, got %+v", result)
+		}
+	})
+
+	t.Run("没有命中", func(t *testing.T) {
+		rr, result := get("q=printer")
+		if rr.Code != http.StatusOK || result.Total != 0 || result.Hits == nil {
+			t.Errorf("expected an empty result, got %d %+v", rr.Code, result)
+		}
+	})
+
+	for _, tt := range []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"缺少查询词", "q=", http.StatusBadRequest},
+		{"查询词只有标点", "q=%21%3F", http.StatusBadRequest},
+		{"未知的价格区间", "q=mouse&price=10-20", http.StatusBadRequest},
+		{"inStock 不是布尔值", "q=mouse&inStock=maybe", http.StatusBadRequest},
+		{"limit 不合法", "q=mouse&limit=0", http.StatusBadRequest},
+		{"分类不存在", "q=mouse&category=nope", http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := get(tt.query)
+			if rr.Code != tt.status {
+				t.Errorf("期望状态码 %d, 实际状态码 %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 每页的默认和最大条数
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// PriceBucket 价格分面的一个区间，包含 Min，不包含 Max
+type PriceBucket struct {
+	Key      string
+	Min, Max float64
+}
+
+// PriceBuckets 价格分面的区间，也是 ?price= 允许的取值
+var PriceBuckets = []PriceBucket{
+	{Key: "0-25", Min: 0, Max: 25},
+	{Key: "25-50", Min: 25, Max: 50},
+	{Key: "50-100", Min: 50, Max: 100},
+	{Key: "100-200", Min: 100, Max: 200},
+	{Key: "200+", Min: 200, Max: math.Inf(1)},
+}
+
+func priceBucket(key string) (PriceBucket, bool) {
+	for _, b := range PriceBuckets {
+		if b.Key == key {
+			return b, true
+		}
+	}
+	return PriceBucket{}, false
+}
+
+func (b PriceBucket) contains(price float64) bool {
+	return price >= b.Min && price < b.Max
+}
+
+// candidate 一个命中的产品以及它是否满足各个过滤条件
+type candidate struct {
+	product    types.Product
+	score      float64
+	categories []int // 包含所有祖先分类
+	inCategory bool
+	inPrice    bool
+	inStock    bool
+}
+
+// Search 全文检索产品，按相关度排序后过滤、统计分面并返回一页结果
+// 短查询（不超过 3 个词）会容忍拼写错误，见 Fuzzy
+func Search(ctx context.Context, stores types.Stores, params types.SearchParams) (*types.SearchResult, error) {
+	query := strings.TrimSpace(params.Query)
+	if len(Tokenize(query)) == 0 {
+		return nil, apperr.Validation("validation_failed", "request validation failed").WithFields([]apperr.FieldError{
+			{Field: "q", Rule: "required", Message: "a search query is required"},
+		})
+	}
+	var bucket PriceBucket
+	if params.Price != "" {
+		var ok bool
+		if bucket, ok = priceBucket(params.Price); !ok {
+			return nil, apperr.Validation("validation_failed", "request validation failed").WithFields([]apperr.FieldError{
+				{Field: "price", Rule: "oneof", Param: bucketKeys(), Message: fmt.Sprintf("price must be one of %s", bucketKeys())},
+			})
+		}
+	}
+
+	matches, err := stores.Search.SearchProducts(ctx, query, Fuzzy(query))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) > maxMatches {
+		matches = matches[:maxMatches]
+	}
+
+	flat, err := stores.Categories.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 候选产品的分类已经包含所有祖先，检查是否含有该分类即可匹配到子分类下的产品
+	categoryID := 0
+	if params.Category != "" {
+		c, err := stores.Categories.GetCategoryBySlug(ctx, params.Category)
+		if err != nil {
+			return nil, err
+		}
+		categoryID = c.ID
+	}
+
+	candidates, err := loadCandidates(ctx, stores, matches, flat)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		c.inCategory = categoryID == 0 || slices.Contains(c.categories, categoryID)
+		c.inPrice = params.Price == "" || bucket.contains(c.product.Price)
+		c.inStock = params.InStock == nil || *params.InStock == (c.product.Quantity > 0)
+	}
+
+	result := &types.SearchResult{Query: query, Hits: []types.SearchHit{}, Facets: facets(candidates, flat)}
+	var hits []candidate
+	for _, c := range candidates {
+		if c.inCategory && c.inPrice && c.inStock {
+			hits = append(hits, c)
+		}
+	}
+	result.Total = len(hits)
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+	offset := min(max(params.Offset, 0), len(hits))
+	for _, c := range hits[offset:min(offset+limit, len(hits))] {
+		result.Hits = append(result.Hits, types.SearchHit{
+			Product: c.product,
+			Score:   c.score,
+			Highlights: types.SearchHighlights{
+				Name:        Highlight(c.product.Name, query),
+				Description: Snippet(c.product.Description, query),
+			},
+		})
+	}
+	return result, nil
+}
+
+// loadCandidates 读取命中的产品（价格、库存是实时的）和它们所属的分类，保持相关度的顺序
+func loadCandidates(ctx context.Context, stores types.Stores, matches []types.SearchMatch, flat []types.Category) ([]candidate, error) {
+	ids := make([]int, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ProductID
+	}
+	ps, err := stores.Products.GetProductByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make(map[int]types.Product, len(ps))
+	for _, p := range ps {
+		products[p.ID] = p
+	}
+	productCategories, err := stores.Categories.GetCategoryIDsByProductIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := map[int]int{}
+	for _, c := range flat {
+		if c.ParentID != nil {
+			parents[c.ID] = *c.ParentID
+		}
+	}
+	candidates := make([]candidate, 0, len(matches))
+	for _, m := range matches {
+		p, ok := products[m.ProductID]
+		if !ok {
+			// 索引还没有刷新，产品已经不存在了
+			continue
+		}
+		candidates = append(candidates, candidate{
+			product:    p,
+			score:      m.Score,
+			categories: withAncestors(productCategories[p.ID], parents),
+		})
+	}
+	return candidates, nil
+}
+
+// withAncestors 返回分类以及它们的所有祖先，去重
+func withAncestors(ids []int, parents map[int]int) []int {
+	var all []int
+	for _, id := range ids {
+		for {
+			if slices.Contains(all, id) {
+				break
+			}
+			all = append(all, id)
+			parent, ok := parents[id]
+			if !ok {
+				break
+			}
+			id = parent
+		}
+	}
+	return all
+}
+
+// facets 统计分面，每个分面应用其它分面的过滤条件，不应用自身的，这样用户可以看到切换取值后的结果数
+func facets(candidates []candidate, flat []types.Category) types.SearchFacets {
+	categoryCounts := map[int]int{}
+	priceCounts := make([]int, len(PriceBuckets))
+	stockCounts := map[bool]int{}
+	for _, c := range candidates {
+		if c.inPrice && c.inStock {
+			for _, id := range c.categories {
+				categoryCounts[id]++
+			}
+		}
+		if c.inCategory && c.inStock {
+			for i, b := range PriceBuckets {
+				if b.contains(c.product.Price) {
+					priceCounts[i]++
+				}
+			}
+		}
+		if c.inCategory && c.inPrice {
+			stockCounts[c.product.Quantity > 0]++
+		}
+	}
+
+	f := types.SearchFacets{
+		Categories: []types.FacetCount{},
+		Price:      make([]types.FacetCount, len(PriceBuckets)),
+		InStock: []types.FacetCount{
+			{Value: "true", Label: "In stock", Count: stockCounts[true]},
+			{Value: "false", Label: "Out of stock", Count: stockCounts[false]},
+		},
+	}
+	for _, c := range flat {
+		if n := categoryCounts[c.ID]; n > 0 {
+			f.Categories = append(f.Categories, types.FacetCount{Value: c.Slug, Label: c.Name, Count: n})
+		}
+	}
+	// GetCategories 已经按 sort_order、名称排好，这里只按数量稳定排序
+	sort.SliceStable(f.Categories, func(i, j int) bool { return f.Categories[i].Count > f.Categories[j].Count })
+	for i, b := range PriceBuckets {
+		f.Price[i] = types.FacetCount{Value: b.Key, Label: priceLabel(b), Count: priceCounts[i]}
+	}
+	return f
+}
+
+func priceLabel(b PriceBucket) string {
+	if math.IsInf(b.Max, 1) {
+		return fmt.Sprintf("%g and above", b.Min)
+	}
+	return fmt.Sprintf("%g to %g", b.Min, b.Max)
+}
+
+func bucketKeys() string {
+	keys := make([]string, len(PriceBuckets))
+	for i, b := range PriceBuckets {
+		keys[i] = b.Key
+	}
+	return strings.Join(keys, " ")
+}
@@ -0,0 +1,200 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 单次搜索最多返回的命中数，分面统计也只基于这些命中
+const maxMatches = 1000
+
+// Store 基于 MySQL FULLTEXT 索引的搜索，索引由迁移 add_products_*_fulltext 创建
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// SearchProducts 用自然语言模式计算相关度，名称上的相关度额外加权
+// fuzzy 时再用 LIKE 找出含有拼写相近（编辑距离为 1）的词的产品，它们排在精确命中之后
+func (s *Store) SearchProducts(ctx context.Context, query string, fuzzy bool) ([]types.SearchMatch, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []types.SearchMatch{}, nil
+	}
+
+	matches, err := s.fulltext(ctx, strings.Join(terms, " "))
+	if err != nil || !fuzzy {
+		return matches, err
+	}
+
+	similar, err := s.similar(ctx, terms)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSimilar(matches, similar), nil
+}
+
+func (s *Store) fulltext(ctx context.Context, query string) (_ []types.SearchMatch, err error) {
+	const stmt = "SELECT id, MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) * 3 + " +
+		"MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE) AS score " +
+		"FROM products WHERE MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE) " +
+		"ORDER BY score DESC, id LIMIT ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "search.Store.SearchProducts", stmt)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "search.Store.SearchProducts", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(stmt), query, query, query, maxMatches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []types.SearchMatch{}
+	for rows.Next() {
+		var m types.SearchMatch
+		if err := rows.Scan(&m.ProductID, &m.Score); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// similar 用 LIKE 粗筛出可能含有相近词的产品，再在 Go 中按编辑距离精确判断并打分
+// 这个查询需要全表扫描，所以只用于短查询
+func (s *Store) similar(ctx context.Context, terms []string) (_ map[int]float64, err error) {
+	var (
+		conds []string
+		args  []any
+	)
+	for _, t := range terms {
+		if MaxEdits(t) == 0 {
+			continue
+		}
+		for _, p := range likePatterns(t) {
+			conds = append(conds, "name LIKE ?", "description LIKE ?")
+			args = append(args, p, p)
+		}
+	}
+	scores := map[int]float64{}
+	if len(conds) == 0 {
+		return scores, nil
+	}
+
+	query := "SELECT id, name, COALESCE(description, '') FROM products WHERE " + strings.Join(conds, " OR ") + " LIMIT ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "search.Store.SearchProducts.similar", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "search.Store.SearchProducts", db.Read)
+	defer cancel()
+
+	args = append(args, maxMatches)
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id                int
+			name, description string
+		)
+		if err := rows.Scan(&id, &name, &description); err != nil {
+			return nil, err
+		}
+		if score := similarScore(terms, name, description); score > 0 {
+			scores[id] = score
+		}
+	}
+	return scores, rows.Err()
+}
+
+// similarScore 每个查询词在名称中有相近的词记 nameWeight 分，只在描述中有记 descriptionWeight 分
+func similarScore(terms []string, name, description string) float64 {
+	nameTerms, descTerms := Tokenize(name), Tokenize(description)
+	var score float64
+	for _, t := range terms {
+		switch {
+		case containsSimilar(nameTerms, t):
+			score += nameWeight
+		case containsSimilar(descTerms, t):
+			score += descriptionWeight
+		}
+	}
+	return score
+}
+
+func containsSimilar(words []string, term string) bool {
+	for _, w := range words {
+		if termMatches(w, []string{term}, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// likePatterns 生成与 term 编辑距离为 1 的 LIKE 模式：缺一个字符、多一个字符、错一个字符和相邻字符交换
+// 词里只有字母、数字和汉字，不需要转义 LIKE 的通配符
+func likePatterns(term string) []string {
+	r := []rune(term)
+	seen := map[string]bool{}
+	var patterns []string
+	add := func(s string) {
+		p := "%" + s + "%"
+		if !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	for i := 0; i <= len(r); i++ {
+		add(string(r[:i]) + "_" + string(r[i:]))
+	}
+	for i := range r {
+		add(string(r[:i]) + "_" + string(r[i+1:]))
+		add(string(r[:i]) + string(r[i+1:]))
+		if i+1 < len(r) {
+			add(string(r[:i]) + string(r[i+1]) + string(r[i]) + string(r[i+2:]))
+		}
+	}
+	return patterns
+}
+
+// mergeSimilar 把只靠拼写纠错命中的产品追加在精确命中之后，分数缩放到低于最低的精确命中分数
+func mergeSimilar(exact []types.SearchMatch, similar map[int]float64) []types.SearchMatch {
+	found := map[int]bool{}
+	floor := 1.0
+	for _, m := range exact {
+		found[m.ProductID] = true
+		floor = min(floor, m.Score)
+	}
+
+	var top float64
+	for id, score := range similar {
+		if !found[id] {
+			top = max(top, score)
+		}
+	}
+	var extra []types.SearchMatch
+	for id, score := range similar {
+		if !found[id] {
+			extra = append(extra, types.SearchMatch{ProductID: id, Score: score / top * floor / 2})
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		if extra[i].Score != extra[j].Score {
+			return extra[i].Score > extra[j].Score
+		}
+		return extra[i].ProductID < extra[j].ProductID
+	})
+	return append(exact, extra...)
+}
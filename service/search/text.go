@@ -0,0 +1,217 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 词语数不超过 maxFuzzyTerms 的查询才做拼写纠错，长查询靠多个词共同命中就足够准确
+const maxFuzzyTerms = 3
+
+// 摘要最多保留的字符数（按 rune 计）
+const snippetRunes = 160
+
+// token 文本中的一个词，Start、End 是它在原文中的字节位置
+type token struct {
+	Term       string
+	Start, End int
+}
+
+// Tokenize 把文本切分为小写的词：连续的字母和数字组成一个词，汉字每个字单独成词
+func Tokenize(text string) []string {
+	tokens := tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.Term
+	}
+	return terms
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, token{Term: strings.ToLower(text[start:end]), Start: start, End: end})
+			start = -1
+		}
+	}
+	for i, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush(i)
+			end := i + utf8.RuneLen(r)
+			tokens = append(tokens, token{Term: text[i:end], Start: i, End: end})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// queryTerms 返回查询中去重后的词，保持原有顺序
+func queryTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, t := range Tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// Fuzzy 判断查询是否足够短，需要容忍拼写错误
+func Fuzzy(query string) bool {
+	n := len(queryTerms(query))
+	return n > 0 && n <= maxFuzzyTerms
+}
+
+// MaxEdits 返回一个查询词允许的最大编辑距离：4 个字符以下不纠错，8 个字符以上允许两处错误
+func MaxEdits(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance 计算 Damerau-Levenshtein 距离（相邻字符交换算一次编辑），超过 limit 时提前返回 limit+1
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	// 只需要保留最近三行
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(rb)], limit+1)
+}
+
+// termMatches 判断文本中的词是否命中查询词，fuzzy 时允许 MaxEdits 以内的拼写错误
+func termMatches(word string, terms []string, fuzzy bool) bool {
+	for _, t := range terms {
+		if word == t {
+			return true
+		}
+		if fuzzy {
+			if limit := MaxEdits(t); limit > 0 && editDistance(word, t, limit) <= limit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Highlight 对整段文本做 HTML 转义，并用 <mark> 标出命中的词
+func Highlight(text, query string) string {
+	return highlight(text, 0, len(text), tokenize(text), queryTerms(query), Fuzzy(query))
+}
+
+// Snippet 截取文本中第一个命中词附近的一段并高亮，文本较短时返回整段
+// 没有命中时返回开头的一段，截断处用 "…" 表示
+func Snippet(text, query string) string {
+	terms, fuzzy := queryTerms(query), Fuzzy(query)
+	tokens := tokenize(text)
+	if utf8.RuneCountInString(text) <= snippetRunes {
+		return highlight(text, 0, len(text), tokens, terms, fuzzy)
+	}
+
+	first := 0
+	for _, t := range tokens {
+		if termMatches(t.Term, terms, fuzzy) {
+			first = t.Start
+			break
+		}
+	}
+
+	// 命中词之前保留大约四分之一的长度作为上下文
+	start := first
+	for n := 0; n < snippetRunes/4 && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for n := 0; n < snippetRunes && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	// 不在词的中间截断
+	for _, t := range tokens {
+		if t.Start < start && t.End > start {
+			start = t.End
+		}
+		if t.Start < end && t.End > end {
+			end = t.Start
+		}
+	}
+	for start < end && text[start] == ' ' {
+		start++
+	}
+	for end > start && text[end-1] == ' ' {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(highlight(text, start, end, tokens, terms, fuzzy))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// highlight 转义 text[start:end] 并标出其中命中的词
+func highlight(text string, start, end int, tokens []token, terms []string, fuzzy bool) string {
+	var b strings.Builder
+	pos := start
+	for _, t := range tokens {
+		if t.Start < start || t.End > end || !termMatches(t.Term, terms, fuzzy) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:t.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.Start:t.End]))
+		b.WriteString("</mark>")
+		pos = t.End
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
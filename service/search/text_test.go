@@ -0,0 +1,104 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Albert-tru/ecom/types"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Mechanical Keyboard, 87-key (USB-C) 机械键盘")
+	want := []string{"mechanical", "keyboard", "87", "key", "usb", "c", "机", "械", "键", "盘"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"keyboard", "keyboard", 0},
+		{"keybaord", "keyboard", 1}, // 相邻字符交换
+		{"keybord", "keyboard", 1},
+		{"mouse", "moose", 1},
+		{"mouse", "house", 1},
+		{"mouse", "lamp", 3}, // 超过上限时返回上限加一
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, 2); got != tt.want {
+			t.Errorf("editDistance(%q, %q): expected %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	if !Fuzzy("wireless mouse") {
+		t.Error("expected short query to be fuzzy")
+	}
+	if Fuzzy("red wireless gaming mouse") {
+		t.Error("expected long query not to be fuzzy")
+	}
+	if MaxEdits("usb") != 0 || MaxEdits("mouse") != 1 || MaxEdits("keyboards") != 2 {
+		t.Error("unexpected max edits")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Wireless <Mouse> & mousepad", "mouse")
+	want := "Wireless &lt;<mark>Mouse</mark>&gt; &amp; mousepad"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	// 短查询同时高亮拼写相近的词
+	if got := Highlight("Mechanical Keyboard", "keybaord"); got != "Mechanical <mark>Keyboard</mark>" {
+		t.Errorf("unexpected highlight: %q", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	if got := Snippet("short text about a mouse", "mouse"); got != "short text about a <mark>mouse</mark>" {
+		t.Errorf("unexpected snippet: %q", got)
+	}
+
+	long := strings.Repeat("filler words here ", 20) + "the mouse is wireless " + strings.Repeat("more text after ", 20)
+	got := Snippet(long, "mouse")
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected both ends to be truncated: %q", got)
+	}
+	if !strings.Contains(got, "the <mark>mouse</mark> is wireless") {
+		t.Errorf("expected the match in the snippet: %q", got)
+	}
+	plain := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(got)
+	if n := len([]rune(plain)); n > snippetRunes+2 {
+		t.Errorf("snippet too long: %d runes", n)
+	}
+	// 不在词的中间截断
+	inner := strings.Trim(got, "…")
+	if !strings.HasPrefix(inner, "filler") && !strings.HasPrefix(inner, "words") && !strings.HasPrefix(inner, "here") {
+		t.Errorf("snippet starts mid-word: %q", got)
+	}
+}
+
+func TestLikePatterns(t *testing.T) {
+	patterns := likePatterns("abc")
+	for _, want := range []string{"%_abc%", "%ab_c%", "%a_c%", "%ac%", "%bac%"} {
+		if !slices.Contains(patterns, want) {
+			t.Errorf("expected %q in %v", want, patterns)
+		}
+	}
+}
+
+func TestMergeSimilar(t *testing.T) {
+	exact := []types.SearchMatch{{ProductID: 1, Score: 4}, {ProductID: 2, Score: 2}}
+	got := mergeSimilar(exact, map[int]float64{2: 3, 3: 1, 4: 3})
+	if len(got) != 4 || got[2].ProductID != 4 || got[3].ProductID != 3 {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+	if got[2].Score >= got[1].Score {
+		t.Errorf("expected similar matches to rank below exact ones: %+v", got)
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/Albert-tru/ecom/apperr"
 	"github.com/Albert-tru/ecom/config"
 	"github.com/Albert-tru/ecom/service/auth"
 	"github.com/Albert-tru/ecom/types"
 	"github.com/Albert-tru/ecom/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
+var errInvalidCredentials = apperr.Unauthorized("invalid_credentials", "invalid credentials")
+
 type Handler struct {
 	store types.UserStore
 }
@@ -30,44 +32,54 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	// 1. 解析请求
 	var payload types.LoginrUserPayload
-	if err := utils.ParseJson(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// 2. 验证字段
-	if err := utils.Validate.Struct(&payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	if err := utils.ValidatePayload(r, &payload); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// 3. 查用户
-	user, err := h.store.GetUserByEmail(payload.Email)
+	user, err := h.store.GetUserByEmail(r.Context(), payload.Email)
 	if err != nil {
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			utils.WriteError(w, r, err)
+			return
+		}
 		// 不泄露是"找不到用户"还是密码错误，统一返回 401
-		utils.WriteError(w, http.StatusUnauthorized, "invalid credentials")
+		utils.WriteError(w, r, errInvalidCredentials)
 		return
 	}
 
 	// 4. 检查密码
 	if err := auth.ComparePassword(user.Password, payload.Password); err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, "invalid credentials")
+		utils.WriteError(w, r, errInvalidCredentials)
 		return
 	}
 
-	// 5. 生成 JWT
+	// 5. 密码正确之后再告诉对方账号被禁用，避免暴露账号状态
+	if user.Disabled {
+		utils.WriteError(w, r, auth.ErrAccountDisabled)
+		return
+	}
+
+	// 6. 生成 JWT
 	secret := []byte(config.Envs.JWTSecret)
 	token, err := auth.GenerateJWT(secret, user.ID)
 
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "failed to generate token")
+		utils.WriteError(w, r, apperr.Internal(fmt.Errorf("failed to generate token: %w", err)))
 		return
 	}
 
-	utils.WriteJson(w, http.StatusOK, map[string]string{
-		"message": "login successful",
-		"user_id": fmt.Sprintf("%d", user.ID),
-		"token":   token, // 返回 JWT 令牌到客户端
+	utils.WriteJson(w, http.StatusOK, types.LoginResponse{
+		Message: "login successful",
+		UserID:  fmt.Sprintf("%d", user.ID),
+		Token:   token, // 返回 JWT 令牌到客户端
 	})
 }
 
@@ -78,30 +90,37 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	//1. 获取json数据
 	var payload types.RegisterUserPayload
 	//解码过程中发生错误
-	if err := utils.ParseJson(r, &payload); err != nil {
+	if err := utils.ParseJson(w, r, &payload); err != nil {
 		//进行错误处理
-		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	//验证数据
-	if err := utils.Validate.Struct(&payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("validation error: %v", errors.Error()).Error())
+	if err := utils.ValidatePayload(r, &payload); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	//2. 检查user是否已存在
-	_, err := h.store.GetUserByEmail(payload.Email)
+	_, err := h.store.GetUserByEmail(r.Context(), payload.Email)
 	if err == nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with email %s already exists", payload.Email).Error())
+		utils.WriteError(w, r, apperr.Conflict("email_taken", fmt.Sprintf("user with email %s already exists", payload.Email)))
+		return
+	}
+	if !apperr.IsKind(err, apperr.KindNotFound) {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	hashedPassword, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteError(w, r, apperr.Internal(err))
+		return
+	}
 
 	//3. 不存在，创建user
-	err = h.store.CreateUser(&types.User{
+	err = h.store.CreateUser(r.Context(), &types.User{
 		Firstname: payload.Firstname,
 		Lastname:  payload.Lastname,
 		Email:     payload.Email,
@@ -109,7 +128,7 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -2,12 +2,13 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Albert-tru/ecom/memstore"
 	"github.com/Albert-tru/ecom/types"
 	"github.com/gorilla/mux"
 )
@@ -24,7 +25,7 @@ import (
 // 测试用户服务处理函数
 func TestUserServiceHandle(t *testing.T) {
 
-	userStore := &mockUserStore{}    //可控的假仓库
+	userStore := memstore.New()      //内存中的仓库，行为与数据库一致
 	handler := NewHandler(userStore) //把它注入到“待测的处理器”中
 
 	// 测试用例：成功注册
@@ -92,19 +93,60 @@ func TestUserServiceHandle(t *testing.T) {
 			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusCreated, rr.Code)
 		}
 	})
-}
 
-// 创建一个模拟对象，模仿真实对象的行为但不实际依赖外部系统
-type mockUserStore struct {
-}
+	t.Run("重复注册", func(t *testing.T) {
+		// 上一个用例已经注册了 123@gmail.com
+		payload := types.RegisterUserPayload{
+			Firstname: "John",
+			Lastname:  "Doe",
+			Email:     "123@gmail.com",
+			Password:  "123456",
+		}
+		marshalled, _ := json.Marshal(payload)
 
-func (m *mockUserStore) CreateUser(user *types.User) error {
-	return nil
-}
+		req, err := http.NewRequest(http.MethodPost, "/register", bytes.NewReader(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
-	return nil, fmt.Errorf("user not found") // 返回错误表示用户不存在
-}
-func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
-	return nil, nil
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/register", handler.handleRegister)
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("禁用的账号不能登录", func(t *testing.T) {
+		// 先用正确的密码登录成功，禁用后同样的请求返回 403
+		login := func() int {
+			marshalled, _ := json.Marshal(types.LoginrUserPayload{Email: "123@gmail.com", Password: "123456"})
+			req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.HandleFunc("/login", handler.handleLogin)
+			router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		if code := login(); code != http.StatusOK {
+			t.Fatalf("期望状态码 %d, 实际状态码 %d", http.StatusOK, code)
+		}
+		u, _ := userStore.GetUserByEmail(context.Background(), "123@gmail.com")
+		if err := userStore.SetDisabled(context.Background(), u.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if code := login(); code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, code)
+		}
+	})
 }
@@ -1,26 +1,43 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
 	"github.com/Albert-tru/ecom/types"
 )
 
+var errUserNotFound = apperr.NotFound("user_not_found", "未找到用户")
+
+// 查询用户时的列，顺序与 scanRowIntoUser 一致
+const userColumns = "id, firstname, lastname, email, password, role, disabled, createdat"
+
 type Store struct {
-	db *sql.DB
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
 }
 
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
-func (s *Store) GetUserByEmail(email string) (*types.User, error) {
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (_ *types.User, err error) {
+	const query = "SELECT " + userColumns + " FROM users WHERE email = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.GetUserByEmail", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.GetUserByEmail", db.Read)
+	defer cancel()
+
 	//查询数据库,将查询的多行结果保存到row
-	rows, err := s.db.Query("SELECT * FROM users WHERE email = ?", email)
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), email)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	u := new(types.User)
 
@@ -32,27 +49,47 @@ func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("未找到用户")
+		return nil, errUserNotFound
 	}
 
 	return u, nil
 }
 
-func (s *Store) CreateUser(user *types.User) error {
-	_, err := s.db.Exec("INSERT INTO users (firstName, lastName, email, password) VALUES (?, ?, ?, ?)", user.Firstname, user.Lastname, user.Email, user.Password)
+func (s *Store) CreateUser(ctx context.Context, user *types.User) (err error) {
+	const query = "INSERT INTO users (firstName, lastName, email, password, role) VALUES (?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.CreateUser", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.CreateUser", db.Write)
+	defer cancel()
+
+	if user.Role == "" {
+		user.Role = types.RoleCustomer
+	}
+	id, err := s.dialect.InsertID(ctx, s.db, query, user.Firstname, user.Lastname, user.Email, user.Password, user.Role)
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("email_taken", fmt.Sprintf("user with email %s already exists", user.Email)).WithCause(err)
+	}
 	if err != nil {
 		return err
 	}
 
+	user.ID = int(id)
 	return nil
 }
 
-func (s *Store) GetUserByID(id int) (*types.User, error) {
+func (s *Store) GetUserByID(ctx context.Context, id int) (_ *types.User, err error) {
+	const query = "SELECT " + userColumns + " FROM users WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.GetUserByID", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.GetUserByID", db.Read)
+	defer cancel()
+
 	// 查询数据库
-	rows, err := s.db.Query("SELECT * FROM users WHERE id = ?", id)
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), id)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	u := new(types.User)
 
@@ -64,15 +101,88 @@ func (s *Store) GetUserByID(id int) (*types.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("未找到用户")
+		return nil, errUserNotFound
 	}
 
 	return u, nil
 }
 
+// GetUsers 按ID顺序返回所有用户
+func (s *Store) GetUsers(ctx context.Context) (_ []types.User, err error) {
+	const query = "SELECT " + userColumns + " FROM users ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.GetUsers", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.GetUsers", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+	for rows.Next() {
+		u, err := scanRowIntoUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// UpdatePassword 替换用户的密码哈希
+func (s *Store) UpdatePassword(ctx context.Context, id int, hashedPassword string) (err error) {
+	const query = "UPDATE users SET password = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.UpdatePassword", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.UpdatePassword", db.Write)
+	defer cancel()
+
+	return s.update(ctx, query, hashedPassword, id)
+}
+
+// SetDisabled 禁用或重新启用账号
+func (s *Store) SetDisabled(ctx context.Context, id int, disabled bool) (err error) {
+	const query = "UPDATE users SET disabled = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "user.Store.SetDisabled", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "user.Store.SetDisabled", db.Write)
+	defer cancel()
+
+	return s.update(ctx, query, disabled, id)
+}
+
+// 执行按ID更新的语句，最后一个参数是用户ID；没有匹配的行时返回 user_not_found
+func (s *Store) update(ctx context.Context, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// MySQL 在值没有变化时 RowsAffected 也是 0，需要确认用户是否真的不存在
+	var count int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM users WHERE id = ?"), args[len(args)-1]).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
 func scanRowIntoUser(row *sql.Rows) (*types.User, error) {
 	u := new(types.User)
-	err := row.Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Email, &u.Password, &u.CreatedAt)
+	err := row.Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Email, &u.Password, &u.Role, &u.Disabled, &u.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,104 @@
+package variant
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/variants", h.handleGetVariants).Methods("GET")
+
+	// 管理接口
+	router.HandleFunc("/admin/products/{id:[0-9]+}/options", auth.WithAdmin(h.handleSetOptions, h.stores.Users)).Methods("PUT")
+	router.HandleFunc("/admin/products/{id:[0-9]+}/variants", auth.WithAdmin(h.handleCreate, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/admin/variants/{id:[0-9]+}", auth.WithAdmin(h.handleUpdate, h.stores.Users)).Methods("PUT")
+}
+
+// 返回产品的规格类型和所有变体
+func (h *Handler) handleGetVariants(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	result, err := Get(r.Context(), h.stores, productID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, result)
+}
+
+func (h *Handler) handleSetOptions(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.ProductOptionsPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	result, err := SetOptions(r.Context(), h.stores, productID, payload.Options)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, result)
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.CreateVariantPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	v, err := Create(r.Context(), h.stores, productID, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, v)
+}
+
+func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.VariantPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	v, err := Update(r.Context(), h.stores, id, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, v)
+}
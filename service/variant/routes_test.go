@@ -0,0 +1,108 @@
+package variant_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+func TestVariantRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(types.Product{Name: "t-shirt", Price: 19.9, Quantity: 5})
+	router := mux.NewRouter()
+	variant.NewHandler(store.Stores()).RegisterRoutes(router)
+
+	admin := &types.User{Email: "admin@example.com", Role: types.RoleAdmin}
+	customer := &types.User{Email: "customer@example.com"}
+	for _, u := range []*types.User{admin, customer} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+	red := func(size string) types.CreateVariantPayload {
+		return types.CreateVariantPayload{
+			VariantPayload: types.VariantPayload{SKU: "TS-RED-" + size, Options: map[string]string{"size": size, "colour": "red"}},
+			Quantity:       2,
+		}
+	}
+
+	t.Run("只有管理员可以修改", func(t *testing.T) {
+		rr := api.JSON(customer, http.MethodPut, "/admin/products/1/options", types.ProductOptionsPayload{Options: []string{"size"}})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	rr := api.JSON(admin, http.MethodPut, "/admin/products/1/options", types.ProductOptionsPayload{Options: []string{"size", "colour"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("期望状态码 %d, 实际状态码 %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+
+	var m types.ProductVariant
+	t.Run("创建变体", func(t *testing.T) {
+		apitest.Decode(t, api.JSON(admin, http.MethodPost, "/admin/products/1/variants", red("M")), http.StatusCreated, &m)
+		if m.ID == 0 || m.ProductID != 1 || m.Quantity != 2 || m.Options["size"] != "M" {
+			t.Errorf("unexpected variant: %+v", m)
+		}
+
+		// 规格取值与产品的规格类型不一致
+		missing := red("L")
+		delete(missing.Options, "colour")
+		if rr := api.JSON(admin, http.MethodPost, "/admin/products/1/variants", missing); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		unknown := red("L")
+		unknown.Options["material"] = "cotton"
+		if rr := api.JSON(admin, http.MethodPost, "/admin/products/1/variants", unknown); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+
+		// 相同的规格取值或重复的 SKU
+		same := red("M")
+		same.SKU = "OTHER"
+		if rr := api.JSON(admin, http.MethodPost, "/admin/products/1/variants", same); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		dup := red("S")
+		dup.SKU = "TS-RED-M"
+		if rr := api.JSON(admin, http.MethodPost, "/admin/products/1/variants", dup); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		if rr := api.JSON(admin, http.MethodPost, "/admin/products/9999/variants", red("S")); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("修改变体", func(t *testing.T) {
+		price := 24.9
+		payload := types.VariantPayload{SKU: "TS-RED-M", Price: &price, Options: map[string]string{"size": "M", "colour": "red"}}
+		var v types.ProductVariant
+		apitest.Decode(t, api.JSON(admin, http.MethodPut, fmt.Sprintf("/admin/variants/%d", m.ID), payload), http.StatusOK, &v)
+		if v.Price == nil || *v.Price != 24.9 || v.Quantity != 2 {
+			t.Errorf("unexpected variant: %+v", v)
+		}
+		if rr := api.JSON(admin, http.MethodPut, "/admin/variants/9999", payload); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("查询规格和变体", func(t *testing.T) {
+		var got types.ProductVariants
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/products/1/variants", ""), http.StatusOK, &got)
+		if len(got.Options) != 2 || got.Options[0] != "size" || len(got.Variants) != 2 || got.Variants[1].ID != m.ID {
+			t.Errorf("unexpected variants: %+v", got)
+		}
+		if rr := api.Do(nil, http.MethodGet, "/products/9999/variants", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
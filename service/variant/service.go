@@ -0,0 +1,162 @@
+package variant
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Get 返回产品的规格类型和所有变体
+func Get(ctx context.Context, stores types.Stores, productID int) (*types.ProductVariants, error) {
+	if err := productExists(ctx, stores, productID); err != nil {
+		return nil, err
+	}
+
+	options, err := stores.Variants.GetOptions(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := stores.Variants.GetVariants(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ProductVariants{ProductID: productID, Options: options, Variants: variants}, nil
+}
+
+// SetOptions 替换产品的规格类型
+// 新增的规格不会自动补到已有的变体上，这些变体在补全取值之前仍然可以购买，但修改时需要带上新规格
+func SetOptions(ctx context.Context, stores types.Stores, productID int, names []string) (*types.ProductVariants, error) {
+	var result *types.ProductVariants
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := tx.Variants.SetOptions(ctx, productID, names); err != nil {
+			return err
+		}
+		var err error
+		result, err = Get(ctx, tx, productID)
+		return err
+	})
+	return result, err
+}
+
+// Create 校验规格取值并创建变体
+func Create(ctx context.Context, stores types.Stores, productID int, p types.CreateVariantPayload) (*types.ProductVariant, error) {
+	v := fromPayload(p.VariantPayload)
+	v.ProductID = productID
+	v.Quantity = p.Quantity
+
+	var created *types.ProductVariant
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if err := productExists(ctx, tx, productID); err != nil {
+			return err
+		}
+		if err := checkOptions(ctx, tx, v); err != nil {
+			return err
+		}
+		if err := tx.Variants.CreateVariant(ctx, v); err != nil {
+			return err
+		}
+		var err error
+		created, err = getVariant(ctx, tx, v.ID)
+		return err
+	})
+	return created, err
+}
+
+// Update 校验规格取值并修改变体，不修改库存
+func Update(ctx context.Context, stores types.Stores, id int, p types.VariantPayload) (*types.ProductVariant, error) {
+	v := fromPayload(p)
+	v.ID = id
+
+	var updated *types.ProductVariant
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		current, err := getVariant(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		v.ProductID = current.ProductID
+		if err := checkOptions(ctx, tx, v); err != nil {
+			return err
+		}
+		if err := tx.Variants.UpdateVariant(ctx, v); err != nil {
+			return err
+		}
+		updated, err = getVariant(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+// checkOptions 检查变体的规格取值与产品的规格类型一一对应，且同一个产品下没有取值完全相同的变体
+func checkOptions(ctx context.Context, stores types.Stores, v *types.ProductVariant) error {
+	names, err := stores.Variants.GetOptions(ctx, v.ProductID)
+	if err != nil {
+		return err
+	}
+
+	var fields []apperr.FieldError
+	for _, name := range names {
+		if _, ok := v.Options[name]; !ok {
+			fields = append(fields, apperr.FieldError{
+				Field: "options." + name, Rule: "required", Message: fmt.Sprintf("a value for option %q is required", name),
+			})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(v.Options)) {
+		if !slices.Contains(names, name) {
+			fields = append(fields, apperr.FieldError{
+				Field: "options." + name, Rule: "option", Message: fmt.Sprintf("product ID %d has no option %q", v.ProductID, name),
+			})
+		}
+	}
+	if len(fields) > 0 {
+		return apperr.Validation("validation_failed", "request validation failed").WithFields(fields)
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+	siblings, err := stores.Variants.GetVariants(ctx, v.ProductID)
+	if err != nil {
+		return err
+	}
+	for _, other := range siblings {
+		if other.ID != v.ID && maps.Equal(other.Options, v.Options) {
+			return apperr.Conflict("variant_exists", fmt.Sprintf("variant %s already has the options %v", other.SKU, v.Options))
+		}
+	}
+	return nil
+}
+
+func productExists(ctx context.Context, stores types.Stores, productID int) error {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+	return nil
+}
+
+func getVariant(ctx context.Context, stores types.Stores, id int) (*types.ProductVariant, error) {
+	vs, err := stores.Variants.GetVariantsByIDs(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) == 0 {
+		return nil, variantNotFound(fmt.Sprintf("variant ID %d not found", id))
+	}
+	return &vs[0], nil
+}
+
+func fromPayload(p types.VariantPayload) *types.ProductVariant {
+	options := p.Options
+	if options == nil {
+		options = map[string]string{}
+	}
+	return &types.ProductVariant{SKU: p.SKU, Price: p.Price, ImageURL: p.ImageURL, Options: options}
+}
@@ -0,0 +1,406 @@
+package variant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询变体时的列，顺序与 query 中的 Scan 一致
+const variantColumns = "id, product_id, sku, price, quantity, image, createdat"
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) GetOptions(ctx context.Context, productID int) (_ []string, err error) {
+	const query = "SELECT name FROM product_options WHERE product_id = ? ORDER BY position, name"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.GetOptions", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.GetOptions", db.Read)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SetOptions 替换产品的规格类型，先删除再插入，调用方应该把它放在事务中
+func (s *Store) SetOptions(ctx context.Context, productID int, names []string) (err error) {
+	const query = "INSERT INTO product_options (product_id, name, position) VALUES (?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.SetOptions", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.SetOptions", db.Write)
+	defer cancel()
+
+	if err := s.checkProduct(ctx, productID); err != nil {
+		return err
+	}
+	if dup := duplicate(names); dup != "" {
+		return apperr.Validation("duplicate_option", fmt.Sprintf("option %q is listed more than once", dup))
+	}
+
+	// 删除不再使用的规格在变体上的取值
+	args := []any{productID}
+	removed := "DELETE FROM variant_options WHERE variant_id IN (SELECT id FROM product_variants WHERE product_id = ?)"
+	if len(names) > 0 {
+		removed += " AND name NOT IN (" + db.Placeholders(len(names)) + ")"
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(removed), args...); err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM product_options WHERE product_id = ?"), productID); err != nil {
+		return err
+	}
+	for i, name := range names {
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), productID, name, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetVariants(ctx context.Context, productID int) (_ []types.ProductVariant, err error) {
+	const query = "SELECT " + variantColumns + " FROM product_variants WHERE product_id = ? ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.GetVariants", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.GetVariants", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, productID)
+}
+
+func (s *Store) GetVariantsByIDs(ctx context.Context, ids []int) (_ []types.ProductVariant, err error) {
+	if len(ids) == 0 {
+		return []types.ProductVariant{}, nil
+	}
+
+	query := "SELECT " + variantColumns + " FROM product_variants WHERE id IN (" + db.Placeholders(len(ids)) + ") ORDER BY id"
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.GetVariantsByIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.GetVariantsByIDs", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, args...)
+}
+
+func (s *Store) GetVariantsByProductIDs(ctx context.Context, productIDs []int) (_ []types.ProductVariant, err error) {
+	if len(productIDs) == 0 {
+		return []types.ProductVariant{}, nil
+	}
+
+	query := "SELECT " + variantColumns + " FROM product_variants WHERE product_id IN (" + db.Placeholders(len(productIDs)) + ") ORDER BY product_id, id"
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.GetVariantsByProductIDs", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.GetVariantsByProductIDs", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, args...)
+}
+
+func (s *Store) GetVariantBySKU(ctx context.Context, sku string) (_ *types.ProductVariant, err error) {
+	const query = "SELECT " + variantColumns + " FROM product_variants WHERE sku = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.GetVariantBySKU", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.GetVariantBySKU", db.Read)
+	defer cancel()
+
+	vs, err := s.query(ctx, query, sku)
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) == 0 {
+		return nil, variantNotFound(fmt.Sprintf("variant with SKU %q not found", sku))
+	}
+	return &vs[0], nil
+}
+
+// CreateVariant 创建变体，变体的初始库存同时计入产品的库存；SKU 重复时返回 sku_taken
+func (s *Store) CreateVariant(ctx context.Context, v *types.ProductVariant) (err error) {
+	const query = "INSERT INTO product_variants (product_id, sku, price, quantity, image) VALUES (?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.CreateVariant", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.CreateVariant", db.Write)
+	defer cancel()
+
+	if err := s.checkProduct(ctx, v.ProductID); err != nil {
+		return err
+	}
+	id, err := s.dialect.InsertID(ctx, s.db, query, v.ProductID, v.SKU, v.Price, v.Quantity, v.ImageURL)
+	if db.IsUniqueViolation(err) {
+		return skuTaken(v.SKU).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	v.ID = int(id)
+
+	if v.Quantity != 0 {
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("UPDATE products SET quantity = quantity + ? WHERE id = ?"), v.Quantity, v.ProductID); err != nil {
+			return err
+		}
+		if err := inventory.Record(ctx, s.db, s.dialect, inventory.Movement(v.ProductID, v.ID, v.Quantity, v.Quantity, inventory.InitialStock)); err != nil {
+			return err
+		}
+	}
+	return s.setValues(ctx, v.ID, v.Options)
+}
+
+func (s *Store) UpdateVariant(ctx context.Context, v *types.ProductVariant) (err error) {
+	const query = "UPDATE product_variants SET sku = ?, price = ?, image = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.UpdateVariant", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.UpdateVariant", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), v.SKU, v.Price, v.ImageURL, v.ID)
+	if db.IsUniqueViolation(err) {
+		return skuTaken(v.SKU).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL 在值没有变化时 RowsAffected 也是 0
+		vs, err := s.query(ctx, "SELECT "+variantColumns+" FROM product_variants WHERE id = ?", v.ID)
+		if err != nil {
+			return err
+		}
+		if len(vs) == 0 {
+			return variantNotFound(fmt.Sprintf("variant ID %d not found", v.ID))
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM variant_options WHERE variant_id = ?"), v.ID); err != nil {
+		return err
+	}
+	return s.setValues(ctx, v.ID, v.Options)
+}
+
+// AdjustVariantStock 把变体的库存增加 delta 并返回调整后的库存，产品的总库存同步调整，并追加一条库存流水
+// 调整后库存为负时不做修改，返回 insufficient_stock；delta 为 0 时不记流水
+func (s *Store) AdjustVariantStock(ctx context.Context, variantID, delta int, change types.StockChange) (_ int, err error) {
+	const query = "UPDATE product_variants SET quantity = quantity + ? WHERE id = ? AND quantity + ? >= 0"
+	ctx, span := tracing.StartStoreSpan(ctx, "variant.Store.AdjustVariantStock", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "variant.Store.AdjustVariantStock", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), delta, variantID, delta)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	var productID, quantity int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT product_id, quantity FROM product_variants WHERE id = ?"), variantID).
+		Scan(&productID, &quantity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, variantNotFound(fmt.Sprintf("variant ID %d not found", variantID))
+	}
+	if err != nil {
+		return 0, err
+	}
+	// delta 为 0 时 MySQL 的 RowsAffected 也是 0，此时变体存在就算成功
+	if n == 0 && delta != 0 {
+		return 0, apperr.Conflict("insufficient_stock", fmt.Sprintf("insufficient stock for variant ID %d", variantID))
+	}
+
+	if delta != 0 {
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind("UPDATE products SET quantity = quantity + ? WHERE id = ?"), delta, productID); err != nil {
+			return 0, err
+		}
+		if err := inventory.Record(ctx, s.db, s.dialect, inventory.Movement(productID, variantID, delta, quantity, change)); err != nil {
+			return 0, err
+		}
+	}
+	return quantity, nil
+}
+
+// DefaultSKU 产品默认变体的 SKU，与迁移中为已有产品生成的一致
+func DefaultSKU(productID int) string {
+	return fmt.Sprintf("SKU-%d", productID)
+}
+
+// SoleVariantID 返回只有一个变体的产品的变体ID，用于兼容只传产品ID的调用
+// 产品不存在时返回 product_not_found，有多个变体时返回 variant_required
+func SoleVariantID(ctx context.Context, q db.Querier, dialect db.Dialect, productID int) (int, error) {
+	rows, err := q.QueryContext(ctx, dialect.Rebind("SELECT id FROM product_variants WHERE product_id = ? ORDER BY id LIMIT 2"), productID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, VariantRequired(productID)
+	}
+}
+
+// VariantRequired 产品有多个变体却没有指定变体时返回的错误
+func VariantRequired(productID int) *apperr.Error {
+	return apperr.Validation("variant_required", fmt.Sprintf("product ID %d has several variants, a variant ID is required", productID))
+}
+
+// 查询变体并填充规格取值
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]types.ProductVariant, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []types.ProductVariant{}
+	for rows.Next() {
+		var (
+			v     types.ProductVariant
+			price sql.NullFloat64
+		)
+		if err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &price, &v.Quantity, &v.ImageURL, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			v.Price = &price.Float64
+		}
+		v.Options = map[string]string{}
+		variants = append(variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return variants, nil
+	}
+
+	// 再一次性查出这些变体的规格取值
+	index := make(map[int]int, len(variants))
+	ids := make([]any, len(variants))
+	for i, v := range variants {
+		index[v.ID] = i
+		ids[i] = v.ID
+	}
+	optRows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		"SELECT variant_id, name, value FROM variant_options WHERE variant_id IN ("+db.Placeholders(len(ids))+")"), ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer optRows.Close()
+	for optRows.Next() {
+		var (
+			id          int
+			name, value string
+		)
+		if err := optRows.Scan(&id, &name, &value); err != nil {
+			return nil, err
+		}
+		variants[index[id]].Options[name] = value
+	}
+	return variants, optRows.Err()
+}
+
+func (s *Store) setValues(ctx context.Context, variantID int, options map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		_, err := s.db.ExecContext(ctx, s.dialect.Rebind("INSERT INTO variant_options (variant_id, name, value) VALUES (?, ?, ?)"),
+			variantID, name, options[name])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 产品不存在时返回 product_not_found，而不是让外键约束报出数据库错误
+func (s *Store) checkProduct(ctx context.Context, productID int) error {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM products WHERE id = ?"), productID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", productID))
+	}
+	return nil
+}
+
+// 返回第一个重复的名称，没有重复时返回空字符串
+func duplicate(names []string) string {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			return name
+		}
+		seen[name] = true
+	}
+	return ""
+}
+
+func variantNotFound(message string) *apperr.Error {
+	return apperr.NotFound("variant_not_found", message)
+}
+
+func skuTaken(sku string) *apperr.Error {
+	return apperr.Conflict("sku_taken", fmt.Sprintf("SKU %q already exists", sku))
+}
@@ -0,0 +1,172 @@
+package wishlist
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Albert-tru/ecom/service/auth"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/Albert-tru/ecom/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	stores types.Stores
+}
+
+func NewHandler(stores types.Stores) *Handler {
+	return &Handler{stores: stores}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/wishlists", auth.WithJWTAuth(h.handleList, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/me/wishlists", auth.WithJWTAuth(h.handleCreate, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}", auth.WithJWTAuth(h.handleGet, h.stores.Users)).Methods("GET")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}", auth.WithJWTAuth(h.handleDelete, h.stores.Users)).Methods("DELETE")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}/items", auth.WithJWTAuth(h.handleAddItem, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}/items/{itemId:[0-9]+}", auth.WithJWTAuth(h.handleRemoveItem, h.stores.Users)).Methods("DELETE")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}/items/{itemId:[0-9]+}/move-to-cart", auth.WithJWTAuth(h.handleMoveToCart, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}/share", auth.WithJWTAuth(h.handleShare, h.stores.Users)).Methods("POST")
+	router.HandleFunc("/me/wishlists/{id:[0-9]+}/share", auth.WithJWTAuth(h.handleUnshare, h.stores.Users)).Methods("DELETE")
+
+	// 分享链接不需要登录
+	router.HandleFunc("/wishlists/shared/{token:[A-Za-z0-9_-]+}", h.handleShared).Methods("GET")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	ws, err := List(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ws)
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var payload types.WishlistPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	wl, err := Create(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), payload.Name)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, wl)
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	// 路由的正则保证了 id 是数字
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	wl, err := Get(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, wl)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := Delete(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleAddItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var payload types.WishlistItemPayload
+	if err := utils.ParseJson(w, r, &payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := utils.ValidatePayload(r, payload); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	item, err := AddItem(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id, payload)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, item)
+}
+
+func (h *Handler) handleRemoveItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	itemID, _ := strconv.Atoi(mux.Vars(r)["itemId"])
+
+	if err := RemoveItem(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id, itemID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 请求体可以省略，默认移动 1 件
+func (h *Handler) handleMoveToCart(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	itemID, _ := strconv.Atoi(mux.Vars(r)["itemId"])
+
+	var payload types.MoveToCartPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJson(w, r, &payload); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		if err := utils.ValidatePayload(r, payload); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	item, err := MoveToCart(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id, itemID, payload.Quantity)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, item)
+}
+
+func (h *Handler) handleShare(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	wl, err := Share(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, wl)
+}
+
+func (h *Handler) handleUnshare(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	wl, err := Unshare(r.Context(), h.stores, auth.GetUserIDFromContext(r.Context()), id)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, wl)
+}
+
+func (h *Handler) handleShared(w http.ResponseWriter, r *http.Request) {
+	wl, err := Shared(r.Context(), h.stores, mux.Vars(r)["token"])
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, wl)
+}
@@ -0,0 +1,259 @@
+package wishlist_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apitest"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/wishlist"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/gorilla/mux"
+)
+
+// recorder 记录收到的通知
+type recorder struct{ events []notify.Event }
+
+func (r *recorder) Notify(_ context.Context, e notify.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestWishlistRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(
+		types.Product{Name: "lamp", Price: 15, Quantity: 2},
+		types.Product{Name: "t-shirt", Price: 20, Quantity: 0},
+	)
+	// 第二个产品有两个变体，都没货
+	blue := &types.ProductVariant{ProductID: 2, SKU: "TS-BLUE", Options: map[string]string{"colour": "blue"}}
+	if err := store.CreateVariant(ctx, blue); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	wishlist.NewHandler(store.Stores()).RegisterRoutes(router)
+
+	owner := &types.User{Email: "owner@example.com"}
+	other := &types.User{Email: "other@example.com"}
+	for _, u := range []*types.User{owner, other} {
+		if err := store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := apitest.New(router)
+
+	var list types.Wishlist
+	var lampItem, shirtItem types.WishlistItem
+	t.Run("创建心愿单并加入产品", func(t *testing.T) {
+		apitest.Decode(t, api.Do(owner, http.MethodPost, "/me/wishlists", `{"name":"birthday"}`), http.StatusCreated, &list)
+		if list.ID == 0 || list.UserID != owner.ID || list.Name != "birthday" || len(list.Items) != 0 {
+			t.Fatalf("unexpected wishlist: %+v", list)
+		}
+		if rr := api.Do(owner, http.MethodPost, "/me/wishlists", `{"name":"birthday"}`); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		if rr := api.Do(owner, http.MethodPost, "/me/wishlists", `{"name":""}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+
+		path := "/me/wishlists/" + strconv.Itoa(list.ID) + "/items"
+		apitest.Decode(t, api.Do(owner, http.MethodPost, path, `{"productId":1}`), http.StatusCreated, &lampItem)
+		if lampItem.ProductID != 1 || lampItem.VariantID != nil || lampItem.Product == nil || lampItem.Product.Name != "lamp" {
+			t.Errorf("unexpected item: %+v", lampItem)
+		}
+		apitest.Decode(t, api.Do(owner, http.MethodPost, path, `{"productId":2}`), http.StatusCreated, &shirtItem)
+
+		if rr := api.Do(owner, http.MethodPost, path, `{"productId":1}`); rr.Code != http.StatusConflict {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+		if rr := api.Do(owner, http.MethodPost, path, `{"productId":99}`); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := api.Do(owner, http.MethodPost, path, `{"productId":1,"variantId":`+strconv.Itoa(blue.ID)+`}`); rr.Code != http.StatusNotFound {
+			t.Errorf("variant of another product: 期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+
+		var ws []types.Wishlist
+		apitest.Decode(t, api.Do(owner, http.MethodGet, "/me/wishlists", ""), http.StatusOK, &ws)
+		if len(ws) != 1 || len(ws[0].Items) != 2 || ws[0].Items[1].Product.Name != "t-shirt" {
+			t.Errorf("unexpected wishlists: %+v", ws)
+		}
+	})
+
+	t.Run("别人的心愿单视为不存在", func(t *testing.T) {
+		path := "/me/wishlists/" + strconv.Itoa(list.ID)
+		for _, rr := range []*httptest.ResponseRecorder{
+			api.Do(other, http.MethodGet, path, ""),
+			api.Do(other, http.MethodDelete, path, ""),
+			api.Do(other, http.MethodPost, path+"/items", `{"productId":1}`),
+			api.Do(other, http.MethodPost, path+"/share", ""),
+		} {
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+			}
+		}
+		if rr := api.Do(nil, http.MethodGet, "/me/wishlists", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("分享链接", func(t *testing.T) {
+		path := "/me/wishlists/" + strconv.Itoa(list.ID) + "/share"
+		var shared types.Wishlist
+		apitest.Decode(t, api.Do(owner, http.MethodPost, path, ""), http.StatusOK, &shared)
+		if len(shared.ShareToken) != 32 {
+			t.Fatalf("unexpected share token %q", shared.ShareToken)
+		}
+
+		var viewed types.Wishlist
+		apitest.Decode(t, api.Do(nil, http.MethodGet, "/wishlists/shared/"+shared.ShareToken, ""), http.StatusOK, &viewed)
+		if viewed.ID != list.ID || len(viewed.Items) != 2 || viewed.Items[0].Product == nil {
+			t.Errorf("unexpected shared wishlist: %+v", viewed)
+		}
+
+		// 重新生成后旧链接失效，关闭分享后新链接也失效
+		var rotated types.Wishlist
+		apitest.Decode(t, api.Do(owner, http.MethodPost, path, ""), http.StatusOK, &rotated)
+		if rr := api.Do(nil, http.MethodGet, "/wishlists/shared/"+shared.ShareToken, ""); rr.Code != http.StatusNotFound || rotated.ShareToken == shared.ShareToken {
+			t.Errorf("expected the old link to stop working, got %d", rr.Code)
+		}
+		var closed types.Wishlist
+		apitest.Decode(t, api.Do(owner, http.MethodDelete, path, ""), http.StatusOK, &closed)
+		if rr := api.Do(nil, http.MethodGet, "/wishlists/shared/"+rotated.ShareToken, ""); rr.Code != http.StatusNotFound || closed.ShareToken != "" {
+			t.Errorf("expected the link to be revoked, got %d", rr.Code)
+		}
+	})
+
+	t.Run("到货和降价时通知", func(t *testing.T) {
+		n := &recorder{}
+		if sent, err := wishlist.Check(ctx, store.Stores(), n); err != nil || sent != 0 {
+			t.Fatalf("expected no notifications, got %d %v", sent, err)
+		}
+
+		// t-shirt 的蓝色变体到货，台灯降价
+		if _, err := store.AdjustVariantStock(ctx, blue.ID, 3, types.StockChange{Type: types.StockRestock, Reason: "restock"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateProduct(ctx, &types.Product{ID: 1, Name: "lamp", Price: 12}); err != nil {
+			t.Fatal(err)
+		}
+		sent, err := wishlist.Check(ctx, store.Stores(), n)
+		if err != nil || sent != 2 {
+			t.Fatalf("expected 2 notifications, got %d %v", sent, err)
+		}
+		alerts := map[string]wishlist.Alert{}
+		for _, e := range n.events {
+			alerts[e.Type] = e.Data.(wishlist.Alert)
+		}
+		if a := alerts[wishlist.EventPriceDrop]; a.ItemID != lampItem.ID || a.UserID != owner.ID || a.Recipient() != owner.Email || a.OldPrice != 15 || a.Price != 12 {
+			t.Errorf("unexpected price drop: %+v", a)
+		}
+		if a := alerts[wishlist.EventBackInStock]; a.ItemID != shirtItem.ID || a.ProductName != "t-shirt" {
+			t.Errorf("unexpected back in stock: %+v", a)
+		}
+
+		// 状态已经记录，不会重复通知
+		if sent, err := wishlist.Check(ctx, store.Stores(), n); err != nil || sent != 0 {
+			t.Errorf("expected no repeated notifications, got %d %v", sent, err)
+		}
+	})
+
+	t.Run("移到购物车", func(t *testing.T) {
+		base := "/me/wishlists/" + strconv.Itoa(list.ID) + "/items/"
+		shirt := base + strconv.Itoa(shirtItem.ID) + "/move-to-cart"
+		if rr := api.Do(owner, http.MethodPost, shirt, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("multiple variants: 期望状态码 %d, 实际状态码 %d", http.StatusBadRequest, rr.Code)
+		}
+		lamp := base + strconv.Itoa(lampItem.ID) + "/move-to-cart"
+		if rr := api.Do(owner, http.MethodPost, lamp, `{"quantity":3}`); rr.Code != http.StatusConflict {
+			t.Errorf("insufficient stock: 期望状态码 %d, 实际状态码 %d", http.StatusConflict, rr.Code)
+		}
+
+		var item types.CartItem
+		apitest.Decode(t, api.Do(owner, http.MethodPost, lamp, `{"quantity":2}`), http.StatusOK, &item)
+		if item.ProductID != 1 || item.VariantID == 0 || item.Quantity != 2 {
+			t.Errorf("unexpected cart item: %+v", item)
+		}
+		// 移到购物车后从心愿单中移除
+		if rr := api.Do(owner, http.MethodPost, lamp, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("移除产品和删除心愿单", func(t *testing.T) {
+		path := "/me/wishlists/" + strconv.Itoa(list.ID)
+		if rr := api.Do(owner, http.MethodDelete, path+"/items/"+strconv.Itoa(shirtItem.ID), ""); rr.Code != http.StatusNoContent {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNoContent, rr.Code)
+		}
+		var w types.Wishlist
+		apitest.Decode(t, api.Do(owner, http.MethodGet, path, ""), http.StatusOK, &w)
+		if len(w.Items) != 0 {
+			t.Errorf("expected an empty wishlist, got %+v", w.Items)
+		}
+		if rr := api.Do(owner, http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNoContent, rr.Code)
+		}
+		if rr := api.Do(owner, http.MethodGet, path, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// failOn 对指定类型的事件返回错误，其它事件照常记录
+type failOn struct {
+	recorder
+	eventType string
+}
+
+func (f *failOn) Notify(ctx context.Context, e notify.Event) error {
+	if e.Type == f.eventType {
+		return errors.New("smtp unavailable")
+	}
+	return f.recorder.Notify(ctx, e)
+}
+
+func TestCheckRecordsEachNotifiedChange(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New(types.Product{Name: "lamp", Price: 15, Quantity: 0})
+	owner := &types.User{Email: "owner@example.com"}
+	if err := store.CreateUser(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	list := &types.Wishlist{UserID: owner.ID, Name: "later"}
+	if err := store.CreateWishlist(ctx, list); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddWishlistItem(ctx, &types.WishlistItem{WishlistID: list.ID, ProductID: 1, LastPrice: 15}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同时到货和降价，降价通知失败
+	if _, err := store.AdjustStock(ctx, 1, 2, types.StockChange{Type: types.StockRestock, Reason: "restock"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateProduct(ctx, &types.Product{ID: 1, Name: "lamp", Price: 12}); err != nil {
+		t.Fatal(err)
+	}
+	n := &failOn{eventType: wishlist.EventPriceDrop}
+	if sent, err := wishlist.Check(ctx, store.Stores(), n); err == nil || sent != 1 {
+		t.Fatalf("expected the price drop to fail after one notification, got %d %v", sent, err)
+	}
+
+	// 下次检查只重试降价，不再重发到货通知
+	n.eventType = ""
+	if sent, err := wishlist.Check(ctx, store.Stores(), n); err != nil || sent != 1 {
+		t.Fatalf("expected one retried notification, got %d %v", sent, err)
+	}
+	if len(n.events) != 2 || n.events[0].Type != wishlist.EventBackInStock || n.events[1].Type != wishlist.EventPriceDrop {
+		t.Errorf("expected each change to be notified once, got %+v", n.events)
+	}
+	if sent, err := wishlist.Check(ctx, store.Stores(), n); err != nil || sent != 0 {
+		t.Errorf("expected no repeated notifications, got %d %v", sent, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package wishlist 心愿单：用户可以建多个命名的心愿单收藏产品，通过分享链接公开，
+// 把产品移到购物车，并在收藏的产品到货或降价时发出通知
+package wishlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// List 返回用户的所有心愿单
+func List(ctx context.Context, stores types.Stores, userID int) ([]types.Wishlist, error) {
+	ws, err := stores.Wishlists.GetWishlists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillProducts(ctx, stores, ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Get 返回用户自己的心愿单，别人的心愿单视为不存在
+func Get(ctx context.Context, stores types.Stores, userID, id int) (*types.Wishlist, error) {
+	w, err := owned(ctx, stores, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillProducts(ctx, stores, []types.Wishlist{*w}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Shared 通过分享链接的 token 查看心愿单
+func Shared(ctx context.Context, stores types.Stores, token string) (*types.Wishlist, error) {
+	w, err := stores.Wishlists.GetWishlistByShareToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillProducts(ctx, stores, []types.Wishlist{*w}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func Create(ctx context.Context, stores types.Stores, userID int, name string) (*types.Wishlist, error) {
+	w := &types.Wishlist{UserID: userID, Name: name}
+	if err := stores.Wishlists.CreateWishlist(ctx, w); err != nil {
+		return nil, err
+	}
+	return stores.Wishlists.GetWishlist(ctx, w.ID)
+}
+
+func Delete(ctx context.Context, stores types.Stores, userID, id int) error {
+	return stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if _, err := owned(ctx, tx, userID, id); err != nil {
+			return err
+		}
+		return tx.Wishlists.DeleteWishlist(ctx, id)
+	})
+}
+
+// Share 生成新的分享 token，之前的分享链接随之失效
+func Share(ctx context.Context, stores types.Stores, userID, id int) (*types.Wishlist, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	return setShareToken(ctx, stores, userID, id, token)
+}
+
+// Unshare 关闭分享，分享链接随之失效
+func Unshare(ctx context.Context, stores types.Stores, userID, id int) (*types.Wishlist, error) {
+	return setShareToken(ctx, stores, userID, id, "")
+}
+
+// AddItem 把产品加入心愿单；指定 variantId 时收藏的是这个变体，否则是整个产品
+func AddItem(ctx context.Context, stores types.Stores, userID, wishlistID int, payload types.WishlistItemPayload) (*types.WishlistItem, error) {
+	item := &types.WishlistItem{WishlistID: wishlistID, ProductID: payload.ProductID}
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if _, err := owned(ctx, tx, userID, wishlistID); err != nil {
+			return err
+		}
+		if payload.VariantID > 0 {
+			vs, err := tx.Variants.GetVariantsByIDs(ctx, []int{payload.VariantID})
+			if err != nil {
+				return err
+			}
+			if len(vs) == 0 || payload.ProductID != 0 && vs[0].ProductID != payload.ProductID {
+				return apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d not found", payload.VariantID))
+			}
+			item.ProductID = vs[0].ProductID
+			item.VariantID = &vs[0].ID
+		}
+
+		st, err := currentState(ctx, tx, item.ProductID, item.VariantID)
+		if err != nil {
+			return err
+		}
+		item.LastPrice, item.LastInStock = st.price, st.inStock
+		if err := tx.Wishlists.AddWishlistItem(ctx, item); err != nil {
+			return err
+		}
+		item.Product = &st.product
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func RemoveItem(ctx context.Context, stores types.Stores, userID, wishlistID, itemID int) error {
+	return stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if _, err := owned(ctx, tx, userID, wishlistID); err != nil {
+			return err
+		}
+		return tx.Wishlists.RemoveWishlistItem(ctx, wishlistID, itemID)
+	})
+}
+
+// MoveToCart 检查库存后把产品从心愿单中移除，返回可以直接放进结账请求的购物车项
+// 收藏的是有多个变体的整个产品时需要先选择变体（variant_required）
+func MoveToCart(ctx context.Context, stores types.Stores, userID, wishlistID, itemID, quantity int) (*types.CartItem, error) {
+	if quantity == 0 {
+		quantity = 1
+	}
+	var cartItem *types.CartItem
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		w, err := owned(ctx, tx, userID, wishlistID)
+		if err != nil {
+			return err
+		}
+		var item *types.WishlistItem
+		for i := range w.Items {
+			if w.Items[i].ID == itemID {
+				item = &w.Items[i]
+			}
+		}
+		if item == nil {
+			return itemNotFound(itemID)
+		}
+
+		var v types.ProductVariant
+		if item.VariantID != nil {
+			vs, err := tx.Variants.GetVariantsByIDs(ctx, []int{*item.VariantID})
+			if err != nil {
+				return err
+			}
+			if len(vs) == 0 {
+				return apperr.NotFound("variant_not_found", fmt.Sprintf("variant ID %d not found", *item.VariantID))
+			}
+			v = vs[0]
+		} else {
+			vs, err := tx.Variants.GetVariants(ctx, item.ProductID)
+			if err != nil {
+				return err
+			}
+			switch len(vs) {
+			case 0:
+				return productNotFound(item.ProductID)
+			case 1:
+				v = vs[0]
+			default:
+				return variant.VariantRequired(item.ProductID)
+			}
+		}
+		if v.Quantity < quantity {
+			return apperr.Conflict("insufficient_stock", fmt.Sprintf("insufficient stock for product ID %d (SKU %s)", v.ProductID, v.SKU))
+		}
+
+		if err := tx.Wishlists.RemoveWishlistItem(ctx, wishlistID, itemID); err != nil {
+			return err
+		}
+		cartItem = &types.CartItem{ProductID: v.ProductID, VariantID: v.ID, Quantity: quantity}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cartItem, nil
+}
+
+func setShareToken(ctx context.Context, stores types.Stores, userID, id int, token string) (*types.Wishlist, error) {
+	var w *types.Wishlist
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		if _, err := owned(ctx, tx, userID, id); err != nil {
+			return err
+		}
+		if err := tx.Wishlists.SetShareToken(ctx, id, token); err != nil {
+			return err
+		}
+		var err error
+		w, err = tx.Wishlists.GetWishlist(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := fillProducts(ctx, stores, []types.Wishlist{*w}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// owned 返回属于该用户的心愿单，别人的心愿单和不存在的一样返回 wishlist_not_found
+func owned(ctx context.Context, stores types.Stores, userID, id int) (*types.Wishlist, error) {
+	w, err := stores.Wishlists.GetWishlist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.UserID != userID {
+		return nil, wishlistNotFound(id)
+	}
+	return w, nil
+}
+
+// fillProducts 填充心愿单中每一项的产品信息；ws 中的 Items 与调用方共享底层数组，填充后调用方可以直接看到
+func fillProducts(ctx context.Context, stores types.Stores, ws []types.Wishlist) error {
+	var ids []int
+	for _, w := range ws {
+		for _, item := range w.Items {
+			ids = append(ids, item.ProductID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	ps, err := stores.Products.GetProductByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	products := make(map[int]*types.Product, len(ps))
+	for i := range ps {
+		products[ps[i].ID] = &ps[i]
+	}
+	for _, w := range ws {
+		for i := range w.Items {
+			w.Items[i].Product = products[w.Items[i].ProductID]
+		}
+	}
+	return nil
+}
+
+// newShareToken 生成 32 个字符的随机 token，无法被猜到或枚举
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func productNotFound(id int) error {
+	return apperr.NotFound("product_not_found", fmt.Sprintf("product ID %d not found", id))
+}
@@ -0,0 +1,315 @@
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/tracing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 查询心愿单和心愿单产品时的列，顺序与 Scan 一致
+const (
+	wishlistColumns = "id, user_id, name, share_token, createdat"
+	itemColumns     = "id, wishlist_id, product_id, variant_id, last_price, last_in_stock, createdat"
+)
+
+type Store struct {
+	db      db.Querier // *sql.DB，或者事务中的 *sql.Tx
+	dialect db.Dialect
+}
+
+func NewStore(db db.Querier, dialect db.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) GetWishlists(ctx context.Context, userID int) (_ []types.Wishlist, err error) {
+	const query = "SELECT " + wishlistColumns + " FROM wishlists WHERE user_id = ? ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.GetWishlists", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.GetWishlists", db.Read)
+	defer cancel()
+
+	return s.query(ctx, query, userID)
+}
+
+func (s *Store) GetWishlist(ctx context.Context, id int) (_ *types.Wishlist, err error) {
+	const query = "SELECT " + wishlistColumns + " FROM wishlists WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.GetWishlist", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.GetWishlist", db.Read)
+	defer cancel()
+
+	ws, err := s.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ws) == 0 {
+		return nil, wishlistNotFound(id)
+	}
+	return &ws[0], nil
+}
+
+func (s *Store) GetWishlistByShareToken(ctx context.Context, token string) (_ *types.Wishlist, err error) {
+	const query = "SELECT " + wishlistColumns + " FROM wishlists WHERE share_token = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.GetWishlistByShareToken", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.GetWishlistByShareToken", db.Read)
+	defer cancel()
+
+	ws, err := s.query(ctx, query, token)
+	if err != nil {
+		return nil, err
+	}
+	if len(ws) == 0 {
+		return nil, SharedWishlistNotFound()
+	}
+	return &ws[0], nil
+}
+
+func (s *Store) CreateWishlist(ctx context.Context, w *types.Wishlist) (err error) {
+	const query = "INSERT INTO wishlists (user_id, name) VALUES (?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.CreateWishlist", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.CreateWishlist", db.Write)
+	defer cancel()
+
+	id, err := s.dialect.InsertID(ctx, s.db, query, w.UserID, w.Name)
+	if db.IsUniqueViolation(err) {
+		return WishlistExists(w.Name).WithCause(err)
+	}
+	if err != nil {
+		return err
+	}
+	w.ID = int(id)
+	w.ShareToken = ""
+	w.Items = []types.WishlistItem{}
+	return nil
+}
+
+// DeleteWishlist 删除心愿单，其中的产品通过外键级联删除
+func (s *Store) DeleteWishlist(ctx context.Context, id int) (err error) {
+	const query = "DELETE FROM wishlists WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.DeleteWishlist", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.DeleteWishlist", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return wishlistNotFound(id)
+	}
+	return nil
+}
+
+func (s *Store) SetShareToken(ctx context.Context, id int, token string) (err error) {
+	const query = "UPDATE wishlists SET share_token = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.SetShareToken", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.SetShareToken", db.Write)
+	defer cancel()
+
+	// 关闭分享时写入 NULL，唯一索引允许多个 NULL
+	var value sql.NullString
+	if token != "" {
+		value = sql.NullString{String: token, Valid: true}
+	}
+	if err := s.exists(ctx, id); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind(query), value, id)
+	return err
+}
+
+// AddWishlistItem 加入心愿单，调用方应该把它放在事务中，保证同一个产品（变体）不会加入两次
+func (s *Store) AddWishlistItem(ctx context.Context, item *types.WishlistItem) (err error) {
+	const query = "INSERT INTO wishlist_items (wishlist_id, product_id, variant_id, last_price, last_in_stock) VALUES (?, ?, ?, ?, ?)"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.AddWishlistItem", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.AddWishlistItem", db.Write)
+	defer cancel()
+
+	if err := s.exists(ctx, item.WishlistID); err != nil {
+		return err
+	}
+	// variant_id 可以为空，唯一索引对 NULL 不生效，所以在这里检查重复
+	var (
+		variantID sql.NullInt64
+		count     int
+	)
+	dup := "SELECT COUNT(*) FROM wishlist_items WHERE wishlist_id = ? AND product_id = ? AND variant_id IS NULL"
+	args := []any{item.WishlistID, item.ProductID}
+	if item.VariantID != nil {
+		variantID = sql.NullInt64{Int64: int64(*item.VariantID), Valid: true}
+		dup = "SELECT COUNT(*) FROM wishlist_items WHERE wishlist_id = ? AND product_id = ? AND variant_id = ?"
+		args = append(args, variantID)
+	}
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind(dup), args...).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ItemExists(item.ProductID)
+	}
+
+	id, err := s.dialect.InsertID(ctx, s.db, query, item.WishlistID, item.ProductID, variantID, item.LastPrice, item.LastInStock)
+	if err != nil {
+		return err
+	}
+	item.ID = int(id)
+	return nil
+}
+
+func (s *Store) RemoveWishlistItem(ctx context.Context, wishlistID, itemID int) (err error) {
+	const query = "DELETE FROM wishlist_items WHERE id = ? AND wishlist_id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.RemoveWishlistItem", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.RemoveWishlistItem", db.Write)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(query), itemID, wishlistID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return itemNotFound(itemID)
+	}
+	return nil
+}
+
+func (s *Store) GetAllWishlistItems(ctx context.Context) (_ []types.WishlistItem, err error) {
+	const query = "SELECT " + itemColumns + " FROM wishlist_items ORDER BY id"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.GetAllWishlistItems", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.GetAllWishlistItems", db.Read)
+	defer cancel()
+
+	return s.queryItems(ctx, query)
+}
+
+func (s *Store) UpdateWishlistItemState(ctx context.Context, itemID int, price float64, inStock bool) (err error) {
+	const query = "UPDATE wishlist_items SET last_price = ?, last_in_stock = ? WHERE id = ?"
+	ctx, span := tracing.StartStoreSpan(ctx, "wishlist.Store.UpdateWishlistItemState", query)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := db.WithTimeout(ctx, "wishlist.Store.UpdateWishlistItemState", db.Write)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, s.dialect.Rebind(query), price, inStock, itemID)
+	return err
+}
+
+func (s *Store) exists(ctx context.Context, id int) error {
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT COUNT(*) FROM wishlists WHERE id = ?"), id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return wishlistNotFound(id)
+	}
+	return nil
+}
+
+// 查询心愿单并填充其中的产品
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]types.Wishlist, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ws := []types.Wishlist{}
+	for rows.Next() {
+		var (
+			w     types.Wishlist
+			token sql.NullString
+		)
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &token, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		w.ShareToken = token.String
+		w.Items = []types.WishlistItem{}
+		ws = append(ws, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ws) == 0 {
+		return ws, nil
+	}
+
+	// 再一次性查出这些心愿单中的产品
+	index := make(map[int]int, len(ws))
+	ids := make([]any, len(ws))
+	for i, w := range ws {
+		index[w.ID] = i
+		ids[i] = w.ID
+	}
+	items, err := s.queryItems(ctx, "SELECT "+itemColumns+" FROM wishlist_items WHERE wishlist_id IN ("+
+		db.Placeholders(len(ids))+") ORDER BY id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		w := &ws[index[item.WishlistID]]
+		w.Items = append(w.Items, item)
+	}
+	return ws, nil
+}
+
+func (s *Store) queryItems(ctx context.Context, query string, args ...any) ([]types.WishlistItem, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.WishlistItem{}
+	for rows.Next() {
+		var (
+			item      types.WishlistItem
+			variantID sql.NullInt64
+		)
+		if err := rows.Scan(&item.ID, &item.WishlistID, &item.ProductID, &variantID, &item.LastPrice,
+			&item.LastInStock, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			id := int(variantID.Int64)
+			item.VariantID = &id
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// WishlistExists 同一个用户的心愿单名称不能重复
+func WishlistExists(name string) *apperr.Error {
+	return apperr.Conflict("wishlist_exists", fmt.Sprintf("a wishlist named %q already exists", name))
+}
+
+// ItemExists 同一个产品（变体）在一个心愿单中只能出现一次
+func ItemExists(productID int) *apperr.Error {
+	return apperr.Conflict("wishlist_item_exists", fmt.Sprintf("product ID %d is already in the wishlist", productID))
+}
+
+// SharedWishlistNotFound 分享链接不存在或已关闭，不透露心愿单 ID
+func SharedWishlistNotFound() *apperr.Error {
+	return apperr.NotFound("wishlist_not_found", "shared wishlist not found")
+}
+
+func wishlistNotFound(id int) *apperr.Error {
+	return apperr.NotFound("wishlist_not_found", fmt.Sprintf("wishlist ID %d not found", id))
+}
+
+func itemNotFound(id int) *apperr.Error {
+	return apperr.NotFound("wishlist_item_not_found", fmt.Sprintf("wishlist item ID %d not found", id))
+}
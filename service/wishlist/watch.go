@@ -0,0 +1,165 @@
+package wishlist
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Albert-tru/ecom/notify"
+	"github.com/Albert-tru/ecom/service/pricing"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// 心愿单通知的事件类型
+const (
+	EventBackInStock = "wishlist.back_in_stock"
+	EventPriceDrop   = "wishlist.price_drop"
+)
+
+// Alert 通知事件的数据，OldPrice 只在降价时有值；发邮件时发给心愿单的主人
+type Alert struct {
+	UserID      int     `json:"userId"`
+	Email       string  `json:"email"`
+	WishlistID  int     `json:"wishlistId"`
+	ItemID      int     `json:"itemId"`
+	ProductID   int     `json:"productId"`
+	VariantID   *int    `json:"variantId,omitempty"`
+	ProductName string  `json:"productName"`
+	Price       float64 `json:"price"`
+	OldPrice    float64 `json:"oldPrice,omitempty"`
+}
+
+func (a Alert) Recipient() string { return a.Email }
+
+// state 产品（或变体）当前的售价和是否有货
+type state struct {
+	product types.Product
+	price   float64
+	inStock bool
+}
+
+// currentState 收藏的是变体时使用变体的售价和库存；收藏整个产品时使用各个变体中最低的售价，任意变体有货即有货
+func currentState(ctx context.Context, stores types.Stores, productID int, variantID *int) (state, error) {
+	ps, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+	if err != nil {
+		return state{}, err
+	}
+	if len(ps) == 0 {
+		return state{}, productNotFound(productID)
+	}
+	st := state{product: ps[0], price: ps[0].Price, inStock: ps[0].Quantity > 0}
+
+	var vs []types.ProductVariant
+	if variantID != nil {
+		vs, err = stores.Variants.GetVariantsByIDs(ctx, []int{*variantID})
+	} else {
+		vs, err = stores.Variants.GetVariants(ctx, productID)
+	}
+	if err != nil {
+		return state{}, err
+	}
+	if variantID != nil {
+		st.inStock = len(vs) > 0 && vs[0].Quantity > 0
+	}
+	for i, v := range vs {
+		price, _ := pricing.VariantPrice(st.product, v)
+		if i == 0 || price < st.price {
+			st.price = price
+		}
+	}
+	return st, nil
+}
+
+// Check 检查所有心愿单中的产品，与上次记录的状态相比到货或降价时发出通知，返回发出的通知数
+// 通知失败的变化不记录，下次检查时重试；其它状态变化（涨价、缺货）只记录不通知
+func Check(ctx context.Context, stores types.Stores, n notify.Notifier) (int, error) {
+	items, err := stores.Wishlists.GetAllWishlistItems(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	owners := map[int]*types.User{} // 心愿单ID -> 心愿单的主人
+	sent := 0
+	var firstErr error
+	for _, item := range items {
+		st, err := currentState(ctx, stores, item.ProductID, item.VariantID)
+		if err != nil {
+			return sent, err
+		}
+		if st.price == item.LastPrice && st.inStock == item.LastInStock {
+			continue
+		}
+
+		if _, ok := owners[item.WishlistID]; !ok {
+			w, err := stores.Wishlists.GetWishlist(ctx, item.WishlistID)
+			if err != nil {
+				return sent, err
+			}
+			u, err := stores.Users.GetUserByID(ctx, w.UserID)
+			if err != nil {
+				return sent, err
+			}
+			owners[item.WishlistID] = u
+		}
+		alert := Alert{
+			UserID:      owners[item.WishlistID].ID,
+			Email:       owners[item.WishlistID].Email,
+			WishlistID:  item.WishlistID,
+			ItemID:      item.ID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			ProductName: st.product.Name,
+			Price:       st.price,
+		}
+		// 每发出一个通知就记下对应的变化，后面的通知失败时前面的不会在下次检查时重发
+		price, inStock := item.LastPrice, item.LastInStock
+		now := time.Now().UTC()
+		failed := false
+		send := func(e notify.Event) bool {
+			if err := n.Notify(ctx, e); err != nil {
+				failed = true
+				if firstErr == nil {
+					firstErr = err
+				}
+				return false
+			}
+			sent++
+			return true
+		}
+		if st.inStock && !item.LastInStock && send(notify.Event{Type: EventBackInStock, Time: now, Data: alert}) {
+			inStock = true
+		}
+		if !failed && st.price < item.LastPrice {
+			alert.OldPrice = item.LastPrice
+			if send(notify.Event{Type: EventPriceDrop, Time: now, Data: alert}) {
+				price = st.price
+			}
+		}
+		if !failed {
+			price, inStock = st.price, st.inStock
+		}
+		if price == item.LastPrice && inStock == item.LastInStock {
+			continue
+		}
+		if err := stores.Wishlists.UpdateWishlistItemState(ctx, item.ID, price, inStock); err != nil {
+			return sent, err
+		}
+	}
+	return sent, firstErr
+}
+
+// Watch 每隔 interval 执行一次 Check，直到 ctx 结束；出错只写日志
+func Watch(ctx context.Context, stores types.Stores, n notify.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if sent, err := Check(ctx, stores, n); err != nil {
+				log.Printf("wishlist check failed after %d notifications: %v", sent, err)
+			}
+		}
+	}
+}
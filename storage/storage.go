@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/Albert-tru/ecom/config"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/category"
+	"github.com/Albert-tru/ecom/service/image"
+	"github.com/Albert-tru/ecom/service/inventory"
+	"github.com/Albert-tru/ecom/service/order"
+	"github.com/Albert-tru/ecom/service/product"
+	"github.com/Albert-tru/ecom/service/review"
+	"github.com/Albert-tru/ecom/service/search"
+	"github.com/Albert-tru/ecom/service/user"
+	"github.com/Albert-tru/ecom/service/variant"
+	"github.com/Albert-tru/ecom/service/wishlist"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Memory 表示使用内存存储，数据只在进程存活期间有效
+const Memory = "memory"
+
+// Backend 打开的存储后端
+type Backend struct {
+	types.Stores
+	DB      *sql.DB    // 内存存储时为 nil
+	Dialect db.Dialect // 内存存储时为空
+}
+
+// Name 返回存储类型，用于日志和链路追踪
+func (b *Backend) Name() string {
+	if b.DB == nil {
+		return Memory
+	}
+	return string(b.Dialect)
+}
+
+// Close 关闭数据库连接
+func (b *Backend) Close() error {
+	if b.DB == nil {
+		return nil
+	}
+	return b.DB.Close()
+}
+
+// SQL 用数据库连接创建所有 store，事务使用 db.TxDefaults 中的配置
+func SQL(conn *sql.DB, dialect db.Dialect) types.Stores {
+	return SQLWithTxOptions(conn, dialect, db.TxDefaults)
+}
+
+// SQLWithTxOptions 与 SQL 相同，但可以指定事务的隔离级别和重试策略
+func SQLWithTxOptions(conn *sql.DB, dialect db.Dialect, opts db.TxOptions) types.Stores {
+	stores := newStores(conn, dialect)
+	stores.Tx = &txManager{conn: conn, dialect: dialect, opts: opts}
+	return stores
+}
+
+func newStores(q db.Querier, dialect db.Dialect) types.Stores {
+	products := product.NewStore(q, dialect)
+	return types.Stores{
+		Users:      user.NewStore(q, dialect),
+		Products:   products,
+		Variants:   variant.NewStore(q, dialect),
+		Orders:     order.NewStore(q, dialect),
+		Categories: category.NewStore(q, dialect),
+		Images:     image.NewStore(q, dialect),
+		Reviews:    review.NewStore(q, dialect),
+		Wishlists:  wishlist.NewStore(q, dialect),
+		Stock:      inventory.NewStore(q, dialect),
+		Search:     searcher(q, dialect, products),
+	}
+}
+
+// searcher 只有 MySQL 有 FULLTEXT 索引，其它数据库使用内存中的倒排索引
+func searcher(q db.Querier, dialect db.Dialect, products types.ProductStore) types.ProductSearcher {
+	if dialect == db.MySQL {
+		return search.NewStore(q, dialect)
+	}
+	return search.NewIndex(products)
+}
+
+// Open 根据 DB_DRIVER 打开存储，driver 为 "memory" 时使用内存实现并放入演示用的产品
+func Open(cfg config.Config) (*Backend, error) {
+	if strings.EqualFold(strings.TrimSpace(cfg.DBDriver), Memory) {
+		return &Backend{Stores: memstore.New(demoProducts...).Stores()}, nil
+	}
+
+	conn, dialect, err := db.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{Stores: SQL(conn, dialect), DB: conn, Dialect: dialect}, nil
+}
+
+// 与 .http 示例文件中使用的产品ID 1、2 对应
+var demoProducts = []types.Product{
+	{Name: "Mechanical Keyboard", Description: "87-key mechanical keyboard", ImageURL: "https://example.com/keyboard.png", Price: 49.9, Quantity: 100},
+	{Name: "Wireless Mouse", Description: "2.4G wireless mouse", ImageURL: "https://example.com/mouse.png", Price: 19.5, Quantity: 100},
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/cmd/migrate/migrations"
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/memstore"
+	"github.com/Albert-tru/ecom/service/product"
+	"github.com/Albert-tru/ecom/storetest"
+	"github.com/Albert-tru/ecom/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, products []types.Product) types.Stores {
+		return memstore.New(products...).Stores()
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, products []types.Product) types.Stores {
+		return SQL(openSQLite(t, products), db.SQLite)
+	})
+}
+
+// 死锁等可重试的错误会让整个事务重新执行，其它错误不会重试
+func TestTxRetriesDeadlock(t *testing.T) {
+	ctx := context.Background()
+	conn := openSQLite(t, nil)
+	stores := SQLWithTxOptions(conn, db.SQLite, db.TxOptions{MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	attempts := 0
+	err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		attempts++
+		if err := tx.Users.CreateUser(ctx, &types.User{Email: "retry@example.com"}); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	err = stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		attempts++
+		return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	})
+	if !db.IsRetryable(err) || attempts != 3 {
+		t.Errorf("expected 3 attempts ending in lock wait timeout, got %d attempts and %v", attempts, err)
+	}
+
+	attempts = 0
+	stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+		attempts++
+		return errors.New("not retryable")
+	})
+	if attempts != 1 {
+		t.Errorf("expected no retry for ordinary errors, got %d attempts", attempts)
+	}
+}
+
+// 打开一个应用了迁移的内存 SQLite 数据库，并放入给定的产品
+func openSQLite(t *testing.T, products []types.Product) *sql.DB {
+	t.Helper()
+	conn, err := db.NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	m, err := migrations.New(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 通过 store 创建，产品才会带上默认变体
+	store := product.NewStore(conn, db.SQLite)
+	for _, p := range products {
+		if err := store.CreateProduct(context.Background(), &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
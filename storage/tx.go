@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Albert-tru/ecom/db"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// txManager 基于 database/sql 的事务管理
+// tx 为 nil 时开启新事务，否则在已有事务中使用保存点实现嵌套
+type txManager struct {
+	conn    *sql.DB
+	dialect db.Dialect
+	opts    db.TxOptions
+	tx      *sql.Tx
+	depth   int
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(tx types.Stores) error) error {
+	if m.tx == nil {
+		return m.dialect.RunInTx(ctx, m.conn, m.opts, func(tx *sql.Tx) error {
+			return fn(m.stores(tx, 1))
+		})
+	}
+
+	name := fmt.Sprintf("sp_%d", m.depth)
+	return db.RunInSavepoint(ctx, m.tx, name, func() error {
+		return fn(m.stores(m.tx, m.depth+1))
+	})
+}
+
+func (m *txManager) stores(tx *sql.Tx, depth int) types.Stores {
+	stores := newStores(tx, m.dialect)
+	stores.Tx = &txManager{conn: m.conn, dialect: m.dialect, opts: m.opts, tx: tx, depth: depth}
+	return stores
+}
@@ -0,0 +1,1610 @@
+// Package storetest 是所有 store 实现都必须通过的一致性测试
+// 新增存储实现时，在它的测试中调用 Run 即可
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/types"
+)
+
+// Factory 创建一套全新的、互相隔离的 store，并预先放入给定的产品（ID由实现分配）
+type Factory func(t *testing.T, products []types.Product) types.Stores
+
+// Run 对 factory 创建的 store 执行全部一致性测试
+func Run(t *testing.T, factory Factory) {
+	t.Run("UserStore", func(t *testing.T) { testUserStore(t, factory) })
+	t.Run("ProductStore", func(t *testing.T) { testProductStore(t, factory) })
+	t.Run("VariantStore", func(t *testing.T) { testVariantStore(t, factory) })
+	t.Run("OrderStore", func(t *testing.T) { testOrderStore(t, factory) })
+	t.Run("CategoryStore", func(t *testing.T) { testCategoryStore(t, factory) })
+	t.Run("ImageStore", func(t *testing.T) { testImageStore(t, factory) })
+	t.Run("ReviewStore", func(t *testing.T) { testReviewStore(t, factory) })
+	t.Run("WishlistStore", func(t *testing.T) { testWishlistStore(t, factory) })
+	t.Run("StockStore", func(t *testing.T) { testStockStore(t, factory) })
+	t.Run("ProductPrices", func(t *testing.T) { testProductPrices(t, factory) })
+	t.Run("ProductSearcher", func(t *testing.T) { testProductSearcher(t, factory) })
+	t.Run("TxManager", func(t *testing.T) { testTxManager(t, factory) })
+}
+
+func newUser(email string) *types.User {
+	return &types.User{Firstname: "John", Lastname: "Doe", Email: email, Password: "hashed"}
+}
+
+func testUserStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("自增ID并可以按邮箱和ID查询", func(t *testing.T) {
+		s := factory(t, nil).Users
+
+		a, b := newUser("a@example.com"), newUser("b@example.com")
+		if err := s.CreateUser(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateUser(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		if a.ID <= 0 || b.ID <= a.ID {
+			t.Fatalf("expected increasing IDs, got %d and %d", a.ID, b.ID)
+		}
+
+		got, err := s.GetUserByEmail(ctx, "b@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != b.ID || got.Firstname != "John" || got.Password != "hashed" || got.CreatedAt.IsZero() {
+			t.Errorf("unexpected user: %+v", got)
+		}
+
+		got, err = s.GetUserByID(ctx, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "a@example.com" {
+			t.Errorf("expected a@example.com, got %s", got.Email)
+		}
+	})
+
+	t.Run("邮箱唯一", func(t *testing.T) {
+		s := factory(t, nil).Users
+
+		if err := s.CreateUser(ctx, newUser("dup@example.com")); err != nil {
+			t.Fatal(err)
+		}
+		err := s.CreateUser(ctx, newUser("dup@example.com"))
+		if !apperr.IsKind(err, apperr.KindConflict) {
+			t.Fatalf("expected conflict, got %v", err)
+		}
+	})
+
+	t.Run("角色、禁用和修改密码", func(t *testing.T) {
+		s := factory(t, nil).Users
+
+		customer, admin := newUser("c@example.com"), newUser("admin@example.com")
+		admin.Role = types.RoleAdmin
+		for _, u := range []*types.User{customer, admin} {
+			if err := s.CreateUser(ctx, u); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.UpdatePassword(ctx, customer.ID, "rehashed"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetDisabled(ctx, customer.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		// 值没有变化时也不能报错
+		if err := s.SetDisabled(ctx, customer.ID, true); err != nil {
+			t.Fatal(err)
+		}
+
+		users, err := s.GetUsers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 2 || users[0].ID != customer.ID || users[1].ID != admin.ID {
+			t.Fatalf("unexpected users: %+v", users)
+		}
+		if u := users[0]; u.Role != types.RoleCustomer || !u.Disabled || u.Password != "rehashed" {
+			t.Errorf("unexpected customer: %+v", u)
+		}
+		if u := users[1]; u.Role != types.RoleAdmin || u.Disabled {
+			t.Errorf("unexpected admin: %+v", u)
+		}
+
+		if err := s.SetDisabled(ctx, 9999, true); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("SetDisabled: expected not found, got %v", err)
+		}
+		if err := s.UpdatePassword(ctx, 9999, "x"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("UpdatePassword: expected not found, got %v", err)
+		}
+	})
+
+	t.Run("用户不存在", func(t *testing.T) {
+		s := factory(t, nil).Users
+
+		if _, err := s.GetUserByEmail(ctx, "missing@example.com"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetUserByEmail: expected not found, got %v", err)
+		}
+		if _, err := s.GetUserByID(ctx, 42); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetUserByID: expected not found, got %v", err)
+		}
+	})
+}
+
+func testProductStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	s := factory(t, []types.Product{
+		{Name: "keyboard", Description: "mechanical", Price: 49.9, Quantity: 5},
+		{Name: "mouse", Description: "wireless", Price: 19.5, Quantity: 0},
+		{Name: "monitor", Description: "27 inch", Price: 199, Quantity: 2},
+	}).Products
+
+	all, err := s.GetProducts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 products, got %d", len(all))
+	}
+	sortProducts(all)
+	if all[0].Name != "keyboard" || all[0].Price != 49.9 || all[0].Quantity != 5 || all[0].CreatedAt.IsZero() {
+		t.Errorf("unexpected product: %+v", all[0])
+	}
+
+	t.Run("按ID查询时忽略不存在的ID", func(t *testing.T) {
+		got, err := s.GetProductByIDs(ctx, []int{all[2].ID, all[0].ID, 9999})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sortProducts(got)
+		if len(got) != 2 || got[0].ID != all[0].ID || got[1].ID != all[2].ID {
+			t.Errorf("unexpected products: %+v", got)
+		}
+	})
+
+	t.Run("按ID分批查询", func(t *testing.T) {
+		first, err := s.GetProductsAfter(ctx, 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(first) != 2 || first[0].ID != all[0].ID || first[1].ID != all[1].ID || first[1].Price != 19.5 {
+			t.Fatalf("unexpected first page: %+v", first)
+		}
+		rest, err := s.GetProductsAfter(ctx, first[1].ID, 2)
+		if err != nil || len(rest) != 1 || rest[0].ID != all[2].ID {
+			t.Errorf("unexpected second page: %+v %v", rest, err)
+		}
+		if rest, err := s.GetProductsAfter(ctx, all[2].ID, 2); err != nil || len(rest) != 0 {
+			t.Errorf("expected no products, got %+v %v", rest, err)
+		}
+	})
+
+	t.Run("创建产品", func(t *testing.T) {
+		p := &types.Product{Name: "headset", Description: "noise cancelling", Price: 89.5, Quantity: 4}
+		if err := s.CreateProduct(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+		if p.ID <= all[2].ID {
+			t.Fatalf("expected a new ID after %d, got %d", all[2].ID, p.ID)
+		}
+
+		// 指定ID创建，之后自动分配的ID不能与之冲突
+		fixed := &types.Product{ID: p.ID + 10, Name: "webcam", Price: 30, Quantity: 1}
+		if err := s.CreateProduct(ctx, fixed); err != nil {
+			t.Fatal(err)
+		}
+		if fixed.ID != p.ID+10 {
+			t.Errorf("expected ID %d, got %d", p.ID+10, fixed.ID)
+		}
+		next := &types.Product{Name: "speaker", Price: 25, Quantity: 1}
+		if err := s.CreateProduct(ctx, next); err != nil {
+			t.Fatal(err)
+		}
+		if next.ID <= fixed.ID {
+			t.Errorf("expected auto ID after %d, got %d", fixed.ID, next.ID)
+		}
+
+		if err := s.CreateProduct(ctx, &types.Product{ID: fixed.ID, Name: "dup", Price: 1}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected conflict for duplicate ID, got %v", err)
+		}
+
+		got, err := s.GetProductByIDs(ctx, []int{p.ID})
+		if err != nil || len(got) != 1 || got[0].Name != "headset" || got[0].Price != 89.5 || got[0].CreatedAt.IsZero() {
+			t.Errorf("unexpected product: %+v %v", got, err)
+		}
+	})
+
+	t.Run("修改产品", func(t *testing.T) {
+		p := all[1]
+		p.Name = "silent mouse"
+		p.Description = "wireless, silent click"
+		p.ImageURL = "https://example.com/mouse.png"
+		p.Price = 21
+		if err := s.UpdateProduct(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		// 值没有变化时也不能报错
+		if err := s.UpdateProduct(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetProductByIDs(ctx, []int{p.ID})
+		if err != nil || len(got) != 1 || got[0].Name != "silent mouse" || got[0].ImageURL != p.ImageURL || got[0].Price != 21 ||
+			got[0].Quantity != all[1].Quantity {
+			t.Errorf("unexpected product: %+v %v", got, err)
+		}
+
+		if err := s.UpdateProduct(ctx, &types.Product{ID: 9999, Name: "x", Price: 1}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("调整库存", func(t *testing.T) {
+		if got, err := s.AdjustStock(ctx, all[0].ID, 10, types.StockChange{Type: types.StockRestock, Reason: "restock"}); err != nil || got != 15 {
+			t.Fatalf("expected 15, got %d %v", got, err)
+		}
+		if got, err := s.AdjustStock(ctx, all[0].ID, -15, types.StockChange{Reason: "write off"}); err != nil || got != 0 {
+			t.Fatalf("expected 0, got %d %v", got, err)
+		}
+		if got, err := s.AdjustStock(ctx, all[0].ID, 0, types.StockChange{Reason: "noop"}); err != nil || got != 0 {
+			t.Fatalf("expected 0, got %d %v", got, err)
+		}
+		if _, err := s.AdjustStock(ctx, all[0].ID, -1, types.StockChange{Reason: "oversell"}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected insufficient stock conflict, got %v", err)
+		}
+		if _, err := s.AdjustStock(ctx, 9999, 1, types.StockChange{Reason: "missing"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("空的ID列表", func(t *testing.T) {
+		got, err := s.GetProductByIDs(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("expected no products, got %d", len(got))
+		}
+	})
+}
+
+func testVariantStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (types.Stores, types.Product) {
+		stores := factory(t, []types.Product{{Name: "t-shirt", Price: 19.9, Quantity: 5}})
+		ps, err := stores.Products.GetProducts(ctx)
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("seed products: %v %v", ps, err)
+		}
+		return stores, ps[0]
+	}
+	price := func(v float64) *float64 { return &v }
+
+	t.Run("产品自带默认变体", func(t *testing.T) {
+		stores, p := setup(t)
+
+		vs, err := stores.Variants.GetVariants(ctx, p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vs) != 1 || vs[0].ProductID != p.ID || vs[0].SKU == "" || vs[0].Quantity != 5 ||
+			vs[0].Price != nil || len(vs[0].Options) != 0 || vs[0].CreatedAt.IsZero() {
+			t.Fatalf("unexpected default variant: %+v", vs)
+		}
+
+		got, err := stores.Variants.GetVariantBySKU(ctx, vs[0].SKU)
+		if err != nil || got.ID != vs[0].ID {
+			t.Errorf("GetVariantBySKU: %+v %v", got, err)
+		}
+		if _, err := stores.Variants.GetVariantBySKU(ctx, "missing"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if vs, err := stores.Variants.GetVariants(ctx, 9999); err != nil || len(vs) != 0 {
+			t.Errorf("expected no variants, got %+v %v", vs, err)
+		}
+	})
+
+	t.Run("规格类型", func(t *testing.T) {
+		stores, p := setup(t)
+
+		if err := stores.Variants.SetOptions(ctx, p.ID, []string{"size", "colour"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := stores.Variants.GetOptions(ctx, p.ID); err != nil || len(got) != 2 || got[0] != "size" || got[1] != "colour" {
+			t.Errorf("unexpected options: %v %v", got, err)
+		}
+		if err := stores.Variants.SetOptions(ctx, p.ID, []string{"size", "size"}); !apperr.IsKind(err, apperr.KindValidation) {
+			t.Errorf("expected validation error for duplicates, got %v", err)
+		}
+		if err := stores.Variants.SetOptions(ctx, 9999, []string{"size"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if got, err := stores.Variants.GetOptions(ctx, 9999); err != nil || len(got) != 0 {
+			t.Errorf("expected no options, got %v %v", got, err)
+		}
+	})
+
+	t.Run("创建和修改变体", func(t *testing.T) {
+		stores, p := setup(t)
+		if err := stores.Variants.SetOptions(ctx, p.ID, []string{"size", "colour"}); err != nil {
+			t.Fatal(err)
+		}
+
+		v := &types.ProductVariant{ProductID: p.ID, SKU: "TS-RED-M", Price: price(24.9), Quantity: 4,
+			ImageURL: "red.png", Options: map[string]string{"size": "M", "colour": "red"}}
+		if err := stores.Variants.CreateVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		if got := quantityOf(t, stores, p.ID); got != 9 {
+			t.Errorf("expected product stock 9, got %d", got)
+		}
+
+		got, err := stores.Variants.GetVariantsByIDs(ctx, []int{v.ID, 9999})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].SKU != "TS-RED-M" || got[0].Price == nil || *got[0].Price != 24.9 ||
+			got[0].Quantity != 4 || got[0].ImageURL != "red.png" || got[0].Options["colour"] != "red" {
+			t.Errorf("unexpected variant: %+v", got)
+		}
+
+		dup := &types.ProductVariant{ProductID: p.ID, SKU: "TS-RED-M"}
+		if err := stores.Variants.CreateVariant(ctx, dup); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected SKU conflict, got %v", err)
+		}
+		if err := stores.Variants.CreateVariant(ctx, &types.ProductVariant{ProductID: 9999, SKU: "X"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected product not found, got %v", err)
+		}
+
+		// 修改不影响库存；去掉单独定价后使用产品价格
+		v.SKU, v.Price, v.Options = "TS-RED-L", nil, map[string]string{"size": "L", "colour": "red"}
+		if err := stores.Variants.UpdateVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		if err := stores.Variants.UpdateVariant(ctx, v); err != nil {
+			t.Fatalf("unchanged update: %v", err)
+		}
+		got, _ = stores.Variants.GetVariantsByIDs(ctx, []int{v.ID})
+		if got[0].SKU != "TS-RED-L" || got[0].Price != nil || got[0].Quantity != 4 || got[0].Options["size"] != "L" {
+			t.Errorf("unexpected variant after update: %+v", got[0])
+		}
+		if err := stores.Variants.UpdateVariant(ctx, &types.ProductVariant{ID: 9999, SKU: "Y"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+
+		// 删除规格时一并删除变体上的取值
+		if err := stores.Variants.SetOptions(ctx, p.ID, []string{"size"}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ = stores.Variants.GetVariantsByIDs(ctx, []int{v.ID})
+		if len(got[0].Options) != 1 || got[0].Options["size"] != "L" {
+			t.Errorf("expected only the size option, got %v", got[0].Options)
+		}
+	})
+
+	t.Run("按产品批量查询", func(t *testing.T) {
+		stores := factory(t, []types.Product{
+			{Name: "t-shirt", Price: 19.9, Quantity: 5},
+			{Name: "hoodie", Price: 39.9, Quantity: 2},
+			{Name: "cap", Price: 9.9, Quantity: 1},
+		})
+		ps, err := stores.Products.GetProducts(ctx)
+		if err != nil || len(ps) != 3 {
+			t.Fatalf("seed products: %v %v", ps, err)
+		}
+		sortProducts(ps)
+		if err := stores.Variants.SetOptions(ctx, ps[2].ID, []string{"size"}); err != nil {
+			t.Fatal(err)
+		}
+		v := &types.ProductVariant{ProductID: ps[2].ID, SKU: "CAP-L", Quantity: 3, Options: map[string]string{"size": "L"}}
+		if err := stores.Variants.CreateVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := stores.Variants.GetVariantsByProductIDs(ctx, []int{ps[2].ID, ps[0].ID, 9999})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || got[0].ProductID != ps[0].ID || got[1].ProductID != ps[2].ID || got[2].ID != v.ID ||
+			got[1].ID > got[2].ID || got[2].Options["size"] != "L" {
+			t.Errorf("unexpected variants: %+v", got)
+		}
+		if got, err := stores.Variants.GetVariantsByProductIDs(ctx, nil); err != nil || len(got) != 0 {
+			t.Errorf("expected no variants, got %+v %v", got, err)
+		}
+	})
+
+	t.Run("按变体调整库存和下单", func(t *testing.T) {
+		stores, p := setup(t)
+		v := &types.ProductVariant{ProductID: p.ID, SKU: "TS-XL", Quantity: 2}
+		if err := stores.Variants.CreateVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := stores.Variants.AdjustVariantStock(ctx, v.ID, 3, types.StockChange{Type: types.StockRestock, Reason: "restock"}); err != nil || got != 5 {
+			t.Errorf("expected 5, got %d %v", got, err)
+		}
+		if _, err := stores.Variants.AdjustVariantStock(ctx, v.ID, -6, types.StockChange{Reason: "oversell"}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected conflict, got %v", err)
+		}
+		if _, err := stores.Variants.AdjustVariantStock(ctx, 9999, 1, types.StockChange{Reason: "missing"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if got := quantityOf(t, stores, p.ID); got != 10 {
+			t.Errorf("expected product stock 10, got %d", got)
+		}
+
+		// 有多个变体时必须指定变体
+		if _, err := stores.Products.AdjustStock(ctx, p.ID, 1, types.StockChange{Reason: "ambiguous"}); !apperr.IsKind(err, apperr.KindValidation) {
+			t.Errorf("expected validation error, got %v", err)
+		}
+
+		u := newUser("buyer@example.com")
+		if err := stores.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := stores.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 39.8, Status: types.OrderPending, Address: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID, Quantity: 1, Price: 19.9})
+		if !apperr.IsKind(err, apperr.KindValidation) {
+			t.Errorf("expected validation error without a variant, got %v", err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID + 1, VariantID: v.ID, Quantity: 1, Price: 19.9})
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found for a variant of another product, got %v", err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID, VariantID: v.ID, Quantity: 2, Price: 19.9})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := stores.Variants.GetVariantsByIDs(ctx, []int{v.ID}); got[0].Quantity != 3 {
+			t.Errorf("expected variant stock 3, got %d", got[0].Quantity)
+		}
+		if got := quantityOf(t, stores, p.ID); got != 8 {
+			t.Errorf("expected product stock 8, got %d", got)
+		}
+		if items, _ := stores.Orders.GetOrderItems(ctx, orderID); len(items) != 1 || items[0].VariantID != v.ID {
+			t.Errorf("unexpected items: %+v", items)
+		}
+	})
+}
+
+func testOrderStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (types.Stores, int, types.Product) {
+		stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}})
+		u := newUser("buyer@example.com")
+		if err := stores.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		ps, err := stores.Products.GetProducts(ctx)
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("seed products: %v %v", ps, err)
+		}
+		return stores, u.ID, ps[0]
+	}
+
+	order := func(userID int) types.Order {
+		return types.Order{UserID: userID, Total: 99.8, Status: types.OrderPending, Address: "some address"}
+	}
+
+	t.Run("自增订单ID", func(t *testing.T) {
+		stores, userID, _ := setup(t)
+
+		first, err := stores.Orders.CreateOrder(ctx, order(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := stores.Orders.CreateOrder(ctx, order(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first <= 0 || second <= first {
+			t.Fatalf("expected increasing IDs, got %d and %d", first, second)
+		}
+	})
+
+	t.Run("创建订单项时扣减库存", func(t *testing.T) {
+		stores, userID, p := setup(t)
+
+		orderID, err := stores.Orders.CreateOrder(ctx, order(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID, Quantity: 2, Price: p.Price})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := quantityOf(t, stores, p.ID); got != 1 {
+			t.Errorf("expected remaining stock 1, got %d", got)
+		}
+
+		// 剩余库存不够时拒绝，且库存不变
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID, Quantity: 2, Price: p.Price})
+		if !apperr.IsKind(err, apperr.KindConflict) {
+			t.Fatalf("expected insufficient stock conflict, got %v", err)
+		}
+		if got := quantityOf(t, stores, p.ID); got != 1 {
+			t.Errorf("stock changed after rejected item: %d", got)
+		}
+	})
+
+	t.Run("产品不存在", func(t *testing.T) {
+		stores, userID, _ := setup(t)
+
+		orderID, err := stores.Orders.CreateOrder(ctx, order(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: 9999, Quantity: 1, Price: 1})
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("查询订单和订单项并修改状态", func(t *testing.T) {
+		stores, userID, p := setup(t)
+
+		orderID, err := stores.Orders.CreateOrder(ctx, order(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: p.ID, Quantity: 2, Price: p.Price, PriceID: p.PriceID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := stores.Orders.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.UserID != userID || o.Total != 99.8 || o.Status != types.OrderPending || o.CreatedAt.IsZero() {
+			t.Errorf("unexpected order: %+v", o)
+		}
+		items, err := stores.Orders.GetOrderItems(ctx, orderID)
+		if err != nil || len(items) != 1 || items[0].ProductID != p.ID || items[0].Quantity != 2 || items[0].Price != p.Price {
+			t.Errorf("unexpected items: %+v %v", items, err)
+		}
+		// 没有指定变体时使用产品的默认变体
+		if vs, _ := stores.Variants.GetVariants(ctx, p.ID); len(items) == 1 && items[0].VariantID != vs[0].ID {
+			t.Errorf("expected the default variant %d, got %d", vs[0].ID, items[0].VariantID)
+		}
+		// 记下成交价来自哪条价格记录
+		if len(items) == 1 && (p.PriceID == nil || items[0].PriceID == nil || *items[0].PriceID != *p.PriceID) {
+			t.Errorf("expected price row %v, got %v", p.PriceID, items[0].PriceID)
+		}
+
+		// 订单送达之前不算买过
+		if ok, err := stores.Orders.HasPurchased(ctx, userID, p.ID); err != nil || ok {
+			t.Errorf("expected no delivered purchase yet, got %v %v", ok, err)
+		}
+		if err := stores.Orders.UpdateOrderStatus(ctx, orderID, types.OrderCompleted); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := stores.Orders.HasPurchased(ctx, userID, p.ID); err != nil || ok {
+			t.Errorf("expected a shipped order not to count, got %v %v", ok, err)
+		}
+		if err := stores.Orders.UpdateOrderStatus(ctx, orderID, types.OrderDelivered); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := stores.Orders.HasPurchased(ctx, userID, p.ID); err != nil || !ok {
+			t.Errorf("expected a delivered purchase, got %v %v", ok, err)
+		}
+		if ok, _ := stores.Orders.HasPurchased(ctx, userID+1, p.ID); ok {
+			t.Error("expected another user not to have purchased the product")
+		}
+		if err := stores.Orders.UpdateOrderStatus(ctx, orderID, types.OrderDelivered); err != nil {
+			t.Fatal(err)
+		}
+		if o, _ := stores.Orders.GetOrderByID(ctx, orderID); o.Status != types.OrderDelivered {
+			t.Errorf("expected delivered, got %s", o.Status)
+		}
+
+		if _, err := stores.Orders.GetOrderByID(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetOrderByID: expected not found, got %v", err)
+		}
+		if err := stores.Orders.UpdateOrderStatus(ctx, 9999, types.OrderCancelled); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("UpdateOrderStatus: expected not found, got %v", err)
+		}
+		if items, err := stores.Orders.GetOrderItems(ctx, 9999); err != nil || len(items) != 0 {
+			t.Errorf("expected no items, got %+v %v", items, err)
+		}
+	})
+
+	t.Run("订单必须属于已存在的用户", func(t *testing.T) {
+		stores, _, _ := setup(t)
+
+		if _, err := stores.Orders.CreateOrder(ctx, order(9999)); err == nil {
+			t.Fatal("expected error for unknown user")
+		}
+	})
+}
+
+func testProductSearcher(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	stores := factory(t, []types.Product{
+		{Name: "Wireless Mouse", Description: "2.4G mouse with a silent click", Price: 19.5, Quantity: 1},
+		{Name: "Mechanical Keyboard", Description: "87-key keyboard, works with any mouse", Price: 49.9, Quantity: 1},
+		{Name: "Desk Lamp", Description: "warm light", Price: 15, Quantity: 1},
+	})
+	s := stores.Search
+	ps, _ := stores.Products.GetProducts(ctx)
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+
+	t.Run("按相关度排序，名称中命中排在前面", func(t *testing.T) {
+		matches, err := s.SearchProducts(ctx, "mouse", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 2 || matches[0].ProductID != ps[0].ID || matches[1].ProductID != ps[1].ID {
+			t.Fatalf("unexpected matches: %+v", matches)
+		}
+		if matches[0].Score <= matches[1].Score {
+			t.Errorf("expected name match to score higher: %+v", matches)
+		}
+	})
+
+	t.Run("拼写纠错", func(t *testing.T) {
+		if matches, err := s.SearchProducts(ctx, "keybaord", false); err != nil || len(matches) != 0 {
+			t.Errorf("expected no matches without fuzzy, got %+v %v", matches, err)
+		}
+		matches, err := s.SearchProducts(ctx, "keybaord", true)
+		if err != nil || len(matches) != 1 || matches[0].ProductID != ps[1].ID {
+			t.Errorf("expected the keyboard, got %+v %v", matches, err)
+		}
+	})
+
+	t.Run("没有命中", func(t *testing.T) {
+		if matches, err := s.SearchProducts(ctx, "printer", true); err != nil || len(matches) != 0 {
+			t.Errorf("expected no matches, got %+v %v", matches, err)
+		}
+	})
+}
+
+func testCategoryStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (types.Stores, []types.Product, *types.Category, *types.Category) {
+		stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}, {Name: "mouse", Price: 19.5, Quantity: 3}})
+		ps, err := stores.Products.GetProducts(ctx)
+		if err != nil || len(ps) != 2 {
+			t.Fatalf("seed products: %v %v", ps, err)
+		}
+		sortProducts(ps)
+
+		root := &types.Category{Name: "Electronics", Slug: "electronics", SortOrder: 1}
+		if err := stores.Categories.CreateCategory(ctx, root); err != nil {
+			t.Fatal(err)
+		}
+		child := &types.Category{ParentID: &root.ID, Name: "Accessories", Slug: "accessories"}
+		if err := stores.Categories.CreateCategory(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		return stores, ps, root, child
+	}
+
+	t.Run("创建、查询和排序", func(t *testing.T) {
+		stores, _, root, child := setup(t)
+		s := stores.Categories
+
+		if root.ID <= 0 || child.ID <= root.ID {
+			t.Fatalf("expected increasing IDs, got %d and %d", root.ID, child.ID)
+		}
+
+		got, err := s.GetCategoryBySlug(ctx, "accessories")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != child.ID || got.ParentID == nil || *got.ParentID != root.ID || got.Name != "Accessories" || got.CreatedAt.IsZero() {
+			t.Errorf("unexpected category: %+v", got)
+		}
+		got, err = s.GetCategoryByID(ctx, root.ID)
+		if err != nil || got.ParentID != nil || got.SortOrder != 1 {
+			t.Errorf("unexpected category: %+v %v", got, err)
+		}
+
+		// 按 sort_order 排序，sort_order 相同时按名称
+		all, err := s.GetCategories(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].ID != child.ID || all[1].ID != root.ID {
+			t.Errorf("unexpected order: %+v", all)
+		}
+
+		if _, err := s.GetCategoryBySlug(ctx, "missing"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetCategoryBySlug: expected not found, got %v", err)
+		}
+		if _, err := s.GetCategoryByID(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetCategoryByID: expected not found, got %v", err)
+		}
+	})
+
+	t.Run("slug 唯一且父分类必须存在", func(t *testing.T) {
+		stores, _, _, child := setup(t)
+		s := stores.Categories
+
+		if err := s.CreateCategory(ctx, &types.Category{Name: "Dup", Slug: "electronics"}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected slug conflict, got %v", err)
+		}
+		missing := 9999
+		if err := s.CreateCategory(ctx, &types.Category{ParentID: &missing, Name: "Orphan", Slug: "orphan"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected parent not found, got %v", err)
+		}
+
+		child.Slug = "electronics"
+		if err := s.UpdateCategory(ctx, child); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected slug conflict on update, got %v", err)
+		}
+	})
+
+	t.Run("修改分类", func(t *testing.T) {
+		stores, _, _, child := setup(t)
+		s := stores.Categories
+
+		child.ParentID = nil
+		child.Name = "Gadgets"
+		child.Slug = "gadgets"
+		child.SortOrder = 5
+		if err := s.UpdateCategory(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		// 值没有变化时也不能报错
+		if err := s.UpdateCategory(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetCategoryByID(ctx, child.ID)
+		if err != nil || got.ParentID != nil || got.Name != "Gadgets" || got.Slug != "gadgets" || got.SortOrder != 5 {
+			t.Errorf("unexpected category: %+v %v", got, err)
+		}
+
+		if err := s.UpdateCategory(ctx, &types.Category{ID: 9999, Name: "x", Slug: "x"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("产品分类", func(t *testing.T) {
+		stores, ps, root, child := setup(t)
+		s := stores.Categories
+
+		if err := s.SetProductCategories(ctx, ps[0].ID, []int{child.ID, root.ID, child.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetProductCategories(ctx, ps[1].ID, []int{child.ID}); err != nil {
+			t.Fatal(err)
+		}
+		ids, err := s.GetProductCategoryIDs(ctx, ps[0].ID)
+		if err != nil || len(ids) != 2 || ids[0] != root.ID || ids[1] != child.ID {
+			t.Errorf("unexpected categories: %v %v", ids, err)
+		}
+
+		// 同时属于多个分类的产品只返回一次
+		got, err := s.GetProductsByCategoryIDs(ctx, []int{root.ID, child.ID})
+		if err != nil || len(got) != 2 || got[0].ID != ps[0].ID || got[1].ID != ps[1].ID || got[0].Name != "keyboard" {
+			t.Errorf("unexpected products: %+v %v", got, err)
+		}
+		byProduct, err := s.GetCategoryIDsByProductIDs(ctx, []int{ps[0].ID, ps[1].ID, 9999})
+		if err != nil || len(byProduct) != 2 || len(byProduct[ps[0].ID]) != 2 || byProduct[ps[0].ID][0] != root.ID ||
+			len(byProduct[ps[1].ID]) != 1 || byProduct[ps[1].ID][0] != child.ID {
+			t.Errorf("unexpected categories by product: %v %v", byProduct, err)
+		}
+		got, err = s.GetProductsByCategoryIDs(ctx, []int{root.ID})
+		if err != nil || len(got) != 1 || got[0].ID != ps[0].ID {
+			t.Errorf("unexpected products: %+v %v", got, err)
+		}
+		if got, err := s.GetProductsByCategoryIDs(ctx, nil); err != nil || len(got) != 0 {
+			t.Errorf("expected no products, got %+v %v", got, err)
+		}
+
+		// 替换而不是追加；传空列表清空
+		if err := s.SetProductCategories(ctx, ps[0].ID, []int{root.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if ids, _ := s.GetProductCategoryIDs(ctx, ps[0].ID); len(ids) != 1 || ids[0] != root.ID {
+			t.Errorf("expected [%d], got %v", root.ID, ids)
+		}
+		if err := s.SetProductCategories(ctx, ps[0].ID, []int{}); err != nil {
+			t.Fatal(err)
+		}
+		if ids, err := s.GetProductCategoryIDs(ctx, ps[0].ID); err != nil || len(ids) != 0 {
+			t.Errorf("expected no categories, got %v %v", ids, err)
+		}
+
+		if err := s.SetProductCategories(ctx, 9999, []int{root.ID}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected product not found, got %v", err)
+		}
+		if err := s.SetProductCategories(ctx, ps[0].ID, []int{root.ID, 9999}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected category not found, got %v", err)
+		}
+	})
+
+	t.Run("删除分类", func(t *testing.T) {
+		stores, ps, root, child := setup(t)
+		s := stores.Categories
+
+		if err := s.SetProductCategories(ctx, ps[0].ID, []int{child.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteCategory(ctx, root.ID); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Fatalf("expected conflict while subcategories exist, got %v", err)
+		}
+		if err := s.DeleteCategory(ctx, child.ID); err != nil {
+			t.Fatal(err)
+		}
+		if ids, _ := s.GetProductCategoryIDs(ctx, ps[0].ID); len(ids) != 0 {
+			t.Errorf("expected product links to be removed, got %v", ids)
+		}
+		if err := s.DeleteCategory(ctx, root.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteCategory(ctx, root.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+}
+
+func testImageStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}, {Name: "mouse", Price: 19.5, Quantity: 3}})
+	s := stores.Images
+	ps, _ := stores.Products.GetProducts(ctx)
+	sortProducts(ps)
+
+	newImage := func(productID int, key string) *types.ProductImage {
+		return &types.ProductImage{
+			ProductID: productID, Key: key + ".png", ContentType: "image/png", Width: 800, Height: 600, Size: 1234,
+			Thumbnails: []types.ImageThumbnail{
+				{Size: 600, Key: key + "_600.png", Width: 600, Height: 450},
+				{Size: 150, Key: key + "_150.png", Width: 150, Height: 112},
+			},
+		}
+	}
+	ids := func(imgs []types.ProductImage) []int {
+		var out []int
+		for _, img := range imgs {
+			out = append(out, img.ID)
+		}
+		return out
+	}
+
+	var a, b, c *types.ProductImage
+	t.Run("追加图片并写回ID和位置", func(t *testing.T) {
+		a, b, c = newImage(ps[0].ID, "a"), newImage(ps[0].ID, "b"), newImage(ps[0].ID, "c")
+		for i, img := range []*types.ProductImage{a, b, c} {
+			if err := s.CreateImage(ctx, img); err != nil {
+				t.Fatal(err)
+			}
+			if img.ID <= 0 || img.Position != i {
+				t.Errorf("unexpected image: %+v", img)
+			}
+		}
+		if err := s.CreateImage(ctx, newImage(ps[1].ID, "d")); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetImage(ctx, b.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ProductID != ps[0].ID || got.Key != "b.png" || got.Width != 800 || got.Size != 1234 || got.CreatedAt.IsZero() {
+			t.Errorf("unexpected image: %+v", got)
+		}
+		// 缩略图按边长排序
+		if len(got.Thumbnails) != 2 || got.Thumbnails[0].Size != 150 || got.Thumbnails[0].Key != "b_150.png" || got.Thumbnails[1].Height != 450 {
+			t.Errorf("unexpected thumbnails: %+v", got.Thumbnails)
+		}
+
+		imgs, err := s.GetImages(ctx, ps[0].ID)
+		if err != nil || len(imgs) != 3 || imgs[0].ID != a.ID || imgs[2].ID != c.ID || len(imgs[1].Thumbnails) != 2 {
+			t.Errorf("unexpected images: %+v %v", imgs, err)
+		}
+	})
+
+	t.Run("调整顺序", func(t *testing.T) {
+		if err := s.ReorderImages(ctx, ps[0].ID, []int{c.ID, a.ID, b.ID}); err != nil {
+			t.Fatal(err)
+		}
+		imgs, _ := s.GetImages(ctx, ps[0].ID)
+		if got := ids(imgs); len(got) != 3 || got[0] != c.ID || got[1] != a.ID || got[2] != b.ID || imgs[0].Position != 0 || imgs[2].Position != 2 {
+			t.Errorf("unexpected order: %v", got)
+		}
+
+		// 必须恰好列出产品的所有图片
+		for _, order := range [][]int{{c.ID, a.ID}, {c.ID, a.ID, a.ID}, {c.ID, a.ID, b.ID, 9999}} {
+			if err := s.ReorderImages(ctx, ps[0].ID, order); !apperr.IsKind(err, apperr.KindValidation) {
+				t.Errorf("%v: expected validation error, got %v", order, err)
+			}
+		}
+	})
+
+	t.Run("删除后后面的图片前移", func(t *testing.T) {
+		if err := s.DeleteImage(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		imgs, _ := s.GetImages(ctx, ps[0].ID)
+		if len(imgs) != 2 || imgs[0].ID != c.ID || imgs[1].ID != b.ID || imgs[1].Position != 1 {
+			t.Errorf("unexpected images: %+v", imgs)
+		}
+		// 新图片接在末尾
+		e := newImage(ps[0].ID, "e")
+		if err := s.CreateImage(ctx, e); err != nil || e.Position != 2 {
+			t.Errorf("unexpected position %d: %v", e.Position, err)
+		}
+	})
+
+	t.Run("图片或产品不存在", func(t *testing.T) {
+		if _, err := s.GetImage(ctx, a.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("GetImage: expected not found, got %v", err)
+		}
+		if err := s.DeleteImage(ctx, a.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("DeleteImage: expected not found, got %v", err)
+		}
+		if err := s.CreateImage(ctx, newImage(9999, "x")); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("CreateImage: expected product not found, got %v", err)
+		}
+		if imgs, err := s.GetImages(ctx, 9999); err != nil || len(imgs) != 0 {
+			t.Errorf("expected no images, got %+v %v", imgs, err)
+		}
+	})
+}
+
+func testReviewStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}, {Name: "mouse", Price: 19.5, Quantity: 3}})
+	s := stores.Reviews
+	ps, _ := stores.Products.GetProducts(ctx)
+	sortProducts(ps)
+	var users []*types.User
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		u := newUser(email)
+		if err := stores.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	rating := func(productID int) (float64, int) {
+		t.Helper()
+		got, err := stores.Products.GetProductByIDs(ctx, []int{productID})
+		if err != nil || len(got) != 1 {
+			t.Fatalf("GetProductByIDs: %v %v", got, err)
+		}
+		return got[0].RatingAverage, got[0].RatingCount
+	}
+
+	var reviews []*types.Review
+	t.Run("创建评价，每个用户每个产品一条", func(t *testing.T) {
+		for i, u := range users {
+			r := &types.Review{ProductID: ps[0].ID, UserID: u.ID, Rating: 5 - i*2, Title: "title", Body: "body"}
+			if err := s.CreateReview(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			if r.ID <= 0 || r.Status != types.ReviewPending {
+				t.Errorf("unexpected review: %+v", r)
+			}
+			reviews = append(reviews, r)
+		}
+		dup := &types.Review{ProductID: ps[0].ID, UserID: users[0].ID, Rating: 1, Title: "again", Body: "body"}
+		if err := s.CreateReview(ctx, dup); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected conflict, got %v", err)
+		}
+		// 同一个用户可以评价其它产品
+		if err := s.CreateReview(ctx, &types.Review{ProductID: ps[1].ID, UserID: users[0].ID, Rating: 4, Title: "t", Body: "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateReview(ctx, &types.Review{ProductID: 9999, UserID: users[0].ID, Rating: 4, Title: "t", Body: "b"}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+
+		got, err := s.GetReviewByID(ctx, reviews[0].ID)
+		if err != nil || got.Rating != 5 || got.UserID != users[0].ID || got.CreatedAt.IsZero() || got.UpdatedAt.IsZero() {
+			t.Errorf("unexpected review: %+v %v", got, err)
+		}
+		if avg, count := rating(ps[0].ID); avg != 0 || count != 0 {
+			t.Errorf("expected pending reviews not to count, got %v %d", avg, count)
+		}
+	})
+
+	t.Run("审核后同步产品评分", func(t *testing.T) {
+		// 5 和 3 通过，1 被拒绝
+		for i, status := range []string{types.ReviewApproved, types.ReviewApproved, types.ReviewRejected} {
+			reviews[i].Status = status
+			if err := s.UpdateReview(ctx, reviews[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if avg, count := rating(ps[0].ID); avg != 4 || count != 2 {
+			t.Errorf("expected 4.00 from 2 reviews, got %v %d", avg, count)
+		}
+
+		// 修改评分后重新计算，撤回审核后不再计入
+		reviews[1].Rating = 4
+		if err := s.UpdateReview(ctx, reviews[1]); err != nil {
+			t.Fatal(err)
+		}
+		if avg, count := rating(ps[0].ID); avg != 4.5 || count != 2 {
+			t.Errorf("expected 4.50 from 2 reviews, got %v %d", avg, count)
+		}
+		reviews[0].Status = types.ReviewPending
+		if err := s.UpdateReview(ctx, reviews[0]); err != nil {
+			t.Fatal(err)
+		}
+		if avg, count := rating(ps[0].ID); avg != 4 || count != 1 {
+			t.Errorf("expected 4.00 from 1 review, got %v %d", avg, count)
+		}
+		if avg, count := rating(ps[1].ID); avg != 0 || count != 0 {
+			t.Errorf("expected the other product not to change, got %v %d", avg, count)
+		}
+
+		if err := s.UpdateReview(ctx, &types.Review{ID: 9999, Rating: 1, Status: types.ReviewApproved}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("按产品和状态查询", func(t *testing.T) {
+		all, err := s.GetReviews(ctx, ps[0].ID, "")
+		if err != nil || len(all) != 3 {
+			t.Fatalf("unexpected reviews: %+v %v", all, err)
+		}
+		approved, _ := s.GetReviews(ctx, ps[0].ID, types.ReviewApproved)
+		if len(approved) != 1 || approved[0].ID != reviews[1].ID || approved[0].Rating != 4 {
+			t.Errorf("unexpected approved reviews: %+v", approved)
+		}
+		pending, _ := s.GetReviewsByStatus(ctx, types.ReviewPending)
+		if len(pending) != 2 || pending[0].ID != reviews[0].ID {
+			t.Errorf("unexpected pending reviews: %+v", pending)
+		}
+		if none, err := s.GetReviews(ctx, 9999, ""); err != nil || len(none) != 0 {
+			t.Errorf("expected no reviews, got %+v %v", none, err)
+		}
+		if _, err := s.GetReviewByID(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+}
+
+func testWishlistStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}, {Name: "mouse", Price: 19.5}})
+	s := stores.Wishlists
+	ps, _ := stores.Products.GetProducts(ctx)
+	sortProducts(ps)
+	vs, _ := stores.Variants.GetVariants(ctx, ps[0].ID)
+	var users []*types.User
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		u := newUser(email)
+		if err := stores.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+
+	var birthday *types.Wishlist
+	t.Run("创建心愿单，同一个用户的名称唯一", func(t *testing.T) {
+		birthday = &types.Wishlist{UserID: users[0].ID, Name: "birthday"}
+		if err := s.CreateWishlist(ctx, birthday); err != nil {
+			t.Fatal(err)
+		}
+		if birthday.ID <= 0 || len(birthday.Items) != 0 {
+			t.Errorf("unexpected wishlist: %+v", birthday)
+		}
+		if err := s.CreateWishlist(ctx, &types.Wishlist{UserID: users[0].ID, Name: "birthday"}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected conflict, got %v", err)
+		}
+		for _, w := range []*types.Wishlist{{UserID: users[0].ID, Name: "later"}, {UserID: users[1].ID, Name: "birthday"}} {
+			if err := s.CreateWishlist(ctx, w); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ws, err := s.GetWishlists(ctx, users[0].ID)
+		if err != nil || len(ws) != 2 || ws[0].ID != birthday.ID || ws[1].Name != "later" {
+			t.Errorf("unexpected wishlists: %+v %v", ws, err)
+		}
+	})
+
+	t.Run("加入和移除产品", func(t *testing.T) {
+		whole := &types.WishlistItem{WishlistID: birthday.ID, ProductID: ps[0].ID, LastPrice: 49.9, LastInStock: true}
+		variant := &types.WishlistItem{WishlistID: birthday.ID, ProductID: ps[0].ID, VariantID: &vs[0].ID, LastPrice: 49.9, LastInStock: true}
+		other := &types.WishlistItem{WishlistID: birthday.ID, ProductID: ps[1].ID, LastPrice: 19.5}
+		for _, item := range []*types.WishlistItem{whole, variant, other} {
+			if err := s.AddWishlistItem(ctx, item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 整个产品和某个变体各自只能加入一次
+		for _, dup := range []*types.WishlistItem{
+			{WishlistID: birthday.ID, ProductID: ps[0].ID},
+			{WishlistID: birthday.ID, ProductID: ps[0].ID, VariantID: &vs[0].ID},
+		} {
+			if err := s.AddWishlistItem(ctx, dup); !apperr.IsKind(err, apperr.KindConflict) {
+				t.Errorf("expected conflict, got %v", err)
+			}
+		}
+		if err := s.AddWishlistItem(ctx, &types.WishlistItem{WishlistID: 999, ProductID: ps[0].ID}); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+
+		w, err := s.GetWishlist(ctx, birthday.ID)
+		if err != nil || len(w.Items) != 3 {
+			t.Fatalf("unexpected wishlist: %+v %v", w, err)
+		}
+		if got := w.Items[1]; got.ID != variant.ID || got.VariantID == nil || *got.VariantID != vs[0].ID || got.LastPrice != 49.9 || !got.LastInStock {
+			t.Errorf("unexpected item: %+v", got)
+		}
+		if w.Items[0].VariantID != nil || w.Items[2].LastInStock {
+			t.Errorf("unexpected items: %+v", w.Items)
+		}
+
+		if err := s.UpdateWishlistItemState(ctx, other.ID, 15, true); err != nil {
+			t.Fatal(err)
+		}
+		all, err := s.GetAllWishlistItems(ctx)
+		if err != nil || len(all) != 3 || all[2].LastPrice != 15 || !all[2].LastInStock {
+			t.Errorf("unexpected items: %+v %v", all, err)
+		}
+
+		if err := s.RemoveWishlistItem(ctx, birthday.ID, whole.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RemoveWishlistItem(ctx, birthday.ID, whole.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("分享链接", func(t *testing.T) {
+		if err := s.SetShareToken(ctx, birthday.ID, "token-1"); err != nil {
+			t.Fatal(err)
+		}
+		w, err := s.GetWishlistByShareToken(ctx, "token-1")
+		if err != nil || w.ID != birthday.ID || w.ShareToken != "token-1" || len(w.Items) != 2 {
+			t.Fatalf("unexpected wishlist: %+v %v", w, err)
+		}
+		if err := s.SetShareToken(ctx, birthday.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetWishlistByShareToken(ctx, "token-1"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if w, _ := s.GetWishlist(ctx, birthday.ID); w.ShareToken != "" {
+			t.Errorf("expected the share token to be cleared, got %q", w.ShareToken)
+		}
+	})
+
+	t.Run("删除心愿单时一并删除其中的产品", func(t *testing.T) {
+		if err := s.DeleteWishlist(ctx, birthday.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetWishlist(ctx, birthday.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if err := s.DeleteWishlist(ctx, birthday.ID); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if all, err := s.GetAllWishlistItems(ctx); err != nil || len(all) != 0 {
+			t.Errorf("expected no items left, got %+v %v", all, err)
+		}
+	})
+}
+
+func testStockStore(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}, {Name: "mouse", Price: 19.5}})
+	s := stores.Stock
+	ps, _ := stores.Products.GetProducts(ctx)
+	sortProducts(ps)
+	vs, _ := stores.Variants.GetVariants(ctx, ps[0].ID)
+	u := newUser("buyer@example.com")
+	if err := stores.Users.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("初始库存记为进货", func(t *testing.T) {
+		ms, err := s.GetStockMovements(ctx, ps[0].ID)
+		if err != nil || len(ms) != 1 {
+			t.Fatalf("expected one movement, got %+v %v", ms, err)
+		}
+		if m := ms[0]; m.VariantID != vs[0].ID || m.Type != types.StockRestock || m.Delta != 3 || m.Balance != 3 || m.ActorID != nil {
+			t.Errorf("unexpected movement: %+v", m)
+		}
+		// 没有库存的产品没有流水
+		if ms, err := s.GetStockMovements(ctx, ps[1].ID); err != nil || len(ms) != 0 {
+			t.Errorf("expected no movements, got %+v %v", ms, err)
+		}
+	})
+
+	t.Run("调整库存和下单都记入流水", func(t *testing.T) {
+		change := types.StockChange{Type: types.StockRestock, Reason: "delivery", ActorID: u.ID}
+		if _, err := stores.Products.AdjustStock(ctx, ps[0].ID, 2, change); err != nil {
+			t.Fatal(err)
+		}
+		// 没有变化时不记流水
+		if _, err := stores.Products.AdjustStock(ctx, ps[0].ID, 0, types.StockChange{Reason: "noop"}); err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := stores.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 199.6, Status: types.OrderPending, Address: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stores.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: ps[0].ID, Quantity: 4, Price: 49.9}); err != nil {
+			t.Fatal(err)
+		}
+
+		ms, err := s.GetStockMovements(ctx, ps[0].ID)
+		if err != nil || len(ms) != 3 {
+			t.Fatalf("expected 3 movements, got %+v %v", ms, err)
+		}
+		sale, restock := ms[0], ms[1]
+		if sale.Type != types.StockSale || sale.Delta != -4 || sale.Balance != 1 || sale.OrderID == nil || *sale.OrderID != orderID ||
+			sale.ActorID == nil || *sale.ActorID != u.ID {
+			t.Errorf("unexpected sale: %+v", sale)
+		}
+		if restock.Type != types.StockRestock || restock.Delta != 2 || restock.Balance != 5 || restock.Reason != "delivery" ||
+			restock.ActorID == nil || *restock.ActorID != u.ID || restock.OrderID != nil {
+			t.Errorf("unexpected restock: %+v", restock)
+		}
+		if sale.ID <= restock.ID || sale.CreatedAt.IsZero() {
+			t.Errorf("expected the newest movement first, got %+v", ms)
+		}
+	})
+
+	t.Run("按流水累计库存", func(t *testing.T) {
+		bs, err := s.GetStockBalances(ctx)
+		if err != nil || len(bs) != 2 {
+			t.Fatalf("expected 2 balances, got %+v %v", bs, err)
+		}
+		for _, b := range bs {
+			if b.Quantity != b.Ledger {
+				t.Errorf("expected the ledger to match the stock, got %+v", b)
+			}
+		}
+		if b := bs[0]; b.VariantID != vs[0].ID || b.ProductID != ps[0].ID || b.SKU != vs[0].SKU || b.Quantity != 1 {
+			t.Errorf("unexpected balance: %+v", b)
+		}
+
+		// 直接追加的流水不改变库存
+		m := &types.StockMovement{ProductID: ps[0].ID, VariantID: vs[0].ID, Type: types.StockAdjustment, Delta: -1, Balance: 1, Reason: "correction"}
+		if err := s.RecordStockMovement(ctx, m); err != nil || m.ID <= 0 {
+			t.Fatalf("record movement: %+v %v", m, err)
+		}
+		bs, _ = s.GetStockBalances(ctx)
+		if bs[0].Quantity != 1 || bs[0].Ledger != 0 {
+			t.Errorf("unexpected balance: %+v", bs[0])
+		}
+		if err := s.RecordStockMovement(ctx, &types.StockMovement{ProductID: ps[0].ID, VariantID: 9999, Type: types.StockAdjustment}); err == nil {
+			t.Error("expected an error for a missing variant")
+		}
+	})
+
+	t.Run("补货阈值", func(t *testing.T) {
+		if err := s.SetReorderThreshold(ctx, ps[0].ID, 2); err != nil {
+			t.Fatal(err)
+		}
+		// 设为相同的值也不算不存在
+		if err := s.SetReorderThreshold(ctx, ps[0].ID, 2); err != nil {
+			t.Fatal(err)
+		}
+		thresholds, err := s.GetReorderThresholds(ctx, []int{ps[0].ID, ps[1].ID, 9999})
+		if err != nil || len(thresholds) != 2 || thresholds[ps[0].ID] != 2 || thresholds[ps[1].ID] != 0 {
+			t.Errorf("unexpected thresholds: %v %v", thresholds, err)
+		}
+		if err := s.SetReorderThreshold(ctx, 9999, 1); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("到货订阅", func(t *testing.T) {
+		sub := &types.StockSubscription{UserID: u.ID, ProductID: ps[1].ID}
+		if err := s.CreateStockSubscription(ctx, sub); err != nil || sub.ID <= 0 {
+			t.Fatalf("create subscription: %+v %v", sub, err)
+		}
+		if err := s.CreateStockSubscription(ctx, &types.StockSubscription{UserID: u.ID, ProductID: ps[1].ID}); !apperr.IsKind(err, apperr.KindConflict) {
+			t.Errorf("expected conflict, got %v", err)
+		}
+		// 指定变体的订阅与整个产品的订阅不冲突
+		variantID := vs[0].ID
+		bySKU := &types.StockSubscription{UserID: u.ID, ProductID: ps[0].ID, VariantID: &variantID}
+		if err := s.CreateStockSubscription(ctx, bySKU); err != nil {
+			t.Fatal(err)
+		}
+
+		subs, err := s.GetPendingStockSubscriptions(ctx)
+		if err != nil || len(subs) != 2 || subs[0].ID != sub.ID || subs[0].VariantID != nil ||
+			subs[1].VariantID == nil || *subs[1].VariantID != variantID || subs[1].CreatedAt.IsZero() {
+			t.Fatalf("unexpected subscriptions: %+v %v", subs, err)
+		}
+
+		if ok, err := s.ClaimStockSubscription(ctx, sub.ID); err != nil || !ok {
+			t.Fatalf("expected to claim the subscription, got %v %v", ok, err)
+		}
+		// 已经领取的订阅不能再领取
+		if ok, err := s.ClaimStockSubscription(ctx, sub.ID); err != nil || ok {
+			t.Errorf("expected the subscription to be claimed once, got %v %v", ok, err)
+		}
+		if subs, _ := s.GetPendingStockSubscriptions(ctx); len(subs) != 1 || subs[0].ID != bySKU.ID {
+			t.Errorf("expected only the variant subscription to be pending, got %+v", subs)
+		}
+		// 放回后重新等待通知
+		if err := s.ReleaseStockSubscription(ctx, bySKU.ID); err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := s.ClaimStockSubscription(ctx, bySKU.ID); !ok {
+			t.Error("expected to claim the variant subscription")
+		}
+		if err := s.ReleaseStockSubscription(ctx, bySKU.ID); err != nil {
+			t.Fatal(err)
+		}
+		if subs, _ := s.GetPendingStockSubscriptions(ctx); len(subs) != 1 || subs[0].ID != bySKU.ID {
+			t.Errorf("expected the released subscription to be pending, got %+v", subs)
+		}
+		// 通知过之后可以再次订阅
+		if err := s.CreateStockSubscription(ctx, &types.StockSubscription{UserID: u.ID, ProductID: ps[1].ID}); err != nil {
+			t.Errorf("expected to subscribe again, got %v", err)
+		}
+		if _, err := s.ClaimStockSubscription(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("ClaimStockSubscription: expected not found, got %v", err)
+		}
+		if err := s.ReleaseStockSubscription(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("ReleaseStockSubscription: expected not found, got %v", err)
+		}
+	})
+}
+
+func testProductPrices(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	// 价格按秒存储，测试用的时间都取整到秒
+	now := time.Now().UTC().Truncate(time.Second)
+
+	setup := func(t *testing.T) (types.Stores, types.Product) {
+		stores := factory(t, []types.Product{{Name: "keyboard", Price: 49.9, Quantity: 3}})
+		ps, err := stores.Products.GetProducts(ctx)
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("seed products: %v %v", ps, err)
+		}
+		return stores, ps[0]
+	}
+
+	t.Run("初始价格和修改价格", func(t *testing.T) {
+		stores, p := setup(t)
+		s := stores.Products
+
+		prices, err := s.GetPrices(ctx, p.ID)
+		if err != nil || len(prices) != 1 {
+			t.Fatalf("expected the opening price, got %+v %v", prices, err)
+		}
+		if pr := prices[0]; pr.Price != 49.9 || pr.EffectiveTo != nil || pr.CompareAtPrice != nil || p.PriceID == nil || *p.PriceID != pr.ID {
+			t.Errorf("unexpected opening price %+v for %+v", pr, p)
+		}
+
+		// 价格没变时不记新价格
+		p.Name = "mechanical keyboard"
+		if err := s.UpdateProduct(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		if prices, _ := s.GetPrices(ctx, p.ID); len(prices) != 1 {
+			t.Errorf("expected no new price row, got %+v", prices)
+		}
+
+		p.Price = 59.9
+		if err := s.UpdateProduct(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		prices, _ = s.GetPrices(ctx, p.ID)
+		if len(prices) != 2 {
+			t.Fatalf("expected the old price to be kept, got %+v", prices)
+		}
+		got, _ := s.GetProductByIDs(ctx, []int{p.ID})
+		if len(got) != 1 || got[0].Price != 59.9 || got[0].Name != "mechanical keyboard" || *got[0].PriceID == *p.PriceID {
+			t.Errorf("unexpected product after price change: %+v", got)
+		}
+	})
+
+	t.Run("促销价和计划价格", func(t *testing.T) {
+		stores, p := setup(t)
+		s := stores.Products
+
+		compareAt := 49.9
+		end := now.Add(time.Hour)
+		sale := &types.ProductPrice{ProductID: p.ID, Price: 39.9, CompareAtPrice: &compareAt, EffectiveFrom: now.Add(-time.Minute), EffectiveTo: &end}
+		if err := s.CreatePrice(ctx, sale); err != nil || sale.ID <= 0 {
+			t.Fatalf("create sale: %+v %v", sale, err)
+		}
+		future := &types.ProductPrice{ProductID: p.ID, Price: 44.9, EffectiveFrom: now.Add(24 * time.Hour)}
+		if err := s.CreatePrice(ctx, future); err != nil {
+			t.Fatal(err)
+		}
+
+		// 促销期内促销价优先，并带上原价
+		got, _ := s.GetProductByIDs(ctx, []int{p.ID})
+		if len(got) != 1 || got[0].Price != 39.9 || got[0].CompareAtPrice == nil || *got[0].CompareAtPrice != 49.9 || *got[0].PriceID != sale.ID {
+			t.Errorf("expected the sale price, got %+v", got)
+		}
+		// 促销期内修改常规价格不影响促销价
+		p.Price = 45.9
+		if err := s.UpdateProduct(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetProducts(ctx); len(got) != 1 || got[0].Price != 39.9 {
+			t.Errorf("expected the sale to win over a regular price, got %+v", got)
+		}
+
+		resolved, err := s.ResolvePrices(ctx, []int{p.ID, 9999}, now.Add(2*time.Hour))
+		if err != nil || len(resolved) != 1 || resolved[p.ID].Price != 45.9 {
+			t.Errorf("expected the regular price after the sale, got %+v %v", resolved, err)
+		}
+		resolved, _ = s.ResolvePrices(ctx, []int{p.ID}, now.Add(25*time.Hour))
+		if pr := resolved[p.ID]; pr.ID != future.ID || pr.Price != 44.9 {
+			t.Errorf("expected the scheduled price, got %+v", pr)
+		}
+		if resolved, _ := s.ResolvePrices(ctx, []int{p.ID}, now.Add(-24*time.Hour)); len(resolved) != 0 {
+			t.Errorf("expected no price before the product existed, got %+v", resolved)
+		}
+
+		prices, _ := s.GetPrices(ctx, p.ID)
+		if len(prices) != 4 {
+			t.Fatalf("expected 4 price rows, got %+v", prices)
+		}
+		for i := 1; i < len(prices); i++ {
+			if prices[i].EffectiveFrom.Before(prices[i-1].EffectiveFrom) {
+				t.Errorf("expected prices ordered by effectiveFrom, got %+v", prices)
+			}
+		}
+		var stored types.ProductPrice
+		for _, pr := range prices {
+			if pr.ID == sale.ID {
+				stored = pr
+			}
+		}
+		if !stored.EffectiveFrom.Equal(sale.EffectiveFrom) || stored.EffectiveTo == nil || !stored.EffectiveTo.Equal(end) {
+			t.Errorf("expected the sale window to round-trip, got %+v", stored)
+		}
+	})
+
+	t.Run("结束和删除价格", func(t *testing.T) {
+		stores, p := setup(t)
+		s := stores.Products
+
+		end := now.Add(time.Hour)
+		sale := &types.ProductPrice{ProductID: p.ID, Price: 39.9, EffectiveFrom: now.Add(-time.Minute), EffectiveTo: &end}
+		if err := s.CreatePrice(ctx, sale); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetProductByIDs(ctx, []int{p.ID}); got[0].Price != 39.9 {
+			t.Fatalf("expected the sale price, got %+v", got)
+		}
+		if err := s.EndPrice(ctx, sale.ID, now.Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetProductByIDs(ctx, []int{p.ID}); got[0].Price != 49.9 {
+			t.Errorf("expected the opening price after ending the sale, got %+v", got)
+		}
+
+		future := &types.ProductPrice{ProductID: p.ID, Price: 29.9, EffectiveFrom: now.Add(time.Hour)}
+		if err := s.CreatePrice(ctx, future); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeletePrice(ctx, future.ID); err != nil {
+			t.Fatal(err)
+		}
+		if prices, _ := s.GetPrices(ctx, p.ID); len(prices) != 2 {
+			t.Errorf("expected the future price to be deleted, got %+v", prices)
+		}
+
+		if err := s.EndPrice(ctx, 9999, now); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("EndPrice: expected not found, got %v", err)
+		}
+		if err := s.DeletePrice(ctx, 9999); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("DeletePrice: expected not found, got %v", err)
+		}
+		err := s.CreatePrice(ctx, &types.ProductPrice{ProductID: 9999, Price: 1, EffectiveFrom: now})
+		if !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("CreatePrice: expected not found, got %v", err)
+		}
+	})
+}
+
+func testTxManager(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	t.Run("提交后可见", func(t *testing.T) {
+		stores := factory(t, nil)
+
+		err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+			return tx.Users.CreateUser(ctx, newUser("commit@example.com"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stores.Users.GetUserByEmail(ctx, "commit@example.com"); err != nil {
+			t.Fatalf("committed user not visible: %v", err)
+		}
+	})
+
+	t.Run("出错时回滚所有 store 的修改", func(t *testing.T) {
+		stores := factory(t, []types.Product{{Name: "keyboard", Price: 10, Quantity: 3}})
+		buyer := newUser("buyer@example.com")
+		if err := stores.Users.CreateUser(ctx, buyer); err != nil {
+			t.Fatal(err)
+		}
+		ps, _ := stores.Products.GetProducts(ctx)
+
+		err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+			if err := tx.Users.CreateUser(ctx, newUser("rollback@example.com")); err != nil {
+				return err
+			}
+			orderID, err := tx.Orders.CreateOrder(ctx, types.Order{UserID: buyer.ID, Total: 20, Status: types.OrderPending, Address: "x"})
+			if err != nil {
+				return err
+			}
+			if err := tx.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: ps[0].ID, Quantity: 2, Price: 10}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("expected abort error, got %v", err)
+		}
+		if _, err := stores.Users.GetUserByEmail(ctx, "rollback@example.com"); !apperr.IsKind(err, apperr.KindNotFound) {
+			t.Errorf("user created in rolled back tx is visible: %v", err)
+		}
+		if got := quantityOf(t, stores, ps[0].ID); got != 3 {
+			t.Errorf("stock not restored after rollback: %d", got)
+		}
+	})
+
+	t.Run("嵌套事务使用保存点", func(t *testing.T) {
+		stores := factory(t, nil)
+
+		err := stores.Tx.WithinTx(ctx, func(tx types.Stores) error {
+			if err := tx.Users.CreateUser(ctx, newUser("outer@example.com")); err != nil {
+				return err
+			}
+			// 内层失败只回滚内层，外层继续提交
+			err := tx.Tx.WithinTx(ctx, func(inner types.Stores) error {
+				if err := inner.Users.CreateUser(ctx, newUser("inner@example.com")); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Errorf("expected abort error from inner tx, got %v", err)
+			}
+			// 内层成功的修改随外层一起提交
+			return tx.Tx.WithinTx(ctx, func(inner types.Stores) error {
+				return inner.Users.CreateUser(ctx, newUser("inner2@example.com"))
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for email, want := range map[string]bool{"outer@example.com": true, "inner@example.com": false, "inner2@example.com": true} {
+			_, err := stores.Users.GetUserByEmail(ctx, email)
+			if got := err == nil; got != want {
+				t.Errorf("%s: expected exists=%v, got err %v", email, want, err)
+			}
+		}
+	})
+}
+
+func quantityOf(t *testing.T, stores types.Stores, id int) int {
+	t.Helper()
+	ps, err := stores.Products.GetProductByIDs(context.Background(), []int{id})
+	if err != nil || len(ps) != 1 {
+		t.Fatalf("get product %d: %v %v", id, ps, err)
+	}
+	return ps[0].Quantity
+}
+
+func sortProducts(ps []types.Product) {
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Albert-tru/ecom/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Albert-tru/ecom"
+
+// 存储层 span 上记录的数据库类型，启动时根据 DB_DRIVER 设置
+var dbSystem = semconv.DBSystemNameMySQL
+
+// SetDBSystem 设置存储层 span 上的 db.system.name 属性
+func SetDBSystem(name string) {
+	switch name {
+	case "postgres":
+		dbSystem = semconv.DBSystemNamePostgreSQL
+	case "sqlite":
+		dbSystem = semconv.DBSystemNameSQLite
+	default:
+		dbSystem = semconv.DBSystemNameKey.String(name)
+	}
+}
+
+// Init 根据配置初始化全局 TracerProvider 和 W3C traceparent 传播器
+// 返回的函数用于在程序退出前把缓冲中的 span 全部导出
+func Init(ctx context.Context, cfg config.Config) (func(context.Context) error, error) {
+	// 无论是否导出，都要能解析和透传上游的 traceparent
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var closers []io.Closer
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TraceSampleRatio))),
+	}
+
+	for _, name := range strings.Split(cfg.TraceExporters, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		switch name {
+		case "", "none":
+			continue
+		case "otlp":
+			var otlpOpts []otlptracehttp.Option
+			if cfg.TraceOTLPEndpoint != "" {
+				otlpOpts = append(otlpOpts, otlptracehttp.WithEndpointURL(cfg.TraceOTLPEndpoint))
+			}
+			exp, err := otlptracehttp.New(ctx, otlpOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("create otlp exporter: %w", err)
+			}
+			opts = append(opts, sdktrace.WithBatcher(exp))
+		case "stdout":
+			exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+			if err != nil {
+				return nil, fmt.Errorf("create stdout exporter: %w", err)
+			}
+			opts = append(opts, sdktrace.WithSyncer(exp))
+		case "file":
+			f, err := os.OpenFile(cfg.TraceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("open trace file: %w", err)
+			}
+			closers = append(closers, f)
+			exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+			if err != nil {
+				return nil, fmt.Errorf("create file exporter: %w", err)
+			}
+			opts = append(opts, sdktrace.WithBatcher(exp))
+		default:
+			return nil, fmt.Errorf("unknown trace exporter: %s", name)
+		}
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, sdktrace.WithResource(res))
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	log.Printf("tracing enabled, exporters=%q", cfg.TraceExporters)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		for _, c := range closers {
+			err = errors.Join(err, c.Close())
+		}
+		return err
+	}, nil
+}
+
+// Tracer 返回本项目统一使用的 tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 开启一个普通的内部 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartStoreSpan 为一次存储层调用开启 client span，并记录 SQL 语句
+func StartStoreSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// End 结束 span，err 不为空时记录错误并把状态标记为 Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,27 +1,26 @@
 package types
 
-import "time"
+import (
+	"context"
+	"time"
 
-type UserStore interface {
-	GetUserByEmail(email string) (*User, error)
-	GetUserByID(id int) (*User, error)
-	CreateUser(u *User) error
-}
+	"github.com/Albert-tru/ecom/apperr"
+)
 
-type mockUserStore struct {
-}
-
-func GetUserByEmail(email string) (*User, error) {
-	return nil, nil
-}
-
-func GetUserByID(id int) (*User, error) {
-	return nil, nil
+type UserStore interface {
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	GetUsers(ctx context.Context) ([]User, error)
+	CreateUser(ctx context.Context, u *User) error
+	UpdatePassword(ctx context.Context, id int, hashedPassword string) error
+	SetDisabled(ctx context.Context, id int, disabled bool) error
 }
 
-func CreateUser(u *User) error {
-	return nil
-}
+// 用户角色，只有管理员可以访问 /admin 下的接口
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
 
 type User struct {
 	ID        int       `json:"id"`
@@ -29,6 +28,8 @@ type User struct {
 	Lastname  string    `json:"lastname"`
 	Email     string    `json:"email"`
 	Password  string    `json:"password"`
+	Role      string    `json:"role"`     // 为空时按 customer 创建
+	Disabled  bool      `json:"disabled"` // 被禁用的账号不能登录，已签发的 token 也会失效
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -44,26 +45,485 @@ type LoginrUserPayload struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type LoginResponse struct {
+	Message string `json:"message"`
+	UserID  string `json:"user_id"`
+	Token   string `json:"token"`
+}
+
 type ProductStore interface {
-	GetProducts() ([]Product, error)
-	GetProductByIDs(ps []int) ([]Product, error)
+	GetProducts(ctx context.Context) ([]Product, error)
+	// GetProductsAfter 按ID顺序返回ID大于 afterID 的最多 limit 个产品，用于分批遍历所有产品
+	GetProductsAfter(ctx context.Context, afterID, limit int) ([]Product, error)
+	GetProductByIDs(ctx context.Context, ps []int) ([]Product, error)
+	// CreateProduct 创建产品以及它的默认变体（SKU-<ID>），初始库存放在默认变体上
+	CreateProduct(ctx context.Context, p *Product) error
+	// UpdateProduct 修改产品的名称、描述、图片和价格，库存只能通过调整变体的库存修改
+	// 价格与当前售价不同时记录一条从现在开始的长期价格，之前的价格保留为历史
+	UpdateProduct(ctx context.Context, p *Product) error
+	// AdjustStock 调整只有一个变体的产品的库存，产品有多个变体时返回 variant_required
+	AdjustStock(ctx context.Context, id, delta int, change StockChange) (int, error)
+
+	// GetPrices 返回产品的所有价格记录（包括已经结束的），按生效时间排序
+	GetPrices(ctx context.Context, productID int) ([]ProductPrice, error)
+	// ResolvePrices 返回每个产品在 at 时刻生效的价格记录，没有生效价格的产品不出现在结果中
+	ResolvePrices(ctx context.Context, productIDs []int, at time.Time) (map[int]ProductPrice, error)
+	// CreatePrice 添加一条价格记录并写回分配的ID，产品不存在时返回 product_not_found
+	CreatePrice(ctx context.Context, price *ProductPrice) error
+	// EndPrice 让价格记录在 at 时刻结束，用于提前结束正在生效的价格
+	EndPrice(ctx context.Context, id int, at time.Time) error
+	// DeletePrice 删除价格记录，只应用于还没有生效的价格
+	DeletePrice(ctx context.Context, id int) error
 }
 
 type Product struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"imageUrl"`
-	Quantity    int       `json:"quantity"`
-	Price       float64   `json:"price"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	ImageURL      string    `json:"imageUrl"`
+	Quantity      int       `json:"quantity"`      // 所有变体库存之和，由 store 在修改变体库存时同步
+	Price         float64   `json:"price"`         // 当前售价（读取时按价格计划解析），变体没有单独定价时使用
+	RatingAverage float64   `json:"ratingAverage"` // 已通过审核的评价的平均分，保留两位小数，由 ReviewStore 同步
+	RatingCount   int       `json:"ratingCount"`   // 已通过审核的评价数
+	CreatedAt     time.Time `json:"createdAt"`
+	// CompareAtPrice 当前价格的划线价（促销前的价格），没有时为空
+	CompareAtPrice *float64 `json:"compareAtPrice,omitempty"`
+	// PriceID 当前售价来自哪条价格记录，没有生效的价格记录时为空
+	PriceID *int `json:"priceId,omitempty"`
+	// SaleEndsAt 当前售价是促销价（有结束时间的价格记录）时促销的结束时间；促销期间单独定价的变体也按促销价出售
+	SaleEndsAt *time.Time `json:"saleEndsAt,omitempty"`
+}
+
+// ProductPrice 价格计划中的一条记录，在 [EffectiveFrom, EffectiveTo) 内生效，EffectiveTo 为空表示一直有效
+// 同一时刻有多条生效时，有结束时间的价格（促销）优先于长期价格，同类中开始时间最晚的优先
+type ProductPrice struct {
+	ID             int        `json:"id"`
+	ProductID      int        `json:"productId"`
+	Price          float64    `json:"price"`
+	CompareAtPrice *float64   `json:"compareAtPrice"` // 划线价，促销时展示原价
+	EffectiveFrom  time.Time  `json:"effectiveFrom"`
+	EffectiveTo    *time.Time `json:"effectiveTo"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// ProductPricePayload 安排价格：不指定 effectiveFrom 时立即生效，不指定 effectiveTo 时一直有效
+// compareAtPrice 必须高于 price
+type ProductPricePayload struct {
+	Price          float64    `json:"price" validate:"required,gt=0"`
+	CompareAtPrice *float64   `json:"compareAtPrice" validate:"omitempty,gt=0"`
+	EffectiveFrom  *time.Time `json:"effectiveFrom"`
+	EffectiveTo    *time.Time `json:"effectiveTo"`
+}
+
+type VariantStore interface {
+	// GetOptions 返回产品的规格类型，按 position 排序
+	GetOptions(ctx context.Context, productID int) ([]string, error)
+	// SetOptions 替换产品的规格类型，被删除的规格在各个变体上的取值也会删除
+	SetOptions(ctx context.Context, productID int, names []string) error
+	// GetVariants 返回产品的所有变体，按ID排序
+	GetVariants(ctx context.Context, productID int) ([]ProductVariant, error)
+	// GetVariantsByIDs 返回存在的那部分变体，按ID排序
+	GetVariantsByIDs(ctx context.Context, ids []int) ([]ProductVariant, error)
+	// GetVariantsByProductIDs 返回这些产品的所有变体，按产品ID、变体ID排序
+	GetVariantsByProductIDs(ctx context.Context, productIDs []int) ([]ProductVariant, error)
+	GetVariantBySKU(ctx context.Context, sku string) (*ProductVariant, error)
+	// CreateVariant 创建变体，并把分配的ID写回 v.ID；v.Quantity 会计入产品的库存
+	CreateVariant(ctx context.Context, v *ProductVariant) error
+	// UpdateVariant 修改变体的 SKU、价格、图片和规格取值，库存只能通过 AdjustVariantStock 修改
+	UpdateVariant(ctx context.Context, v *ProductVariant) error
+	// AdjustVariantStock 把变体的库存增加 delta（可以为负）并返回调整后的库存，库存不能被调整为负数
+	// 每次调整都在库存流水中记录一条 change 描述的变动
+	AdjustVariantStock(ctx context.Context, variantID, delta int, change StockChange) (int, error)
+}
+
+// ProductVariant 产品的一个可购买的变体（如 M 码红色），库存按变体管理
+type ProductVariant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"productId"`
+	SKU       string            `json:"sku"`
+	Price     *float64          `json:"price"` // 为空时使用产品的价格，产品促销期间使用促销价
+	Quantity  int               `json:"quantity"`
+	ImageURL  string            `json:"imageUrl"` // 为空时使用产品的图片
+	Options   map[string]string `json:"options"`  // 规格名 -> 取值
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// ProductVariants 产品的规格类型和所有变体
+type ProductVariants struct {
+	ProductID int              `json:"productId"`
+	Options   []string         `json:"options"`
+	Variants  []ProductVariant `json:"variants"`
+}
+
+type ProductOptionsPayload struct {
+	Options []string `json:"options" validate:"required,dive,required,max=50"`
+}
+
+// VariantPayload 修改变体时使用；Options 的键必须与产品的规格类型一致
+type VariantPayload struct {
+	SKU      string            `json:"sku" validate:"required,max=64"`
+	Price    *float64          `json:"price" validate:"omitempty,gt=0"`
+	ImageURL string            `json:"imageUrl" validate:"omitempty,max=255"`
+	Options  map[string]string `json:"options" validate:"dive,keys,required,max=50,endkeys,required,max=100"`
+}
+
+// CreateVariantPayload 创建变体时可以同时指定初始库存
+type CreateVariantPayload struct {
+	VariantPayload
+	Quantity int `json:"quantity" validate:"min=0"`
+}
+
+type ImageStore interface {
+	// GetImages 返回产品的图片及缩略图，按 position 排序
+	GetImages(ctx context.Context, productID int) ([]ProductImage, error)
+	GetImage(ctx context.Context, id int) (*ProductImage, error)
+	// CreateImage 把图片（连同缩略图）追加到产品图片的末尾，并写回分配的 ID 和 Position
+	CreateImage(ctx context.Context, img *ProductImage) error
+	// DeleteImage 删除图片及其缩略图，排在它后面的图片依次前移
+	DeleteImage(ctx context.Context, id int) error
+	// ReorderImages 按 imageIDs 的顺序重排产品的图片，imageIDs 必须恰好是产品的所有图片
+	ReorderImages(ctx context.Context, productID int, imageIDs []int) error
+}
+
+// ProductImage 产品的一张图片，Position 为 0 的是主图
+// Key 是文件在存储中的路径，URL 由 service 根据存储生成（可能是带有效期的签名地址），不保存在数据库中
+type ProductImage struct {
+	ID          int              `json:"id"`
+	ProductID   int              `json:"productId"`
+	Position    int              `json:"position"`
+	Key         string           `json:"key"`
+	ContentType string           `json:"contentType"`
+	Width       int              `json:"width"`
+	Height      int              `json:"height"`
+	Size        int64            `json:"size"`
+	URL         string           `json:"url"`
+	Thumbnails  []ImageThumbnail `json:"thumbnails"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// ImageThumbnail 按 Size（最长边的像素数）等比缩小的图片，原图更小时不放大
+type ImageThumbnail struct {
+	Size   int    `json:"size"`
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+type ReorderImagesPayload struct {
+	ImageIDs []int `json:"imageIds" validate:"required,dive,min=1"`
+}
+
+// ProductRecord 批量导入导出中的一行，对应一个变体，按 SKU 匹配：SKU 已存在时更新，否则创建新产品
+// 导入时为空（nil）的字段保持不变，新产品必须提供 name 和 price
+// price 是变体的售价：产品只有一个变体时修改产品价格，否则修改变体自己的价格；quantity 是变体的库存
+type ProductRecord struct {
+	SKU         string   `json:"sku" validate:"required,max=64"`
+	Name        *string  `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string  `json:"description,omitempty"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Quantity    *int     `json:"quantity,omitempty" validate:"omitempty,min=0"`
+	ImageURL    *string  `json:"imageUrl,omitempty" validate:"omitempty,max=255"`
+}
+
+// ImportResult 一次导入的汇总，DryRun 时计数表示将要发生的修改
+type ImportResult struct {
+	DryRun    bool             `json:"dryRun"`
+	Total     int              `json:"total"` // 数据行数，不含表头和空行
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Failed    int              `json:"failed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// ImportRowError 一行导入失败的原因，失败的行不影响其它行
+type ImportRowError struct {
+	Line    int                 `json:"line"` // 文件中的行号，CSV 的表头是第 1 行
+	SKU     string              `json:"sku,omitempty"`
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Fields  []apperr.FieldError `json:"fields,omitempty"`
+}
+
+// 导入任务的状态
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob 在后台执行的导入任务，Processed 是已处理的行数
+type ImportJob struct {
+	ID         string        `json:"id"`
+	Status     string        `json:"status"`
+	DryRun     bool          `json:"dryRun"`
+	Total      int           `json:"total"`
+	Processed  int           `json:"processed"`
+	Result     *ImportResult `json:"result,omitempty"` // 完成后才有
+	Error      string        `json:"error,omitempty"`  // 状态为 failed 时的原因
+	CreatedAt  time.Time     `json:"createdAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// StockStore 库存流水，只能追加；流水由调整库存的 store 方法在同一个事务中写入
+type StockStore interface {
+	// GetStockMovements 返回产品所有变体的库存变动，按ID倒序（最新的在前）
+	GetStockMovements(ctx context.Context, productID int) ([]StockMovement, error)
+	// GetStockBalances 返回每个变体的库存和按流水累计的库存，按变体ID排序
+	GetStockBalances(ctx context.Context) ([]StockBalance, error)
+	// RecordStockMovement 追加一条不改变库存的流水，用于对账时补记差异
+	RecordStockMovement(ctx context.Context, m *StockMovement) error
+
+	// GetReorderThresholds 返回产品ID -> 补货阈值，0 表示不告警；不存在的产品不在结果中
+	GetReorderThresholds(ctx context.Context, productIDs []int) (map[int]int, error)
+	// SetReorderThreshold 设置产品的补货阈值，产品不存在时返回 product_not_found
+	SetReorderThreshold(ctx context.Context, productID, threshold int) error
+
+	// CreateStockSubscription 创建到货订阅并写回分配的 ID
+	// 同一个用户对同一个产品（或变体）已经有未通知的订阅时返回 subscription_exists
+	CreateStockSubscription(ctx context.Context, sub *StockSubscription) error
+	// GetPendingStockSubscriptions 返回还没有通知过的订阅，按ID排序
+	GetPendingStockSubscriptions(ctx context.Context) ([]StockSubscription, error)
+	// ClaimStockSubscription 在发通知之前把还没有通知过的订阅记为已通知，之后不再通知
+	// 返回 false 表示订阅已经被其它检查记为已通知，调用方不应再发；订阅不存在时返回 subscription_not_found
+	ClaimStockSubscription(ctx context.Context, id int) (bool, error)
+	// ReleaseStockSubscription 清除订阅的通知时间（通知发送失败时调用），下次检查时重新通知
+	ReleaseStockSubscription(ctx context.Context, id int) error
+}
+
+// 库存变动的类型
+const (
+	StockSale       = "sale"       // 下单扣减
+	StockReturn     = "return"     // 退货入库
+	StockRestock    = "restock"    // 进货（包括创建产品、变体时的初始库存）
+	StockAdjustment = "adjustment" // 人工调整：盘点、报损、导入等
+	StockRelease    = "release"    // 取消订单释放占用的库存
+)
+
+// StockChange 调整库存时说明变动的原因，ActorID 和 OrderID 为 0 表示没有
+type StockChange struct {
+	Type    string
+	Reason  string
+	ActorID int // 操作的用户，系统和 ecomctl 的操作为 0
+	OrderID int // 关联的订单
+}
+
+// StockMovement 库存流水中的一条记录，Balance 是变动后变体的库存
+type StockMovement struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"productId"`
+	VariantID int       `json:"variantId"`
+	Type      string    `json:"type"`
+	Delta     int       `json:"delta"`
+	Balance   int       `json:"balance"`
+	Reason    string    `json:"reason"`
+	ActorID   *int      `json:"actorId"`
+	OrderID   *int      `json:"orderId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// StockBalance 变体的库存与流水累计值，两者不一致说明库存被绕过流水修改过
+type StockBalance struct {
+	ProductID int    `json:"productId"`
+	VariantID int    `json:"variantId"`
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+	Ledger    int    `json:"ledger"`
+}
+
+// StockSubscription 用户订阅的到货通知，VariantID 为空表示产品的任意变体；只通知一次
+type StockSubscription struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	ProductID  int        `json:"productId"`
+	VariantID  *int       `json:"variantId"`
+	NotifiedAt *time.Time `json:"notifiedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// StockSubscriptionPayload 订阅到货通知，请求体可以省略，表示产品的任意变体
+type StockSubscriptionPayload struct {
+	VariantID int `json:"variantId" validate:"min=0"`
+}
+
+// ReorderThresholdPayload 设置补货阈值，0 表示不告警
+type ReorderThresholdPayload struct {
+	Threshold int `json:"threshold" validate:"min=0"`
+}
+
+// StockLevel 产品的库存和补货阈值
+type StockLevel struct {
+	ProductID        int `json:"productId"`
+	Quantity         int `json:"quantity"`
+	ReorderThreshold int `json:"reorderThreshold"`
+}
+
+// StockAdjustmentPayload 管理员调整库存，产品只有一个变体时可以省略 variantId
+type StockAdjustmentPayload struct {
+	VariantID int    `json:"variantId" validate:"min=0"`
+	Delta     int    `json:"delta" validate:"required"`
+	Type      string `json:"type" validate:"omitempty,oneof=restock return adjustment"` // 默认为 adjustment
+	Reason    string `json:"reason" validate:"required,max=255"`
+}
+
+type ReviewStore interface {
+	// GetReviews 返回产品的评价，status 为空时返回所有状态，按创建时间倒序
+	GetReviews(ctx context.Context, productID int, status string) ([]Review, error)
+	// GetReviewsByStatus 返回所有产品中某个状态的评价，按创建时间正序，用于审核
+	GetReviewsByStatus(ctx context.Context, status string) ([]Review, error)
+	GetReviewByID(ctx context.Context, id int) (*Review, error)
+	// CreateReview 创建评价并写回分配的 ID，用户已经评价过该产品时返回 review_exists
+	CreateReview(ctx context.Context, r *Review) error
+	// UpdateReview 修改评分、标题、正文和审核状态，并重新计算产品的评分
+	UpdateReview(ctx context.Context, r *Review) error
+}
+
+// 评价的审核状态，新建和修改过的评价需要重新审核
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review 用户对产品的评价，只有买过该产品（订单已送达）的用户可以评价
+type Review struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"productId"`
+	UserID    int       `json:"userId"`
+	Rating    int       `json:"rating"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ReviewPayload struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title" validate:"required,max=255"`
+	Body   string `json:"body" validate:"required,max=5000"`
+}
+
+type ReviewStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=approved rejected"`
+}
+
+type CategoryStore interface {
+	GetCategories(ctx context.Context) ([]Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (*Category, error)
+	CreateCategory(ctx context.Context, c *Category) error
+	UpdateCategory(ctx context.Context, c *Category) error
+	DeleteCategory(ctx context.Context, id int) error
+	// SetProductCategories 用 categoryIDs 替换产品现有的分类
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error
+	GetProductCategoryIDs(ctx context.Context, productID int) ([]int, error)
+	// GetProductsByCategoryIDs 返回属于任意一个分类的产品（去重，按ID排序）
+	GetProductsByCategoryIDs(ctx context.Context, categoryIDs []int) ([]Product, error)
+	// GetCategoryIDsByProductIDs 批量返回产品所属的分类ID（产品ID -> 有序的分类ID），没有分类的产品不出现在结果中
+	GetCategoryIDsByProductIDs(ctx context.Context, productIDs []int) (map[int][]int, error)
+}
+
+// Category 分类，ParentID 为空的是顶级分类；Children 只在返回分类树时填充
+type Category struct {
+	ID        int        `json:"id"`
+	ParentID  *int       `json:"parentId"`
+	Name      string     `json:"name"`
+	Slug      string     `json:"slug"`
+	SortOrder int        `json:"sortOrder"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Children  []Category `json:"children,omitempty"`
+}
+
+// CategoryPayload 创建和修改分类时使用，slug 为空时根据名称生成
+type CategoryPayload struct {
+	ParentID  *int   `json:"parentId" validate:"omitempty,min=1"`
+	Name      string `json:"name" validate:"required,max=255"`
+	Slug      string `json:"slug" validate:"omitempty,max=100"`
+	SortOrder int    `json:"sortOrder"`
+}
+
+type ProductCategoriesPayload struct {
+	CategoryIDs []int `json:"categoryIds" validate:"required,dive,min=1"`
+}
+
+// ProductSearcher 在产品名称和描述上做全文检索
+// MySQL 使用 FULLTEXT 索引，其它存储使用 service/search 中的倒排索引
+type ProductSearcher interface {
+	// SearchProducts 返回与 query 相关的产品，按相关度从高到低排序；fuzzy 为 true 时允许词语有拼写错误
+	SearchProducts(ctx context.Context, query string, fuzzy bool) ([]SearchMatch, error)
+}
+
+// SearchMatch 一个命中的产品及其相关度，不同实现的分数不能相互比较
+type SearchMatch struct {
+	ProductID int
+	Score     float64
+}
+
+// SearchParams 搜索请求，Category、Price、InStock 为空时不过滤
+type SearchParams struct {
+	Query    string
+	Category string // 分类 slug，包含子分类
+	Price    string // 价格区间，取值见 search.PriceBuckets
+	InStock  *bool
+	Limit    int
+	Offset   int
+}
+
+// SearchResult 一页搜索结果以及分面统计，Total 是过滤后的总命中数
+type SearchResult struct {
+	Query  string       `json:"query"`
+	Total  int          `json:"total"`
+	Hits   []SearchHit  `json:"hits"`
+	Facets SearchFacets `json:"facets"`
+}
+
+type SearchHit struct {
+	Product    Product          `json:"product"`
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights 已做 HTML 转义的片段，命中的词用 <mark> 标出
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SearchFacets 每个分面的计数都应用了其它分面的过滤条件，但不应用自身的
+type SearchFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Price      []FacetCount `json:"price"`
+	InStock    []FacetCount `json:"inStock"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
 }
 
 type OrderStore interface {
-	CreateOrder(Order) (int, error)
-	CreateOrderItem(OrderItem) error
+	CreateOrder(context.Context, Order) (int, error)
+	CreateOrderItem(context.Context, OrderItem) error
+	GetOrderByID(ctx context.Context, id int) (*Order, error)
+	GetOrderItems(ctx context.Context, orderID int) ([]OrderItem, error)
+	UpdateOrderStatus(ctx context.Context, id int, status string) error
+	// HasPurchased 用户是否有包含该产品的已送达订单
+	HasPurchased(ctx context.Context, userID, productID int) (bool, error)
 }
 
+// 订单状态，允许的流转见 service/order
+// completed 表示商家已处理完订单并发货，delivered 表示买家已收到
+const (
+	OrderPending   = "pending"
+	OrderCompleted = "completed"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
 type Order struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"userId"`
@@ -77,16 +537,108 @@ type OrderItem struct {
 	ID        int       `json:"id"`
 	OrderID   int       `json:"orderId"`
 	ProductID int       `json:"productId"`
+	VariantID int       `json:"variantId"` // 为 0 时使用产品唯一的变体
 	Quantity  int       `json:"quantity"`
 	Price     float64   `json:"price"`
+	PriceID   *int      `json:"priceId"` // 价格来自哪条价格记录，按变体自己的价格成交时为空
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// CartItem 购物车中的一项，产品只有一个变体时可以只传 productId
 type CartItem struct {
-	ProductID int `json:"productId" validate:"required"`
+	ProductID int `json:"productId" validate:"required_without=VariantID"`
+	VariantID int `json:"variantId"`
 	Quantity  int `json:"quantity" validate:"required,min=1"`
 }
 
 type CartCheckoutPayload struct {
 	Items []CartItem `json:"items" validate:"required,dive"`
 }
+
+type UpdateOrderStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=pending completed delivered cancelled"`
+}
+
+type CheckoutResponse struct {
+	Status     string  `json:"status"`
+	OrderID    int     `json:"orderId"`
+	TotalPrice float64 `json:"totalPrice"`
+}
+
+type WishlistStore interface {
+	// GetWishlists 返回用户的所有心愿单（含产品），按ID排序
+	GetWishlists(ctx context.Context, userID int) ([]Wishlist, error)
+	GetWishlist(ctx context.Context, id int) (*Wishlist, error)
+	GetWishlistByShareToken(ctx context.Context, token string) (*Wishlist, error)
+	// CreateWishlist 创建心愿单并写回分配的 ID，同一个用户的心愿单名称重复时返回 wishlist_exists
+	CreateWishlist(ctx context.Context, w *Wishlist) error
+	DeleteWishlist(ctx context.Context, id int) error
+	// SetShareToken 设置分享链接的 token，token 为空时关闭分享
+	SetShareToken(ctx context.Context, id int, token string) error
+	// AddWishlistItem 把产品（或变体）加入心愿单并写回分配的 ID，已经在心愿单中时返回 wishlist_item_exists
+	AddWishlistItem(ctx context.Context, item *WishlistItem) error
+	RemoveWishlistItem(ctx context.Context, wishlistID, itemID int) error
+	// GetAllWishlistItems 返回所有心愿单中的产品，用于检查到货和降价
+	GetAllWishlistItems(ctx context.Context) ([]WishlistItem, error)
+	// UpdateWishlistItemState 记录检查时产品的价格和是否有货，下次检查与之比较
+	UpdateWishlistItemState(ctx context.Context, itemID int, price float64, inStock bool) error
+}
+
+// Wishlist 用户的一个心愿单（如“生日”“稍后购买”），ShareToken 不为空时可以通过分享链接查看
+type Wishlist struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"userId"`
+	Name       string         `json:"name"`
+	ShareToken string         `json:"shareToken,omitempty"`
+	Items      []WishlistItem `json:"items"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// WishlistItem 心愿单中的一个产品，VariantID 为空表示整个产品
+// LastPrice 和 LastInStock 是加入时或上次检查时的售价和库存状态，用于发现到货和降价
+type WishlistItem struct {
+	ID          int       `json:"id"`
+	WishlistID  int       `json:"wishlistId"`
+	ProductID   int       `json:"productId"`
+	VariantID   *int      `json:"variantId"`
+	LastPrice   float64   `json:"-"`
+	LastInStock bool      `json:"-"`
+	Product     *Product  `json:"product,omitempty"` // 由 service 在返回时填充
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type WishlistPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type WishlistItemPayload struct {
+	ProductID int `json:"productId" validate:"required_without=VariantID"`
+	VariantID int `json:"variantId"`
+}
+
+// MoveToCartPayload 从心愿单移到购物车的数量，为 0 时按 1 件计算
+type MoveToCartPayload struct {
+	Quantity int `json:"quantity" validate:"min=0"`
+}
+
+// Stores 汇总所有的 store，方便整体传递和替换实现（SQL、内存等）
+type Stores struct {
+	Users      UserStore
+	Products   ProductStore
+	Variants   VariantStore
+	Orders     OrderStore
+	Categories CategoryStore
+	Images     ImageStore
+	Reviews    ReviewStore
+	Wishlists  WishlistStore
+	Stock      StockStore
+	Search     ProductSearcher
+	Tx         TxManager
+}
+
+// TxManager 让跨多个 store 的操作在同一个事务中完成
+// fn 收到的 tx 中所有 store 都绑定在这个事务上；在 fn 中再调用 tx.Tx.WithinTx 会使用保存点，
+// 内层失败只回滚内层的修改。fn 返回错误时整个事务回滚，遇到死锁等冲突时 fn 可能被重新执行
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(tx Stores) error) error
+}
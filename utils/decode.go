@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/Albert-tru/ecom/config"
+)
+
+type decodeOptions struct {
+	maxBytes        int64
+	disallowUnknown bool
+}
+
+// DecodeOption 调整单次 ParseJson 的行为，默认值来自配置
+type DecodeOption func(*decodeOptions)
+
+// MaxBodyBytes 覆盖请求体大小上限
+func MaxBodyBytes(n int64) DecodeOption {
+	return func(o *decodeOptions) { o.maxBytes = n }
+}
+
+// DisallowUnknownFields 请求体中出现结构体没有的字段时报错
+func DisallowUnknownFields() DecodeOption {
+	return func(o *decodeOptions) { o.disallowUnknown = true }
+}
+
+// AllowUnknownFields 忽略请求体中结构体没有的字段
+func AllowUnknownFields() DecodeOption {
+	return func(o *decodeOptions) { o.disallowUnknown = false }
+}
+
+// 解析从前端发送过来的http请求的json数据
+// 要求 Content-Type 为 application/json（否则 415），请求体不超过上限（否则 413），
+// 并且只包含一个 JSON 值；返回的错误都是 *apperr.Error，可以直接交给 WriteError
+func ParseJson(w http.ResponseWriter, r *http.Request, payload any, opts ...DecodeOption) error {
+	o := decodeOptions{
+		maxBytes:        config.Envs.MaxBodyBytes,
+		disallowUnknown: config.Envs.DisallowUnknownJSON,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return apperr.UnsupportedMediaType("unsupported_media_type", "Content-Type must be application/json")
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return apperr.BadRequest("empty_body", "missing request body")
+	}
+
+	// 先把请求体完整读出（有上限），出错时才能换算出行列号
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, o.maxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return apperr.PayloadTooLarge("payload_too_large", fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+		}
+		return apperr.BadRequest("invalid_body", "failed to read request body")
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return apperr.BadRequest("empty_body", "missing request body")
+	}
+
+	//解码到go结构体
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if o.disallowUnknown {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(payload); err != nil {
+		return decodeError(body, dec, err)
+	}
+
+	// 请求体只能包含一个 JSON 值
+	if _, err := dec.Token(); err != io.EOF {
+		line, col := position(body, dec.InputOffset())
+		return apperr.BadRequest("multiple_json_values",
+			fmt.Sprintf("request body must contain a single JSON value (extra data at line %d, column %d)", line, col))
+	}
+
+	return nil
+}
+
+func isJSONContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// 把 encoding/json 的错误翻译成带位置的客户端错误
+func decodeError(body []byte, dec *json.Decoder, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset 指向出错字符之后，减一才是出错字符本身
+		line, col := position(body, max(syntaxErr.Offset-1, 0))
+		return apperr.BadRequest("invalid_json",
+			fmt.Sprintf("malformed JSON at line %d, column %d: %s", line, col, syntaxErr.Error()))
+
+	case errors.As(err, &typeErr):
+		line, col := position(body, typeErr.Offset)
+		return apperr.BadRequest("invalid_json_type",
+			fmt.Sprintf("field %q must be of type %s (line %d, column %d)", typeErr.Field, typeErr.Type, line, col))
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		line, col := position(body, int64(len(body)))
+		return apperr.BadRequest("invalid_json",
+			fmt.Sprintf("malformed JSON: unexpected end of input at line %d, column %d", line, col))
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json 没有为未知字段提供专门的错误类型
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		line, col := position(body, dec.InputOffset())
+		return apperr.BadRequest("unknown_field",
+			fmt.Sprintf("unknown field %s (line %d, column %d)", field, line, col))
+
+	default:
+		return apperr.BadRequest("invalid_json", err.Error())
+	}
+}
+
+// 把字节偏移量换算成从 1 开始的行号和列号
+func position(body []byte, offset int64) (line, col int) {
+	if offset > int64(len(body)) {
+		offset = int64(len(body))
+	}
+	line, col = 1, 1
+	for _, b := range body[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
@@ -2,29 +2,13 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/Albert-tru/ecom/apperr"
 )
 
-var Validate = validator.New()
-
-//validator.New() 创建的 *validator.Validate 会根据结构体字段的 validate:"..."
-//  标签去检查字段值是否满足规则；Validate.Struct(&payload) 调用触发这些检查。
-
-// 解析从前端发送过来的http请求的json数据
-func ParseJson(r *http.Request, payload any) error {
-	if r.Body == nil {
-		return fmt.Errorf("missing request body")
-	}
-
-	//解码到go结构体
-	return json.NewDecoder(r.Body).Decode(payload)
-
-}
-
 func WriteJson(w http.ResponseWriter, staus int, v any) error {
 	//设置响应头部
 	w.Header().Add("Content-Type", "application/json")
@@ -34,9 +18,19 @@ func WriteJson(w http.ResponseWriter, staus int, v any) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, staus int, message string) {
-	WriteJson(w, staus, map[string]string{"error": message})
+// WriteError 把错误渲染为 application/problem+json
+// 内部错误只记录日志（带请求ID），客户端只会看到通用提示
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := apperr.From(err)
+	requestID := GetRequestID(r.Context())
+
+	if e.Kind == apperr.KindInternal {
+		log.Printf("[%s] %s %s: internal error: %v", requestID, r.Method, r.URL.Path, e.Err)
+	}
 
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.Status())
+	json.NewEncoder(w).Encode(e.Problem(r.URL.Path, requestID))
 }
 
 // 从请求中提取 token
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Albert-tru/ecom/apperr"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Run("应用错误按类别映射状态码", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+		rr := httptest.NewRecorder()
+
+		WriteError(rr, req, apperr.NotFound("user_not_found", "user not found"))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusNotFound, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("期望 Content-Type 为 application/problem+json, 实际为 %s", ct)
+		}
+
+		var p apperr.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != "user_not_found" || p.Status != http.StatusNotFound || p.Instance != "/api/v1/users/1" {
+			t.Errorf("problem 内容不正确: %+v", p)
+		}
+	})
+
+	t.Run("内部错误不泄露细节", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/register", nil)
+		rr := httptest.NewRecorder()
+
+		handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, fmt.Errorf("Error 1062: Duplicate entry 'a@b.c' for key 'users.email'"))
+		}))
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("期望状态码 %d, 实际状态码 %d", http.StatusInternalServerError, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "Duplicate") {
+			t.Errorf("响应中泄露了内部错误: %s", rr.Body.String())
+		}
+
+		var p apperr.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != "internal" {
+			t.Errorf("期望错误码 internal, 实际为 %s", p.Code)
+		}
+		if p.RequestID == "" || p.RequestID != rr.Header().Get(RequestIDHeader) {
+			t.Errorf("problem 中的请求ID %q 与响应头 %q 不一致", p.RequestID, rr.Header().Get(RequestIDHeader))
+		}
+	})
+}
+
+func TestValidatePayload(t *testing.T) {
+	type item struct {
+		Quantity int `json:"quantity" validate:"required,min=1"`
+	}
+	type payload struct {
+		Password string `json:"password" validate:"required,min=6"`
+		Items    []item `json:"items" validate:"required,dive"`
+	}
+
+	cases := []struct {
+		name     string
+		language string
+		message  string
+	}{
+		{"英文", "en-US,en;q=0.9", "password must be at least 6 characters in length"},
+		{"中文", "zh-CN,zh;q=0.9,en;q=0.8", "password长度必须至少为6个字符"},
+		{"未支持的语言回退到英文", "fr-FR", "password must be at least 6 characters in length"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Accept-Language", c.language)
+
+			err := ValidatePayload(req, &payload{Password: "123", Items: []item{{Quantity: 0}}})
+			e := apperr.From(err)
+			if e.Kind != apperr.KindValidation {
+				t.Fatalf("期望验证错误, 实际为 %v", err)
+			}
+			if len(e.Fields) != 2 {
+				t.Fatalf("期望 2 个字段错误, 实际为 %+v", e.Fields)
+			}
+
+			pw := e.Fields[0]
+			if pw.Field != "password" || pw.Rule != "min" || pw.Param != "6" || pw.Message != c.message {
+				t.Errorf("password 字段错误不正确: %+v", pw)
+			}
+			if e.Fields[1].Field != "items[0].quantity" || e.Fields[1].Rule != "required" {
+				t.Errorf("嵌套字段错误不正确: %+v", e.Fields[1])
+			}
+		})
+	}
+}
+
+func TestParseJson(t *testing.T) {
+	type payload struct {
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []DecodeOption
+		status      int
+		code        string
+		contains    string
+	}{
+		{"合法请求", "application/json; charset=utf-8", `{"email":"a@b.c","age":3}`, nil, 0, "", ""},
+		{"缺少 Content-Type", "", `{"email":"a@b.c"}`, nil, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"空请求体", "application/json", ``, nil, http.StatusBadRequest, "empty_body", ""},
+		{"超出大小上限", "application/json", `{"email":"` + strings.Repeat("a", 64) + `"}`, []DecodeOption{MaxBodyBytes(16)}, http.StatusRequestEntityTooLarge, "payload_too_large", ""},
+		{"语法错误带行列号", "application/json", "{\n  \"email\": \"a@b.c\"\n  \"age\": 3\n}", nil, http.StatusBadRequest, "invalid_json", "line 3, column 3"},
+		{"类型错误", "application/json", `{"age":"three"}`, nil, http.StatusBadRequest, "invalid_json_type", `field "age"`},
+		{"未知字段", "application/json", `{"emial":"a@b.c"}`, []DecodeOption{DisallowUnknownFields()}, http.StatusBadRequest, "unknown_field", `"emial"`},
+		{"允许未知字段", "application/json", `{"emial":"a@b.c"}`, []DecodeOption{AllowUnknownFields()}, 0, "", ""},
+		{"多个 JSON 值", "application/json", `{"email":"a@b.c"} {"age":1}`, nil, http.StatusBadRequest, "multiple_json_values", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+			if c.contentType != "" {
+				req.Header.Set("Content-Type", c.contentType)
+			}
+
+			var p payload
+			err := ParseJson(httptest.NewRecorder(), req, &p, c.opts...)
+			if c.status == 0 {
+				if err != nil {
+					t.Fatalf("期望解析成功, 实际错误: %v", err)
+				}
+				return
+			}
+
+			e := apperr.From(err)
+			if e.Status() != c.status || e.Code != c.code {
+				t.Fatalf("期望 %d/%s, 实际 %d/%s (%v)", c.status, c.code, e.Status(), e.Code, err)
+			}
+			if !strings.Contains(e.Message, c.contains) {
+				t.Errorf("错误信息 %q 中应包含 %q", e.Message, c.contains)
+			}
+		})
+	}
+}
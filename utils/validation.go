@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Albert-tru/ecom/apperr"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entrans "github.com/go-playground/validator/v10/translations/en"
+	zhtrans "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+// validator.New() 创建的 *validator.Validate 会根据结构体字段的 validate:"..."
+//
+//	标签去检查字段值是否满足规则；Validate.Struct(&payload) 调用触发这些检查。
+var Validate = newValidator()
+
+// 每种语言对应的翻译器，第一个是默认语言
+var (
+	supportedLanguages = []language.Tag{language.English, language.Chinese}
+	languageMatcher    = language.NewMatcher(supportedLanguages)
+	translators        = map[string]ut.Translator{}
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// 错误中的字段名使用 json 标签，和客户端提交的字段保持一致
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	enLocale, zhLocale := en.New(), zh.New()
+	uni := ut.New(enLocale, enLocale, zhLocale)
+
+	enTrans, _ := uni.GetTranslator("en")
+	if err := entrans.RegisterDefaultTranslations(v, enTrans); err != nil {
+		panic(err)
+	}
+	zhTrans, _ := uni.GetTranslator("zh")
+	if err := zhtrans.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		panic(err)
+	}
+	translators["en"] = enTrans
+	translators["zh"] = zhTrans
+
+	return v
+}
+
+// ValidatePayload 校验请求体结构，失败时返回带字段明细的 apperr 验证错误
+// 错误信息的语言根据请求的 Accept-Language 选择
+func ValidatePayload(r *http.Request, payload any) error {
+	return validate(payload, translatorFor(r))
+}
+
+// ValidateStruct 与 ValidatePayload 相同，错误信息使用默认语言，用于命令行等没有请求的场景
+func ValidateStruct(payload any) error {
+	return validate(payload, translators["en"])
+}
+
+func validate(payload any, trans ut.Translator) error {
+	err := Validate.Struct(payload)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return apperr.Internal(err)
+	}
+
+	return apperr.Validation("validation_failed", "request validation failed").
+		WithFields(TranslateValidationErrors(verrs, trans))
+}
+
+// TranslateValidationErrors 把 validator 的错误转换为字段级的错误列表
+func TranslateValidationErrors(verrs validator.ValidationErrors, trans ut.Translator) []apperr.FieldError {
+	fields := make([]apperr.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, apperr.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		})
+	}
+	return fields
+}
+
+// 去掉命名空间中最外层的结构体名，如 "CartCheckoutPayload.items[0].quantity" -> "items[0].quantity"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return fe.Field()
+}
+
+func translatorFor(r *http.Request) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	tag, _, _ := languageMatcher.Match(tags...)
+	base, _ := tag.Base()
+	if trans, ok := translators[base.String()]; ok {
+		return trans
+	}
+	return translators["en"]
+}